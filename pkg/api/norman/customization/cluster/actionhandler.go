@@ -172,6 +172,8 @@ func (a ActionHandler) createTokenInput(apiContext *types.APIContext) (user.Toke
 		TTL:           defaultTokenTTL,
 		Randomize:     true,
 		UserPrincipal: authToken.UserPrincipal,
+		// a kubeconfig token created with a scoped token can't be given more access than it
+		Scope: authToken.Scope,
 	}, nil
 }
 
@@ -3,12 +3,15 @@
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/rbac"
 	"github.com/rancher/rancher/pkg/types/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func NewPRTBValidator(management *config.ScaledContext) types.Validator {
@@ -36,6 +39,10 @@ type validator struct {
 }
 
 func (v *validator) validator(request *types.APIContext, schema *types.Schema, data map[string]interface{}) error {
+	if err := validateBindingWindow(data); err != nil {
+		return err
+	}
+
 	roleTemplateName := data[v.field]
 	if roleTemplateName == nil && request.Method == http.MethodPut {
 		return nil
@@ -88,3 +95,23 @@ func (v *validator) validateRoleTemplateBinding(obj interface{}) (*v3.RoleTempla
 
 	return roleTemplate, nil
 }
+
+// validateBindingWindow rejects bindings which would never grant access because they expire before they start.
+func validateBindingWindow(data map[string]interface{}) error {
+	var window [2]*metav1.Time
+	for i, field := range []string{client.ClusterRoleTemplateBindingFieldNotBefore, client.ClusterRoleTemplateBindingFieldExpiresAt} {
+		value, _ := data[field].(string)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return httperror.NewAPIError(httperror.InvalidFormat, fmt.Sprintf("invalid %s [%s]: %v", field, value, err))
+		}
+		window[i] = &metav1.Time{Time: t}
+	}
+	if err := rbac.ValidateBindingWindow(window[0], window[1]); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	}
+	return nil
+}
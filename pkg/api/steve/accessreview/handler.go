@@ -0,0 +1,231 @@
+// Package accessreview provides a HTTPHandler reporting the effective permissions of a user or group across all
+// clusters and projects. This handler should be registered at Endpoint
+package accessreview
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/pkg/auth/util"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	authzv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	authv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+const (
+	// Endpoint The endpoint that this URL is accessible at - used for routing
+	Endpoint       = "/v1/accessreview"
+	csvContentType = "text/csv"
+	logPrefix      = "access-review"
+)
+
+// reviewedResources are the bindings which must be listable by the requester to review someone's access.
+var reviewedResources = []string{"globalrolebindings", "clusterroletemplatebindings", "projectroletemplatebindings"}
+
+// Handler implements http.Handler - and serves the effective permissions of the user or group given by the user or
+// group query parameter, as JSON or as CSV if format=csv
+type Handler struct {
+	SubjectAccessReviews authv1.SubjectAccessReviewInterface
+	reviewer             *reviewer
+}
+
+// UserContextGetter returns the context of a downstream cluster.
+type UserContextGetter interface {
+	UserContext(clusterName string) (*config.UserContext, error)
+}
+
+// NewHandler creates a handler using the caches defined in scaledContext, the cluster roles of external role templates
+// are read from each downstream cluster through clusters
+func NewHandler(scaledContext *config.ScaledContext, clusters UserContextGetter) Handler {
+	mgmt := scaledContext.Wrangler.Mgmt
+	return Handler{
+		SubjectAccessReviews: scaledContext.K8sClient.AuthorizationV1().SubjectAccessReviews(),
+		reviewer: &reviewer{
+			grbs:           mgmt.GlobalRoleBinding().Cache(),
+			globalRoles:    mgmt.GlobalRole().Cache(),
+			crtbs:          mgmt.ClusterRoleTemplateBinding().Cache(),
+			prtbs:          mgmt.ProjectRoleTemplateBinding().Cache(),
+			roleTemplates:  mgmt.RoleTemplate().Cache(),
+			users:          mgmt.User().Cache(),
+			userAttributes: mgmt.UserAttribute().Cache(),
+			clusters:       mgmt.Cluster().Cache(),
+			clusterRoles: func(clusterName, name string) (*rbacv1.ClusterRole, error) {
+				userContext, err := clusters.UserContext(clusterName)
+				if err != nil {
+					return nil, err
+				}
+				return userContext.RBAC.ClusterRoles("").Get(name, metav1.GetOptions{})
+			},
+		},
+	}
+}
+
+// ServeHTTP implements http.Handler - attempts to authorize the user and returns the access report of the requested
+// user or group if the user can list all global role, cluster role template and project role template bindings
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	authorized, err := h.authorize(req)
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		logrus.Errorf("[%s] Failed to authorize user with error: %s", logPrefix, err.Error())
+		return
+	}
+	if !authorized {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return
+	}
+
+	query := req.URL.Query()
+	userName, group := query.Get("user"), query.Get("group")
+	var sub *subject
+	switch {
+	case userName != "" && group != "":
+		util.ReturnHTTPError(writer, req, http.StatusBadRequest, "only one of user and group can be specified")
+		return
+	case userName != "":
+		sub, err = h.reviewer.userSubject(userName)
+		if apierrors.IsNotFound(err) {
+			util.ReturnHTTPError(writer, req, http.StatusNotFound, fmt.Sprintf("user %s not found", userName))
+			return
+		} else if err != nil {
+			logrus.Errorf("[%s] Error getting user %s: %v", logPrefix, userName, err)
+			util.ReturnHTTPError(writer, req, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+	case group != "":
+		sub = groupSubject(group)
+	default:
+		util.ReturnHTTPError(writer, req, http.StatusBadRequest, "user or group must be specified")
+		return
+	}
+
+	report, err := h.reviewer.review(sub, time.Now())
+	if err != nil {
+		logrus.Errorf("[%s] Error reviewing access: %v", logPrefix, err)
+		util.ReturnHTTPError(writer, req, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	switch format := query.Get("format"); format {
+	case "csv":
+		writer.Header().Set("Content-Type", csvContentType)
+		writer.Header().Set("Content-Disposition", "attachment; filename=\"accessreview.csv\"")
+		err = writeCSV(writer, report)
+	case "", "json":
+		writer.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(writer).Encode(report)
+	default:
+		util.ReturnHTTPError(writer, req, http.StatusBadRequest, fmt.Sprintf("unsupported format %s", format))
+		return
+	}
+	if err != nil {
+		logrus.Warnf("[%s] Failed to write access review: %v", logPrefix, err)
+	}
+}
+
+// authorize checks to see if the user can list all the bindings which are reviewed. Returns a bool (if the user is
+// authorized) and optionally an error
+func (h *Handler) authorize(r *http.Request) (bool, error) {
+	userInfo, ok := request.UserFrom(r.Context())
+	if !ok {
+		return false, fmt.Errorf("unable to extract user info from context")
+	}
+	for _, resource := range reviewedResources {
+		response, err := h.SubjectAccessReviews.Create(r.Context(), &authzv1.SubjectAccessReview{
+			Spec: authzv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &authzv1.ResourceAttributes{
+					Group:    "management.cattle.io",
+					Resource: resource,
+					Verb:     "list",
+				},
+				User:   userInfo.GetName(),
+				Groups: userInfo.GetGroups(),
+				Extra:  convertExtra(userInfo.GetExtra()),
+				UID:    userInfo.GetUID(),
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to create a SubjectAccessReview: %w", err)
+		}
+		if !response.Status.Allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// writeCSV writes one row per rule of each grant in the report.
+func writeCSV(writer io.Writer, report *Report) error {
+	w := csv.NewWriter(writer)
+	if err := w.Write([]string{"scope", "scopeId", "binding", "role", "via", "external", "expiresAt",
+		"apiGroups", "resources", "resourceNames", "nonResourceURLs", "verbs"}); err != nil {
+		return err
+	}
+	writeScope := func(scope, id string, s *Scope) error {
+		for _, grant := range s.Grants {
+			expiresAt := ""
+			if grant.ExpiresAt != nil {
+				expiresAt = grant.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+			rules := grant.Rules
+			if len(rules) == 0 {
+				// external roles and roles without rules still get a row so they show up in the report
+				rules = []rbacv1.PolicyRule{{}}
+			}
+			for _, rule := range rules {
+				if err := w.Write([]string{scope, id, grant.Binding, grant.Role, grant.Via,
+					strconv.FormatBool(grant.External), expiresAt,
+					strings.Join(rule.APIGroups, " "), strings.Join(rule.Resources, " "),
+					strings.Join(rule.ResourceNames, " "), strings.Join(rule.NonResourceURLs, " "),
+					strings.Join(rule.Verbs, " ")}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := writeScope("global", "", &report.Global); err != nil {
+		return err
+	}
+	for _, id := range sortedKeys(report.Clusters) {
+		if err := writeScope("cluster", id, report.Clusters[id]); err != nil {
+			return err
+		}
+	}
+	for _, id := range sortedKeys(report.Projects) {
+		if err := writeScope("project", id, report.Projects[id]); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func sortedKeys(scopes map[string]*Scope) []string {
+	keys := make([]string, 0, len(scopes))
+	for key := range scopes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func convertExtra(extra map[string][]string) map[string]authzv1.ExtraValue {
+	result := map[string]authzv1.ExtraValue{}
+	for k, v := range extra {
+		result[k] = authzv1.ExtraValue(v)
+	}
+	return result
+}
@@ -0,0 +1,349 @@
+package accessreview
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
+	"github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// maxRoleTemplateDepth matches the limit used when role templates are expanded for reconciliation.
+	maxRoleTemplateDepth = 50
+	localCluster         = "local"
+)
+
+// Report is the effective access of a user or group across Rancher.
+type Report struct {
+	User   string   `json:"user,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Global is granted by global role bindings. Its rules apply to the local cluster.
+	Global Scope `json:"global"`
+	// Clusters is keyed by cluster ID, it includes the role templates inherited from global roles.
+	Clusters map[string]*Scope `json:"clusters"`
+	// Projects is keyed by <cluster ID>:<project ID>.
+	Projects map[string]*Scope `json:"projects"`
+}
+
+// Scope is the access granted in the global scope, a cluster or a project.
+type Scope struct {
+	Grants []Grant `json:"grants"`
+	// Rules is the union of the rules of all grants.
+	Rules []rbacv1.PolicyRule `json:"rules"`
+	// ExternalRoles are the external role templates whose cluster role couldn't be resolved in the cluster, so that
+	// their rules are missing from the report.
+	ExternalRoles []string `json:"externalRoles,omitempty"`
+}
+
+// Grant is a role granted by a binding, either directly or through the role templates it inherits from.
+type Grant struct {
+	// Binding is the name of the global role binding, or the <namespace>/<name> of the role template binding.
+	Binding string `json:"binding"`
+	// Role is the global role or role template which grants the rules.
+	Role string `json:"role"`
+	// Via is the role bound by the binding when Role is inherited from it.
+	Via string `json:"via,omitempty"`
+	// External is true for role templates whose rules are gathered from the cluster role with the same name.
+	External bool `json:"external,omitempty"`
+	// Unresolved is true for external role templates whose cluster role couldn't be found or read in the cluster, so
+	// that Rules only has the rules of the role template.
+	Unresolved bool                `json:"unresolved,omitempty"`
+	ExpiresAt  *metav1.Time        `json:"expiresAt,omitempty"`
+	Rules      []rbacv1.PolicyRule `json:"rules,omitempty"`
+}
+
+// reviewer resolves the bindings of a subject into a Report.
+type reviewer struct {
+	grbs           mgmtcontrollers.GlobalRoleBindingCache
+	globalRoles    mgmtcontrollers.GlobalRoleCache
+	crtbs          mgmtcontrollers.ClusterRoleTemplateBindingCache
+	prtbs          mgmtcontrollers.ProjectRoleTemplateBindingCache
+	roleTemplates  mgmtcontrollers.RoleTemplateCache
+	users          mgmtcontrollers.UserCache
+	userAttributes mgmtcontrollers.UserAttributeCache
+	clusters       mgmtcontrollers.ClusterCache
+	clusterRoles   clusterRoleGetter
+}
+
+// clusterRoleGetter gets the cluster role with the given name from the cluster with the given ID.
+type clusterRoleGetter func(clusterName, name string) (*rbacv1.ClusterRole, error)
+
+// inheritedRole is a role template inherited from a global role, which is expanded in every cluster.
+type inheritedRole struct {
+	binding string
+	rtName  string
+}
+
+// subject is who the bindings are resolved for: a user with its principals and groups, or a single group.
+type subject struct {
+	user       string
+	principals sets.String
+	groups     sets.String
+}
+
+func (s *subject) matches(userName, userPrincipalName, groupName, groupPrincipalName string) bool {
+	return (s.user != "" && userName == s.user) ||
+		(userPrincipalName != "" && s.principals.Has(userPrincipalName)) ||
+		(groupName != "" && s.groups.Has(groupName)) ||
+		(groupPrincipalName != "" && s.groups.Has(groupPrincipalName))
+}
+
+// userSubject returns the subject for a user, including the groups it was a member of when it last logged in or had
+// its groups refreshed.
+func (r *reviewer) userSubject(userName string) (*subject, error) {
+	user, err := r.users.Get(userName)
+	if err != nil {
+		return nil, err
+	}
+	sub := &subject{
+		user:       user.Name,
+		principals: sets.NewString(user.PrincipalIDs...),
+		groups:     sets.NewString(),
+	}
+	attribs, err := r.userAttributes.Get(userName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if attribs != nil {
+		for _, principals := range attribs.GroupPrincipals {
+			for _, principal := range principals.Items {
+				sub.groups.Insert(principal.Name)
+			}
+		}
+	}
+	return sub, nil
+}
+
+func groupSubject(group string) *subject {
+	return &subject{
+		principals: sets.NewString(),
+		groups:     sets.NewString(group),
+	}
+}
+
+func (r *reviewer) review(sub *subject, now time.Time) (*Report, error) {
+	report := &Report{
+		User:     sub.user,
+		Groups:   sub.groups.List(),
+		Clusters: map[string]*Scope{},
+		Projects: map[string]*Scope{},
+	}
+
+	var inherited []inheritedRole
+	grbs, err := r.grbs.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sortByName(grbs)
+	for _, grb := range grbs {
+		if !sub.matches(grb.UserName, "", "", grb.GroupPrincipalName) {
+			continue
+		}
+		globalRole, err := r.globalRoles.Get(grb.GlobalRoleName)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		binding := grb.Name
+		var rules []rbacv1.PolicyRule
+		rules = append(rules, globalRole.Rules...)
+		for namespace, namespacedRules := range globalRole.NamespacedRules {
+			for _, rule := range namespacedRules {
+				// namespaced rules are reported as resource names of the namespace they apply to
+				rule = *rule.DeepCopy()
+				rule.ResourceNames = append(rule.ResourceNames, "namespace="+namespace)
+				rules = append(rules, rule)
+			}
+		}
+		report.Global.add(Grant{Binding: binding, Role: globalRole.Name, Rules: rules})
+		for _, rtName := range globalRole.InheritedClusterRoles {
+			inherited = append(inherited, inheritedRole{binding: binding, rtName: rtName})
+		}
+	}
+
+	if len(inherited) > 0 {
+		clusters, err := r.clusters.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters {
+			if cluster.Name == localCluster {
+				continue
+			}
+			scope := report.cluster(cluster.Name)
+			for _, role := range inherited {
+				grants, err := r.expandRoleTemplate(cluster.Name, role.binding, role.rtName)
+				if err != nil {
+					return nil, err
+				}
+				for _, grant := range grants {
+					scope.add(grant)
+				}
+			}
+		}
+	}
+
+	crtbs, err := r.crtbs.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sortByName(crtbs)
+	for _, crtb := range crtbs {
+		if !sub.matches(crtb.UserName, crtb.UserPrincipalName, crtb.GroupName, crtb.GroupPrincipalName) {
+			continue
+		}
+		if state, _ := pkgrbac.BindingWindow(crtb.NotBefore, crtb.ExpiresAt, now); state != pkgrbac.BindingActive {
+			continue
+		}
+		grants, err := r.expandRoleTemplate(crtb.ClusterName, crtb.Namespace+"/"+crtb.Name, crtb.RoleTemplateName)
+		if err != nil {
+			return nil, err
+		}
+		scope := report.cluster(crtb.ClusterName)
+		for _, grant := range grants {
+			grant.ExpiresAt = crtb.ExpiresAt
+			scope.add(grant)
+		}
+	}
+
+	prtbs, err := r.prtbs.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sortByName(prtbs)
+	for _, prtb := range prtbs {
+		if !sub.matches(prtb.UserName, prtb.UserPrincipalName, prtb.GroupName, prtb.GroupPrincipalName) {
+			continue
+		}
+		if state, _ := pkgrbac.BindingWindow(prtb.NotBefore, prtb.ExpiresAt, now); state != pkgrbac.BindingActive {
+			continue
+		}
+		clusterName, _, _ := strings.Cut(prtb.ProjectName, ":")
+		grants, err := r.expandRoleTemplate(clusterName, prtb.Namespace+"/"+prtb.Name, prtb.RoleTemplateName)
+		if err != nil {
+			return nil, err
+		}
+		scope := report.Projects[prtb.ProjectName]
+		if scope == nil {
+			scope = &Scope{}
+			report.Projects[prtb.ProjectName] = scope
+		}
+		for _, grant := range grants {
+			grant.ExpiresAt = prtb.ExpiresAt
+			scope.add(grant)
+		}
+	}
+
+	return report, nil
+}
+
+func (r *Report) cluster(name string) *Scope {
+	scope := r.Clusters[name]
+	if scope == nil {
+		scope = &Scope{}
+		r.Clusters[name] = scope
+	}
+	return scope
+}
+
+// add adds the grant to the scope and its rules to the union of the scope's rules.
+func (s *Scope) add(grant Grant) {
+	s.Grants = append(s.Grants, grant)
+	if grant.Unresolved {
+		s.ExternalRoles = append(s.ExternalRoles, grant.Role)
+	}
+	for _, rule := range grant.Rules {
+		if !containsRule(s.Rules, rule) {
+			s.Rules = append(s.Rules, rule)
+		}
+	}
+}
+
+// expandRoleTemplate returns a grant in the cluster for the role template and one for each role template it inherits
+// from.
+func (r *reviewer) expandRoleTemplate(clusterName, binding, rtName string) ([]Grant, error) {
+	var grants []Grant
+	seen := sets.NewString()
+	var expand func(name string, depth int) error
+	expand = func(name string, depth int) error {
+		if depth > maxRoleTemplateDepth {
+			return fmt.Errorf("role template %s exceeds the max depth of %d", rtName, maxRoleTemplateDepth)
+		}
+		if seen.Has(name) {
+			return nil
+		}
+		seen.Insert(name)
+		rt, err := r.roleTemplates.Get(name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		rules, resolved := r.roleTemplateRules(clusterName, rt)
+		grant := Grant{
+			Binding:    binding,
+			Role:       rt.Name,
+			External:   rt.External,
+			Unresolved: !resolved,
+			Rules:      rules,
+		}
+		if name != rtName {
+			grant.Via = rtName
+		}
+		grants = append(grants, grant)
+		for _, inherited := range rt.RoleTemplateNames {
+			if err := expand(inherited, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return grants, expand(rtName, 0)
+}
+
+// roleTemplateRules returns the rules of the role template in the cluster, which for external cluster role templates
+// include the rules of the cluster role with the same name in that cluster, as they are gathered when the role template
+// is bound. It returns false if that cluster role couldn't be found or read in the cluster.
+func (r *reviewer) roleTemplateRules(clusterName string, rt *v3.RoleTemplate) ([]rbacv1.PolicyRule, bool) {
+	if !rt.External || rt.Context != "cluster" {
+		return rt.Rules, true
+	}
+	cr, err := r.clusterRoles(clusterName, rt.Name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Debugf("[%s] Failed to get cluster role %s in cluster %s: %v", logPrefix, rt.Name, clusterName, err)
+		}
+		return rt.Rules, false
+	}
+	return append(append([]rbacv1.PolicyRule{}, cr.Rules...), rt.Rules...), true
+}
+
+func containsRule(rules []rbacv1.PolicyRule, rule rbacv1.PolicyRule) bool {
+	for i := range rules {
+		if reflect.DeepEqual(rules[i], rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortByName[T metav1.Object](objs []T) {
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].GetNamespace() != objs[j].GetNamespace() {
+			return objs[i].GetNamespace() < objs[j].GetNamespace()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+}
@@ -0,0 +1,228 @@
+package accessreview
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	readPods   = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}
+	writePods  = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"create"}}
+	readNodes  = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}}
+	readGlobal = rbacv1.PolicyRule{APIGroups: []string{"management.cattle.io"}, Resources: []string{"settings"}, Verbs: []string{"get"}}
+)
+
+func newNonNamespacedCache[T runtime.Object](ctrl *gomock.Controller, objs map[string]T) *fake.MockNonNamespacedCacheInterface[T] {
+	cache := fake.NewMockNonNamespacedCacheInterface[T](ctrl)
+	cache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (T, error) {
+		obj, ok := objs[name]
+		if !ok {
+			return obj, apierrors.NewNotFound(schema.GroupResource{}, name)
+		}
+		return obj, nil
+	}).AnyTimes()
+	cache.EXPECT().List(gomock.Any()).DoAndReturn(func(_ labels.Selector) ([]T, error) {
+		var list []T
+		for _, obj := range objs {
+			list = append(list, obj)
+		}
+		return list, nil
+	}).AnyTimes()
+	return cache
+}
+
+func newCache[T runtime.Object](ctrl *gomock.Controller, objs []T) *fake.MockCacheInterface[T] {
+	cache := fake.NewMockCacheInterface[T](ctrl)
+	cache.EXPECT().List(gomock.Any(), gomock.Any()).Return(objs, nil).AnyTimes()
+	return cache
+}
+
+func newReviewer(t *testing.T, now time.Time) *reviewer {
+	ctrl := gomock.NewController(t)
+	return &reviewer{
+		users: newNonNamespacedCache(ctrl, map[string]*v3.User{
+			"u-alice": {ObjectMeta: metav1.ObjectMeta{Name: "u-alice"}, PrincipalIDs: []string{"local://u-alice", "github_user://1"}},
+		}),
+		userAttributes: newNonNamespacedCache(ctrl, map[string]*v3.UserAttribute{
+			"u-alice": {
+				ObjectMeta: metav1.ObjectMeta{Name: "u-alice"},
+				GroupPrincipals: map[string]v3.Principals{
+					"github": {Items: []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "github_team://dev"}}}},
+				},
+			},
+		}),
+		clusters: newNonNamespacedCache(ctrl, map[string]*v3.Cluster{
+			"local":   {ObjectMeta: metav1.ObjectMeta{Name: "local"}},
+			"c-abcde": {ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"}},
+		}),
+		globalRoles: newNonNamespacedCache(ctrl, map[string]*v3.GlobalRole{
+			"user": {ObjectMeta: metav1.ObjectMeta{Name: "user"}, Rules: []rbacv1.PolicyRule{readGlobal}},
+			"cluster-viewer": {
+				ObjectMeta:            metav1.ObjectMeta{Name: "cluster-viewer"},
+				InheritedClusterRoles: []string{"nodes-view"},
+			},
+		}),
+		roleTemplates: newNonNamespacedCache(ctrl, map[string]*v3.RoleTemplate{
+			"nodes-view":       {ObjectMeta: metav1.ObjectMeta{Name: "nodes-view"}, Rules: []rbacv1.PolicyRule{readNodes}},
+			"pods-view":        {ObjectMeta: metav1.ObjectMeta{Name: "pods-view"}, Rules: []rbacv1.PolicyRule{readPods}},
+			"pods-edit":        {ObjectMeta: metav1.ObjectMeta{Name: "pods-edit"}, Rules: []rbacv1.PolicyRule{writePods}, RoleTemplateNames: []string{"pods-view", "pods-edit"}},
+			"external-admin":   {ObjectMeta: metav1.ObjectMeta{Name: "external-admin"}, Context: "cluster", External: true},
+			"external-missing": {ObjectMeta: metav1.ObjectMeta{Name: "external-missing"}, Context: "cluster", External: true},
+		}),
+		clusterRoles: func(clusterName, name string) (*rbacv1.ClusterRole, error) {
+			clusterRoles := map[string]map[string]*rbacv1.ClusterRole{
+				"local": {
+					"external-admin": {ObjectMeta: metav1.ObjectMeta{Name: "external-admin"}, Rules: []rbacv1.PolicyRule{readGlobal}},
+				},
+				"c-abcde": {
+					"external-admin": {ObjectMeta: metav1.ObjectMeta{Name: "external-admin"}, Rules: []rbacv1.PolicyRule{writePods}},
+				},
+			}
+			if cr, ok := clusterRoles[clusterName][name]; ok {
+				return cr, nil
+			}
+			if clusterName == "c-unavailable" {
+				return nil, errors.New("cluster context c-unavailable is unavailable")
+			}
+			return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+		},
+		grbs: newNonNamespacedCache(ctrl, map[string]*v3.GlobalRoleBinding{
+			"grb-alice": {ObjectMeta: metav1.ObjectMeta{Name: "grb-alice"}, UserName: "u-alice", GlobalRoleName: "user"},
+			"grb-dev":   {ObjectMeta: metav1.ObjectMeta{Name: "grb-dev"}, GroupPrincipalName: "github_team://dev", GlobalRoleName: "cluster-viewer"},
+			"grb-bob":   {ObjectMeta: metav1.ObjectMeta{Name: "grb-bob"}, UserName: "u-bob", GlobalRoleName: "user"},
+		}),
+		crtbs: newCache(ctrl, []*v3.ClusterRoleTemplateBinding{
+			{
+				ObjectMeta:        metav1.ObjectMeta{Namespace: "c-abcde", Name: "crtb-principal"},
+				ClusterName:       "c-abcde",
+				UserPrincipalName: "github_user://1",
+				RoleTemplateName:  "external-admin",
+			},
+			{
+				ObjectMeta:        metav1.ObjectMeta{Namespace: "c-fghij", Name: "crtb-other"},
+				ClusterName:       "c-fghij",
+				UserPrincipalName: "github_user://1",
+				RoleTemplateName:  "external-admin",
+			},
+			{
+				ObjectMeta:        metav1.ObjectMeta{Namespace: "c-unavailable", Name: "crtb-unavailable"},
+				ClusterName:       "c-unavailable",
+				UserPrincipalName: "github_user://1",
+				RoleTemplateName:  "external-admin",
+			},
+			{
+				ObjectMeta:       metav1.ObjectMeta{Namespace: "c-abcde", Name: "crtb-missing"},
+				ClusterName:      "c-abcde",
+				UserName:         "u-alice",
+				RoleTemplateName: "external-missing",
+			},
+			{
+				ObjectMeta:       metav1.ObjectMeta{Namespace: "c-abcde", Name: "crtb-expired"},
+				ClusterName:      "c-abcde",
+				UserName:         "u-alice",
+				RoleTemplateName: "pods-edit",
+				ExpiresAt:        &metav1.Time{Time: now.Add(-time.Hour)},
+			},
+		}),
+		prtbs: newCache(ctrl, []*v3.ProjectRoleTemplateBinding{
+			{
+				ObjectMeta:       metav1.ObjectMeta{Namespace: "p-xyz", Name: "prtb-alice"},
+				ProjectName:      "c-abcde:p-xyz",
+				UserName:         "u-alice",
+				RoleTemplateName: "pods-edit",
+			},
+			{
+				ObjectMeta:       metav1.ObjectMeta{Namespace: "p-xyz", Name: "prtb-pending"},
+				ProjectName:      "c-abcde:p-xyz",
+				GroupName:        "github_team://dev",
+				RoleTemplateName: "pods-view",
+				NotBefore:        &metav1.Time{Time: now.Add(time.Hour)},
+			},
+		}),
+	}
+}
+
+func TestReviewUser(t *testing.T) {
+	now := time.Now()
+	r := newReviewer(t, now)
+	sub, err := r.userSubject("u-alice")
+	require.NoError(t, err)
+	report, err := r.review(sub, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "u-alice", report.User)
+	assert.Equal(t, []string{"github_team://dev"}, report.Groups)
+	assert.Equal(t, []rbacv1.PolicyRule{readGlobal}, report.Global.Rules)
+
+	require.Len(t, report.Clusters, 3)
+	cluster := report.Clusters["c-abcde"]
+	require.NotNil(t, cluster)
+	assert.Equal(t, []rbacv1.PolicyRule{readNodes, writePods}, cluster.Rules, "external roles have the rules of their cluster role")
+	assert.Equal(t, []string{"external-missing"}, cluster.ExternalRoles, "external roles without a cluster role are reported")
+
+	// the cluster role of the local cluster isn't used for downstream clusters which don't have it
+	other := report.Clusters["c-fghij"]
+	require.NotNil(t, other)
+	assert.Empty(t, other.Rules)
+	assert.Equal(t, []Grant{{Binding: "c-fghij/crtb-other", Role: "external-admin", External: true, Unresolved: true}}, other.Grants)
+	assert.Equal(t, []string{"external-admin"}, other.ExternalRoles)
+	unavailable := report.Clusters["c-unavailable"]
+	require.NotNil(t, unavailable)
+	assert.Empty(t, unavailable.Rules)
+	assert.Equal(t, []string{"external-admin"}, unavailable.ExternalRoles, "external roles of unavailable clusters are reported")
+
+	project := report.Projects["c-abcde:p-xyz"]
+	require.NotNil(t, project)
+	assert.Equal(t, []Grant{
+		{Binding: "p-xyz/prtb-alice", Role: "pods-edit", Rules: []rbacv1.PolicyRule{writePods}},
+		{Binding: "p-xyz/prtb-alice", Role: "pods-view", Via: "pods-edit", Rules: []rbacv1.PolicyRule{readPods}},
+	}, project.Grants)
+	assert.Equal(t, []rbacv1.PolicyRule{writePods, readPods}, project.Rules)
+}
+
+func TestReviewGroup(t *testing.T) {
+	now := time.Now()
+	r := newReviewer(t, now)
+	report, err := r.review(groupSubject("github_team://dev"), now)
+	require.NoError(t, err)
+
+	assert.Empty(t, report.Global.Rules)
+	assert.Equal(t, []Grant{{Binding: "grb-dev", Role: "cluster-viewer"}}, report.Global.Grants)
+	assert.Equal(t, []rbacv1.PolicyRule{readNodes}, report.Clusters["c-abcde"].Rules)
+	assert.Empty(t, report.Projects)
+}
+
+func TestWriteCSV(t *testing.T) {
+	expiresAt := &metav1.Time{Time: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)}
+	report := &Report{
+		Global: Scope{Grants: []Grant{{Binding: "grb-alice", Role: "user", Rules: []rbacv1.PolicyRule{readGlobal}}}},
+		Clusters: map[string]*Scope{
+			"c-abcde": {Grants: []Grant{{Binding: "c-abcde/crtb", Role: "external-admin", External: true, ExpiresAt: expiresAt}}},
+		},
+		Projects: map[string]*Scope{},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCSV(&buf, report))
+	assert.Equal(t, strings.Join([]string{
+		"scope,scopeId,binding,role,via,external,expiresAt,apiGroups,resources,resourceNames,nonResourceURLs,verbs",
+		"global,,grb-alice,user,,false,,management.cattle.io,settings,,,get",
+		"cluster,c-abcde,c-abcde/crtb,external-admin,,true,2030-01-02T03:04:05Z,,,,,",
+		"",
+	}, "\n"), buf.String())
+}
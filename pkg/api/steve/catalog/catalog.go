@@ -47,7 +47,8 @@ func Register(ctx context.Context, server *steve.Server,
 }
 
 // addSchemas adds and customizes API schemas for operations, app, repo, and clusterrepo.
-// It adds action handlers and resource actions for install, upgrade, and uninstall operations of Charts.
+// It adds action handlers and resource actions for install, upgrade, rollback and uninstall operations of Charts,
+// and the history link of apps.
 // It also sets up handlers for byID and link requests.
 //
 // The function uses predefined structure templates for API schemas, allowing for customization
@@ -62,6 +63,9 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartUpgrade{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartInstallAction{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartInstall{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.ChartRollbackAction{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.ChartHistory{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.ChartPreview{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartActionOutput{}, nil)
 
 	operationTemplate := schema2.Template{
@@ -69,12 +73,16 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 		Kind:  "Operation",
 		Customize: func(apiSchema *types.APISchema) {
 			apiSchema.LinkHandlers = map[string]http.Handler{
-				"logs": ops,
+				"logs":    ops,
+				"preview": ops,
 			}
 			apiSchema.Formatter = func(request *types.APIRequest, resource *types.RawResource) {
 				if !resource.APIObject.Data().Bool("status", "podCreated") {
 					delete(resource.Links, "logs")
 				}
+				if !resource.APIObject.Data().Bool("status", "dryRun") || !resource.APIObject.Data().Bool("status", "podCreated") {
+					delete(resource.Links, "preview")
+				}
 			}
 		},
 	}
@@ -84,12 +92,20 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 		Customize: func(apiSchema *types.APISchema) {
 			apiSchema.ActionHandlers = map[string]http.Handler{
 				"uninstall": ops,
+				"rollback":  ops,
 			}
 			apiSchema.ResourceActions = map[string]schemas3.Action{
 				"uninstall": {
 					Input:  "chartUninstallAction",
 					Output: "chartActionOutput",
 				},
+				"rollback": {
+					Input:  "chartRollbackAction",
+					Output: "chartActionOutput",
+				},
+			}
+			apiSchema.LinkHandlers = map[string]http.Handler{
+				"history": ops,
 			}
 		},
 	}
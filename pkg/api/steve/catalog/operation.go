@@ -1,6 +1,7 @@
 package catalog
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/rancher/apiserver/pkg/types"
@@ -47,7 +48,7 @@ func newOperation(
 // For example, if the api request is for installing a chart, then it will call the
 // install function of the Operation struct.
 //
-// All chart actions (install, upgrade, and uninstall) are served through this method.
+// All chart actions (install, upgrade, rollback and uninstall) are served through this method.
 func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	// Get the APIContext from the current request's context. This APIContext
 	// encapsulates the details of the API request, which will be used to
@@ -73,14 +74,39 @@ func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		op, err = o.ops.Upgrade(apiRequest.Context(), user, ns, name, req.Body, o.imageOverride)
 	case "uninstall":
 		op, err = o.ops.Uninstall(apiRequest.Context(), user, ns, name, req.Body, o.imageOverride)
+	case "rollback":
+		op, err = o.ops.Rollback(apiRequest.Context(), user, ns, name, req.Body, o.imageOverride)
 	}
 
 	switch apiRequest.Link {
 	case "logs":
 		err = o.ops.Log(apiRequest.Response, apiRequest.Request,
 			apiRequest.Namespace, apiRequest.Name)
+	case "preview":
+		var preview *catalogtypes.ChartPreview
+		preview, err = o.ops.Preview(apiRequest.Context(), user, apiRequest.Namespace, apiRequest.Name)
+		if err == nil {
+			apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+				Type:   "chartPreview",
+				Object: preview,
+			})
+		}
+	case "history":
+		var history *catalogtypes.ChartHistory
+		history, err = o.ops.History(user, apiRequest.Namespace, apiRequest.Name)
+		if err == nil {
+			apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+				Type:   "chartHistory",
+				Object: history,
+			})
+		}
 	}
 
+	var valuesErr *helmop.ValuesError
+	if errors.As(err, &valuesErr) {
+		writeValuesError(apiRequest, valuesErr)
+		return
+	}
 	if err != nil {
 		apiRequest.WriteError(err)
 		return
@@ -99,6 +125,25 @@ func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	})
 }
 
+// writeValuesError writes the error the same way as other API errors, with every invalid value in fieldErrors so
+// that clients can report all of them at once instead of only the first field.
+func writeValuesError(apiRequest *types.APIRequest, err *helmop.ValuesError) {
+	e := map[string]interface{}{
+		"type":        "error",
+		"status":      validation.InvalidBodyContent.Status,
+		"code":        validation.InvalidBodyContent.Code,
+		"message":     err.Error(),
+		"fieldErrors": err.Errors,
+	}
+	if len(err.Errors) > 0 && err.Errors[0].Field != "" {
+		e["fieldName"] = err.Errors[0].Field
+	}
+	apiRequest.WriteResponse(validation.InvalidBodyContent.Status, types.APIObject{
+		Type:   "error",
+		Object: e,
+	})
+}
+
 // OnAdd is registered as a callback of a Kubernetes Informer.
 // It is invoked when a new object is added to the Kubernetes cluster.
 // It purges old roles related to the object being added.
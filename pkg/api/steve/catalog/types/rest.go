@@ -2,7 +2,7 @@
 Package types define several types representing Helm chart operations.
 
 These types are used by the Steve Catalog API to handle requests and responses
-associated with Helm chart actions such as install, upgrade, and uninstall.
+associated with Helm chart actions such as install, upgrade, rollback and uninstall.
 
 Types in this package include:
 
@@ -12,6 +12,14 @@
   - ChartUninstallAction: Describes the configuration for an uninstallation action.
   - ChartUpgradeAction: Describes the configuration for an upgrade action.
   - ChartUpgrade: Represents a Helm chart upgrade request.
+  - ChartRollbackAction: Describes the configuration for a rollback action.
+  - ChartHistory: Lists the revisions of a Helm release.
+  - ReleaseRevision: Contains the details of a single revision of a Helm release.
+  - ChartPreview: Lists the changes a dry run install or upgrade would make.
+  - ReleasePreview: Describes the changes a dry run would make to a single Helm release.
+  - ObjectChange: Identifies an object added, changed or removed by a dry run.
+  - FieldChange: Describes a single field or value changed by a dry run.
+  - ValueError: Describes a value of a chart that violates its values schema or questions.
   - ChartActionOutput: Represents the output after performing a Helm chart action.
 
 Each type includes fields that map directly to properties of Helm chart operations,
@@ -38,6 +46,7 @@ type ChartInstallAction struct {
 	Wait                     bool             `json:"wait,omitempty"`
 	DisableHooks             bool             `json:"noHooks,omitempty"`
 	DisableOpenAPIValidation bool             `json:"disableOpenAPIValidation,omitempty"`
+	DryRun                   bool             `json:"dryRun,omitempty"`
 	Namespace                string           `json:"namespace,omitempty"`
 	ProjectID                string           `json:"projectId,omitempty"`
 
@@ -71,6 +80,7 @@ type ChartUpgradeAction struct {
 	Install                  bool             `json:"install,omitempty"`
 	Namespace                string           `json:"namespace,omitempty"`
 	CleanupOnFail            bool             `json:"cleanupOnFail,omitempty"`
+	DryRun                   bool             `json:"dryRun,omitempty"`
 	Charts                   []ChartUpgrade   `json:"charts,omitempty"`
 }
 
@@ -89,3 +99,68 @@ type ChartActionOutput struct {
 	OperationName      string `json:"operationName,omitempty"`
 	OperationNamespace string `json:"operationNamespace,omitempty"`
 }
+
+type ChartRollbackAction struct {
+	Revision      int              `json:"revision,omitempty"`
+	Timeout       *metav1.Duration `json:"timeout,omitempty"`
+	Wait          bool             `json:"wait,omitempty"`
+	DisableHooks  bool             `json:"noHooks,omitempty"`
+	Force         bool             `json:"force,omitempty"`
+	RecreatePods  bool             `json:"recreatePods,omitempty"`
+	CleanupOnFail bool             `json:"cleanupOnFail,omitempty"`
+	MaxHistory    int              `json:"historyMax,omitempty"`
+}
+
+type ChartHistory struct {
+	Revisions []ReleaseRevision `json:"revisions,omitempty"`
+}
+
+type ReleaseRevision struct {
+	Revision     int                   `json:"revision,omitempty"`
+	Status       string                `json:"status,omitempty"`
+	Description  string                `json:"description,omitempty"`
+	ChartName    string                `json:"chartName,omitempty"`
+	ChartVersion string                `json:"chartVersion,omitempty"`
+	AppVersion   string                `json:"appVersion,omitempty"`
+	Updated      metav1.Time           `json:"updated,omitempty"`
+	Values       v3.MapStringInterface `json:"values,omitempty"`
+}
+
+type ChartPreview struct {
+	Releases []ReleasePreview `json:"releases,omitempty"`
+}
+
+type ReleasePreview struct {
+	ReleaseName     string         `json:"releaseName,omitempty"`
+	Namespace       string         `json:"namespace,omitempty"`
+	ChartName       string         `json:"chartName,omitempty"`
+	ChartVersion    string         `json:"chartVersion,omitempty"`
+	CurrentRevision int            `json:"currentRevision,omitempty"`
+	CurrentVersion  string         `json:"currentVersion,omitempty"`
+	Added           []ObjectChange `json:"added,omitempty"`
+	Changed         []ObjectChange `json:"changed,omitempty"`
+	Removed         []ObjectChange `json:"removed,omitempty"`
+	Values          []FieldChange  `json:"values,omitempty"`
+}
+
+type ObjectChange struct {
+	APIVersion string        `json:"apiVersion,omitempty"`
+	Kind       string        `json:"kind,omitempty"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	Fields     []FieldChange `json:"fields,omitempty"`
+}
+
+type FieldChange struct {
+	Path string      `json:"path,omitempty"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+type ValueError struct {
+	Chart   string `json:"chart,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message,omitempty"`
+}
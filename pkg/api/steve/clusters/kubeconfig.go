@@ -95,6 +95,8 @@ func (k kubeconfigDownload) ensureToken(userName string, req *http.Request) (str
 		TTL:           defaultTokenTTL,
 		Randomize:     true,
 		UserPrincipal: authToken.UserPrincipal,
+		// a kubeconfig token created with a scoped token can't be given more access than it
+		Scope: authToken.Scope,
 	}
 
 	return k.userMgr.EnsureToken(input)
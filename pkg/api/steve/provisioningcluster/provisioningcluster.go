@@ -0,0 +1,250 @@
+// Package provisioningcluster customizes the provisioning.cattle.io Cluster schema with a previewPlans action, which
+// returns the changes a proposed cluster spec would make to the plans of the machines of the cluster, and a
+// cloneFromEtcdSnapshot action, which restores the etcd snapshot of another cluster into the cluster.
+package provisioningcluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	caprcommon "github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	"github.com/rancher/rancher/pkg/controllers/capr"
+	provcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/wrangler"
+	schema2 "github.com/rancher/steve/pkg/schema"
+	steve "github.com/rancher/steve/pkg/server"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/schemas"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	previewPlansAction          = "previewPlans"
+	cloneFromEtcdSnapshotAction = "cloneFromEtcdSnapshot"
+)
+
+// ETCDSnapshotCloneInput is the input of the cloneFromEtcdSnapshot action
+type ETCDSnapshotCloneInput struct {
+	// SnapshotName is the name of the etcd snapshot of another cluster in the namespace of the cluster
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// RestoreRKEConfig is either none (or empty string), all, or kubernetesVersion
+	RestoreRKEConfig string `json:"restoreRKEConfig,omitempty"`
+}
+
+// planPreviewer renders the plans of a cluster without delivering them
+type planPreviewer interface {
+	Preview(cp *rkev1.RKEControlPlane) (*planner.PlanPreview, error)
+}
+
+type previewHandler struct {
+	clusters      provcontrollers.ClusterCache
+	controlPlanes rkecontrollers.RKEControlPlaneCache
+	planner       planPreviewer
+}
+
+type cloneHandler struct {
+	clusters      provcontrollers.ClusterCache
+	clusterClient provcontrollers.ClusterClient
+	etcdSnapshots rkecontrollers.ETCDSnapshotCache
+	secrets       corecontrollers.SecretCache
+}
+
+func Register(ctx context.Context, server *steve.Server, clients *wrangler.Context) {
+	handler := &previewHandler{
+		clusters:      clients.Provisioning.Cluster().Cache(),
+		controlPlanes: clients.RKE.RKEControlPlane().Cache(),
+		planner:       capr.NewPlanner(ctx, clients),
+	}
+	clone := &cloneHandler{
+		clusters:      clients.Provisioning.Cluster().Cache(),
+		clusterClient: clients.Provisioning.Cluster(),
+		etcdSnapshots: clients.RKE.ETCDSnapshot().Cache(),
+		secrets:       clients.Core.Secret().Cache(),
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(planner.PlanPreview{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(ETCDSnapshotCloneInput{}, nil)
+	server.SchemaFactory.AddTemplate(schema2.Template{
+		Group: "provisioning.cattle.io",
+		Kind:  "Cluster",
+		Customize: func(schema *types.APISchema) {
+			if schema.ActionHandlers == nil {
+				schema.ActionHandlers = map[string]http.Handler{}
+			}
+			schema.ActionHandlers[previewPlansAction] = handler
+			schema.ActionHandlers[cloneFromEtcdSnapshotAction] = clone
+			if schema.ResourceActions == nil {
+				schema.ResourceActions = map[string]schemas.Action{}
+			}
+			schema.ResourceActions[previewPlansAction] = schemas.Action{
+				Output: "planPreview",
+			}
+			schema.ResourceActions[cloneFromEtcdSnapshotAction] = schemas.Action{
+				Input: "etcdSnapshotCloneInput",
+			}
+		},
+	})
+}
+
+// ServeHTTP decodes the proposed spec of the cluster from the body of the request, and responds with the changes it
+// would make to the plans of the machines of the cluster. Only users who can update the cluster may preview it.
+func (h *previewHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, apiRequest.Schema.ID, "update", apiRequest.Namespace, apiRequest.Name); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	spec := provv1.ClusterSpec{}
+	if err := json.NewDecoder(req.Body).Decode(&spec); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	preview, err := h.preview(apiRequest.Namespace, apiRequest.Name, spec)
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "planPreview",
+		Object: preview,
+	})
+}
+
+func (h *previewHandler) preview(namespace, name string, spec provv1.ClusterSpec) (*planner.PlanPreview, error) {
+	cluster, err := h.clusters.Get(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Spec.RKEConfig == nil {
+		return nil, apierror.NewAPIError(validation.InvalidAction, "plans can only be previewed for RKE2 and K3s clusters")
+	}
+	if spec.RKEConfig == nil {
+		return nil, apierror.NewFieldAPIError(validation.MissingRequired, "rkeConfig", "the proposed spec must have an rkeConfig")
+	}
+
+	cp, err := h.controlPlanes.Get(namespace, name)
+	if apierrors.IsNotFound(err) {
+		return nil, apierror.NewAPIError(validation.InvalidState, "the cluster has not been provisioned yet")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return h.planner.Preview(proposedControlPlane(cp, spec))
+}
+
+// proposedControlPlane returns a copy of the control plane with the fields the provisioning cluster controller sets
+// from the spec of the cluster replaced by those of the proposed spec. The Kubernetes version is kept if the proposed
+// spec doesn't set one.
+func proposedControlPlane(cp *rkev1.RKEControlPlane, spec provv1.ClusterSpec) *rkev1.RKEControlPlane {
+	cp = cp.DeepCopy()
+	cp.Spec.RKEClusterSpecCommon = *spec.RKEConfig.RKEClusterSpecCommon.DeepCopy()
+	cp.Spec.LocalClusterAuthEndpoint = *spec.LocalClusterAuthEndpoint.DeepCopy()
+	cp.Spec.AgentEnvVars = spec.AgentEnvVars
+	if spec.KubernetesVersion != "" {
+		cp.Spec.KubernetesVersion = spec.KubernetesVersion
+	}
+	return cp
+}
+
+// ServeHTTP requests the restore of the etcd snapshot of another cluster into the cluster, which clones that cluster.
+// The snapshot and the server token of the other cluster give full access to it, so the requesting user must be able to
+// update both clusters. The restore is authorized with a key only Rancher can compute, so that a clone can't be
+// requested by editing the cluster directly.
+func (h *cloneHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, apiRequest.Schema.ID, "update", apiRequest.Namespace, apiRequest.Name); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	input := ETCDSnapshotCloneInput{}
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+	if input.SnapshotName == "" {
+		apiRequest.WriteError(apierror.NewFieldAPIError(validation.MissingRequired, "snapshotName", "the etcd snapshot to clone from must be set"))
+		return
+	}
+
+	snapshot, err := h.etcdSnapshots.Get(apiRequest.Namespace, input.SnapshotName)
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+	if snapshot.Spec.ClusterName == "" || snapshot.Spec.ClusterName == apiRequest.Name {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidOption, "the etcd snapshot must be taken from another cluster"))
+		return
+	}
+	if err := apiRequest.AccessControl.CanDo(apiRequest, apiRequest.Schema.ID, "update", apiRequest.Namespace, snapshot.Spec.ClusterName); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	if err := h.clone(apiRequest.Namespace, apiRequest.Name, input, snapshot); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *cloneHandler) clone(namespace, name string, input ETCDSnapshotCloneInput, snapshot *rkev1.ETCDSnapshot) error {
+	cluster, err := h.clusters.Get(namespace, name)
+	if err != nil {
+		return err
+	}
+	if cluster.Spec.RKEConfig == nil {
+		return apierror.NewAPIError(validation.InvalidAction, "only RKE2 and K3s clusters can be cloned into")
+	}
+	if snapshot.SnapshotFile.S3 == nil {
+		return apierror.NewAPIError(validation.InvalidOption, "only etcd snapshots stored in S3 can be restored into another cluster")
+	}
+
+	// The server token of the clone is rotated away from the server token of the cloned cluster by the distribution of
+	// the cluster, which runs either the Kubernetes version of the cluster or the one of the snapshot.
+	snapshotClusterSpec, err := caprcommon.ParseSnapshotClusterSpecOrError(snapshot)
+	if err != nil {
+		return apierror.NewAPIError(validation.InvalidOption, "unable to determine the Kubernetes version of the etcd snapshot: "+err.Error())
+	}
+	for _, version := range []string{cluster.Spec.KubernetesVersion, snapshotClusterSpec.KubernetesVersion} {
+		supported, err := caprcommon.SupportsServerTokenRotation(version)
+		if err != nil {
+			return apierror.NewAPIError(validation.InvalidOption, err.Error())
+		}
+		if !supported {
+			return apierror.NewAPIError(validation.InvalidOption, "Kubernetes version "+version+" does not support rotating the server token of a cloned cluster")
+		}
+	}
+
+	sourceServerToken, err := caprcommon.ETCDSnapshotSourceServerToken(h.secrets, snapshot)
+	if err != nil {
+		return err
+	}
+
+	restore := &rkev1.ETCDSnapshotRestore{
+		Name:             snapshot.Name,
+		Generation:       1,
+		RestoreRKEConfig: input.RestoreRKEConfig,
+		CloneFromCluster: snapshot.Spec.ClusterName,
+	}
+	if cluster.Spec.RKEConfig.ETCDSnapshotRestore != nil {
+		restore.Generation = cluster.Spec.RKEConfig.ETCDSnapshotRestore.Generation + 1
+	}
+	restore.CloneAuthorization = caprcommon.ETCDSnapshotCloneAuthorization(sourceServerToken, namespace, name, restore)
+
+	cluster = cluster.DeepCopy()
+	cluster.Spec.RKEConfig.ETCDSnapshotRestore = restore
+	_, err = h.clusterClient.Update(cluster)
+	return err
+}
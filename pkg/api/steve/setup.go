@@ -8,8 +8,10 @@
 	"github.com/rancher/rancher/pkg/api/steve/disallow"
 	"github.com/rancher/rancher/pkg/api/steve/machine"
 	"github.com/rancher/rancher/pkg/api/steve/navlinks"
+	"github.com/rancher/rancher/pkg/api/steve/provisioningcluster"
 	"github.com/rancher/rancher/pkg/api/steve/settings"
 	"github.com/rancher/rancher/pkg/api/steve/userpreferences"
+	"github.com/rancher/rancher/pkg/features"
 	"github.com/rancher/rancher/pkg/wrangler"
 	steve "github.com/rancher/steve/pkg/server"
 )
@@ -20,6 +22,9 @@ func Setup(ctx context.Context, server *steve.Server, config *wrangler.Context)
 		return err
 	}
 	machine.Register(server, config)
+	if features.RKE2.Enabled() {
+		provisioningcluster.Register(ctx, server, config)
+	}
 	navlinks.Register(ctx, server)
 	settings.Register(server)
 	disallow.Register(server)
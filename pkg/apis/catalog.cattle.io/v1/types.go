@@ -17,6 +17,20 @@ type ClusterRepo struct {
 	Status            RepoStatus `json:"status"`
 }
 
+// +genclient
+// +kubebuilder:skipversion
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Repo is a chart repository in a namespace. It has the same spec as a ClusterRepo, but is only visible to the users
+// with access to its namespace, and its secrets are in its namespace. Its charts are installed as the requesting user,
+// its service account is ignored.
+type Repo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RepoSpec   `json:"spec"`
+	Status            RepoStatus `json:"status"`
+}
+
 // SecretReference a reference to a secret object
 type SecretReference struct {
 	Name      string `json:"name,omitempty"`
@@ -24,7 +38,8 @@ type SecretReference struct {
 }
 
 type RepoSpec struct {
-	// URL A http URL of the repo to connect to
+	// URL A http URL of the repo to connect to, or an oci:// URL of a chart repository in an OCI registry
+	// whose semver tags are the versions of the chart
 	URL string `json:"url,omitempty"`
 
 	// GitRepo a git repo to clone and index as the helm repo
@@ -46,14 +61,15 @@ type RepoSpec struct {
 	// For a repo the Namespace file will be ignored
 	ClientSecret *SecretReference `json:"clientSecret,omitempty"`
 
-	// BasicAuthSecretName is the client secret to be used to connect to the repo
+	// BasicAuthSecretName is the basic auth secret to be used to connect to an oci:// repo
 	BasicAuthSecretName string `json:"basicAuthSecretName,omitempty"`
 
 	// ForceUpdate will cause the repo index to be downloaded if it was last download before the specified time
 	// If ForceUpdate is greater than time.Now() it will not trigger an update
 	ForceUpdate *metav1.Time `json:"forceUpdate,omitempty"`
 
-	// ServiceAccount this service account will be used to deploy charts instead of the end users credentials
+	// ServiceAccount this service account will be used to deploy charts instead of the end users credentials.
+	// This value is used only on ClusterRepo and will be ignored on Repo
 	ServiceAccount string `json:"serviceAccount,omitempty"`
 
 	// ServiceAccountNamespace namespace of the service account to use. This value is used only on
@@ -65,6 +81,16 @@ type RepoSpec struct {
 
 	// DisableSameOriginCheck attaches the Basic Auth Header to all helm client API calls, regardless of whether the destination of the API call matches the origin of the repository's URL
 	DisableSameOriginCheck bool `json:"disableSameOriginCheck,omitempty"`
+
+	// RefreshInterval is the number of seconds between downloads of the repo index. If unspecified, the index is
+	// downloaded every 5 minutes. Failed downloads are retried with an exponential backoff instead.
+	RefreshInterval int `json:"refreshInterval,omitempty"`
+
+	// KeyringSecret is a secret holding the keys charts of the repo must be signed with to be installed or upgraded.
+	// Its "keyring" key holds the PGP public keyring used to verify the .prov files of charts in http and git repos,
+	// and its "cosign.pub" key holds the PEM encoded cosign public key used to verify charts in OCI registries.
+	// For a Repo the Namespace field will be ignored
+	KeyringSecret *SecretReference `json:"keyringSecret,omitempty"`
 }
 
 type RepoCondition string
@@ -94,6 +120,15 @@ type RepoStatus struct {
 	// The git commit used to generate the index
 	Commit string `json:"commit,omitempty"`
 
+	// LastDownloadError is the error of the last download of the index, if it failed
+	LastDownloadError string `json:"lastDownloadError,omitempty"`
+
+	// DownloadFailures is the number of consecutive failed downloads of the index
+	DownloadFailures int `json:"downloadFailures,omitempty"`
+
+	// NextAttemptTime the time when a failed download of the index will be retried
+	NextAttemptTime metav1.Time `json:"nextAttemptTime,omitempty"`
+
 	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
 }
 
@@ -120,5 +155,22 @@ type OperationStatus struct {
 	PodName            string                              `json:"podName,omitempty"`
 	PodNamespace       string                              `json:"podNamespace,omitempty"`
 	PodCreated         bool                                `json:"podCreated,omitempty"`
+	DryRun             bool                                `json:"dryRun,omitempty"`
+	Verifications      []ChartVerification                 `json:"verifications,omitempty"`
 	Conditions         []genericcondition.GenericCondition `json:"conditions,omitempty"`
 }
+
+// ChartVerification is the result of the verification of the signature of a chart installed by an operation.
+type ChartVerification struct {
+	Chart   string `json:"chart,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// Method is how the chart was verified, either "provenance" for a .prov file or "cosign" for a cosign signature
+	Method string `json:"method,omitempty"`
+
+	// Signer is the identity of the PGP key that signed the chart, which is empty for cosign signatures
+	Signer string `json:"signer,omitempty"`
+
+	// Digest is the sha256 digest of the verified chart archive
+	Digest string `json:"digest,omitempty"`
+}
@@ -31,6 +31,7 @@
 	AppResourceName         = "apps"
 	ClusterRepoResourceName = "clusterrepos"
 	OperationResourceName   = "operations"
+	RepoResourceName        = "repos"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -60,6 +61,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&ClusterRepoList{},
 		&Operation{},
 		&OperationList{},
+		&Repo{},
+		&RepoList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -36,4 +36,20 @@ type ClusterAuthToken struct {
 	ExpiresAt     string `json:"expiresAt,omitempty"`
 	SecretKeyHash string `json:"hash"`
 	Enabled       bool   `json:"enabled"`
+	// Scope is the scope of the token the ClusterAuthToken was created from. Scoped tokens aren't synced to clusters
+	// until kube-api-auth enforces scopes, and ClusterAuthTokens with a scope are refused.
+	Scope *ClusterAuthTokenScope `json:"scope,omitempty"`
+}
+
+// ClusterAuthTokenScope restricts the requests a ClusterAuthToken can be used for, see the TokenScope of the token it
+// was created from.
+type ClusterAuthTokenScope struct {
+	ReadOnly  bool                            `json:"readOnly,omitempty"`
+	Resources []ClusterAuthTokenScopeResource `json:"resources,omitempty"`
+}
+
+// ClusterAuthTokenScopeResource allows access to resources of an API group.
+type ClusterAuthTokenScopeResource struct {
+	APIGroup  string   `json:"apiGroup"`
+	Resources []string `json:"resources,omitempty"`
 }
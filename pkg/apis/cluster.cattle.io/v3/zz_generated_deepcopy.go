@@ -31,6 +31,11 @@ func (in *ClusterAuthToken) DeepCopyInto(out *ClusterAuthToken) {
 	out.Namespaced = in.Namespaced
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Scope != nil {
+		in, out := &in.Scope, &out.Scope
+		*out = new(ClusterAuthTokenScope)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -85,6 +90,50 @@ func (in *ClusterAuthTokenList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAuthTokenScope) DeepCopyInto(out *ClusterAuthTokenScope) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ClusterAuthTokenScopeResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAuthTokenScope.
+func (in *ClusterAuthTokenScope) DeepCopy() *ClusterAuthTokenScope {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAuthTokenScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAuthTokenScopeResource) DeepCopyInto(out *ClusterAuthTokenScopeResource) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAuthTokenScopeResource.
+func (in *ClusterAuthTokenScopeResource) DeepCopy() *ClusterAuthTokenScopeResource {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAuthTokenScopeResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterUserAttribute) DeepCopyInto(out *ClusterUserAttribute) {
 	*out = *in
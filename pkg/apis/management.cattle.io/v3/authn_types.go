@@ -36,12 +36,38 @@ type Token struct {
 	Current         bool              `json:"current"`
 	ClusterName     string            `json:"clusterName,omitempty" norman:"noupdate,type=reference[cluster]"`
 	Enabled         *bool             `json:"enabled,omitempty" norman:"default=true"`
+	Scope           *TokenScope       `json:"scope,omitempty" norman:"noupdate"`
+	LastUsedAt      *metav1.Time      `json:"lastUsedAt,omitempty" norman:"nocreate,noupdate"`
+	LastUsedIP      string            `json:"lastUsedIP,omitempty" norman:"nocreate,noupdate"`
 }
 
 func (t *Token) ObjClusterName() string {
 	return t.ClusterName
 }
 
+// TokenScope restricts the requests a token can be used for, on top of the permissions of its user.
+// Empty fields don't restrict anything.
+type TokenScope struct {
+	// Clusters is a list of cluster IDs. If set, the token can only be used for requests to these clusters, which
+	// include requests for the management resources of a cluster, like its projects. Requests to the /v1 API of the
+	// management cluster are for the local cluster.
+	Clusters []string `json:"clusters,omitempty" norman:"type=array[reference[cluster]]"`
+	// ReadOnly limits the token to the get, list and watch verbs.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// Resources is a list of the API groups and resources the token can be used for.
+	Resources []TokenScopeResource `json:"resources,omitempty"`
+}
+
+// TokenScopeResource allows access to resources of an API group. Resources of the Rancher /v3 API belong to
+// the management.cattle.io group.
+type TokenScopeResource struct {
+	// APIGroup is the API group of the resources, "" for the core group and "*" for every group.
+	APIGroup string `json:"apiGroup"`
+	// Resources is a list of resource names, for example pods or deployments. Empty or "*" matches every
+	// resource of the group.
+	Resources []string `json:"resources,omitempty"`
+}
+
 // +genclient
 // +kubebuilder:skipversion
 // +genclient:nonNamespaced
@@ -59,12 +85,18 @@ type User struct {
 	PrincipalIDs       []string   `json:"principalIds,omitempty" norman:"type=array[reference[principal]]"`
 	Me                 bool       `json:"me,omitempty" norman:"nocreate,noupdate"`
 	Enabled            *bool      `json:"enabled,omitempty" norman:"default=true"`
+	MFAEnabled         bool       `json:"mfaEnabled,omitempty" norman:"nocreate,noupdate"`
 	Spec               UserSpec   `json:"spec,omitempty"`
 	Status             UserStatus `json:"status"`
 }
 
 type UserStatus struct {
 	Conditions []UserCondition `json:"conditions"`
+	// LockedUntil is the end of the last lockout of the user after too many failed password logins.
+	LockedUntil *metav1.Time `json:"lockedUntil,omitempty"`
+	// PasswordChangedAt is when the password of the local user was last set. The creation time of the user is used
+	// for users whose password wasn't set since they were created.
+	PasswordChangedAt *metav1.Time `json:"passwordChangedAt,omitempty" norman:"nocreate,noupdate"`
 }
 
 type UserCondition struct {
@@ -164,6 +196,25 @@ type SetPasswordInput struct {
 	NewPassword string `json:"newPassword" norman:"type=string,required"`
 }
 
+// TOTPCodeInput is the input of the TOTP actions of users. Either a code of the authenticator of the user or one of its
+// recovery codes can be used to disable TOTP.
+type TOTPCodeInput struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+// TOTPEnrollment is the shared secret of a TOTP enrollment, and its otpauth:// URI to be shown as a QR code.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+// TOTPRecoveryCodes are the one-time codes which can be used to log in if the TOTP authenticator of a user is lost.
+// They are only returned when TOTP is activated.
+type TOTPRecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 // +genclient
 // +kubebuilder:skipversion
 // +genclient:nonNamespaced
@@ -461,6 +512,57 @@ type OIDCConfig struct {
 	PrivateKey         string `json:"privateKey" norman:"type=password"`
 	RancherURL         string `json:"rancherUrl" norman:"required,notnullable"`
 	GroupSearchEnabled *bool  `json:"groupSearchEnabled"`
+
+	// GroupsClaim is the path of the claim holding the groups of users, with the names of nested claims separated by
+	// dots, such as realm_access.roles. The groups and full_group_path claims are used when it is empty.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// GroupRewrites are applied in order to the name of each group. A group whose name is rewritten to an empty
+	// string is dropped.
+	GroupRewrites []OIDCGroupRewrite `json:"groupRewrites,omitempty"`
+	// GroupPrefix is prepended to the name of each group once it is rewritten.
+	GroupPrefix string `json:"groupPrefix,omitempty"`
+	// ClaimRules grant roles to the users whose claims match them when they log in. Roles granted by a rule are
+	// revoked once the rule no longer matches at a login or refresh of the user, once the rule is changed or removed,
+	// or once the claims of the user haven't been verified for longer than the auth-user-session-ttl-minutes setting.
+	// The user saving the rules must hold every role they grant.
+	ClaimRules []OIDCClaimRule `json:"claimRules,omitempty"`
+}
+
+// OIDCGroupRewrite replaces the matches of a regular expression in the names of groups.
+type OIDCGroupRewrite struct {
+	// Pattern is the regular expression to match in the name of groups.
+	Pattern string `json:"pattern" norman:"required"`
+	// Replacement replaces the matches of Pattern, and can refer to its submatches with $1 or ${name}.
+	Replacement string `json:"replacement"`
+}
+
+// OIDCClaimRule grants roles to the users whose claim matches an expression.
+type OIDCClaimRule struct {
+	// Name identifies the rule in the bindings it creates.
+	Name string `json:"name" norman:"required"`
+	// Claim is the path of the claim, with the names of nested claims separated by dots.
+	Claim string `json:"claim" norman:"required"`
+	// Operator compares the values of the claim with Value: equals, contains (a substring), matches (a regular
+	// expression), or exists, which ignores Value. The rule matches if any value of the claim matches.
+	Operator string `json:"operator,omitempty" norman:"type=enum,options=equals|contains|matches|exists,default=equals"`
+	Value    string `json:"value,omitempty"`
+
+	GlobalRoles  []string                   `json:"globalRoles,omitempty" norman:"type=array[reference[globalRole]]"`
+	ClusterRoles []OIDCClaimRuleClusterRole `json:"clusterRoles,omitempty"`
+	ProjectRoles []OIDCClaimRuleProjectRole `json:"projectRoles,omitempty"`
+}
+
+// OIDCClaimRuleClusterRole is a role template granted in a cluster by a claim rule.
+type OIDCClaimRuleClusterRole struct {
+	ClusterName      string `json:"clusterName" norman:"required"`
+	RoleTemplateName string `json:"roleTemplateName" norman:"required"`
+}
+
+// OIDCClaimRuleProjectRole is a role template granted in a project by a claim rule.
+type OIDCClaimRuleProjectRole struct {
+	// ProjectName is the ID of the project, of the form <cluster name>:<project name>.
+	ProjectName      string `json:"projectName" norman:"required"`
+	RoleTemplateName string `json:"roleTemplateName" norman:"required"`
 }
 
 type OIDCTestOutput struct {
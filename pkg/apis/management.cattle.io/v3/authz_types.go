@@ -343,6 +343,15 @@ type ProjectRoleTemplateBinding struct {
 	// Deprecated.
 	// +optional
 	ServiceAccount string `json:"serviceAccount,omitempty" norman:"nocreate,noupdate"`
+
+	// NotBefore is the time from which the binding grants access. If unset, the binding grants access from its creation.
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// ExpiresAt is the time at which the binding stops granting access and is deleted. If unset, the binding doesn't expire.
+	// It must be after NotBefore.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 func (p *ProjectRoleTemplateBinding) ObjClusterName() string {
@@ -389,6 +398,15 @@ type ClusterRoleTemplateBinding struct {
 	// RoleTemplateName is the name of the role template that defines permissions to perform actions on resources in the cluster. Immutable.
 	// +kubebuilder:validation:Required
 	RoleTemplateName string `json:"roleTemplateName" norman:"required,noupdate,type=reference[roleTemplate]"`
+
+	// NotBefore is the time from which the binding grants access. If unset, the binding grants access from its creation.
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// ExpiresAt is the time at which the binding stops granting access and is deleted. If unset, the binding doesn't expire.
+	// It must be after NotBefore.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 func (c *ClusterRoleTemplateBinding) ObjClusterName() string {
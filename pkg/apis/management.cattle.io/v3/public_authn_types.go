@@ -39,6 +39,9 @@ type BasicLogin struct {
 	GenericLogin `json:",inline"`
 	Username     string `json:"username" norman:"type=string,required"`
 	Password     string `json:"password" norman:"type=string,required"`
+	// TOTPCode or RecoveryCode is required by the local provider for users who enabled TOTP.
+	TOTPCode     string `json:"totpCode,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
 }
 
 // +genclient
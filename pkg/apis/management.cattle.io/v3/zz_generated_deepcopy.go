@@ -2073,6 +2073,14 @@ func (in *ClusterRoleTemplateBinding) DeepCopyInto(out *ClusterRoleTemplateBindi
 	out.Namespaced = in.Namespaced
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -6724,6 +6732,69 @@ func (in *OIDCApplyInput) DeepCopy() *OIDCApplyInput {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClaimRule) DeepCopyInto(out *OIDCClaimRule) {
+	*out = *in
+	if in.GlobalRoles != nil {
+		in, out := &in.GlobalRoles, &out.GlobalRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClusterRoles != nil {
+		in, out := &in.ClusterRoles, &out.ClusterRoles
+		*out = make([]OIDCClaimRuleClusterRole, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProjectRoles != nil {
+		in, out := &in.ProjectRoles, &out.ProjectRoles
+		*out = make([]OIDCClaimRuleProjectRole, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClaimRule.
+func (in *OIDCClaimRule) DeepCopy() *OIDCClaimRule {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClaimRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClaimRuleClusterRole) DeepCopyInto(out *OIDCClaimRuleClusterRole) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClaimRuleClusterRole.
+func (in *OIDCClaimRuleClusterRole) DeepCopy() *OIDCClaimRuleClusterRole {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClaimRuleClusterRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClaimRuleProjectRole) DeepCopyInto(out *OIDCClaimRuleProjectRole) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClaimRuleProjectRole.
+func (in *OIDCClaimRuleProjectRole) DeepCopy() *OIDCClaimRuleProjectRole {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClaimRuleProjectRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
 	*out = *in
@@ -6733,6 +6804,18 @@ func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.GroupRewrites != nil {
+		in, out := &in.GroupRewrites, &out.GroupRewrites
+		*out = make([]OIDCGroupRewrite, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClaimRules != nil {
+		in, out := &in.ClaimRules, &out.ClaimRules
+		*out = make([]OIDCClaimRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -6787,6 +6870,22 @@ func (in *OIDCConfigList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCGroupRewrite) DeepCopyInto(out *OIDCGroupRewrite) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCGroupRewrite.
+func (in *OIDCGroupRewrite) DeepCopy() *OIDCGroupRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCGroupRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCLogin) DeepCopyInto(out *OIDCLogin) {
 	*out = *in
@@ -8196,6 +8295,14 @@ func (in *ProjectRoleTemplateBinding) DeepCopyInto(out *ProjectRoleTemplateBindi
 	out.Namespaced = in.Namespaced
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -9583,6 +9690,59 @@ func (in *SystemServiceRule) DeepCopy() *SystemServiceRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TOTPCodeInput) DeepCopyInto(out *TOTPCodeInput) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TOTPCodeInput.
+func (in *TOTPCodeInput) DeepCopy() *TOTPCodeInput {
+	if in == nil {
+		return nil
+	}
+	out := new(TOTPCodeInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TOTPEnrollment) DeepCopyInto(out *TOTPEnrollment) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TOTPEnrollment.
+func (in *TOTPEnrollment) DeepCopy() *TOTPEnrollment {
+	if in == nil {
+		return nil
+	}
+	out := new(TOTPEnrollment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TOTPRecoveryCodes) DeepCopyInto(out *TOTPRecoveryCodes) {
+	*out = *in
+	if in.RecoveryCodes != nil {
+		in, out := &in.RecoveryCodes, &out.RecoveryCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TOTPRecoveryCodes.
+func (in *TOTPRecoveryCodes) DeepCopy() *TOTPRecoveryCodes {
+	if in == nil {
+		return nil
+	}
+	out := new(TOTPRecoveryCodes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Target) DeepCopyInto(out *Target) {
 	*out = *in
@@ -10044,6 +10204,15 @@ func (in *Token) DeepCopyInto(out *Token) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Scope != nil {
+		in, out := &in.Scope, &out.Scope
+		*out = new(TokenScope)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastUsedAt != nil {
+		in, out := &in.LastUsedAt, &out.LastUsedAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -10098,6 +10267,55 @@ func (in *TokenList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenScope) DeepCopyInto(out *TokenScope) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]TokenScopeResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenScope.
+func (in *TokenScope) DeepCopy() *TokenScope {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenScopeResource) DeepCopyInto(out *TokenScopeResource) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenScopeResource.
+func (in *TokenScopeResource) DeepCopy() *TokenScopeResource {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenScopeResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateGlobalDNSTargetsInput) DeepCopyInto(out *UpdateGlobalDNSTargetsInput) {
 	*out = *in
@@ -10370,6 +10588,14 @@ func (in *UserStatus) DeepCopyInto(out *UserStatus) {
 		*out = make([]UserCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.LockedUntil != nil {
+		in, out := &in.LockedUntil, &out.LockedUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.PasswordChangedAt != nil {
+		in, out := &in.PasswordChangedAt, &out.PasswordChangedAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
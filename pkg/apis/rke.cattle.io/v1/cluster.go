@@ -97,10 +97,58 @@ type ClusterUpgradeStrategy struct {
 	// accepted too.
 	ControlPlaneConcurrency  string       `json:"controlPlaneConcurrency,omitempty"`
 	ControlPlaneDrainOptions DrainOptions `json:"controlPlaneDrainOptions,omitempty"`
+	// ControlPlaneCanary rolls out plan changes to canary etcd and controlplane nodes first, and holds the remaining
+	// nodes until the rollout is approved. If not set, changes are rolled out to all nodes.
+	ControlPlaneCanary *RolloutCanary `json:"controlPlaneCanary,omitempty"`
 
 	// How many workers should be upgraded at a time
 	WorkerConcurrency  string       `json:"workerConcurrency,omitempty"`
 	WorkerDrainOptions DrainOptions `json:"workerDrainOptions,omitempty"`
+	// WorkerCanary rolls out plan changes to canary worker nodes first, and holds the remaining nodes until the
+	// rollout is approved. If not set, changes are rolled out to all nodes.
+	WorkerCanary *RolloutCanary `json:"workerCanary,omitempty"`
+
+	// MaintenanceWindows restrict when plan changes that drain or restart nodes are rolled out. Outside of all windows
+	// these changes are held until the next window opens, while new machines and minor changes are still planned. If
+	// no windows are set, changes are rolled out immediately.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// MaintenanceWindowOverrideUntil rolls out changes regardless of the maintenance windows until the given time, for
+	// emergency changes.
+	MaintenanceWindowOverrideUntil *metav1.Time `json:"maintenanceWindowOverrideUntil,omitempty"`
+}
+
+// RolloutCanary configures the canaries of a rollout. Plan changes that drain or restart nodes are rolled out to the
+// canaries first. Once the canaries are healthy for the soak duration, the rollout pauses until it is approved by
+// incrementing ApprovedGeneration. If a canary fails, the rollout stops.
+type RolloutCanary struct {
+	// Count is the number of nodes of each tier that are updated first, defaults to 1.
+	Count int `json:"count,omitempty"`
+	// SoakDuration is how long the probes of the canaries must be healthy before the rollout pauses for approval,
+	// e.g. "30m". Defaults to pausing as soon as the canaries are healthy.
+	SoakDuration string `json:"soakDuration,omitempty"`
+	// ApprovedGeneration approves a paused rollout when it differs from the canary generation in the status of the
+	// control plane.
+	ApprovedGeneration int64 `json:"approvedGeneration,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period of time during which plan changes may be rolled out. A window is either
+// opened by a cron schedule for a duration, or spans a daily time range on the given days of the week.
+type MaintenanceWindow struct {
+	// Schedule is a standard five field cron expression for the start of the window, e.g. "0 22 * * 1-5".
+	Schedule string `json:"schedule,omitempty"`
+	// Duration is how long the window opened by the schedule stays open, e.g. "4h".
+	Duration string `json:"duration,omitempty"`
+
+	// Days are the days of the week on which the window starts, e.g. "Saturday" or "Sat". Defaults to every day.
+	Days []string `json:"days,omitempty"`
+	// StartTime is the time of day the window opens, in 24 hour HH:MM format.
+	StartTime string `json:"startTime,omitempty"`
+	// EndTime is the time of day the window closes, in 24 hour HH:MM format. If it isn't after StartTime, the window
+	// closes on the next day.
+	EndTime string `json:"endTime,omitempty"`
+
+	// TimeZone is the IANA name of the time zone of the schedule or time range, e.g. "Europe/Berlin". Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type DrainOptions struct {
@@ -52,4 +52,6 @@ type RKEControlPlaneStatus struct {
 	ConfigGeneration              int64                               `json:"configGeneration,omitempty"`
 	Initialized                   bool                                `json:"initialized,omitempty"`
 	AgentConnected                bool                                `json:"agentConnected,omitempty"`
+	ControlPlaneCanaryGeneration  int64                               `json:"controlPlaneCanaryGeneration,omitempty"`
+	WorkerCanaryGeneration        int64                               `json:"workerCanaryGeneration,omitempty"`
 }
@@ -8,9 +8,11 @@
 	ETCDSnapshotPhaseStarted                ETCDSnapshotPhase = "Started"
 	ETCDSnapshotPhaseShutdown               ETCDSnapshotPhase = "Shutdown"
 	ETCDSnapshotPhaseRestore                ETCDSnapshotPhase = "Restore"
+	ETCDSnapshotPhaseRemoveSourceIdentity   ETCDSnapshotPhase = "RemoveSourceIdentity"
 	ETCDSnapshotPhasePostRestorePodCleanup  ETCDSnapshotPhase = "PostRestorePodCleanup"
 	ETCDSnapshotPhaseInitialRestartCluster  ETCDSnapshotPhase = "InitialRestartCluster"
 	ETCDSnapshotPhasePostRestoreNodeCleanup ETCDSnapshotPhase = "PostRestoreNodeCleanup"
+	ETCDSnapshotPhaseRotateServerToken      ETCDSnapshotPhase = "RotateServerToken"
 	ETCDSnapshotPhaseRestartCluster         ETCDSnapshotPhase = "RestartCluster"
 	ETCDSnapshotPhaseFinished               ETCDSnapshotPhase = "Finished"
 	ETCDSnapshotPhaseFailed                 ETCDSnapshotPhase = "Failed"
@@ -32,13 +34,22 @@ type ETCDSnapshotCreate struct {
 }
 
 type ETCDSnapshotRestore struct {
-	// Name refers to the name of the associated etcdsnapshot object
+	// Name refers to the name of the associated etcdsnapshot object.
 	Name string `json:"name,omitempty"`
 
 	// Changing the Generation is the only thing required to initiate a snapshot restore.
 	Generation int `json:"generation,omitempty"`
 	// Set to either none (or empty string), all, or kubernetesVersion
 	RestoreRKEConfig string `json:"restoreRKEConfig,omitempty"`
+
+	// CloneFromCluster requests that the cluster is cloned from the snapshot of another cluster in the same namespace,
+	// which must be stored in S3: the server token of the other cluster is used to restore the snapshot and is then
+	// rotated, and the agents are registered as this cluster. It is set by the cloneFromEtcdSnapshot action of the
+	// cluster.
+	CloneFromCluster string `json:"cloneFromCluster,omitempty"`
+	// CloneAuthorization is set by the cloneFromEtcdSnapshot action of the cluster once it verified that the requesting
+	// user can update the cluster that is cloned. A clone without a valid authorization is refused.
+	CloneAuthorization string `json:"cloneAuthorization,omitempty"`
 }
 
 // +genclient
@@ -55,6 +66,18 @@ type ETCDSnapshot struct {
 
 type ETCDSnapshotSpec struct {
 	ClusterName string `json:"clusterName,omitempty"`
+	// Verify requests a verification of the snapshot, whose result is recorded in the status. Snapshots stored in S3
+	// are downloaded with the S3 cloud credential of the cluster, and snapshots stored on a node are copied from the
+	// node through the API of the cluster.
+	Verify *ETCDSnapshotVerify `json:"verify,omitempty"`
+}
+
+type ETCDSnapshotVerify struct {
+	// Changing the Generation is the only thing required to initiate a snapshot verification.
+	Generation int `json:"generation,omitempty"`
+	// ScratchRestore restores the snapshot into a throwaway etcd member to verify that it can be served, in addition
+	// to checking its hash and database integrity.
+	ScratchRestore bool `json:"scratchRestore,omitempty"`
 }
 
 type ETCDSnapshotFile struct {
@@ -70,7 +93,25 @@ type ETCDSnapshotFile struct {
 }
 
 type ETCDSnapshotStatus struct {
-	Missing bool `json:"missing"`
+	Missing      bool                      `json:"missing"`
+	Verification *ETCDSnapshotVerification `json:"verification,omitempty"`
+}
+
+// ETCDSnapshotVerification is the result of the last verification of a snapshot.
+type ETCDSnapshotVerification struct {
+	// Generation is the generation of the verify request that was verified.
+	Generation int `json:"generation,omitempty"`
+	// Verified is true if the snapshot passed all checks.
+	Verified   bool         `json:"verified"`
+	VerifiedAt *metav1.Time `json:"verifiedAt,omitempty"`
+	// SHA256 is the sha256 hash of the snapshot file as it is stored.
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// Keys is the number of keys in the snapshot, including all revisions.
+	Keys            int  `json:"keys,omitempty"`
+	ScratchRestored bool `json:"scratchRestored,omitempty"`
+	// Message describes why the verification failed.
+	Message string `json:"message,omitempty"`
 }
 
 type ETCD struct {
@@ -78,4 +119,9 @@ type ETCD struct {
 	SnapshotScheduleCron string          `json:"snapshotScheduleCron,omitempty"`
 	SnapshotRetention    int             `json:"snapshotRetention,omitempty"`
 	S3                   *ETCDSnapshotS3 `json:"s3,omitempty"`
+	// SnapshotVerifyScheduleCron is the cron schedule on which the snapshots of the cluster are verified, counted from
+	// when the schedule was set. If not set, snapshots are only verified on request.
+	SnapshotVerifyScheduleCron string `json:"snapshotVerifyScheduleCron,omitempty"`
+	// SnapshotVerifyScratchRestore restores snapshots into a throwaway etcd member when they are verified on schedule.
+	SnapshotVerifyScratchRestore bool `json:"snapshotVerifyScratchRestore,omitempty"`
 }
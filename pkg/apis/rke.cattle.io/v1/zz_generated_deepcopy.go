@@ -49,7 +49,28 @@ func (in *AuthConfig) DeepCopy() *AuthConfig {
 func (in *ClusterUpgradeStrategy) DeepCopyInto(out *ClusterUpgradeStrategy) {
 	*out = *in
 	in.ControlPlaneDrainOptions.DeepCopyInto(&out.ControlPlaneDrainOptions)
+	if in.ControlPlaneCanary != nil {
+		in, out := &in.ControlPlaneCanary, &out.ControlPlaneCanary
+		*out = new(RolloutCanary)
+		**out = **in
+	}
 	in.WorkerDrainOptions.DeepCopyInto(&out.WorkerDrainOptions)
+	if in.WorkerCanary != nil {
+		in, out := &in.WorkerCanary, &out.WorkerCanary
+		*out = new(RolloutCanary)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaintenanceWindowOverrideUntil != nil {
+		in, out := &in.MaintenanceWindowOverrideUntil, &out.MaintenanceWindowOverrideUntil
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -239,9 +260,9 @@ func (in *ETCDSnapshot) DeepCopyInto(out *ETCDSnapshot) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.SnapshotFile.DeepCopyInto(&out.SnapshotFile)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -372,6 +393,11 @@ func (in *ETCDSnapshotS3) DeepCopy() *ETCDSnapshotS3 {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshotSpec) DeepCopyInto(out *ETCDSnapshotSpec) {
 	*out = *in
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(ETCDSnapshotVerify)
+		**out = **in
+	}
 	return
 }
 
@@ -388,6 +414,11 @@ func (in *ETCDSnapshotSpec) DeepCopy() *ETCDSnapshotSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshotStatus) DeepCopyInto(out *ETCDSnapshotStatus) {
 	*out = *in
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(ETCDSnapshotVerification)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -401,6 +432,42 @@ func (in *ETCDSnapshotStatus) DeepCopy() *ETCDSnapshotStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotVerification) DeepCopyInto(out *ETCDSnapshotVerification) {
+	*out = *in
+	if in.VerifiedAt != nil {
+		in, out := &in.VerifiedAt, &out.VerifiedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotVerification.
+func (in *ETCDSnapshotVerification) DeepCopy() *ETCDSnapshotVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotVerify) DeepCopyInto(out *ETCDSnapshotVerify) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotVerify.
+func (in *ETCDSnapshotVerify) DeepCopy() *ETCDSnapshotVerify {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotVerify)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnvVar) DeepCopyInto(out *EnvVar) {
 	*out = *in
@@ -480,6 +547,27 @@ func (in *LocalClusterAuthEndpoint) DeepCopy() *LocalClusterAuthEndpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Mirror) DeepCopyInto(out *Mirror) {
 	*out = *in
@@ -1170,6 +1258,22 @@ func (in *RegistryConfig) DeepCopy() *RegistryConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutCanary) DeepCopyInto(out *RolloutCanary) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutCanary.
+func (in *RolloutCanary) DeepCopy() *RolloutCanary {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutCanary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RotateCertificates) DeepCopyInto(out *RotateCertificates) {
 	*out = *in
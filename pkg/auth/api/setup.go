@@ -10,6 +10,8 @@
 	"github.com/rancher/norman/types"
 	"github.com/rancher/rancher/pkg/api/scheme"
 	"github.com/rancher/rancher/pkg/auth/api/user"
+	"github.com/rancher/rancher/pkg/auth/lockout"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/principals"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	"github.com/rancher/rancher/pkg/auth/providers"
@@ -32,6 +34,10 @@ func User(ctx context.Context, schemas *types.Schemas, management *config.Scaled
 		UserClient:               management.Management.Users(""),
 		GlobalRoleBindingsClient: management.Management.GlobalRoleBindings(""),
 		UserAuthRefresher:        providerrefresh.NewUserAuthRefresher(ctx, management),
+		TOTPStore:                mfa.NewStore(management.Wrangler.Core.Secret(), management.Wrangler.Core.Secret().Cache()),
+		PasswordHistory:          &user.PasswordHistory{Secrets: management.Wrangler.Core.Secret()},
+		LoginAttempts:            lockout.NewTracker(management.Wrangler.Core.ConfigMap(), management.Wrangler.Core.ConfigMap().Cache(), lockout.SettingsPolicy),
+		UserAttributeLister:      management.Management.UserAttributes("").Controller().Lister(),
 	}
 
 	schema.Formatter = handler.UserFormatter
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	passwordHistoryPrefix = "password-history-"
+	passwordHistoryKey    = "hashes"
+)
+
+// PasswordHistory keeps the hashes of the previous passwords of local users in a secret per user in the auth secrets
+// namespace owned by the user, so that they can't be reused.
+type PasswordHistory struct {
+	Secrets corecontrollers.SecretClient
+}
+
+// errPasswordReused is returned when the new password of a user is one of its recent passwords.
+var errPasswordReused = errors.New("The new password must not be the same as a recent password")
+
+// check returns errPasswordReused if the password is the current password of the user or one of its previous
+// passwords, of which the last size-1 are checked. Nothing is checked with a size of 0, so that admins can still set
+// any password, as they could before the history was kept.
+func (h *PasswordHistory) check(user *v3.User, password string, size int) error {
+	if size <= 0 {
+		return nil
+	}
+	hashes := []string{user.Password}
+	if size > 1 {
+		previous, err := h.hashes(user.Name)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, previous...)
+		if len(hashes) > size {
+			hashes = hashes[:size]
+		}
+	}
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return errPasswordReused
+		}
+	}
+	return nil
+}
+
+// record adds the hash of the password which is being replaced to the history of the user, keeping the size-1 most
+// recent hashes.
+func (h *PasswordHistory) record(user *v3.User, previousHash string, size int) error {
+	if size <= 1 || previousHash == "" {
+		return nil
+	}
+	hashes, err := h.hashes(user.Name)
+	if err != nil {
+		return err
+	}
+	hashes = append([]string{previousHash}, hashes...)
+	if len(hashes) > size-1 {
+		hashes = hashes[:size-1]
+	}
+	data := map[string][]byte{passwordHistoryKey: []byte(strings.Join(hashes, "\n"))}
+
+	secret, err := h.Secrets.Get(common.SecretsNamespace, passwordHistoryPrefix+user.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = h.Secrets.Create(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      passwordHistoryPrefix + user.Name,
+				Namespace: common.SecretsNamespace,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "management.cattle.io/v3",
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				}},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+	secret = secret.DeepCopy()
+	secret.Data = data
+	_, err = h.Secrets.Update(secret)
+	return err
+}
+
+func (h *PasswordHistory) hashes(userName string) ([]string, error) {
+	secret, err := h.Secrets.Get(common.SecretsNamespace, passwordHistoryPrefix+userName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(secret.Data[passwordHistoryKey])), nil
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestPasswordHistory returns a password history backed by an in-memory map of secrets by name.
+func newTestPasswordHistory(t *testing.T) (*PasswordHistory, map[string]*corev1.Secret) {
+	ctrl := gomock.NewController(t)
+	secrets := map[string]*corev1.Secret{}
+
+	client := fake.NewMockClientInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ns, name string, _ metav1.GetOptions) (*corev1.Secret, error) {
+			assert.Equal(t, common.SecretsNamespace, ns)
+			if secret, ok := secrets[name]; ok {
+				return secret.DeepCopy(), nil
+			}
+			return nil, apierrors.NewNotFound(corev1.Resource("secrets"), name)
+		}).AnyTimes()
+	client.EXPECT().Create(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		secrets[secret.Name] = secret.DeepCopy()
+		return secret, nil
+	}).AnyTimes()
+	client.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		secrets[secret.Name] = secret.DeepCopy()
+		return secret, nil
+	}).AnyTimes()
+
+	return &PasswordHistory{Secrets: client}, secrets
+}
+
+// changeTestPassword changes the password of the user with updatePassword and the given history size.
+func changeTestPassword(t *testing.T, history *PasswordHistory, user *v3.User, password string, size int) error {
+	previousSize := settings.PasswordHistorySize.Get()
+	require.NoError(t, settings.PasswordHistorySize.Set(strconv.Itoa(size)))
+	defer settings.PasswordHistorySize.Set(previousSize)
+
+	h := &Handler{
+		UserClient: &fakes.UserInterfaceMock{
+			UpdateFunc: func(updated *v3.User) (*v3.User, error) {
+				return updated, nil
+			},
+		},
+		PasswordHistory: history,
+	}
+	updated, err := h.updatePassword(user, password)
+	if err != nil {
+		return err
+	}
+	assert.False(t, updated.MustChangePassword)
+	assert.NotNil(t, updated.Status.PasswordChangedAt)
+	*user = *updated
+	return nil
+}
+
+func TestPasswordHistory(t *testing.T) {
+	history, secrets := newTestPasswordHistory(t)
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abcde", UID: "uid"}}
+
+	for _, password := range []string{"first-password", "second-password", "third-password", "fourth-password"} {
+		require.NoError(t, changeTestPassword(t, history, user, password, 3))
+	}
+	require.Contains(t, secrets, "password-history-u-abcde")
+	assert.Equal(t, "u-abcde", secrets["password-history-u-abcde"].OwnerReferences[0].Name)
+
+	hashes, err := history.hashes(user.Name)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 2, "only the previous passwords which can't be reused are kept")
+
+	for _, password := range []string{"fourth-password", "third-password", "second-password"} {
+		assert.ErrorIs(t, history.check(user, password, 3), errPasswordReused)
+	}
+	assert.Error(t, changeTestPassword(t, history, user, "third-password", 3))
+	assert.NoError(t, history.check(user, "first-password", 3), "passwords older than the history can be reused")
+	assert.NoError(t, history.check(user, "third-password", 1), "only the current password is checked with a history of 1")
+}
+
+func TestPasswordHistoryDisabled(t *testing.T) {
+	history, secrets := newTestPasswordHistory(t)
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abcde"}}
+
+	require.NoError(t, changeTestPassword(t, history, user, "first-password", 0))
+	require.NoError(t, changeTestPassword(t, history, user, "second-password", 0))
+	assert.NoError(t, history.check(user, "first-password", 0))
+	assert.NoError(t, changeTestPassword(t, history, user, "second-password", 0), "admins can set the current password without a history")
+	assert.Empty(t, secrets)
+}
@@ -0,0 +1,89 @@
+package user
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rancher/pkg/settings"
+)
+
+// characterClasses are the classes of characters which the password policy can require, by name.
+var characterClasses = map[string]func(rune) bool{
+	"lower":  unicode.IsLower,
+	"upper":  unicode.IsUpper,
+	"digit":  unicode.IsDigit,
+	"symbol": func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r) },
+}
+
+// commonPasswords are passwords found at the top of leaked password lists, which are never allowed.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "123456789012", "1234567890123", "qwerty", "qwerty123",
+	"qwertyuiop", "qwertyuiop123", "1q2w3e4r", "1q2w3e4r5t6y", "1qaz2wsx3edc", "zaq12wsxcde3", "password",
+	"password1", "password12", "password123", "password1234", "password12345", "passw0rd", "p@ssw0rd", "p@ssword123",
+	"passwordpassword", "iloveyou", "iloveyou123", "admin", "admin123", "admin1234", "administrator",
+	"administrator1", "rancher", "rancher123", "rancheradmin", "rancher-admin", "changeme", "changeme123",
+	"changemenow", "welcome", "welcome123", "welcome1234", "letmein", "letmein123", "letmeinplease", "monkey",
+	"dragon", "football", "baseball", "princess", "sunshine", "superman", "trustno1", "abc123", "abcdefghijkl",
+	"abcdefg12345", "abc123456789", "aaaaaaaaaaaa", "111111111111", "000000000000", "987654321098",
+	"correcthorsebatterystaple", "kubernetes", "kubernetes123", "default", "secret", "secret123",
+}
+
+// passwordPolicy is the complexity required of local user passwords, on top of their minimum length.
+type passwordPolicy struct {
+	requiredClasses []string
+	blocklist       map[string]bool
+}
+
+// passwordPolicyFromSettings returns the policy configured by the password-required-character-classes and
+// password-blocklist settings.
+func passwordPolicyFromSettings() (passwordPolicy, error) {
+	policy := passwordPolicy{
+		blocklist: map[string]bool{},
+	}
+	for _, class := range strings.Split(settings.PasswordRequiredCharacterClasses.Get(), ",") {
+		class = strings.ToLower(strings.TrimSpace(class))
+		if class == "" {
+			continue
+		}
+		if _, ok := characterClasses[class]; !ok {
+			return policy, errors.Errorf("invalid character class %s in setting %s", class, settings.PasswordRequiredCharacterClasses.Name)
+		}
+		policy.requiredClasses = append(policy.requiredClasses, class)
+	}
+	for _, password := range commonPasswords {
+		policy.blocklist[password] = true
+	}
+	for _, password := range strings.FieldsFunc(settings.PasswordBlocklist.Get(), func(r rune) bool { return r == '\n' || r == ',' }) {
+		if password = strings.TrimSpace(password); password != "" {
+			policy.blocklist[strings.ToLower(password)] = true
+		}
+	}
+	return policy, nil
+}
+
+// validate returns an error describing the first requirement of the policy which the password doesn't meet.
+func (p passwordPolicy) validate(password string) error {
+	if p.blocklist[strings.ToLower(password)] {
+		return errors.New("Password is too common, choose a less predictable password")
+	}
+	for _, class := range p.requiredClasses {
+		if strings.IndexFunc(password, characterClasses[class]) < 0 {
+			return errors.Errorf("Password must contain at least one %s character", class)
+		}
+	}
+	return nil
+}
+
+// validatePasswordPolicy validates the password against the length and username rules of validatePassword, then
+// against the configured password policy.
+func validatePasswordPolicy(username, currentPass, pass string) error {
+	if err := validatePassword(username, currentPass, pass, settings.PasswordMinLength.GetInt()); err != nil {
+		return err
+	}
+	policy, err := passwordPolicyFromSettings()
+	if err != nil {
+		return err
+	}
+	return policy.validate(pass)
+}
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		classes    string
+		blocklist  string
+		password   string
+		expectsErr bool
+	}{
+		{
+			name:     "no required classes",
+			password: "lowercaseonly",
+		},
+		{
+			name:       "common password",
+			password:   "Password1234",
+			expectsErr: true,
+		},
+		{
+			name:       "blocklisted password",
+			blocklist:  "first-password\nCompanyName2023, other-password",
+			password:   "companyname2023",
+			expectsErr: true,
+		},
+		{
+			name:      "password not in blocklist",
+			blocklist: "first-password\nCompanyName2023",
+			password:  "companyname2024",
+		},
+		{
+			name:     "all classes",
+			classes:  "lower,upper,digit,symbol",
+			password: "Abcdefghijk1!",
+		},
+		{
+			name:       "missing digit",
+			classes:    "lower, upper, digit",
+			password:   "Abcdefghijkl",
+			expectsErr: true,
+		},
+		{
+			name:       "missing symbol",
+			classes:    "symbol",
+			password:   "Abcdefghijk1",
+			expectsErr: true,
+		},
+		{
+			name:     "unicode classes",
+			classes:  "lower,upper",
+			password: "Абвгдеёжзий1",
+		},
+		{
+			name:       "invalid class",
+			classes:    "lower,emoji",
+			password:   "abcdefghijkl",
+			expectsErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, settings.PasswordRequiredCharacterClasses.Set(test.classes))
+			require.NoError(t, settings.PasswordBlocklist.Set(test.blocklist))
+			defer func() {
+				_ = settings.PasswordRequiredCharacterClasses.Set("")
+				_ = settings.PasswordBlocklist.Set("")
+			}()
+
+			err := validatePasswordPolicy("admin", "currentpassword", test.password)
+			if test.expectsErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
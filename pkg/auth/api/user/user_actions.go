@@ -3,18 +3,26 @@
 import (
 	"net/http"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/parse"
 	"github.com/rancher/norman/types"
+	"github.com/rancher/norman/types/convert"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/lockout"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 )
 
 func (h *Handler) UserFormatter(apiContext *types.APIContext, resource *types.RawResource) {
@@ -23,6 +31,20 @@ func (h *Handler) UserFormatter(apiContext *types.APIContext, resource *types.Ra
 	if canRefresh := h.userCanRefresh(apiContext); canRefresh {
 		resource.AddAction(apiContext, "refreshauthprovideraccess")
 	}
+
+	enabled, err := h.TOTPStore.EnabledFromCache(resource.ID)
+	if err != nil {
+		logrus.Warnf("Failed to get the TOTP status of user %s: %v", resource.ID, err)
+	}
+	resource.Values[client.UserFieldMFAEnabled] = enabled
+	if enabled && h.userCanUpdate(apiContext) {
+		resource.AddAction(apiContext, "resettotp")
+	}
+
+	lockedUntil, _ := time.Parse(time.RFC3339, convert.ToString(resource.Values[client.UserFieldLockedUntil]))
+	if lockedUntil.After(time.Now()) && h.userCanUpdate(apiContext) {
+		resource.AddAction(apiContext, "unlock")
+	}
 }
 
 func (h *Handler) CollectionFormatter(apiContext *types.APIContext, collection *types.GenericCollection) {
@@ -30,12 +52,19 @@ func (h *Handler) CollectionFormatter(apiContext *types.APIContext, collection *
 	if canRefresh := h.userCanRefresh(apiContext); canRefresh {
 		collection.AddAction(apiContext, "refreshauthprovideraccess")
 	}
+	collection.AddAction(apiContext, "enrolltotp")
+	collection.AddAction(apiContext, "activatetotp")
+	collection.AddAction(apiContext, "disabletotp")
 }
 
 type Handler struct {
 	UserClient               v3.UserInterface
 	GlobalRoleBindingsClient v3.GlobalRoleBindingInterface
 	UserAuthRefresher        providerrefresh.UserAuthRefresher
+	TOTPStore                *mfa.Store
+	LoginAttempts            *lockout.Tracker
+	UserAttributeLister      v3.UserAttributeLister
+	PasswordHistory          *PasswordHistory
 }
 
 func (h *Handler) Actions(actionName string, action *types.Action, apiContext *types.APIContext) error {
@@ -52,6 +81,26 @@ func (h *Handler) Actions(actionName string, action *types.Action, apiContext *t
 		if err := h.refreshAttributes(actionName, action, apiContext); err != nil {
 			return err
 		}
+	case "enrolltotp":
+		if err := h.enrollTOTP(actionName, action, apiContext); err != nil {
+			return err
+		}
+	case "activatetotp":
+		if err := h.activateTOTP(actionName, action, apiContext); err != nil {
+			return err
+		}
+	case "disabletotp":
+		if err := h.disableTOTP(actionName, action, apiContext); err != nil {
+			return err
+		}
+	case "resettotp":
+		if err := h.resetTOTP(actionName, action, apiContext); err != nil {
+			return err
+		}
+	case "unlock":
+		if err := h.unlock(actionName, action, apiContext); err != nil {
+			return err
+		}
 	default:
 		return errors.Errorf("bad action %v", actionName)
 	}
@@ -95,7 +144,7 @@ func (h *Handler) changePassword(actionName string, action *types.Action, reques
 		return err
 	}
 
-	if err := validatePassword(user.Username, currentPass, newPass, settings.PasswordMinLength.GetInt()); err != nil {
+	if err := validatePasswordPolicy(user.Username, currentPass, newPass); err != nil {
 		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
 	}
 
@@ -103,22 +152,17 @@ func (h *Handler) changePassword(actionName string, action *types.Action, reques
 		return httperror.NewAPIError(httperror.InvalidBodyContent, "invalid current password")
 	}
 
-	newPassHash, err := HashPasswordString(newPass)
-	if err != nil {
-		return err
-	}
-
-	user.Password = newPassHash
-	user.MustChangePassword = false
-	user, err = h.UserClient.Update(user)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, err = h.updatePassword(user, newPass)
+	return err
 }
 
 func (h *Handler) setPassword(actionName string, action *types.Action, request *types.APIContext) error {
+	// the password is set with Rancher's own client rather than the impersonating store, so the caller must be allowed
+	// to update the user, not just to get it
+	if !h.userCanUpdate(request) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "Not Allowed")
+	}
+
 	actionInput, err := parse.ReadBody(request.Request)
 	if err != nil {
 		return err
@@ -129,7 +173,7 @@ func (h *Handler) setPassword(actionName string, action *types.Action, request *
 		return errors.New("no user store available")
 	}
 
-	userData, err := store.ByID(request, request.Schema, request.ID)
+	user, err := h.UserClient.Get(request.ID, v1.GetOptions{})
 	if err != nil {
 		return err
 	}
@@ -139,21 +183,16 @@ func (h *Handler) setPassword(actionName string, action *types.Action, request *
 		return errors.New("Invalid password")
 	}
 
-	username := userData[client.UserFieldUsername].(string)
-
 	// passing empty currentPass to validator since, this api call doesn't assume an existing password
-	if err := validatePassword(username, "", newPass, settings.PasswordMinLength.GetInt()); err != nil {
+	if err := validatePasswordPolicy(user.Username, "", newPass); err != nil {
 		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
 	}
 
-	userData[client.UserFieldPassword] = newPass
-	if err := hashPassword(userData); err != nil {
+	if _, err := h.updatePassword(user, newPass); err != nil {
 		return err
 	}
-	userData[client.UserFieldMustChangePassword] = false
-	delete(userData, "me")
 
-	userData, err = store.Update(request, request.Schema, userData, request.ID)
+	userData, err := store.ByID(request, request.Schema, request.ID)
 	if err != nil {
 		return err
 	}
@@ -162,6 +201,37 @@ func (h *Handler) setPassword(actionName string, action *types.Action, request *
 	return nil
 }
 
+// updatePassword sets the password of the user, unless it is one of the recent passwords of the user, and clears
+// MustChangePassword.
+func (h *Handler) updatePassword(user *v32.User, newPass string) (*v32.User, error) {
+	historySize := settings.PasswordHistorySize.GetInt()
+	if err := h.PasswordHistory.check(user, newPass, historySize); errors.Is(err, errPasswordReused) {
+		return nil, httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+
+	newPassHash, err := HashPasswordString(newPass)
+	if err != nil {
+		return nil, err
+	}
+
+	previousHash := user.Password
+	user = user.DeepCopy()
+	user.Password = newPassHash
+	user.MustChangePassword = false
+	user.Status.PasswordChangedAt = &v1.Time{Time: time.Now()}
+	updated, err := h.UserClient.Update(user)
+	if err != nil {
+		return nil, err
+	}
+	// the history is only recorded once the password was changed, and failing to record it doesn't undo the change
+	if err := h.PasswordHistory.record(updated, previousHash, historySize); err != nil {
+		logrus.Errorf("Failed to record the password history of user %s: %v", updated.Name, err)
+	}
+	return updated, nil
+}
+
 func (h *Handler) refreshAttributes(actionName string, action *types.Action, request *types.APIContext) error {
 	canRefresh := h.userCanRefresh(request)
 
@@ -183,6 +253,201 @@ func (h *Handler) userCanRefresh(request *types.APIContext) bool {
 	return request.AccessControl.CanDo(v3.UserGroupVersionKind.Group, v3.UserResource.Name, "create", request, nil, request.Schema) == nil
 }
 
+// enrollTOTP generates a new TOTP secret for the current user, which has to be activated with a code before it is
+// required to log in.
+func (h *Handler) enrollTOTP(actionName string, action *types.Action, request *types.APIContext) error {
+	user, err := h.currentLocalUser(request)
+	if err != nil {
+		return err
+	}
+
+	secret, uri, err := h.TOTPStore.Enroll(user)
+	if errors.Is(err, mfa.ErrAlreadyEnabled) {
+		return httperror.NewAPIError(httperror.InvalidState, err.Error())
+	} else if err != nil {
+		return err
+	}
+
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		"type":                           client.TOTPEnrollmentType,
+		client.TOTPEnrollmentFieldSecret: secret,
+		client.TOTPEnrollmentFieldProvisioningURI: uri,
+	})
+	return nil
+}
+
+// activateTOTP enables TOTP for the current user if the code of its enrollment is valid, and returns its recovery
+// codes.
+func (h *Handler) activateTOTP(actionName string, action *types.Action, request *types.APIContext) error {
+	actionInput, err := parse.ReadBody(request.Request)
+	if err != nil {
+		return err
+	}
+	code, _ := actionInput[client.TOTPCodeInputFieldCode].(string)
+	if code == "" {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, "must specify code")
+	}
+
+	user, err := h.currentLocalUser(request)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := h.TOTPStore.Activate(user.Name, code)
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	case errors.Is(err, mfa.ErrNotEnrolled), errors.Is(err, mfa.ErrAlreadyEnabled):
+		return httperror.NewAPIError(httperror.InvalidState, err.Error())
+	case err != nil:
+		return err
+	}
+	if err := h.setMFAEnabled(user.Name, true); err != nil {
+		return err
+	}
+
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		"type": client.TOTPRecoveryCodesType,
+		client.TOTPRecoveryCodesFieldRecoveryCodes: recoveryCodes,
+	})
+	return nil
+}
+
+// disableTOTP disables TOTP for the current user, which requires a valid code or recovery code.
+func (h *Handler) disableTOTP(actionName string, action *types.Action, request *types.APIContext) error {
+	actionInput, err := parse.ReadBody(request.Request)
+	if err != nil {
+		return err
+	}
+	code, _ := actionInput[client.TOTPCodeInputFieldCode].(string)
+	recoveryCode, _ := actionInput[client.TOTPCodeInputFieldRecoveryCode].(string)
+	if code == "" && recoveryCode == "" {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, "must specify code or recoveryCode")
+	}
+
+	user, err := h.currentLocalUser(request)
+	if err != nil {
+		return err
+	}
+
+	if err := h.TOTPStore.Verify(user.Name, code, recoveryCode); errors.Is(err, mfa.ErrInvalidCode) {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	} else if err != nil {
+		return err
+	}
+	if err := h.TOTPStore.Disable(user.Name); err != nil {
+		return err
+	}
+	if err := h.setMFAEnabled(user.Name, false); err != nil {
+		return err
+	}
+
+	request.WriteResponse(http.StatusOK, nil)
+	return nil
+}
+
+// resetTOTP disables TOTP for a user who lost both their authenticator and recovery codes.
+func (h *Handler) resetTOTP(actionName string, action *types.Action, request *types.APIContext) error {
+	if !h.userCanUpdate(request) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "Not Allowed")
+	}
+
+	if err := h.TOTPStore.Disable(request.ID); err != nil {
+		return err
+	}
+	if err := h.setMFAEnabled(request.ID, false); err != nil {
+		return err
+	}
+
+	userData, err := request.Schema.Store.ByID(request, request.Schema, request.ID)
+	if err != nil {
+		return err
+	}
+	request.WriteResponse(http.StatusOK, userData)
+	return nil
+}
+
+// setMFAEnabled keeps the MFAEnabled field of the user in line with its TOTP secret, so that it can be read from the
+// user by API clients which don't go through the user formatter.
+func (h *Handler) setMFAEnabled(userName string, enabled bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		user, err := h.UserClient.Get(userName, v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if user.MFAEnabled == enabled {
+			return nil
+		}
+		user = user.DeepCopy()
+		user.MFAEnabled = enabled
+		_, err = h.UserClient.Update(user)
+		return err
+	})
+}
+
+// unlock ends the lockout of a user after too many failed logins, for all the usernames it logs in with.
+func (h *Handler) unlock(actionName string, action *types.Action, request *types.APIContext) error {
+	if !h.userCanUpdate(request) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "Not Allowed")
+	}
+
+	user, err := h.UserClient.Get(request.ID, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	attribs, err := h.UserAttributeLister.Get("", user.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	for provider, usernames := range lockout.Usernames(user, attribs) {
+		for _, username := range usernames {
+			if err := h.LoginAttempts.Unlock(provider, username); err != nil {
+				return err
+			}
+		}
+	}
+	if user.Status.LockedUntil != nil {
+		user = user.DeepCopy()
+		user.Status.LockedUntil = nil
+		if _, err := h.UserClient.Update(user); err != nil {
+			return err
+		}
+	}
+
+	userData, err := request.Schema.Store.ByID(request, request.Schema, request.ID)
+	if err != nil {
+		return err
+	}
+	request.WriteResponse(http.StatusOK, userData)
+	return nil
+}
+
+// userCanUpdate returns true if the user can update users, which is required to reset TOTP or unlock users as both
+// let anyone who knows the password of a user log in as them.
+func (h *Handler) userCanUpdate(request *types.APIContext) bool {
+	return request.AccessControl.CanDo(v3.UserGroupVersionKind.Group, v3.UserResource.Name, "update", request, nil, request.Schema) == nil
+}
+
+// currentLocalUser returns the user making the request, which must be a local user as TOTP is only used by the local
+// auth provider.
+func (h *Handler) currentLocalUser(request *types.APIContext) (*v32.User, error) {
+	userID := request.Request.Header.Get("Impersonate-User")
+	if userID == "" {
+		return nil, errors.New("can't find user")
+	}
+
+	user, err := h.UserClient.Get(userID, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, principalID := range user.PrincipalIDs {
+		if strings.HasPrefix(principalID, "local://") {
+			return user, nil
+		}
+	}
+	return nil, httperror.NewAPIError(httperror.InvalidAction, "TOTP is only supported for local users")
+}
+
 // validatePassword will ensure a password is at least the minimum required length in runes,
 // that the username and password do not match, and that the new password is not the same as the current password.
 func validatePassword(user string, currentPass string, pass string, minPassLen int) error {
@@ -1,7 +1,21 @@
 package user
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/types"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestValidatePassword(t *testing.T) {
@@ -75,3 +89,91 @@ func TestValidatePassword(t *testing.T) {
 	}
 
 }
+
+func TestSetMFAEnabled(t *testing.T) {
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-1"}}
+	var updates int
+	h := &Handler{
+		UserClient: &fakes.UserInterfaceMock{
+			GetFunc: func(name string, _ metav1.GetOptions) (*v3.User, error) {
+				return user, nil
+			},
+			UpdateFunc: func(updated *v3.User) (*v3.User, error) {
+				updates++
+				user = updated
+				return updated, nil
+			},
+		},
+	}
+
+	require.NoError(t, h.setMFAEnabled("u-1", true))
+	assert.True(t, user.MFAEnabled)
+	require.NoError(t, h.setMFAEnabled("u-1", true))
+	assert.Equal(t, 1, updates, "the user isn't updated when the field is already set")
+	require.NoError(t, h.setMFAEnabled("u-1", false))
+	assert.False(t, user.MFAEnabled)
+	assert.Equal(t, 2, updates)
+}
+
+// updateAccessControl only allows the verbs it is given.
+type updateAccessControl struct {
+	types.AccessControl
+	verbs []string
+}
+
+func (a *updateAccessControl) CanDo(_, _, verb string, _ *types.APIContext, _ map[string]interface{}, _ *types.Schema) error {
+	for _, v := range a.verbs {
+		if v == verb {
+			return nil
+		}
+	}
+	return httperror.NewAPIError(httperror.PermissionDenied, "can't "+verb)
+}
+
+func TestSetPasswordRequiresUpdate(t *testing.T) {
+	h := &Handler{
+		UserClient: &fakes.UserInterfaceMock{
+			GetFunc: func(name string, _ metav1.GetOptions) (*v3.User, error) {
+				t.Fatal("the user must not be read without update permission")
+				return nil, nil
+			},
+			UpdateFunc: func(updated *v3.User) (*v3.User, error) {
+				t.Fatal("the user must not be updated without update permission")
+				return nil, nil
+			},
+		},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v3/users/u-1?action=setpassword", strings.NewReader(`{"newPassword":"a-new-long-password"}`))
+	request := &types.APIContext{
+		ID:            "u-1",
+		Request:       req,
+		Schema:        &types.Schema{},
+		AccessControl: &updateAccessControl{verbs: []string{"get"}},
+	}
+
+	err := h.setPassword("setpassword", nil, request)
+	var apiErr *httperror.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, httperror.PermissionDenied, apiErr.Code)
+}
+
+func TestUpdatePasswordRecordsHistoryAfterUpdate(t *testing.T) {
+	previousSize := settings.PasswordHistorySize.Get()
+	require.NoError(t, settings.PasswordHistorySize.Set("3"))
+	defer settings.PasswordHistorySize.Set(previousSize)
+
+	history, secrets := newTestPasswordHistory(t)
+	h := &Handler{
+		UserClient: &fakes.UserInterfaceMock{
+			UpdateFunc: func(updated *v3.User) (*v3.User, error) {
+				return nil, apierrors.NewConflict(v3.Resource("users"), updated.Name, errors.New("conflict"))
+			},
+		},
+		PasswordHistory: history,
+	}
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-1"}, Password: "$2a$10$previous"}
+
+	_, err := h.updatePassword(user, "a-new-long-password")
+	assert.True(t, apierrors.IsConflict(err))
+	assert.Empty(t, secrets, "the history isn't recorded when the user isn't updated")
+}
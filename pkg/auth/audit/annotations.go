@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+type annotationsKeyType struct{}
+
+var annotationsKey annotationsKeyType
+
+// annotations are added to the audit log of a request by the handlers serving it, to record events such as failed
+// logins which can't be told from the request and response alone.
+type annotations struct {
+	lock   sync.Mutex
+	values map[string]string
+}
+
+func withAnnotations(ctx context.Context) (context.Context, *annotations) {
+	a := &annotations{}
+	return context.WithValue(ctx, annotationsKey, a), a
+}
+
+// AddAnnotation adds an annotation to the audit log of the request of ctx. It is a no-op if the request isn't audited.
+func AddAnnotation(ctx context.Context, key, value string) {
+	a, ok := ctx.Value(annotationsKey).(*annotations)
+	if !ok {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.values == nil {
+		a.values = map[string]string{}
+	}
+	a.values[key] = value
+}
+
+// get returns a copy of the annotations, or nil if there are none.
+func (a *annotations) get() map[string]string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(a.values) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(a.values))
+	for k, v := range a.values {
+		values[k] = v
+	}
+	return values
+}
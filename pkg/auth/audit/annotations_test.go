@@ -0,0 +1,24 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAnnotation(t *testing.T) {
+	// requests which aren't audited are ignored
+	AddAnnotation(context.Background(), "key", "value")
+
+	ctx, annotations := withAnnotations(context.Background())
+	assert.Nil(t, annotations.get())
+
+	AddAnnotation(ctx, "auth.cattle.io/login-failure", "invalid-credentials")
+	AddAnnotation(ctx, "auth.cattle.io/locked-until", "2023-01-01T00:00:00Z")
+	AddAnnotation(ctx, "auth.cattle.io/login-failure", "locked")
+	assert.Equal(t, map[string]string{
+		"auth.cattle.io/login-failure": "locked",
+		"auth.cattle.io/locked-until":  "2023-01-01T00:00:00Z",
+	}, annotations.get())
+}
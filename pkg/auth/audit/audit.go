@@ -3,10 +3,12 @@
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
 	"compress/zlib"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -62,22 +64,29 @@ type auditLog struct {
 	writer            *LogWriter
 	reqBody           []byte
 	keysToRedactRegex *regexp.Regexp
+	// level is set when an audit policy rule matched the request and overrides the writer's level.
+	level Level
+	// resource is the API resource type of the request, used to scope redaction rules.
+	resource    string
+	redaction   *RedactionRules
+	annotations *annotations
 }
 
 type log struct {
-	AuditID           k8stypes.UID `json:"auditID,omitempty"`
-	RequestURI        string       `json:"requestURI,omitempty"`
-	User              *User        `json:"user,omitempty"`
-	Method            string       `json:"method,omitempty"`
-	RemoteAddr        string       `json:"remoteAddr,omitempty"`
-	RequestTimestamp  string       `json:"requestTimestamp,omitempty"`
-	ResponseTimestamp string       `json:"responseTimestamp,omitempty"`
-	ResponseCode      int          `json:"responseCode,omitempty"`
-	RequestHeader     http.Header  `json:"requestHeader,omitempty"`
-	ResponseHeader    http.Header  `json:"responseHeader,omitempty"`
-	RequestBody       []byte       `json:"requestBody,omitempty"`
-	ResponseBody      []byte       `json:"responseBody,omitempty"`
-	UserLoginName     string       `json:"userLoginName,omitempty"`
+	AuditID           k8stypes.UID      `json:"auditID,omitempty"`
+	RequestURI        string            `json:"requestURI,omitempty"`
+	User              *User             `json:"user,omitempty"`
+	Method            string            `json:"method,omitempty"`
+	RemoteAddr        string            `json:"remoteAddr,omitempty"`
+	RequestTimestamp  string            `json:"requestTimestamp,omitempty"`
+	ResponseTimestamp string            `json:"responseTimestamp,omitempty"`
+	ResponseCode      int               `json:"responseCode,omitempty"`
+	RequestHeader     http.Header       `json:"requestHeader,omitempty"`
+	ResponseHeader    http.Header       `json:"responseHeader,omitempty"`
+	RequestBody       []byte            `json:"requestBody,omitempty"`
+	ResponseBody      []byte            `json:"responseBody,omitempty"`
+	UserLoginName     string            `json:"userLoginName,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
 }
 
 var userKey struct{}
@@ -130,22 +139,31 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp
 		},
 		keysToRedactRegex: keysToRedactRegex,
 	}
+	if level, ok := levelFrom(req.Context()); ok {
+		auditLog.level = level
+	}
+	_, auditLog.resource = verbAndResource(req)
 
 	contentType := req.Header.Get("Content-Type")
 	loginReq := isLoginRequest(req.RequestURI)
-	if writer.Level >= LevelRequest || loginReq {
+	if auditLog.logLevel() >= LevelRequest || loginReq {
 		if bodyMethods[req.Method] && strings.HasPrefix(contentType, contentTypeJSON) {
 			reqBody, err := readBodyWithoutLosingContent(req)
 			if err != nil {
 				return nil, err
 			}
+			if reqBody, err = decodeBody(req.Header.Get("Content-Encoding"), reqBody); err != nil {
+				// the body is left out of the record rather than risking logging it unredacted
+				logrus.Debugf("auditLog: omitting request body for requestURI [%s]: %v", req.RequestURI, err)
+				reqBody = nil
+			}
 			if loginReq {
 				loginName := getUserNameForBasicLogin(reqBody)
 				if loginName != "" {
 					auditLog.log.UserLoginName = loginName
 				}
 			}
-			if writer.Level >= LevelRequest {
+			if auditLog.logLevel() >= LevelRequest {
 				auditLog.reqBody = reqBody
 			}
 		}
@@ -153,12 +171,23 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp
 	return auditLog, nil
 }
 
+// logLevel returns the level the request is audited at.
+func (a *auditLog) logLevel() Level {
+	if a.level != LevelNull {
+		return a.level
+	}
+	return a.writer.Level
+}
+
 func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, resCode int, resBody []byte) error {
 	a.log.User = userInfo
 	a.log.ResponseTimestamp = time.Now().Format(time.RFC3339)
-	a.log.RequestHeader = filterOutHeaders(reqHeaders, sensitiveRequestHeader)
-	a.log.ResponseHeader = filterOutHeaders(resHeaders, sensitiveResponseHeader)
+	a.log.RequestHeader = a.redaction.filterHeaders(filterOutHeaders(reqHeaders, sensitiveRequestHeader), a.resource)
+	a.log.ResponseHeader = a.redaction.filterHeaders(filterOutHeaders(resHeaders, sensitiveResponseHeader), a.resource)
 	a.log.ResponseCode = resCode
+	if a.annotations != nil {
+		a.log.Annotations = a.annotations.get()
+	}
 
 	if a.log.UserLoginName != "" {
 		if a.log.User.Extra == nil {
@@ -203,7 +232,7 @@ func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, res
 
 // writeRequest attempts to write the API request to the log message.
 func (a *auditLog) writeRequest(buf *bytes.Buffer) {
-	if a.writer.Level < LevelRequest || len(a.reqBody) == 0 {
+	if a.logLevel() < LevelRequest || len(a.reqBody) == 0 {
 		return
 	}
 
@@ -213,24 +242,11 @@ func (a *auditLog) writeRequest(buf *bytes.Buffer) {
 
 // writeResponse attempt to write the API response to the log message.
 func (a *auditLog) writeResponse(buf *bytes.Buffer, resHeaders http.Header, resBody []byte) (err error) {
-	if a.writer.Level < LevelRequestResponse || resHeaders.Get("Content-Type") != contentTypeJSON || len(resBody) == 0 {
+	if a.logLevel() < LevelRequestResponse || !strings.HasPrefix(resHeaders.Get("Content-Type"), contentTypeJSON) || len(resBody) == 0 {
 		return nil
 	}
 
-	switch resHeaders.Get("Content-Encoding") {
-	case contentEncodingGZIP:
-		resBody, err = decompressGZIP(resBody)
-	case contentEncodingZLib:
-		resBody, err = decompressZLib(resBody)
-	case "none":
-		// do nothing message is not encoded
-	case "":
-		// do nothing message is not encoded
-	default:
-		err = fmt.Errorf("%w '%s' in response header", ErrUnsupportedEncoding, resHeaders.Get("Content-Encoding"))
-	}
-
-	if err != nil {
+	if resBody, err = decodeBody(resHeaders.Get("Content-Encoding"), resBody); err != nil {
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
 
@@ -240,6 +256,21 @@ func (a *auditLog) writeResponse(buf *bytes.Buffer, resHeaders http.Header, resB
 	return nil
 }
 
+// decodeBody decompresses a body according to its Content-Encoding header.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case contentEncodingGZIP:
+		return decompressGZIP(body)
+	case contentEncodingZLib:
+		return decompressZLib(body)
+	case "none", "", "identity":
+		// do nothing message is not encoded
+		return body, nil
+	default:
+		return nil, fmt.Errorf("%w '%s' in header", ErrUnsupportedEncoding, encoding)
+	}
+}
+
 func isLoginRequest(uri string) bool {
 	return strings.Contains(uri, "?action=login")
 }
@@ -296,8 +327,11 @@ func (a *auditLog) redactSensitiveData(requestURI string, body []byte) []byte {
 		changed = redact(m, "config")
 	}
 
+	// Redact values matching the admin defined redaction rules.
+	changed = a.redaction.redactBody(a.resource, m) || changed
+
 	// Redact values for data considered sensitive: passwords, tokens, etc.
-	if !a.redactMap(m) && !changed {
+	if !redactKeys(m, a.keysToRedactRegex) && !changed {
 		return body
 	}
 
@@ -389,22 +423,23 @@ func redactSecret(secret map[string]interface{}) bool {
 	return changed
 }
 
-func (a *auditLog) redactMap(m map[string]interface{}) bool {
+// redactKeys redacts the string values of all keys in m, and in the maps and slices nested in it, which match keysRegex.
+func redactKeys(m map[string]interface{}, keysRegex *regexp.Regexp) bool {
 	var changed bool
 	for key := range m {
 		switch val := m[key].(type) {
 		case string:
-			if a.keysToRedactRegex.MatchString(key) {
+			if keysRegex.MatchString(key) {
 				changed = true
 				m[key] = redacted
 			}
 		case map[string]interface{}:
-			if a.redactMap(val) {
+			if redactKeys(val, keysRegex) {
 				changed = true
 				m[key] = val
 			}
 		case []interface{}:
-			if a.redactSlice(val) {
+			if redactSliceKeys(val, keysRegex) {
 				changed = true
 				m[key] = val
 			}
@@ -414,12 +449,12 @@ func (a *auditLog) redactMap(m map[string]interface{}) bool {
 	return changed
 }
 
-func (a *auditLog) redactSlice(valSlice []interface{}) bool {
+func redactSliceKeys(valSlice []interface{}, keysRegex *regexp.Regexp) bool {
 	var changed bool
 	for i, v := range valSlice {
 		switch val := v.(type) {
 		case map[string]interface{}:
-			if a.redactMap(val) {
+			if redactKeys(val, keysRegex) {
 				changed = true
 				valSlice[i] = val
 			}
@@ -429,7 +464,7 @@ func (a *auditLog) redactSlice(valSlice []interface{}) bool {
 			if i+1 == len(valSlice) {
 				continue
 			}
-			if !strings.HasPrefix(val, "--") || !a.keysToRedactRegex.MatchString(val) {
+			if !strings.HasPrefix(val, "--") || !keysRegex.MatchString(val) {
 				// not a sensitive option flag
 				continue
 			}
@@ -451,6 +486,10 @@ func decompressGZIP(data []byte) ([]byte, error) {
 
 func decompressZLib(data []byte) ([]byte, error) {
 	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if errors.Is(err, zlib.ErrHeader) {
+		// Some servers send raw deflate data without the zlib wrapper required by RFC 9110.
+		return decompress(flate.NewReader(bytes.NewReader(data)))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zlib reader: %w", err)
 	}
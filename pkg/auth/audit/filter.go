@@ -15,6 +15,7 @@
 
 	"github.com/rancher/rancher/pkg/auth/util"
 	"github.com/rancher/rancher/pkg/data/management"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,6 +32,8 @@ func NewAuditLogMiddleware(auditWriter *LogWriter) (func(http.Handler) http.Hand
 			sanitizingRegex: sensitiveRegex,
 			errMap:          make(map[string]time.Time),
 			errLock:         &sync.Mutex{},
+			policy:          newSettingLoader(settings.AuditLogPolicy, ParsePolicy),
+			redaction:       newSettingLoader(settings.AuditLogRedactionRules, ParseRedactionRules),
 		}
 	}, err
 }
@@ -60,6 +63,51 @@ type auditHandler struct {
 	sanitizingRegex *regexp.Regexp
 	errMap          map[string]time.Time
 	errLock         *sync.Mutex
+	policy          *settingLoader[*Policy]
+	redaction       *settingLoader[*RedactionRules]
+}
+
+// settingLoader parses the value of a setting, re-parsing it only when the value changes.
+type settingLoader[T any] struct {
+	setting settings.Setting
+	parse   func([]byte) (T, error)
+
+	lock  sync.Mutex
+	raw   string
+	value T
+}
+
+func newSettingLoader[T any](setting settings.Setting, parse func([]byte) (T, error)) *settingLoader[T] {
+	return &settingLoader[T]{
+		setting: setting,
+		parse:   parse,
+	}
+}
+
+// get returns the parsed value of the setting, or the zero value if the setting is empty. If the setting is invalid,
+// the last valid value is kept, so a bad edit doesn't drop the rules which were in effect.
+func (s *settingLoader[T]) get() T {
+	raw := strings.TrimSpace(s.setting.Get())
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if raw == s.raw {
+		return s.value
+	}
+
+	s.raw = raw
+	if raw == "" {
+		var zero T
+		s.value = zero
+		return zero
+	}
+	value, err := s.parse([]byte(raw))
+	if err != nil {
+		logrus.Errorf("auditLog: ignoring invalid setting %s, keeping the last valid value: %v", s.setting.Name, err)
+		return s.value
+	}
+	s.value = value
+	return value
 }
 
 func (h auditHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -71,6 +119,17 @@ func (h auditHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	user := getUserInfo(req)
 
 	context := context.WithValue(req.Context(), userKey, user)
+	// the level of the matching policy rule overrides the level of the writer, in either direction
+	level := h.auditWriter.Level
+	if policyLevel, ok := h.policy.get().levelFor(newRequestAttributes(req, user)); ok {
+		level = policyLevel
+	}
+	if level == LevelNull {
+		h.next.ServeHTTP(rw, req.WithContext(context))
+		return
+	}
+	context = withLevel(context, level)
+	context, annotations := withAnnotations(context)
 	req = req.WithContext(context)
 
 	auditLog, err := newAuditLog(h.auditWriter, req, h.sanitizingRegex)
@@ -78,6 +137,8 @@ func (h auditHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		util.ReturnHTTPError(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	auditLog.redaction = h.redaction.get()
+	auditLog.annotations = annotations
 
 	wr := &wrapWriter{ResponseWriter: rw, auditWriter: h.auditWriter, statusCode: http.StatusOK}
 	h.next.ServeHTTP(wr, req)
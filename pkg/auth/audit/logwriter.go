@@ -2,37 +2,111 @@
 
 import (
 	"context"
+	"io"
+	"strings"
+	"time"
 
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
 type LogWriter struct {
 	Level  Level
-	Output *lumberjack.Logger
+	Output io.WriteCloser
+
+	file *lumberjack.Logger
 }
 
+// Start configures the sinks selected by the audit-log-sinks setting and closes them once ctx is done.
+// It must be called after settings are available. The sinks are not reconfigured when the settings change later on.
 func (l *LogWriter) Start(ctx context.Context) {
 	if l == nil {
 		return
 	}
+	l.Output = newMultiSink(l.sinksFromSettings()...)
 	go func() {
 		<-ctx.Done()
 		l.Output.Close()
 	}()
 }
 
-func NewLogWriter(path string, level Level, maxAge, maxBackup, maxSize int) *LogWriter {
-	if path == "" || level == LevelNull {
-		return nil
+func (l *LogWriter) sinksFromSettings() []Sink {
+	var sinks []Sink
+	for _, name := range strings.Split(settings.AuditLogSinks.Get(), ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case SinkFile:
+			if l.file == nil {
+				logrus.Warnf("auditLog: %s sink selected but no audit log path is configured", SinkFile)
+				continue
+			}
+			sinks = append(sinks, l.file)
+		case SinkStdout:
+			sinks = append(sinks, NewStdoutSink())
+		case SinkWebhook:
+			sink, err := NewWebhookSink(WebhookOptions{
+				BatchOptions:       batchOptionsFromSettings(),
+				URL:                settings.AuditLogWebhookURL.Get(),
+				CACerts:            settings.AuditLogWebhookCACerts.Get(),
+				InsecureSkipVerify: settings.AuditLogWebhookInsecureSkipVerify.Get() == "true",
+			})
+			if err != nil {
+				logrus.Errorf("auditLog: failed to configure %s sink: %v", SinkWebhook, err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		case SinkSyslog:
+			sink, err := NewSyslogSink(SyslogOptions{
+				BatchOptions:       batchOptionsFromSettings(),
+				Address:            settings.AuditLogSyslogAddress.Get(),
+				TLS:                settings.AuditLogSyslogTLS.Get() == "true",
+				CACerts:            settings.AuditLogSyslogCACerts.Get(),
+				InsecureSkipVerify: settings.AuditLogSyslogInsecureSkipVerify.Get() == "true",
+			})
+			if err != nil {
+				logrus.Errorf("auditLog: failed to configure %s sink: %v", SinkSyslog, err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		default:
+			logrus.Warnf("auditLog: ignoring unknown sink [%s]", name)
+		}
 	}
+	return sinks
+}
 
-	return &LogWriter{
-		Level: level,
-		Output: &lumberjack.Logger{
+func batchOptionsFromSettings() BatchOptions {
+	opts := BatchOptions{
+		BatchSize:  settings.AuditLogBatchSize.GetInt(),
+		MaxRetries: settings.AuditLogMaxRetries.GetInt(),
+	}
+	if interval, err := time.ParseDuration(settings.AuditLogFlushInterval.Get()); err == nil {
+		opts.FlushInterval = interval
+	} else {
+		logrus.Errorf("auditLog: failed to parse setting %s: %v", settings.AuditLogFlushInterval.Name, err)
+	}
+	return opts
+}
+
+// NewLogWriter returns a writer auditing requests which match no rule of the audit policy at level. It is created even
+// if level is LevelNull, as the audit policy can enable auditing of some requests.
+func NewLogWriter(path string, level Level, maxAge, maxBackup, maxSize int) *LogWriter {
+	writer := &LogWriter{
+		Level:  level,
+		Output: newMultiSink(),
+	}
+	if path != "" {
+		writer.file = &lumberjack.Logger{
 			Filename:   path,
 			MaxAge:     maxAge,
 			MaxBackups: maxBackup,
 			MaxSize:    maxSize,
-		},
+		}
+		// Output defaults to the file until Start selects the configured sinks.
+		writer.Output = writer.file
 	}
+	return writer
 }
@@ -0,0 +1,248 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/auth/util"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/yaml"
+)
+
+type levelKeyType struct{}
+
+var (
+	levelKey levelKeyType
+
+	levelNames = map[string]Level{
+		"None":            LevelNull,
+		"Metadata":        LevelMetadata,
+		"Request":         LevelRequest,
+		"RequestResponse": LevelRequestResponse,
+	}
+
+	verbsByMethod = map[string]string{
+		http.MethodPost:   "create",
+		http.MethodPut:    "update",
+		http.MethodPatch:  "patch",
+		http.MethodDelete: "delete",
+	}
+
+	k8sRequestInfoFactory = &request.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("apis", "api"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+)
+
+// UnmarshalJSON accepts either the name of a level, as used in Kubernetes audit policies, or its numeric value.
+func (l *Level) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var value int
+		if err := json.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("audit level must be a string or an integer: %w", err)
+		}
+		if value < int(LevelNull) || value > int(LevelRequestResponse) {
+			return fmt.Errorf("invalid audit level %d", value)
+		}
+		*l = Level(value)
+		return nil
+	}
+	level, ok := levelNames[name]
+	if !ok {
+		return fmt.Errorf("invalid audit level %q", name)
+	}
+	*l = level
+	return nil
+}
+
+// Policy selects the audit level of each request. Rules are evaluated in order and the first matching rule
+// determines the level. Requests which match no rule are logged at the level of the LogWriter.
+type Policy struct {
+	Rules []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyRule matches requests to an audit level. Every non-empty field must match for the rule to apply,
+// an empty field matches everything.
+type PolicyRule struct {
+	// Level is the audit level of matching requests, None drops them from the audit log.
+	Level Level `json:"level"`
+	// Users is a list of user names.
+	Users []string `json:"users,omitempty"`
+	// UserGroups is a list of groups, the rule matches if the user is a member of any of them.
+	UserGroups []string `json:"userGroups,omitempty"`
+	// Methods is a list of HTTP methods.
+	Methods []string `json:"methods,omitempty"`
+	// Verbs is a list of Kubernetes style verbs: get, list, watch, create, update, patch and delete.
+	Verbs []string `json:"verbs,omitempty"`
+	// PathPrefixes is a list of request URI path prefixes, for example /v1/.
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+	// Resources is a list of API resource types, for example secrets or management.cattle.io.settings.
+	Resources []string `json:"resources,omitempty"`
+}
+
+// ParsePolicy parses an audit policy from JSON or YAML.
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse audit policy: %w", err)
+	}
+	return policy, nil
+}
+
+// levelFor returns the level of the first rule matching the request, and false if no rule matches.
+func (p *Policy) levelFor(attrs *requestAttributes) (Level, bool) {
+	if p == nil {
+		return LevelNull, false
+	}
+	for i := range p.Rules {
+		if p.Rules[i].matches(attrs) {
+			return p.Rules[i].Level, true
+		}
+	}
+	return LevelNull, false
+}
+
+func (r *PolicyRule) matches(attrs *requestAttributes) bool {
+	if len(r.Users) > 0 && !isExist(r.Users, attrs.user) {
+		return false
+	}
+	if len(r.UserGroups) > 0 && !containsAny(r.UserGroups, attrs.groups) {
+		return false
+	}
+	if len(r.Methods) > 0 && !containsFold(r.Methods, attrs.method) {
+		return false
+	}
+	if len(r.Verbs) > 0 && !containsFold(r.Verbs, attrs.verb) {
+		return false
+	}
+	if len(r.PathPrefixes) > 0 && !hasAnyPrefix(attrs.path, r.PathPrefixes) {
+		return false
+	}
+	if len(r.Resources) > 0 && !isExist(r.Resources, attrs.resource) {
+		return false
+	}
+	return true
+}
+
+// requestAttributes is the subset of a request that policy rules match on.
+type requestAttributes struct {
+	user     string
+	groups   []string
+	method   string
+	verb     string
+	path     string
+	resource string
+}
+
+func newRequestAttributes(req *http.Request, user *User) *requestAttributes {
+	attrs := &requestAttributes{
+		method: req.Method,
+		path:   req.URL.Path,
+	}
+	if user != nil {
+		attrs.user = user.Name
+		attrs.groups = user.Group
+	}
+	attrs.verb, attrs.resource = verbAndResource(req)
+	return attrs
+}
+
+// verbAndResource derives the Kubernetes style verb and the resource type of a request for Kubernetes API paths,
+// including those proxied through /k8s/clusters/<cluster>, as well as for the /v1 and /v3 Rancher APIs.
+func verbAndResource(req *http.Request) (string, string) {
+	path := req.URL.Path
+	if strings.HasPrefix(path, "/k8s/clusters/") {
+		// strip /k8s/clusters/<cluster>
+		parts := strings.SplitN(strings.TrimPrefix(path, "/k8s/clusters/"), "/", 2)
+		path = "/"
+		if len(parts) == 2 {
+			path += parts[1]
+		}
+	}
+
+	if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/apis/") {
+		k8sReq := req.Clone(req.Context())
+		k8sReq.URL.Path = path
+		if info, err := k8sRequestInfoFactory.NewRequestInfo(k8sReq); err == nil && info.IsResourceRequest {
+			return info.Verb, info.Resource
+		}
+		return methodVerb(req, false), ""
+	}
+
+	var segments []string
+	switch {
+	case strings.HasPrefix(path, "/v1/"):
+		segments = util.SplitPath(strings.TrimPrefix(path, "/v1/"))
+	case strings.HasPrefix(path, "/v3/"):
+		segments = util.SplitPath(strings.TrimPrefix(path, "/v3/"))
+		// /v3/cluster/<id>/<type> and /v3/project/<id>/<type> are scoped collections
+		if len(segments) > 2 && (segments[0] == "cluster" || segments[0] == "project") {
+			segments = segments[2:]
+		}
+	default:
+		return methodVerb(req, false), ""
+	}
+
+	if len(segments) == 0 {
+		return methodVerb(req, false), ""
+	}
+	return methodVerb(req, len(segments) == 1), segments[0]
+}
+
+// methodVerb maps the request method to a verb, collection reads are reported as list and watches as watch.
+func methodVerb(req *http.Request, collection bool) string {
+	if verb, ok := verbsByMethod[req.Method]; ok {
+		return verb
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return strings.ToLower(req.Method)
+	}
+	if req.URL.Query().Get("watch") == "true" || strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return "watch"
+	}
+	if collection {
+		return "list"
+	}
+	return "get"
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, candidate := range candidates {
+		if isExist(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, key string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func withLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, levelKey, level)
+}
+
+func levelFrom(ctx context.Context) (Level, bool) {
+	level, ok := ctx.Value(levelKey).(Level)
+	return level, ok
+}
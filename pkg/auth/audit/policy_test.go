@@ -0,0 +1,222 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const testPolicy = `
+rules:
+- level: None
+  verbs: ["watch", "list"]
+  pathPrefixes: ["/v1/"]
+- level: None
+  users: ["system:serviceaccount:cattle-system:rancher"]
+- level: RequestResponse
+  resources: ["secrets"]
+  methods: ["POST", "PUT", "DELETE"]
+- level: Request
+  userGroups: ["github_team://1234"]
+- level: 1
+  methods: ["GET"]
+`
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	require.Len(t, policy.Rules, 5)
+	assert.Equal(t, LevelNull, policy.Rules[0].Level)
+	assert.Equal(t, LevelRequestResponse, policy.Rules[2].Level)
+	assert.Equal(t, LevelMetadata, policy.Rules[4].Level)
+
+	_, err = ParsePolicy([]byte(`{"rules":[{"level":"Everything"}]}`))
+	assert.Error(t, err)
+
+	_, err = ParsePolicy([]byte(`{"rules":[{"level":"None","unknown":true}]}`))
+	assert.Error(t, err)
+}
+
+func TestPolicyLevelFor(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		method    string
+		uri       string
+		header    http.Header
+		user      *User
+		wantLevel Level
+		wantMatch bool
+	}{
+		{
+			name:      "steve list is dropped",
+			method:    http.MethodGet,
+			uri:       "/v1/management.cattle.io.settings",
+			wantLevel: LevelNull,
+			wantMatch: true,
+		},
+		{
+			name:      "steve watch is dropped",
+			method:    http.MethodGet,
+			uri:       "/v1/subscribe",
+			header:    http.Header{"Upgrade": []string{"websocket"}},
+			wantLevel: LevelNull,
+			wantMatch: true,
+		},
+		{
+			name:      "steve get falls through to GET rule",
+			method:    http.MethodGet,
+			uri:       "/v1/management.cattle.io.settings/server-url",
+			wantLevel: LevelMetadata,
+			wantMatch: true,
+		},
+		{
+			name:      "user is dropped",
+			method:    http.MethodPut,
+			uri:       "/v3/settings/server-url",
+			user:      &User{Name: "system:serviceaccount:cattle-system:rancher"},
+			wantLevel: LevelNull,
+			wantMatch: true,
+		},
+		{
+			name:      "secret creation through norman",
+			method:    http.MethodPost,
+			uri:       "/v3/project/c-abcde:p-12345/secrets",
+			wantLevel: LevelRequestResponse,
+			wantMatch: true,
+		},
+		{
+			name:      "secret deletion through the cluster proxy",
+			method:    http.MethodDelete,
+			uri:       "/k8s/clusters/c-abcde/api/v1/namespaces/default/secrets/foo",
+			wantLevel: LevelRequestResponse,
+			wantMatch: true,
+		},
+		{
+			name:      "group member",
+			method:    http.MethodPost,
+			uri:       "/v3/clusters",
+			user:      &User{Name: "u-abcde", Group: []string{"system:authenticated", "github_team://1234"}},
+			wantLevel: LevelRequest,
+			wantMatch: true,
+		},
+		{
+			name:      "no rule matches",
+			method:    http.MethodPost,
+			uri:       "/v3/clusters",
+			user:      &User{Name: "u-abcde"},
+			wantMatch: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.uri, nil)
+			require.NoError(t, err)
+			if tt.header != nil {
+				req.Header = tt.header
+			}
+			level, matched := policy.levelFor(newRequestAttributes(req, tt.user))
+			assert.Equal(t, tt.wantMatch, matched)
+			assert.Equal(t, tt.wantLevel, level)
+		})
+	}
+}
+
+func TestPolicyOverridesWriterLevel(t *testing.T) {
+	defer settings.AuditLogPolicy.Set(settings.AuditLogPolicy.Default)
+	require.NoError(t, settings.AuditLogPolicy.Set(`
+rules:
+- level: Metadata
+  methods: ["POST"]
+- level: None
+  methods: ["DELETE"]
+`))
+
+	tests := []struct {
+		name        string
+		writerLevel Level
+		method      string
+		wantLogged  bool
+	}{
+		{
+			name:        "policy enables auditing",
+			writerLevel: LevelNull,
+			method:      http.MethodPost,
+			wantLogged:  true,
+		},
+		{
+			name:        "no rule matches with auditing disabled",
+			writerLevel: LevelNull,
+			method:      http.MethodGet,
+		},
+		{
+			name:        "no rule matches with auditing enabled",
+			writerLevel: LevelMetadata,
+			method:      http.MethodGet,
+			wantLogged:  true,
+		},
+		{
+			name:        "policy disables auditing",
+			writerLevel: LevelMetadata,
+			method:      http.MethodDelete,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := NewLogWriter("", tt.writerLevel, 0, 0, 0)
+			require.NotNil(t, writer)
+			var out strings.Builder
+			writer.Output = &stdoutSink{out: &out}
+
+			middleware, err := NewAuditLogMiddleware(writer)
+			require.NoError(t, err)
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			req, err := http.NewRequest(tt.method, "/v3/settings/foo", nil)
+			require.NoError(t, err)
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "u-abcde"}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.wantLogged, out.Len() > 0)
+		})
+	}
+}
+
+func TestVerbAndResource(t *testing.T) {
+	tests := []struct {
+		method       string
+		uri          string
+		wantVerb     string
+		wantResource string
+	}{
+		{http.MethodGet, "/v1/secrets", "list", "secrets"},
+		{http.MethodGet, "/v1/secrets?watch=true", "watch", "secrets"},
+		{http.MethodGet, "/v3/clusters/c-abcde", "get", "clusters"},
+		{http.MethodPost, "/v3/clusters/c-abcde?action=generateKubeconfig", "create", "clusters"},
+		{http.MethodGet, "/v3/cluster/c-abcde/namespaces", "list", "namespaces"},
+		{http.MethodGet, "/apis/management.cattle.io/v3/settings", "list", "settings"},
+		{http.MethodGet, "/k8s/clusters/local/api/v1/pods?watch=true", "watch", "pods"},
+		{http.MethodPatch, "/k8s/clusters/local/api/v1/namespaces/default/pods/foo", "patch", "pods"},
+		{http.MethodGet, "/healthz", "get", ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.uri, nil)
+		require.NoError(t, err)
+		verb, resource := verbAndResource(req)
+		assert.Equal(t, tt.wantVerb, verb, tt.uri)
+		assert.Equal(t, tt.wantResource, resource, tt.uri)
+	}
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// RedactionRules are admin defined rules for removing sensitive data from audit records. They are applied in
+// addition to the built-in redaction of passwords, tokens, kubeconfigs, driver fields and secret data.
+type RedactionRules struct {
+	Rules []RedactionRule `json:"rules,omitempty"`
+
+	compiled []compiledRedactionRule
+}
+
+// RedactionRule describes headers and body fields to redact.
+type RedactionRule struct {
+	// Resources limits the rule to requests for these resource types, or to bodies whose kind, type or baseType
+	// is one of them. Matching is case-insensitive. A rule without resources applies to every request.
+	Resources []string `json:"resources,omitempty"`
+	// Headers is a list of request and response header names to drop from the record.
+	Headers []string `json:"headers,omitempty"`
+	// Keys is a list of regular expressions, the value of every JSON key matching any of them is redacted.
+	Keys []string `json:"keys,omitempty"`
+	// Paths is a list of dot separated JSON paths to redact, such as spec.privateKey or data.*.
+	// A "*" segment matches every key of an object or every element of an array.
+	Paths []string `json:"paths,omitempty"`
+}
+
+type compiledRedactionRule struct {
+	resources []string
+	headers   []string
+	keys      *regexp.Regexp
+	paths     [][]string
+}
+
+// ParseRedactionRules parses and validates redaction rules from JSON or YAML.
+func ParseRedactionRules(data []byte) (*RedactionRules, error) {
+	rules := &RedactionRules{}
+	if err := yaml.UnmarshalStrict(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse audit redaction rules: %w", err)
+	}
+
+	for i, rule := range rules.Rules {
+		compiled := compiledRedactionRule{
+			resources: rule.Resources,
+		}
+		for _, header := range rule.Headers {
+			compiled.headers = append(compiled.headers, http.CanonicalHeaderKey(header))
+		}
+		if len(rule.Keys) > 0 {
+			keys, err := regexp.Compile("(" + strings.Join(rule.Keys, ")|(") + ")")
+			if err != nil {
+				return nil, fmt.Errorf("invalid keys in audit redaction rule %d: %w", i, err)
+			}
+			compiled.keys = keys
+		}
+		for _, path := range rule.Paths {
+			if path == "" {
+				return nil, fmt.Errorf("empty path in audit redaction rule %d", i)
+			}
+			compiled.paths = append(compiled.paths, strings.Split(path, "."))
+		}
+		rules.compiled = append(rules.compiled, compiled)
+	}
+	return rules, nil
+}
+
+// filterHeaders drops the headers named by rules which apply to the resource.
+func (r *RedactionRules) filterHeaders(headers map[string][]string, resource string) map[string][]string {
+	if r == nil {
+		return headers
+	}
+	for _, rule := range r.compiled {
+		if len(rule.headers) == 0 || !rule.matchesResource(resource) {
+			continue
+		}
+		for _, header := range rule.headers {
+			delete(headers, header)
+		}
+	}
+	return headers
+}
+
+// redactBody applies the rules to a decoded JSON body. List bodies, either Rancher collections or Kubernetes
+// lists, have the rules applied to each of their items as well. It returns true if anything was redacted.
+func (r *RedactionRules) redactBody(resource string, body map[string]interface{}) bool {
+	if r == nil {
+		return false
+	}
+
+	changed := r.redactObject(resource, body)
+	for _, itemsKey := range []string{"data", "items"} {
+		items, ok := body[itemsKey].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				changed = r.redactObject(resource, m) || changed
+			}
+		}
+	}
+	return changed
+}
+
+func (r *RedactionRules) redactObject(resource string, object map[string]interface{}) bool {
+	var changed bool
+	for _, rule := range r.compiled {
+		if !rule.matchesResource(resource) && !rule.matchesObject(object) {
+			continue
+		}
+		if rule.keys != nil {
+			changed = redactKeys(object, rule.keys) || changed
+		}
+		for _, path := range rule.paths {
+			changed = redactPath(object, path) || changed
+		}
+	}
+	return changed
+}
+
+func (c *compiledRedactionRule) matchesResource(resource string) bool {
+	return len(c.resources) == 0 || (resource != "" && containsFold(c.resources, resource))
+}
+
+func (c *compiledRedactionRule) matchesObject(object map[string]interface{}) bool {
+	if len(c.resources) == 0 {
+		return true
+	}
+	for _, key := range []string{"kind", "type", "baseType"} {
+		if value, ok := object[key].(string); ok && containsFold(c.resources, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactPath redacts the value found by following path from node.
+func redactPath(node interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	segment, last := path[0], len(path) == 1
+
+	switch val := node.(type) {
+	case map[string]interface{}:
+		if segment == "*" {
+			var changed bool
+			for key := range val {
+				if last {
+					val[key] = redacted
+					changed = true
+					continue
+				}
+				changed = redactPath(val[key], path[1:]) || changed
+			}
+			return changed
+		}
+		child, ok := val[segment]
+		if !ok {
+			return false
+		}
+		if last {
+			val[segment] = redacted
+			return true
+		}
+		return redactPath(child, path[1:])
+	case []interface{}:
+		if segment == "*" {
+			var changed bool
+			for i := range val {
+				if last {
+					val[i] = redacted
+					changed = true
+					continue
+				}
+				changed = redactPath(val[i], path[1:]) || changed
+			}
+			return changed
+		}
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= len(val) {
+			return false
+		}
+		if last {
+			val[index] = redacted
+			return true
+		}
+		return redactPath(val[index], path[1:])
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRedactionRules = `
+rules:
+- headers: ["x-custom-token"]
+- keys: ["^customDriverField$", "(?i)apikey"]
+- resources: ["secrets", "secret"]
+  paths: ["metadata.annotations.custom"]
+- resources: ["cloudCredential"]
+  paths: ["amazonec2credentialConfig.*"]
+- paths: ["spec.hooks.*.env"]
+`
+
+func TestParseRedactionRules(t *testing.T) {
+	rules, err := ParseRedactionRules([]byte(testRedactionRules))
+	require.NoError(t, err)
+	require.Len(t, rules.compiled, 5)
+	assert.Equal(t, []string{"X-Custom-Token"}, rules.compiled[0].headers)
+
+	_, err = ParseRedactionRules([]byte(`{"rules":[{"keys":["("]}]}`))
+	assert.Error(t, err)
+
+	_, err = ParseRedactionRules([]byte(`{"rules":[{"paths":[""]}]}`))
+	assert.Error(t, err)
+}
+
+func TestSettingLoaderKeepsLastValidValue(t *testing.T) {
+	setting := settings.NewSetting("test-audit-log-redaction-rules", "")
+	loader := newSettingLoader(setting, ParseRedactionRules)
+
+	assert.Nil(t, loader.get(), "empty setting uses the built-in rules")
+
+	require.NoError(t, setting.Set(testRedactionRules))
+	valid := loader.get()
+	require.NotNil(t, valid)
+	assert.Len(t, valid.compiled, 5)
+
+	require.NoError(t, setting.Set(`{"rules":[{"keys":["("]}]}`))
+	assert.Same(t, valid, loader.get(), "invalid setting keeps the last valid rules")
+
+	require.NoError(t, setting.Set(""))
+	assert.Nil(t, loader.get())
+
+	require.NoError(t, setting.Set(`{"rules":[{"keys":["("]}]}`))
+	assert.Nil(t, loader.get(), "invalid setting without earlier valid rules uses the built-in rules")
+}
+
+func TestRedactionRules(t *testing.T) {
+	rules, err := ParseRedactionRules([]byte(`
+rules:
+- keys: ["^customDriverField$", "(?i)apikey"]
+- resources: ["secrets"]
+  paths: ["metadata.annotations.custom"]
+- resources: ["cloudCredential"]
+  paths: ["amazonec2credentialConfig.*"]
+- paths: ["spec.hooks.*.env"]
+`))
+	require.NoError(t, err)
+	logger := auditLog{
+		keysToRedactRegex: regexp.MustCompile(`[pP]assword|[tT]oken`),
+		redaction:         rules,
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		input    string
+		want     string
+	}{
+		{
+			name:  "custom keys",
+			input: `{"customDriverField":"value","nested":{"myApiKey":"value"},"other":"value"}`,
+			want:  fmt.Sprintf(`{"customDriverField":"%s","nested":{"myApiKey":"%[1]s"},"other":"value"}`, redacted),
+		},
+		{
+			name:     "path scoped to resource from request",
+			resource: "secrets",
+			input:    `{"metadata":{"annotations":{"custom":"value","other":"value"}}}`,
+			want:     fmt.Sprintf(`{"metadata":{"annotations":{"custom":"%s","other":"value"}}}`, redacted),
+		},
+		{
+			name:     "path scoped to other resource",
+			resource: "configmaps",
+			input:    `{"metadata":{"annotations":{"custom":"value"}}}`,
+			want:     `{"metadata":{"annotations":{"custom":"value"}}}`,
+		},
+		{
+			name:  "path scoped to type of collection items",
+			input: `{"type":"collection","data":[{"type":"cloudCredential","amazonec2credentialConfig":{"accessKey":"a","secretKey":"b"}},{"type":"other","amazonec2credentialConfig":{"accessKey":"a"}}]}`,
+			want:  fmt.Sprintf(`{"type":"collection","data":[{"type":"cloudCredential","amazonec2credentialConfig":{"accessKey":"%s","secretKey":"%[1]s"}},{"type":"other","amazonec2credentialConfig":{"accessKey":"a"}}]}`, redacted),
+		},
+		{
+			name:  "wildcard array path",
+			input: `{"spec":{"hooks":[{"name":"a","env":["A=1"]},{"name":"b"}]}}`,
+			want:  fmt.Sprintf(`{"spec":{"hooks":[{"name":"a","env":"%s"},{"name":"b"}]}}`, redacted),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			logger.resource = tt.resource
+			assert.JSONEq(t, tt.want, string(logger.redactSensitiveData("/test", []byte(tt.input))))
+		})
+	}
+}
+
+func TestRedactionRulesFilterHeaders(t *testing.T) {
+	rules, err := ParseRedactionRules([]byte(`{"rules":[{"headers":["x-custom-token"]},{"resources":["secrets"],"headers":["X-Secret"]}]}`))
+	require.NoError(t, err)
+
+	headers := rules.filterHeaders(http.Header{
+		"X-Custom-Token": []string{"a"},
+		"X-Secret":       []string{"b"},
+		"Accept":         []string{"*/*"},
+	}, "configmaps")
+	assert.Equal(t, map[string][]string{"X-Secret": {"b"}, "Accept": {"*/*"}}, headers)
+
+	headers = rules.filterHeaders(http.Header{"X-Secret": []string{"b"}}, "secrets")
+	assert.Empty(t, headers)
+
+	var nilRules *RedactionRules
+	assert.Equal(t, map[string][]string{"Accept": {"*/*"}}, nilRules.filterHeaders(map[string][]string{"Accept": {"*/*"}}, ""))
+}
+
+func TestRedactCompressedRequestBody(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(`{"password":"secret","user":"fake_user"}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	req, err := http.NewRequest(http.MethodPost, "/v3/users", bytes.NewReader(compressed.Bytes()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentTypeJSON)
+	req.Header.Set("Content-Encoding", contentEncodingGZIP)
+
+	auditLog, err := newAuditLog(&LogWriter{Level: LevelRequest}, req, regexp.MustCompile(`[pP]assword`))
+	require.NoError(t, err)
+	assert.Equal(t, "users", auditLog.resource)
+
+	var buf bytes.Buffer
+	auditLog.writeRequest(&buf)
+	assert.Equal(t, fmt.Sprintf(`,"requestBody":{"password":"%s","user":"fake_user"}`, redacted), buf.String())
+}
+
+func TestDecodeRawDeflate(t *testing.T) {
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(`{"test":"response"}`))
+	require.NoError(t, err)
+	require.NoError(t, fw.Close())
+
+	body, err := decodeBody(contentEncodingZLib, compressed.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, `{"test":"response"}`, string(body))
+}
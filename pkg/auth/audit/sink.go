@@ -0,0 +1,504 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// SinkFile writes audit records to the rotating log file configured with the audit-log-* flags.
+	SinkFile = "file"
+	// SinkStdout writes audit records to the process' standard output.
+	SinkStdout = "stdout"
+	// SinkWebhook sends batches of audit records to an HTTP endpoint.
+	SinkWebhook = "webhook"
+	// SinkSyslog sends audit records as RFC 5424 messages to a syslog server over TCP or TLS.
+	SinkSyslog = "syslog"
+
+	defaultQueueSize = 10000
+	syslogAppName    = "rancher"
+	syslogMsgID      = "audit"
+	// syslogPriority is facility 13 (log audit) with severity 6 (informational).
+	syslogPriority = 13*8 + 6
+)
+
+var (
+	// ErrSinkQueueFull is returned when an asynchronous sink cannot keep up with the rate of audit records.
+	ErrSinkQueueFull = errors.New("audit sink queue is full")
+	// ErrSinkClosed is returned when writing to a sink that has already been closed.
+	ErrSinkClosed = errors.New("audit sink is closed")
+)
+
+// Sink is a destination for audit records. Each call to Write receives exactly one
+// newline terminated JSON audit record.
+type Sink interface {
+	io.WriteCloser
+}
+
+// multiSink fans out every audit record to all of its sinks.
+type multiSink struct {
+	sinks []Sink
+}
+
+func newMultiSink(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return &multiSink{sinks: sinks}
+}
+
+func (m *multiSink) Write(p []byte) (int, error) {
+	var errs []error
+	for _, s := range m.sinks {
+		if _, err := s.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(p), errors.Join(errs...)
+}
+
+func (m *multiSink) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stdoutSink writes audit records to standard output.
+type stdoutSink struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+// NewStdoutSink returns a Sink writing audit records to standard output.
+func NewStdoutSink() Sink {
+	return &stdoutSink{out: os.Stdout}
+}
+
+func (s *stdoutSink) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.out.Write(p)
+}
+
+func (s *stdoutSink) Close() error {
+	return nil
+}
+
+// sendFunc delivers a batch of audit records. It reports whether a failed delivery may be retried.
+type sendFunc func(ctx context.Context, records [][]byte) (retry bool, err error)
+
+// BatchOptions configures how an asynchronous sink groups and retries audit records.
+type BatchOptions struct {
+	// BatchSize is the maximum number of records delivered at once.
+	BatchSize int
+	// FlushInterval is the longest a record waits in the queue before being delivered.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed batch is retried before it is dropped.
+	MaxRetries int
+	// Backoff is the initial delay between retries, it doubles after every attempt.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// QueueSize is the number of records buffered before writes start failing.
+	QueueSize int
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	return o
+}
+
+// batchingSink queues audit records and delivers them in batches from a background goroutine, so that slow or
+// unavailable remote destinations never block API requests.
+type batchingSink struct {
+	name    string
+	opts    BatchOptions
+	send    sendFunc
+	records chan []byte
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	closeOnce sync.Once
+	lock      sync.RWMutex
+	closed    bool
+}
+
+func newBatchingSink(name string, opts BatchOptions, send sendFunc) *batchingSink {
+	opts = opts.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &batchingSink{
+		name:    name,
+		opts:    opts,
+		send:    send,
+		records: make(chan []byte, opts.QueueSize),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go b.run(ctx)
+	return b
+}
+
+func (b *batchingSink) Write(p []byte) (int, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.closed {
+		return 0, fmt.Errorf("%s: %w", b.name, ErrSinkClosed)
+	}
+
+	// the caller may reuse p once Write returns
+	record := make([]byte, len(p))
+	copy(record, p)
+
+	select {
+	case b.records <- record:
+		return len(p), nil
+	default:
+		return 0, fmt.Errorf("%s: %w", b.name, ErrSinkQueueFull)
+	}
+}
+
+// Close stops accepting new records, delivers the ones already queued and waits for the delivery to finish.
+func (b *batchingSink) Close() error {
+	b.closeOnce.Do(func() {
+		b.lock.Lock()
+		b.closed = true
+		close(b.records)
+		b.lock.Unlock()
+	})
+	<-b.done
+	return nil
+}
+
+func (b *batchingSink) run(ctx context.Context) {
+	defer close(b.done)
+	defer b.cancel()
+
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, b.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b.deliver(ctx, batch)
+		batch = make([][]byte, 0, b.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case record, ok := <-b.records:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= b.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// deliver sends the batch, retrying with exponential backoff and jitter until it succeeds, fails with a
+// non-retryable error or runs out of retries. Batches which cannot be delivered are dropped.
+func (b *batchingSink) deliver(ctx context.Context, batch [][]byte) {
+	backoff := wait.Backoff{
+		Duration: b.opts.Backoff,
+		Factor:   2,
+		Jitter:   0.2,
+		Steps:    b.opts.MaxRetries + 1,
+		Cap:      b.opts.MaxBackoff,
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= b.opts.MaxRetries; attempt++ {
+		retry, err := b.send(ctx, batch)
+		if err == nil {
+			return
+		}
+		lastErr = err
+		if !retry || attempt == b.opts.MaxRetries {
+			break
+		}
+		logrus.Debugf("auditLog: failed to deliver %d records to %s sink, retrying: %v", len(batch), b.name, err)
+		select {
+		case <-time.After(backoff.Step()):
+		case <-ctx.Done():
+			return
+		}
+	}
+	logrus.Errorf("auditLog: dropping %d records, failed to deliver them to %s sink: %v", len(batch), b.name, lastErr)
+}
+
+// WebhookOptions configures the webhook sink.
+type WebhookOptions struct {
+	BatchOptions
+	// URL is the endpoint audit records are POSTed to.
+	URL string
+	// CACerts is an optional PEM bundle used to verify the endpoint's certificate.
+	CACerts string
+	// InsecureSkipVerify disables verification of the endpoint's certificate.
+	InsecureSkipVerify bool
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration
+}
+
+// NewWebhookSink returns a Sink which POSTs batches of audit records, as a JSON array, to an HTTP endpoint.
+// Network errors, 429 and 5xx responses are retried with exponential backoff.
+func NewWebhookSink(opts WebhookOptions) (Sink, error) {
+	if opts.URL == "" {
+		return nil, errors.New("webhook sink requires a URL")
+	}
+	tlsConfig, err := sinkTLSConfig(opts.CACerts, opts.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+
+	return newBatchingSink(SinkWebhook, opts.BatchOptions, func(ctx context.Context, records [][]byte) (bool, error) {
+		return postRecords(ctx, client, opts.URL, records)
+	}), nil
+}
+
+func postRecords(ctx context.Context, client *http.Client, url string, records [][]byte) (bool, error) {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, record := range records {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(bytes.TrimSuffix(record, []byte("\n")))
+	}
+	body.WriteByte(']')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send audit records to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// SyslogOptions configures the syslog sink.
+type SyslogOptions struct {
+	BatchOptions
+	// Address is the host:port of the syslog server.
+	Address string
+	// TLS enables TLS for the connection to the syslog server.
+	TLS bool
+	// CACerts is an optional PEM bundle used to verify the server's certificate.
+	CACerts string
+	// InsecureSkipVerify disables verification of the server's certificate.
+	InsecureSkipVerify bool
+	// Hostname is reported in the HOSTNAME field of every message, defaults to os.Hostname.
+	Hostname string
+	// Timeout bounds dialing and writing a batch.
+	Timeout time.Duration
+}
+
+// NewSyslogSink returns a Sink which sends audit records to a syslog server as RFC 5424 messages, framed with
+// octet counting as described in RFC 6587, over TCP or TLS.
+func NewSyslogSink(opts SyslogOptions) (Sink, error) {
+	if opts.Address == "" {
+		return nil, errors.New("syslog sink requires an address")
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	var tlsConfig *tls.Config
+	if opts.TLS {
+		var err error
+		tlsConfig, err = sinkTLSConfig(opts.CACerts, opts.InsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w := &syslogWriter{
+		opts:      opts,
+		tlsConfig: tlsConfig,
+		procID:    fmt.Sprint(os.Getpid()),
+	}
+	b := newBatchingSink(SinkSyslog, opts.BatchOptions, w.send)
+	return &closingSink{batchingSink: b, after: w.close}, nil
+}
+
+// closingSink releases additional resources once the underlying batchingSink has drained.
+type closingSink struct {
+	*batchingSink
+	after func() error
+}
+
+func (c *closingSink) Close() error {
+	if err := c.batchingSink.Close(); err != nil {
+		return err
+	}
+	return c.after()
+}
+
+type syslogWriter struct {
+	opts      SyslogOptions
+	tlsConfig *tls.Config
+	procID    string
+	conn      net.Conn
+}
+
+// send is only ever called from the batchingSink goroutine, so the connection needs no locking.
+func (w *syslogWriter) send(ctx context.Context, records [][]byte) (bool, error) {
+	if w.conn == nil {
+		conn, err := w.dial(ctx)
+		if err != nil {
+			return true, err
+		}
+		w.conn = conn
+	}
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		msg := formatSyslogMessage(time.Now(), w.opts.Hostname, w.procID, bytes.TrimSuffix(record, []byte("\n")))
+		fmt.Fprintf(&buf, "%d %s", len(msg), msg)
+	}
+
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.opts.Timeout)); err != nil {
+		return true, w.reset(err)
+	}
+	if _, err := w.conn.Write(buf.Bytes()); err != nil {
+		// A partial write may leave the stream in the middle of a frame, start over on a new connection.
+		return true, w.reset(err)
+	}
+	return false, nil
+}
+
+func (w *syslogWriter) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: w.opts.Timeout}
+	if w.tlsConfig != nil {
+		conn, err := (&tls.Dialer{NetDialer: dialer, Config: w.tlsConfig}).DialContext(ctx, "tcp", w.opts.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog server %s over TLS: %w", w.opts.Address, err)
+		}
+		return conn, nil
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", w.opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog server %s: %w", w.opts.Address, err)
+	}
+	return conn, nil
+}
+
+func (w *syslogWriter) reset(err error) error {
+	_ = w.close()
+	return fmt.Errorf("failed to write to syslog server %s: %w", w.opts.Address, err)
+}
+
+func (w *syslogWriter) close() error {
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// formatSyslogMessage formats an audit record as an RFC 5424 message without structured data.
+func formatSyslogMessage(ts time.Time, hostname, procID string, record []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s - ", syslogPriority, ts.UTC().Format(time.RFC3339Nano),
+		syslogHeaderField(hostname), syslogAppName, syslogHeaderField(procID), syslogMsgID)
+	buf.Write(record)
+	return buf.Bytes()
+}
+
+// syslogHeaderField returns the NILVALUE for empty header fields and strips characters which are not allowed in them.
+func syslogHeaderField(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func sinkTLSConfig(caCerts string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if caCerts == "" {
+		return tlsConfig, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(caCerts)) {
+		return nil, errors.New("failed to parse CA certificates for audit sink")
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
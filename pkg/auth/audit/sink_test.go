@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSinkBatchesRecords(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		batches [][]map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, contentTypeJSON, req.Header.Get("Content-Type"))
+		var batch []map[string]interface{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&batch))
+		lock.Lock()
+		batches = append(batches, batch)
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(WebhookOptions{
+		BatchOptions: BatchOptions{BatchSize: 2, FlushInterval: time.Hour},
+		URL:          server.URL,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := sink.Write([]byte(`{"auditID":"` + strconv.Itoa(i) + `"}` + "\n"))
+		require.NoError(t, err)
+	}
+	// Close flushes the remaining partial batch.
+	require.NoError(t, sink.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 2)
+	assert.Len(t, batches[1], 1)
+	assert.Equal(t, "2", batches[1][0]["auditID"])
+
+	_, err = sink.Write([]byte("{}\n"))
+	assert.ErrorIs(t, err, ErrSinkClosed)
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantAttempts  int
+		maxRetries    int
+		succeedOnLast bool
+	}{
+		{
+			name:          "server error is retried until it succeeds",
+			status:        http.StatusServiceUnavailable,
+			maxRetries:    3,
+			wantAttempts:  3,
+			succeedOnLast: true,
+		},
+		{
+			name:         "server error is retried until retries run out",
+			status:       http.StatusInternalServerError,
+			maxRetries:   2,
+			wantAttempts: 3,
+		},
+		{
+			name:         "client error is not retried",
+			status:       http.StatusBadRequest,
+			maxRetries:   3,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				lock     sync.Mutex
+				attempts int
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				lock.Lock()
+				defer lock.Unlock()
+				attempts++
+				if tt.succeedOnLast && attempts == tt.wantAttempts {
+					return
+				}
+				rw.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sink, err := NewWebhookSink(WebhookOptions{
+				BatchOptions: BatchOptions{
+					BatchSize:     1,
+					MaxRetries:    tt.maxRetries,
+					Backoff:       time.Millisecond,
+					MaxBackoff:    5 * time.Millisecond,
+					FlushInterval: time.Hour,
+				},
+				URL: server.URL,
+			})
+			require.NoError(t, err)
+
+			_, err = sink.Write([]byte("{}\n"))
+			require.NoError(t, err)
+			require.NoError(t, sink.Close())
+
+			lock.Lock()
+			defer lock.Unlock()
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			// RFC 6587 octet counting: "<length> <message>"
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			messages <- string(msg)
+		}
+	}()
+
+	sink, err := NewSyslogSink(SyslogOptions{
+		BatchOptions: BatchOptions{BatchSize: 2, FlushInterval: time.Hour},
+		Address:      listener.Addr().String(),
+		Hostname:     "rancher-0",
+	})
+	require.NoError(t, err)
+
+	_, err = sink.Write([]byte(`{"auditID":"1"}` + "\n"))
+	require.NoError(t, err)
+	_, err = sink.Write([]byte(`{"auditID":"2"}` + "\n"))
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2"} {
+		select {
+		case msg := <-messages:
+			assert.True(t, strings.HasPrefix(msg, "<110>1 "), "unexpected syslog header: %s", msg)
+			fields := strings.SplitN(msg, " ", 8)
+			require.Len(t, fields, 8)
+			assert.Equal(t, "rancher-0", fields[2])
+			assert.Equal(t, syslogAppName, fields[3])
+			assert.Equal(t, syslogMsgID, fields[5])
+			assert.Equal(t, "-", fields[6])
+			assert.Equal(t, `{"auditID":"`+id+`"}`, fields[7])
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for syslog message")
+		}
+	}
+	require.NoError(t, sink.Close())
+}
+
+func TestMultiSink(t *testing.T) {
+	var first, second strings.Builder
+	sink := newMultiSink(&stdoutSink{out: &first}, &stdoutSink{out: &second})
+
+	_, err := sink.Write([]byte("{}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n", first.String())
+	assert.Equal(t, "{}\n", second.String())
+	assert.NoError(t, sink.Close())
+}
@@ -0,0 +1,338 @@
+// Package lockout tracks failed password logins by username and by source IP, and temporarily locks out a username or
+// IP after too many of them. The failures are counted in config maps, so every replica of an HA install shares them.
+// Only failures of usernames which a user logs in with are counted, and the number of config maps is capped, so that
+// failed logins can't create config maps without bounds.
+package lockout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	"github.com/rancher/rancher/pkg/auth/providers/local"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// Label is set on the config maps of failed login attempts.
+	Label = "authn.management.cattle.io/login-attempts"
+
+	kindUser = "user"
+	kindIP   = "ip"
+
+	keyProvider    = "provider"
+	keySubject     = "subject"
+	keyFailures    = "failures"
+	keyLockouts    = "lockouts"
+	keyLastFailure = "lastFailure"
+	keyLockedUntil = "lockedUntil"
+
+	cleanupInterval = time.Hour
+	// defaultMaxRecords is the number of config maps above which the failures of usernames and IPs without one aren't
+	// counted until the old ones are cleaned up.
+	defaultMaxRecords = 10000
+)
+
+// Policy is the number of failures after which a username or IP is locked out, and for how long.
+type Policy struct {
+	// MaxUserAttempts is the number of consecutive failures after which a username is locked out, 0 to disable.
+	MaxUserAttempts int
+	// MaxIPAttempts is the number of consecutive failures after which an IP is locked out, 0 to disable.
+	MaxIPAttempts int
+	// Duration is how long the first lockout lasts. Each following lockout lasts twice as long as the previous one.
+	Duration time.Duration
+	// MaxDuration is the longest a lockout lasts. Failures are forgotten once none happened for as long.
+	MaxDuration time.Duration
+}
+
+// SettingsPolicy returns the policy configured by the auth-login-* settings. IPs are only locked out when the
+// trusted-proxy-cidrs setting is set: the address of the clients behind the ingress is otherwise the address of the
+// ingress, and locking it out would lock out every client.
+func SettingsPolicy() Policy {
+	policy := Policy{
+		MaxUserAttempts: settings.AuthLoginMaxUserAttempts.GetInt(),
+		MaxIPAttempts:   settings.AuthLoginMaxIPAttempts.GetInt(),
+		Duration:        parseDuration(settings.AuthLoginLockoutDuration),
+		MaxDuration:     parseDuration(settings.AuthLoginMaxLockoutDuration),
+	}
+	if strings.TrimSpace(settings.TrustedProxyCIDRs.Get()) == "" {
+		policy.MaxIPAttempts = 0
+	}
+	return policy
+}
+
+func parseDuration(setting settings.Setting) time.Duration {
+	duration, err := time.ParseDuration(setting.Get())
+	if err != nil {
+		logrus.Errorf("Failed to parse setting %s: %v", setting.Name, err)
+		duration, _ = time.ParseDuration(setting.Default)
+	}
+	return duration
+}
+
+// Tracker counts failed logins and reports lockouts.
+type Tracker struct {
+	configMaps     corecontrollers.ConfigMapClient
+	configMapCache corecontrollers.ConfigMapCache
+	policy         func() Policy
+	now            func() time.Time
+	maxRecords     int
+}
+
+// NewTracker returns a Tracker which stores the failures in config maps of the global data namespace. The policy is
+// read on each use so that changes of settings apply immediately.
+func NewTracker(configMaps corecontrollers.ConfigMapClient, configMapCache corecontrollers.ConfigMapCache, policy func() Policy) *Tracker {
+	return &Tracker{
+		configMaps:     configMaps,
+		configMapCache: configMapCache,
+		policy:         policy,
+		now:            time.Now,
+		maxRecords:     defaultMaxRecords,
+	}
+}
+
+// LockedUntil returns the end of the lockout of the username or IP, or the zero time if neither is locked out. The
+// records are read from the API rather than the cache, so that a lockout applies on every replica as soon as another
+// replica recorded it.
+func (t *Tracker) LockedUntil(provider, username, ip string) time.Time {
+	now := t.now()
+	var until time.Time
+	for _, name := range []string{recordName(kindUser, provider, username), recordName(kindIP, "", ip)} {
+		cm, err := t.configMaps.Get(namespace.GlobalNamespace, name, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				logrus.Warnf("Failed to get login attempts %s: %v", name, err)
+			}
+			continue
+		}
+		if lockedUntil := parseTime(cm.Data[keyLockedUntil]); lockedUntil.After(now) && lockedUntil.After(until) {
+			until = lockedUntil
+		}
+	}
+	return until
+}
+
+// Failure records a failed login of the username from the IP. The failures of the username are only recorded if
+// userExists is true, that is a user logs in to the provider with it. It returns the end of the lockout of the username
+// if this failure locked it out, or the zero time.
+func (t *Tracker) Failure(provider, username, ip string, userExists bool) (time.Time, error) {
+	policy := t.policy()
+	var userLockedUntil time.Time
+	if userExists {
+		var err error
+		userLockedUntil, err = t.failure(kindUser, provider, username, policy.MaxUserAttempts, policy)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	if _, err := t.failure(kindIP, "", ip, policy.MaxIPAttempts, policy); err != nil {
+		return time.Time{}, err
+	}
+	return userLockedUntil, nil
+}
+
+// Success forgets the failed logins of the username. The failures of the IP are kept, as otherwise anyone with an
+// account could log in with it between guesses of the passwords of other users to never be locked out.
+func (t *Tracker) Success(provider, username string) error {
+	name := recordName(kindUser, provider, username)
+	if _, err := t.configMapCache.Get(namespace.GlobalNamespace, name); err == nil {
+		return t.delete(name)
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Unlock ends the lockout of the username and forgets its failed logins.
+func (t *Tracker) Unlock(provider, username string) error {
+	return t.delete(recordName(kindUser, provider, username))
+}
+
+// Start removes the failures which are old enough to be forgotten every hour until ctx is done.
+func (t *Tracker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.cleanup(); err != nil {
+					logrus.Warnf("Failed to clean up login attempts: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (t *Tracker) cleanup() error {
+	cms, err := t.configMapCache.List(namespace.GlobalNamespace, labels.SelectorFromSet(labels.Set{Label: "true"}))
+	if err != nil {
+		return err
+	}
+	now := t.now()
+	maxDuration := t.policy().MaxDuration
+	for _, cm := range cms {
+		if expired(cm, now, maxDuration) {
+			if err := t.delete(cm.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// failure increments the failures of the subject, and locks it out once it reaches maxAttempts. The update is retried
+// on conflicts, so that failures on several replicas at the same time are all counted.
+func (t *Tracker) failure(kind, provider, subject string, maxAttempts int, policy Policy) (time.Time, error) {
+	if maxAttempts <= 0 || subject == "" {
+		return time.Time{}, nil
+	}
+	name := recordName(kind, provider, subject)
+	var lockedUntil time.Time
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lockedUntil = time.Time{}
+		now := t.now()
+		cm, err := t.configMaps.Get(namespace.GlobalNamespace, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if full, err := t.full(); err != nil || full {
+				if full {
+					logrus.Warnf("Not recording failed login of %s %s, there are already %d records of failed logins", kind, subject, t.maxRecords)
+				}
+				return err
+			}
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace.GlobalNamespace,
+					Labels:    map[string]string{Label: "true"},
+				},
+				Data: map[string]string{keyProvider: provider, keySubject: subject},
+			}
+		} else if err != nil {
+			return err
+		} else {
+			cm = cm.DeepCopy()
+			if expired(cm, now, policy.MaxDuration) {
+				delete(cm.Data, keyFailures)
+				delete(cm.Data, keyLockouts)
+				delete(cm.Data, keyLockedUntil)
+			}
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+
+		failures, _ := strconv.Atoi(cm.Data[keyFailures])
+		failures++
+		cm.Data[keyLastFailure] = now.UTC().Format(time.RFC3339)
+		if failures >= maxAttempts {
+			lockouts, _ := strconv.Atoi(cm.Data[keyLockouts])
+			lockouts++
+			lockedUntil = now.Add(lockoutDuration(lockouts, policy)).Truncate(time.Second)
+			cm.Data[keyLockouts] = strconv.Itoa(lockouts)
+			cm.Data[keyLockedUntil] = lockedUntil.UTC().Format(time.RFC3339)
+			failures = 0
+		}
+		cm.Data[keyFailures] = strconv.Itoa(failures)
+
+		if cm.ResourceVersion == "" {
+			_, err = t.configMaps.Create(cm)
+			if apierrors.IsAlreadyExists(err) {
+				// another replica recorded the first failure, retry as an update
+				return apierrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		_, err = t.configMaps.Update(cm)
+		return err
+	})
+	return lockedUntil, err
+}
+
+// full returns true if there are maxRecords records or more, so that no record is added.
+func (t *Tracker) full() (bool, error) {
+	cms, err := t.configMapCache.List(namespace.GlobalNamespace, labels.SelectorFromSet(labels.Set{Label: "true"}))
+	if err != nil {
+		return false, err
+	}
+	return len(cms) >= t.maxRecords, nil
+}
+
+// Usernames returns the usernames the user logs in with by auth provider: the username of local users, and the
+// usernames the UserAttribute of the user has for the other providers, like LDAP and Active Directory.
+func Usernames(user *v3.User, attribs *v3.UserAttribute) map[string][]string {
+	usernames := map[string][]string{}
+	if user.Username != "" {
+		usernames[local.Name] = []string{user.Username}
+	}
+	if attribs == nil {
+		return usernames
+	}
+	for provider, extras := range attribs.ExtraByProvider {
+		if provider == local.Name {
+			continue
+		}
+		for _, username := range extras[common.UserAttributeUserName] {
+			if username != "" {
+				usernames[provider] = append(usernames[provider], username)
+			}
+		}
+	}
+	return usernames
+}
+
+func (t *Tracker) delete(name string) error {
+	err := t.configMaps.Delete(namespace.GlobalNamespace, name, &metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// lockoutDuration returns how long the given lockout of a subject lasts, doubling with each lockout.
+func lockoutDuration(lockouts int, policy Policy) time.Duration {
+	duration := policy.Duration
+	for i := 1; i < lockouts && duration < policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	if policy.MaxDuration > 0 && duration > policy.MaxDuration {
+		duration = policy.MaxDuration
+	}
+	return duration
+}
+
+// expired returns true if the subject isn't locked out and had no failure for maxDuration.
+func expired(cm *corev1.ConfigMap, now time.Time, maxDuration time.Duration) bool {
+	if parseTime(cm.Data[keyLockedUntil]).After(now) {
+		return false
+	}
+	return now.Sub(parseTime(cm.Data[keyLastFailure])) > maxDuration
+}
+
+// recordName returns the name of the config map counting the failures of the subject. Usernames and IPs aren't valid
+// names, so they are hashed. Usernames are case-insensitive for most providers, so the case of the subject is ignored
+// to count the failures of all its spellings together.
+func recordName(kind, provider, subject string) string {
+	sum := sha256.Sum256([]byte(provider + "/" + strings.ToLower(subject)))
+	return "login-" + kind + "-" + hex.EncodeToString(sum[:])[:32]
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
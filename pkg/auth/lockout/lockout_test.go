@@ -0,0 +1,250 @@
+package lockout
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var testPolicy = Policy{
+	MaxUserAttempts: 3,
+	MaxIPAttempts:   5,
+	Duration:        time.Minute,
+	MaxDuration:     5 * time.Minute,
+}
+
+// newTestTracker returns a tracker backed by an in-memory map of config maps by name, whose updates fail with a
+// conflict unless the resource version matches, and a pointer to the current time of the tracker.
+func newTestTracker(t *testing.T) (*Tracker, map[string]*corev1.ConfigMap, *time.Time) {
+	ctrl := gomock.NewController(t)
+	configMaps := map[string]*corev1.ConfigMap{}
+	version := 0
+	get := func(ns, name string) (*corev1.ConfigMap, error) {
+		assert.Equal(t, namespace.GlobalNamespace, ns)
+		if cm, ok := configMaps[name]; ok {
+			return cm.DeepCopy(), nil
+		}
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	save := func(cm *corev1.ConfigMap) *corev1.ConfigMap {
+		version++
+		cm = cm.DeepCopy()
+		cm.ResourceVersion = strconv.Itoa(version)
+		configMaps[cm.Name] = cm
+		return cm.DeepCopy()
+	}
+
+	client := fake.NewMockClientInterface[*corev1.ConfigMap, *corev1.ConfigMapList](ctrl)
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ns, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+			return get(ns, name)
+		}).AnyTimes()
+	client.EXPECT().Create(gomock.Any()).DoAndReturn(func(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		if _, ok := configMaps[cm.Name]; ok {
+			return nil, apierrors.NewAlreadyExists(corev1.Resource("configmaps"), cm.Name)
+		}
+		return save(cm), nil
+	}).AnyTimes()
+	client.EXPECT().Update(gomock.Any()).DoAndReturn(func(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		if existing, ok := configMaps[cm.Name]; !ok || existing.ResourceVersion != cm.ResourceVersion {
+			return nil, apierrors.NewConflict(corev1.Resource("configmaps"), cm.Name, nil)
+		}
+		return save(cm), nil
+	}).AnyTimes()
+	client.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_, name string, _ *metav1.DeleteOptions) error {
+			if _, ok := configMaps[name]; !ok {
+				return apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+			}
+			delete(configMaps, name)
+			return nil
+		}).AnyTimes()
+
+	cache := fake.NewMockCacheInterface[*corev1.ConfigMap](ctrl)
+	cache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(get).AnyTimes()
+	cache.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+		var list []*corev1.ConfigMap
+		for _, cm := range configMaps {
+			if selector.Matches(labels.Set(cm.Labels)) {
+				list = append(list, cm.DeepCopy())
+			}
+		}
+		return list, nil
+	}).AnyTimes()
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(client, cache, func() Policy { return testPolicy })
+	tracker.now = func() time.Time { return now }
+	return tracker, configMaps, &now
+}
+
+func TestUserLockout(t *testing.T) {
+	tracker, _, now := newTestTracker(t)
+
+	for i := 0; i < testPolicy.MaxUserAttempts-1; i++ {
+		lockedUntil, err := tracker.Failure("local", "admin", "10.0.0.1", true)
+		require.NoError(t, err)
+		assert.True(t, lockedUntil.IsZero())
+	}
+	assert.True(t, tracker.LockedUntil("local", "admin", "10.0.0.2").IsZero())
+
+	lockedUntil, err := tracker.Failure("local", "Admin", "10.0.0.1", true)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), lockedUntil, "usernames are case-insensitive")
+	assert.Equal(t, lockedUntil, tracker.LockedUntil("local", "admin", "10.0.0.2"))
+	assert.True(t, tracker.LockedUntil("activedirectory", "admin", "10.0.0.2").IsZero(), "lockouts are per provider")
+
+	// the next lockout lasts twice as long, up to the max duration
+	*now = now.Add(2 * time.Minute)
+	assert.True(t, tracker.LockedUntil("local", "admin", "10.0.0.2").IsZero())
+	for _, expected := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		for i := 0; i < testPolicy.MaxUserAttempts; i++ {
+			lockedUntil, err = tracker.Failure("local", "admin", "10.0.0.2", true)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, now.Add(expected), lockedUntil)
+	}
+
+	// failures are forgotten once none happened for the max duration
+	*now = now.Add(11 * time.Minute)
+	for i := 0; i < testPolicy.MaxUserAttempts; i++ {
+		lockedUntil, err = tracker.Failure("local", "admin", "10.0.0.3", true)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, now.Add(time.Minute), lockedUntil)
+
+	require.NoError(t, tracker.Unlock("local", "admin"))
+	assert.True(t, tracker.LockedUntil("local", "admin", "10.0.0.4").IsZero())
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	tracker, _, now := newTestTracker(t)
+
+	for i := 0; i < testPolicy.MaxUserAttempts-1; i++ {
+		_, err := tracker.Failure("local", "admin", "10.0.0.1", true)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tracker.Success("local", "admin"))
+	require.NoError(t, tracker.Success("local", "other"))
+	lockedUntil, err := tracker.Failure("local", "admin", "10.0.0.1", true)
+	require.NoError(t, err)
+	assert.True(t, lockedUntil.IsZero())
+
+	// the failures of the IP are kept after a success, so logging in between guesses doesn't avoid its lockout
+	require.NoError(t, tracker.Success("local", "mine"))
+	for i := 0; i < testPolicy.MaxIPAttempts-2; i++ {
+		_, err := tracker.Failure("local", "user"+strconv.Itoa(i), "10.0.0.1", false)
+		require.NoError(t, err)
+		require.NoError(t, tracker.Success("local", "mine"))
+	}
+	assert.Equal(t, now.Add(time.Minute), tracker.LockedUntil("local", "mine", "10.0.0.1"))
+}
+
+func TestSettingsPolicy(t *testing.T) {
+	defer settings.TrustedProxyCIDRs.Set(settings.TrustedProxyCIDRs.Default)
+	defer settings.AuthLoginMaxIPAttempts.Set(settings.AuthLoginMaxIPAttempts.Default)
+	require.NoError(t, settings.AuthLoginMaxIPAttempts.Set("100"))
+
+	require.NoError(t, settings.TrustedProxyCIDRs.Set(""))
+	assert.Zero(t, SettingsPolicy().MaxIPAttempts, "IPs aren't locked out without trusted proxies")
+
+	require.NoError(t, settings.TrustedProxyCIDRs.Set("10.42.0.0/16"))
+	assert.Equal(t, 100, SettingsPolicy().MaxIPAttempts)
+}
+
+func TestUsernames(t *testing.T) {
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-1"}, Username: "admin"}
+	attribs := &v3.UserAttribute{
+		ExtraByProvider: map[string]map[string][]string{
+			"local":           {"username": {"ignored"}},
+			"activedirectory": {"username": {"jdoe"}, "principalid": {"activedirectory_user://CN=jdoe"}},
+			"github":          {"principalid": {"github_user://1"}},
+		},
+	}
+	assert.Equal(t, map[string][]string{"local": {"admin"}, "activedirectory": {"jdoe"}}, Usernames(user, attribs))
+	assert.Equal(t, map[string][]string{"local": {"admin"}}, Usernames(user, nil))
+	assert.Empty(t, Usernames(&v3.User{}, nil))
+}
+
+func TestIPLockout(t *testing.T) {
+	tracker, _, now := newTestTracker(t)
+
+	// guessing one password of many users locks out the IP
+	for i := 0; i < testPolicy.MaxIPAttempts; i++ {
+		lockedUntil, err := tracker.Failure("local", "user"+strconv.Itoa(i), "10.0.0.1", false)
+		require.NoError(t, err)
+		assert.True(t, lockedUntil.IsZero())
+	}
+	assert.Equal(t, now.Add(time.Minute), tracker.LockedUntil("local", "admin", "10.0.0.1"))
+	assert.True(t, tracker.LockedUntil("local", "admin", "10.0.0.2").IsZero())
+}
+
+func TestUnknownUsername(t *testing.T) {
+	tracker, configMaps, _ := newTestTracker(t)
+
+	for i := 0; i < testPolicy.MaxUserAttempts; i++ {
+		lockedUntil, err := tracker.Failure("local", "nobody", "10.0.0.1", false)
+		require.NoError(t, err)
+		assert.True(t, lockedUntil.IsZero())
+	}
+	assert.NotContains(t, configMaps, recordName(kindUser, "local", "nobody"))
+	assert.Contains(t, configMaps, recordName(kindIP, "", "10.0.0.1"))
+}
+
+func TestMaxRecords(t *testing.T) {
+	tracker, configMaps, _ := newTestTracker(t)
+	tracker.maxRecords = 2
+
+	_, err := tracker.Failure("local", "admin", "10.0.0.1", true)
+	require.NoError(t, err)
+	_, err = tracker.Failure("local", "other", "10.0.0.2", true)
+	require.NoError(t, err)
+	assert.Len(t, configMaps, 2)
+
+	// existing records are still updated
+	for i := 1; i < testPolicy.MaxUserAttempts; i++ {
+		_, err = tracker.Failure("local", "admin", "10.0.0.1", true)
+		require.NoError(t, err)
+	}
+	assert.False(t, tracker.LockedUntil("local", "admin", "10.0.0.3").IsZero())
+}
+
+func TestDisabledLockout(t *testing.T) {
+	tracker, configMaps, _ := newTestTracker(t)
+	tracker.policy = func() Policy { return Policy{} }
+
+	for i := 0; i < 10; i++ {
+		lockedUntil, err := tracker.Failure("local", "admin", "10.0.0.1", true)
+		require.NoError(t, err)
+		assert.True(t, lockedUntil.IsZero())
+	}
+	assert.Empty(t, configMaps)
+}
+
+func TestCleanup(t *testing.T) {
+	tracker, configMaps, now := newTestTracker(t)
+
+	_, err := tracker.Failure("local", "old", "10.0.0.1", true)
+	require.NoError(t, err)
+	*now = now.Add(testPolicy.MaxDuration + time.Second)
+	_, err = tracker.Failure("local", "recent", "10.0.0.2", true)
+	require.NoError(t, err)
+	require.Len(t, configMaps, 4)
+
+	require.NoError(t, tracker.cleanup())
+	assert.Len(t, configMaps, 2)
+	assert.Contains(t, configMaps, recordName(kindUser, "local", "recent"))
+	assert.Contains(t, configMaps, recordName(kindIP, "", "10.0.0.2"))
+}
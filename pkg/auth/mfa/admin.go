@@ -0,0 +1,30 @@
+package mfa
+
+import (
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/rbac"
+)
+
+// adminRoles are the global roles whose users are required to use TOTP when MFA is required for admins.
+var adminRoles = map[string]bool{
+	rbac.GlobalAdmin:           true,
+	rbac.GlobalRestrictedAdmin: true,
+}
+
+// HasAdminRole returns true if any of the global role bindings binds an admin role to the user, or to one of the
+// group principals of the user.
+func HasAdminRole(grbs []*v3.GlobalRoleBinding, userName string, groupPrincipals []string) bool {
+	groups := make(map[string]bool, len(groupPrincipals))
+	for _, group := range groupPrincipals {
+		groups[group] = true
+	}
+	for _, grb := range grbs {
+		if !adminRoles[grb.GlobalRoleName] {
+			continue
+		}
+		if grb.UserName == userName || (grb.GroupPrincipalName != "" && groups[grb.GroupPrincipalName]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package mfa
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasAdminRole(t *testing.T) {
+	grbs := []*v3.GlobalRoleBinding{
+		{UserName: "u-admin", GlobalRoleName: "admin"},
+		{UserName: "u-restricted", GlobalRoleName: "restricted-admin"},
+		{UserName: "u-user", GlobalRoleName: "user"},
+		{GroupPrincipalName: "local://g-admins", GlobalRoleName: "admin"},
+		{GroupPrincipalName: "local://g-users", GlobalRoleName: "user"},
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		groups []string
+		want   bool
+	}{
+		{name: "admin", user: "u-admin", want: true},
+		{name: "restricted admin", user: "u-restricted", want: true},
+		{name: "user", user: "u-user", groups: []string{"local://g-users"}},
+		{name: "member of admin group", user: "u-user", groups: []string{"local://g-admins"}, want: true},
+		{name: "no bindings", user: "u-other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HasAdminRole(grbs, tt.user, tt.groups))
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	secretPrefix = "totp-"
+
+	secretKey        = "secret"
+	enabledKey       = "enabled"
+	lastStepKey      = "lastStep"
+	recoveryCodesKey = "recoveryCodes"
+
+	// recoveryCodeCount is the number of recovery codes generated when TOTP is enabled, each of which can be used once
+	// instead of a code if the authenticator of the user is lost.
+	recoveryCodeCount = 10
+)
+
+var (
+	// ErrAlreadyEnabled is returned when enrolling a user which already has TOTP enabled.
+	ErrAlreadyEnabled = errors.New("TOTP is already enabled")
+	// ErrNotEnrolled is returned when activating TOTP for a user which hasn't enrolled.
+	ErrNotEnrolled = errors.New("TOTP enrollment not found")
+	// ErrInvalidCode is returned when a code or recovery code is invalid or was already used.
+	ErrInvalidCode = errors.New("invalid TOTP code")
+)
+
+// Store manages the TOTP secrets and recovery codes of users, which are kept in a secret per user in the auth secrets
+// namespace owned by the user.
+type Store struct {
+	secrets     corecontrollers.SecretClient
+	secretCache corecontrollers.SecretCache
+	now         func() time.Time
+}
+
+// NewStore returns a Store using the given secret client and cache.
+func NewStore(secrets corecontrollers.SecretClient, secretCache corecontrollers.SecretCache) *Store {
+	return &Store{
+		secrets:     secrets,
+		secretCache: secretCache,
+		now:         time.Now,
+	}
+}
+
+// Enabled returns true if the user has activated TOTP. The secret of the user is read from the API server, so that a
+// stale cache never lets a user log in without a code.
+func (s *Store) Enabled(userName string) (bool, error) {
+	secret, err := s.secrets.Get(common.SecretsNamespace, secretName(userName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return enabled(secret), nil
+}
+
+// EnabledFromCache returns true if the user has activated TOTP according to the cache, for display purposes only.
+func (s *Store) EnabledFromCache(userName string) (bool, error) {
+	secret, err := s.secretCache.Get(common.SecretsNamespace, secretName(userName))
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return enabled(secret), nil
+}
+
+// Enroll generates a new secret for the user, which isn't required to log in until it is activated with a valid code.
+// It returns the secret and its provisioning URI.
+func (s *Store) Enroll(user *v3.User) (string, string, error) {
+	totpSecret, err := GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := s.secrets.Get(common.SecretsNamespace, secretName(user.Name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = s.secrets.Create(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName(user.Name),
+				Namespace: common.SecretsNamespace,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "management.cattle.io/v3",
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				}},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{secretKey: []byte(totpSecret)},
+		})
+	} else if err == nil {
+		if enabled(secret) {
+			return "", "", ErrAlreadyEnabled
+		}
+		secret = secret.DeepCopy()
+		secret.Data = map[string][]byte{secretKey: []byte(totpSecret)}
+		_, err = s.secrets.Update(secret)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	account := user.Username
+	if account == "" {
+		account = user.Name
+	}
+	return totpSecret, ProvisioningURI(totpSecret, account), nil
+}
+
+// Activate enables TOTP for the user if the code is valid for the enrolled secret. It returns the recovery codes of the
+// user, which are only stored hashed and can't be retrieved again.
+func (s *Store) Activate(userName, code string) ([]string, error) {
+	secret, err := s.secrets.Get(common.SecretsNamespace, secretName(userName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrNotEnrolled
+	} else if err != nil {
+		return nil, err
+	}
+	if enabled(secret) {
+		return nil, ErrAlreadyEnabled
+	}
+	step, ok := validateCode(string(secret.Data[secretKey]), code, s.now(), 0)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	secret = secret.DeepCopy()
+	secret.Data[enabledKey] = []byte("true")
+	secret.Data[lastStepKey] = []byte(strconv.FormatUint(step, 10))
+	secret.Data[recoveryCodesKey] = []byte(strings.Join(hashes, "\n"))
+	if _, err := s.secrets.Update(secret); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// Verify returns ErrInvalidCode unless the code, or else the recovery code, is valid for the user, and marks it as
+// used. The secret is read from the API server rather than the cache and updated with optimistic concurrency, so a
+// code can only be used once even when it is sent to several servers at the same time.
+func (s *Store) Verify(userName, code, recoveryCode string) error {
+	secret, err := s.secrets.Get(common.SecretsNamespace, secretName(userName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return ErrInvalidCode
+	} else if err != nil {
+		return err
+	}
+	if !enabled(secret) {
+		return ErrInvalidCode
+	}
+
+	secret = secret.DeepCopy()
+	switch {
+	case code != "":
+		lastStep, _ := strconv.ParseUint(string(secret.Data[lastStepKey]), 10, 64)
+		step, ok := validateCode(string(secret.Data[secretKey]), code, s.now(), lastStep)
+		if !ok {
+			return ErrInvalidCode
+		}
+		secret.Data[lastStepKey] = []byte(strconv.FormatUint(step, 10))
+	case recoveryCode != "":
+		hashes, ok := useRecoveryCode(strings.Fields(string(secret.Data[recoveryCodesKey])), recoveryCode)
+		if !ok {
+			return ErrInvalidCode
+		}
+		secret.Data[recoveryCodesKey] = []byte(strings.Join(hashes, "\n"))
+	default:
+		return ErrInvalidCode
+	}
+
+	_, err = s.secrets.Update(secret)
+	if apierrors.IsConflict(err) {
+		return ErrInvalidCode
+	}
+	return err
+}
+
+// Disable removes the TOTP secret and recovery codes of the user.
+func (s *Store) Disable(userName string) error {
+	err := s.secrets.Delete(common.SecretsNamespace, secretName(userName), &metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func secretName(userName string) string {
+	return secretPrefix + userName
+}
+
+func enabled(secret *corev1.Secret) bool {
+	return string(secret.Data[enabledKey]) == "true"
+}
+
+// generateRecoveryCodes returns new recovery codes and their hashes.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		h := hex.EncodeToString(b)
+		code := fmt.Sprintf("%s-%s", h[:5], h[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// useRecoveryCode returns the hashes without the hash of the code, and whether the code was found.
+func useRecoveryCode(hashes []string, code string) ([]string, bool) {
+	hash := hashRecoveryCode(code)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return append(hashes[:i:i], hashes[i+1:]...), true
+		}
+	}
+	return hashes, false
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
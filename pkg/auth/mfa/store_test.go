@@ -0,0 +1,144 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// newTestStore returns a store backed by an in-memory map of secrets by name.
+func newTestStore(t *testing.T, now time.Time) (*Store, map[string]*corev1.Secret) {
+	ctrl := gomock.NewController(t)
+	secrets := map[string]*corev1.Secret{}
+	notFound := func(name string) error {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	get := func(namespace, name string) (*corev1.Secret, error) {
+		assert.Equal(t, common.SecretsNamespace, namespace)
+		if secret, ok := secrets[name]; ok {
+			return secret.DeepCopy(), nil
+		}
+		return nil, notFound(name)
+	}
+
+	client := fake.NewMockClientInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(namespace, name string, _ metav1.GetOptions) (*corev1.Secret, error) {
+			return get(namespace, name)
+		}).AnyTimes()
+	client.EXPECT().Create(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		secrets[secret.Name] = secret.DeepCopy()
+		return secret, nil
+	}).AnyTimes()
+	client.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		if _, ok := secrets[secret.Name]; !ok {
+			return nil, notFound(secret.Name)
+		}
+		secrets[secret.Name] = secret.DeepCopy()
+		return secret, nil
+	}).AnyTimes()
+	client.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_, name string, _ *metav1.DeleteOptions) error {
+			if _, ok := secrets[name]; !ok {
+				return notFound(name)
+			}
+			delete(secrets, name)
+			return nil
+		}).AnyTimes()
+
+	cache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+	cache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(get).AnyTimes()
+
+	store := NewStore(client, cache)
+	store.now = func() time.Time { return now }
+	return store, secrets
+}
+
+func codeAt(t *testing.T, secret string, now time.Time) string {
+	key, err := encoding.DecodeString(secret)
+	require.NoError(t, err)
+	return generateCode(key, uint64(now.Unix())/period)
+}
+
+func TestStoreEnrollAndActivate(t *testing.T) {
+	now := time.Now()
+	store, secrets := newTestStore(t, now)
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abcde", UID: "uid"}, Username: "admin"}
+
+	totpSecret, uri, err := store.Enroll(user)
+	require.NoError(t, err)
+	assert.Equal(t, ProvisioningURI(totpSecret, "admin"), uri)
+	require.Contains(t, secrets, "totp-u-abcde")
+	assert.Equal(t, "uid", string(secrets["totp-u-abcde"].OwnerReferences[0].UID))
+
+	// TOTP isn't required until the enrollment is activated
+	enabled, err := store.Enabled(user.Name)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.ErrorIs(t, store.Verify(user.Name, codeAt(t, totpSecret, now), ""), ErrInvalidCode)
+
+	// enrolling again replaces the secret
+	totpSecret, _, err = store.Enroll(user)
+	require.NoError(t, err)
+
+	_, err = store.Activate(user.Name, "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	recoveryCodes, err := store.Activate(user.Name, codeAt(t, totpSecret, now))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+
+	enabled, err = store.Enabled(user.Name)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	enabled, err = store.EnabledFromCache(user.Name)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	_, _, err = store.Enroll(user)
+	assert.ErrorIs(t, err, ErrAlreadyEnabled)
+	_, err = store.Activate(user.Name, codeAt(t, totpSecret, now))
+	assert.ErrorIs(t, err, ErrAlreadyEnabled)
+
+	require.NoError(t, store.Disable(user.Name))
+	assert.NotContains(t, secrets, "totp-u-abcde")
+	require.NoError(t, store.Disable(user.Name))
+
+	_, err = store.Activate(user.Name, codeAt(t, totpSecret, now))
+	assert.ErrorIs(t, err, ErrNotEnrolled)
+}
+
+func TestStoreVerify(t *testing.T) {
+	now := time.Now()
+	store, _ := newTestStore(t, now)
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abcde"}}
+
+	totpSecret, _, err := store.Enroll(user)
+	require.NoError(t, err)
+	// activate with the code of the previous period, so that the current one hasn't been used yet
+	recoveryCodes, err := store.Activate(user.Name, codeAt(t, totpSecret, now.Add(-period*time.Second)))
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, store.Verify(user.Name, "", ""), ErrInvalidCode)
+	assert.ErrorIs(t, store.Verify(user.Name, "000000", ""), ErrInvalidCode)
+
+	code := codeAt(t, totpSecret, now)
+	require.NoError(t, store.Verify(user.Name, code, ""))
+	assert.ErrorIs(t, store.Verify(user.Name, code, ""), ErrInvalidCode, "codes can only be used once")
+
+	require.NoError(t, store.Verify(user.Name, "", " "+recoveryCodes[3]+" "))
+	assert.ErrorIs(t, store.Verify(user.Name, "", recoveryCodes[3]), ErrInvalidCode, "recovery codes can only be used once")
+	require.NoError(t, store.Verify(user.Name, "", recoveryCodes[4]))
+	assert.ErrorIs(t, store.Verify(user.Name, "", "00000-00000"), ErrInvalidCode)
+
+	assert.ErrorIs(t, store.Verify("u-other", code, ""), ErrInvalidCode)
+}
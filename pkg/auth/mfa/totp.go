@@ -0,0 +1,102 @@
+// Package mfa implements time-based one-time passwords (RFC 6238) as a second factor for local users.
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Issuer is the issuer shown by authenticator apps next to the account of the user.
+	Issuer = "Rancher"
+
+	// period is the number of seconds a code is valid for.
+	period = 30
+	// digits is the number of digits of a code.
+	digits = 6
+	// skew is the number of periods before and after the current one whose codes are accepted, to allow for clock
+	// drift between the server and the authenticator.
+	skew = 1
+	// secretSize is the size of the shared secret in bytes, which is the size of the output of HMAC-SHA1 as recommended
+	// by RFC 4226.
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random shared secret encoded as unpadded base32, the format expected by authenticator apps.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI of the secret, which is encoded as a QR code to be scanned by
+// authenticator apps.
+func ProvisioningURI(secret, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", Issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(digits))
+	query.Set("period", fmt.Sprint(period))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + Issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// generateCode returns the code of the secret for the given time step as described in RFC 4226.
+func generateCode(secret []byte, step uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, step)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// validateCode returns the time step of the code if it is valid for the secret at now. Codes of steps up to lastStep
+// were already used and are rejected, so that a code can't be replayed.
+func validateCode(secret, code string, now time.Time, lastStep uint64) (uint64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != digits {
+		return 0, false
+	}
+
+	current := uint64(now.Unix()) / period
+	for i := -skew; i <= skew; i++ {
+		step := uint64(int64(current) + int64(i))
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(generateCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
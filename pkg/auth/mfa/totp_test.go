@@ -0,0 +1,83 @@
+package mfa
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238, "12345678901234567890", encoded as base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateCode(t *testing.T) {
+	// the test vectors of RFC 6238 truncated to 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+	for _, tt := range tests {
+		step, ok := validateCode(rfcSecret, tt.code, time.Unix(tt.unix, 0), 0)
+		assert.True(t, ok, "code %s at %d", tt.code, tt.unix)
+		assert.Equal(t, uint64(tt.unix/period), step)
+	}
+}
+
+func TestValidateCodeSkewAndReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := uint64(now.Unix() / period)
+
+	// a code of the previous or next period is accepted for clock drift, but not one further away
+	_, ok := validateCode(rfcSecret, "081804", now.Add(period*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = validateCode(rfcSecret, "081804", now.Add(-period*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = validateCode(rfcSecret, "081804", now.Add(2*period*time.Second), 0)
+	assert.False(t, ok)
+
+	// a code which was already used is rejected
+	_, ok = validateCode(rfcSecret, "081804", now, step)
+	assert.False(t, ok)
+
+	_, ok = validateCode(rfcSecret, "000000", now, 0)
+	assert.False(t, ok)
+	_, ok = validateCode(rfcSecret, "08180", now, 0)
+	assert.False(t, ok)
+	_, ok = validateCode("not base32!", "081804", now, 0)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	key, err := encoding.DecodeString(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, secretSize)
+
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI(rfcSecret, "admin"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Rancher:admin", u.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {rfcSecret},
+		"issuer":    {"Rancher"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, u.Query())
+}
@@ -258,7 +258,10 @@ func (r *refresher) refreshAttributes(attribs *v3.UserAttribute) (*v3.UserAttrib
 
 		if principalID != "" && !errorConfirmingLogins {
 			// We want to verify that the user still has rancher access
-			canStillAccess, err := providers.CanAccessWithGroupProviders(providerName, principalID, newGroupPrincipals)
+			// the groups the user was made a member of by SCIM provisioning can also be allowed to access Rancher
+			groupPrincipals := append([]v3.Principal{}, newGroupPrincipals...)
+			groupPrincipals = append(groupPrincipals, attribs.GroupPrincipals[tokens.SCIMGroupPrincipalsKey(providerName)].Items...)
+			canStillAccess, err := providers.CanAccessWithGroupProviders(providerName, principalID, groupPrincipals)
 			if err != nil {
 				return nil, err
 			}
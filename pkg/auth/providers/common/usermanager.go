@@ -273,6 +273,7 @@ func (m *userManager) EnsureClusterToken(clusterName string, input user.TokenInp
 		IsDerived:     true,
 		Token:         key,
 		ClusterName:   clusterName,
+		Scope:         input.Scope.DeepCopy(),
 	}
 	if input.TTL != nil {
 		token.TTLMillis = *input.TTL
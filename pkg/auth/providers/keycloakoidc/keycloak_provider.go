@@ -42,6 +42,7 @@ func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, userMGR user.
 			Secrets:     mgmtCtx.Core.Secrets(""),
 			UserMGR:     userMGR,
 			TokenMGR:    tokenMGR,
+			RoleBinder:  oidc.NewClaimRuleBinder(mgmtCtx.Wrangler.Mgmt),
 		},
 	}
 }
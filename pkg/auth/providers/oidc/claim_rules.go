@@ -0,0 +1,397 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	controllers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	claimRuleUserLabel      = "authn.management.cattle.io/claim-rule-user"
+	claimRuleProviderLabel  = "authn.management.cattle.io/claim-rule-provider"
+	claimRuleNameAnnotation = "authn.management.cattle.io/claim-rule"
+	// claimRuleConfirmedAnnotation is the last time the claims of the user were found to match the rule of a binding.
+	claimRuleConfirmedAnnotation = "authn.management.cattle.io/claim-rule-confirmed"
+)
+
+// ClaimRuleBinder grants users the roles of the claim rules their claims match. The bindings it creates are labeled
+// with the user and auth provider, so that the bindings of rules which no longer match are removed at the next login,
+// and so that they can be reconciled with the rules of the auth provider in between.
+type ClaimRuleBinder struct {
+	grbCache  controllers.GlobalRoleBindingCache
+	grbs      controllers.GlobalRoleBindingClient
+	crtbCache controllers.ClusterRoleTemplateBindingCache
+	crtbs     controllers.ClusterRoleTemplateBindingClient
+	prtbCache controllers.ProjectRoleTemplateBindingCache
+	prtbs     controllers.ProjectRoleTemplateBindingClient
+}
+
+// NewClaimRuleBinder returns a ClaimRuleBinder which uses the management clients.
+func NewClaimRuleBinder(c controllers.Interface) *ClaimRuleBinder {
+	return &ClaimRuleBinder{
+		grbCache:  c.GlobalRoleBinding().Cache(),
+		grbs:      c.GlobalRoleBinding(),
+		crtbCache: c.ClusterRoleTemplateBinding().Cache(),
+		crtbs:     c.ClusterRoleTemplateBinding(),
+		prtbCache: c.ProjectRoleTemplateBinding().Cache(),
+		prtbs:     c.ProjectRoleTemplateBinding(),
+	}
+}
+
+// claimRuleBinding is a binding created by a claim rule.
+type claimRuleBinding struct {
+	meta metav1.ObjectMeta
+	// target is the role granted by the binding, preceded by the cluster or project it is granted in.
+	target  []string
+	delete  func() error
+	confirm func(confirmed string) error
+}
+
+// claimRuleBindingKind lists, creates and finds the targets of the bindings of one type.
+type claimRuleBindingKind struct {
+	list    func(selector labels.Selector) ([]claimRuleBinding, error)
+	targets func(rule v32.OIDCClaimRule) [][]string
+	create  func(rule v32.OIDCClaimRule, target []string, objectMeta objectMetaFunc) error
+}
+
+type objectMetaFunc func(namespace, generateName string) metav1.ObjectMeta
+
+// Apply creates the bindings of the rules matching the claims for the user, and deletes the bindings of the user
+// created for the auth provider by rules which no longer match or no longer exist. The bindings which are kept are
+// marked as confirmed.
+func (b *ClaimRuleBinder) Apply(provider, userName, userPrincipalName string, rules []v32.OIDCClaimRule, claims map[string]interface{}) error {
+	var matched []v32.OIDCClaimRule
+	for _, rule := range rules {
+		ok, err := matchClaimRule(rule, claims)
+		if err != nil {
+			return err
+		}
+		if ok {
+			matched = append(matched, rule)
+		}
+	}
+
+	bindingLabels := map[string]string{
+		claimRuleUserLabel:     userName,
+		claimRuleProviderLabel: provider,
+	}
+	selector := labels.SelectorFromSet(bindingLabels)
+	confirmed := time.Now().UTC().Format(time.RFC3339)
+
+	for _, kind := range b.kinds(userName, userPrincipalName) {
+		kept, err := pruneClaimRuleBindings(kind, matched, selector, func(metav1.ObjectMeta) bool { return false })
+		if err != nil {
+			return err
+		}
+		for _, binding := range kept {
+			if binding.meta.Annotations[claimRuleConfirmedAnnotation] == confirmed {
+				continue
+			}
+			if err := binding.confirm(confirmed); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		have := map[string]bool{}
+		for _, binding := range kept {
+			have[ruleTargetKey(binding.meta.Annotations[claimRuleNameAnnotation], binding.target)] = true
+		}
+		for _, rule := range matched {
+			for _, target := range kind.targets(rule) {
+				key := ruleTargetKey(rule.Name, target)
+				if have[key] {
+					continue
+				}
+				objectMeta := func(namespace, generateName string) metav1.ObjectMeta {
+					return metav1.ObjectMeta{
+						Namespace:    namespace,
+						GenerateName: generateName,
+						Labels:       bindingLabels,
+						Annotations: map[string]string{
+							claimRuleNameAnnotation:      rule.Name,
+							claimRuleConfirmedAnnotation: confirmed,
+						},
+					}
+				}
+				if err := kind.create(rule, target, objectMeta); err != nil {
+					return err
+				}
+				have[key] = true
+			}
+		}
+	}
+	return nil
+}
+
+// Reconcile deletes the bindings created for the auth provider by rules which no longer exist or no longer grant them,
+// and those which haven't been confirmed by a login or a refresh of their user since confirmedAfter, as the claims of
+// the user may have changed since. All bindings of the provider are deleted if it has no rules, like when it is
+// disabled.
+func (b *ClaimRuleBinder) Reconcile(provider string, rules []v32.OIDCClaimRule, confirmedAfter time.Time) error {
+	selector := labels.SelectorFromSet(labels.Set{claimRuleProviderLabel: provider})
+	expired := func(meta metav1.ObjectMeta) bool {
+		confirmed, err := time.Parse(time.RFC3339, meta.Annotations[claimRuleConfirmedAnnotation])
+		return err != nil || confirmed.Before(confirmedAfter)
+	}
+	for _, kind := range b.kinds("", "") {
+		if _, err := pruneClaimRuleBindings(kind, rules, selector, expired); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckEscalation returns an error unless the user saving the claim rules holds every role they grant: the user must
+// either be bound to the role, or be allowed to bind it. canBind returns true if the user can bind the role of the
+// given resource, globalroles or roletemplates, and name.
+func (b *ClaimRuleBinder) CheckEscalation(userName string, rules []v32.OIDCClaimRule, canBind func(resource, name string) bool) error {
+	for _, rule := range rules {
+		for _, globalRole := range rule.GlobalRoles {
+			if canBind("globalroles", globalRole) {
+				continue
+			}
+			grbs, err := b.grbCache.List(labels.Everything())
+			if err != nil {
+				return err
+			}
+			if !containsBinding(len(grbs), func(i int) bool {
+				return grbs[i].UserName == userName && grbs[i].GlobalRoleName == globalRole
+			}) {
+				return fmt.Errorf("claim rule %s can't grant global role %s which user %s doesn't hold", rule.Name, globalRole, userName)
+			}
+		}
+		for _, role := range rule.ClusterRoles {
+			if canBind("roletemplates", role.RoleTemplateName) {
+				continue
+			}
+			crtbs, err := b.crtbCache.List(role.ClusterName, labels.Everything())
+			if err != nil {
+				return err
+			}
+			if !containsBinding(len(crtbs), func(i int) bool {
+				return crtbs[i].UserName == userName && crtbs[i].ClusterName == role.ClusterName && crtbs[i].RoleTemplateName == role.RoleTemplateName
+			}) {
+				return fmt.Errorf("claim rule %s can't grant role %s in cluster %s which user %s doesn't hold", rule.Name, role.RoleTemplateName, role.ClusterName, userName)
+			}
+		}
+		for _, role := range rule.ProjectRoles {
+			if canBind("roletemplates", role.RoleTemplateName) {
+				continue
+			}
+			parts := strings.SplitN(role.ProjectName, ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid project %s of claim rule %s", role.ProjectName, rule.Name)
+			}
+			prtbs, err := b.prtbCache.List(parts[1], labels.Everything())
+			if err != nil {
+				return err
+			}
+			if !containsBinding(len(prtbs), func(i int) bool {
+				return prtbs[i].UserName == userName && prtbs[i].ProjectName == role.ProjectName && prtbs[i].RoleTemplateName == role.RoleTemplateName
+			}) {
+				return fmt.Errorf("claim rule %s can't grant role %s in project %s which user %s doesn't hold", rule.Name, role.RoleTemplateName, role.ProjectName, userName)
+			}
+		}
+	}
+	return nil
+}
+
+// pruneClaimRuleBindings deletes the bindings of the kind matching the selector which are expired, duplicated, or not
+// granted by any of the rules, and returns the bindings which are kept.
+func pruneClaimRuleBindings(kind claimRuleBindingKind, rules []v32.OIDCClaimRule, selector labels.Selector, expired func(metav1.ObjectMeta) bool) ([]claimRuleBinding, error) {
+	existing, err := kind.list(selector)
+	if err != nil {
+		return nil, err
+	}
+	var kept []claimRuleBinding
+	have := map[string]bool{}
+	for _, binding := range existing {
+		ruleName := binding.meta.Annotations[claimRuleNameAnnotation]
+		key := bindingKey(binding.meta.Labels[claimRuleUserLabel], ruleTargetKey(ruleName, binding.target))
+		if have[key] || expired(binding.meta) || !ruleGrants(kind, rules, ruleName, binding.target) {
+			if err := binding.delete(); err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			continue
+		}
+		have[key] = true
+		kept = append(kept, binding)
+	}
+	return kept, nil
+}
+
+// kinds returns the kinds of bindings created by claim rules for the given user.
+func (b *ClaimRuleBinder) kinds(userName, userPrincipalName string) []claimRuleBindingKind {
+	return []claimRuleBindingKind{
+		{
+			list: func(selector labels.Selector) ([]claimRuleBinding, error) {
+				grbs, err := b.grbCache.List(selector)
+				if err != nil {
+					return nil, err
+				}
+				bindings := make([]claimRuleBinding, 0, len(grbs))
+				for _, grb := range grbs {
+					grb := grb
+					bindings = append(bindings, claimRuleBinding{
+						meta:   grb.ObjectMeta,
+						target: []string{grb.GlobalRoleName},
+						delete: func() error { return b.grbs.Delete(grb.Name, &metav1.DeleteOptions{}) },
+						confirm: func(confirmed string) error {
+							grb := grb.DeepCopy()
+							grb.Annotations[claimRuleConfirmedAnnotation] = confirmed
+							_, err := b.grbs.Update(grb)
+							return err
+						},
+					})
+				}
+				return bindings, nil
+			},
+			targets: func(rule v32.OIDCClaimRule) [][]string {
+				var targets [][]string
+				for _, globalRole := range rule.GlobalRoles {
+					targets = append(targets, []string{globalRole})
+				}
+				return targets
+			},
+			create: func(rule v32.OIDCClaimRule, target []string, objectMeta objectMetaFunc) error {
+				if _, err := b.grbs.Create(&v32.GlobalRoleBinding{
+					ObjectMeta:     objectMeta("", "grb-"),
+					GlobalRoleName: target[0],
+					UserName:       userName,
+				}); err != nil {
+					return fmt.Errorf("failed to grant global role %s of claim rule %s: %w", target[0], rule.Name, err)
+				}
+				return nil
+			},
+		},
+		{
+			list: func(selector labels.Selector) ([]claimRuleBinding, error) {
+				crtbs, err := b.crtbCache.List("", selector)
+				if err != nil {
+					return nil, err
+				}
+				bindings := make([]claimRuleBinding, 0, len(crtbs))
+				for _, crtb := range crtbs {
+					crtb := crtb
+					bindings = append(bindings, claimRuleBinding{
+						meta:   crtb.ObjectMeta,
+						target: []string{crtb.ClusterName, crtb.RoleTemplateName},
+						delete: func() error { return b.crtbs.Delete(crtb.Namespace, crtb.Name, &metav1.DeleteOptions{}) },
+						confirm: func(confirmed string) error {
+							crtb := crtb.DeepCopy()
+							crtb.Annotations[claimRuleConfirmedAnnotation] = confirmed
+							_, err := b.crtbs.Update(crtb)
+							return err
+						},
+					})
+				}
+				return bindings, nil
+			},
+			targets: func(rule v32.OIDCClaimRule) [][]string {
+				var targets [][]string
+				for _, role := range rule.ClusterRoles {
+					targets = append(targets, []string{role.ClusterName, role.RoleTemplateName})
+				}
+				return targets
+			},
+			create: func(rule v32.OIDCClaimRule, target []string, objectMeta objectMetaFunc) error {
+				if _, err := b.crtbs.Create(&v32.ClusterRoleTemplateBinding{
+					ObjectMeta:        objectMeta(target[0], "crtb-"),
+					ClusterName:       target[0],
+					RoleTemplateName:  target[1],
+					UserName:          userName,
+					UserPrincipalName: userPrincipalName,
+				}); err != nil {
+					return fmt.Errorf("failed to grant role %s in cluster %s of claim rule %s: %w", target[1], target[0], rule.Name, err)
+				}
+				return nil
+			},
+		},
+		{
+			list: func(selector labels.Selector) ([]claimRuleBinding, error) {
+				prtbs, err := b.prtbCache.List("", selector)
+				if err != nil {
+					return nil, err
+				}
+				bindings := make([]claimRuleBinding, 0, len(prtbs))
+				for _, prtb := range prtbs {
+					prtb := prtb
+					bindings = append(bindings, claimRuleBinding{
+						meta:   prtb.ObjectMeta,
+						target: []string{prtb.ProjectName, prtb.RoleTemplateName},
+						delete: func() error { return b.prtbs.Delete(prtb.Namespace, prtb.Name, &metav1.DeleteOptions{}) },
+						confirm: func(confirmed string) error {
+							prtb := prtb.DeepCopy()
+							prtb.Annotations[claimRuleConfirmedAnnotation] = confirmed
+							_, err := b.prtbs.Update(prtb)
+							return err
+						},
+					})
+				}
+				return bindings, nil
+			},
+			targets: func(rule v32.OIDCClaimRule) [][]string {
+				var targets [][]string
+				for _, role := range rule.ProjectRoles {
+					targets = append(targets, []string{role.ProjectName, role.RoleTemplateName})
+				}
+				return targets
+			},
+			create: func(rule v32.OIDCClaimRule, target []string, objectMeta objectMetaFunc) error {
+				parts := strings.SplitN(target[0], ":", 2)
+				if len(parts) != 2 {
+					return fmt.Errorf("invalid project %s of claim rule %s", target[0], rule.Name)
+				}
+				if _, err := b.prtbs.Create(&v32.ProjectRoleTemplateBinding{
+					ObjectMeta:        objectMeta(parts[1], "prtb-"),
+					ProjectName:       target[0],
+					RoleTemplateName:  target[1],
+					UserName:          userName,
+					UserPrincipalName: userPrincipalName,
+				}); err != nil {
+					return fmt.Errorf("failed to grant role %s in project %s of claim rule %s: %w", target[1], target[0], rule.Name, err)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// ruleGrants returns true if the rule with the given name grants a binding of the kind for the target.
+func ruleGrants(kind claimRuleBindingKind, rules []v32.OIDCClaimRule, ruleName string, target []string) bool {
+	for _, rule := range rules {
+		if rule.Name != ruleName {
+			continue
+		}
+		for _, granted := range kind.targets(rule) {
+			if bindingKey(granted...) == bindingKey(target...) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsBinding returns true if any of the n bindings matches.
+func containsBinding(n int, matches func(i int) bool) bool {
+	for i := 0; i < n; i++ {
+		if matches(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func bindingKey(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+// ruleTargetKey identifies the binding of a rule for a target.
+func ruleTargetKey(ruleName string, target []string) string {
+	return bindingKey(append([]string{ruleName}, target...)...)
+}
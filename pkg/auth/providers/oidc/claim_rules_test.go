@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestClaimRuleBinderApply(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	grbCache := fake.NewMockNonNamespacedCacheInterface[*v32.GlobalRoleBinding](ctrl)
+	grbs := fake.NewMockNonNamespacedClientInterface[*v32.GlobalRoleBinding, *v32.GlobalRoleBindingList](ctrl)
+	crtbCache := fake.NewMockCacheInterface[*v32.ClusterRoleTemplateBinding](ctrl)
+	crtbs := fake.NewMockClientInterface[*v32.ClusterRoleTemplateBinding, *v32.ClusterRoleTemplateBindingList](ctrl)
+	prtbCache := fake.NewMockCacheInterface[*v32.ProjectRoleTemplateBinding](ctrl)
+	prtbs := fake.NewMockClientInterface[*v32.ProjectRoleTemplateBinding, *v32.ProjectRoleTemplateBindingList](ctrl)
+	binder := &ClaimRuleBinder{
+		grbCache:  grbCache,
+		grbs:      grbs,
+		crtbCache: crtbCache,
+		crtbs:     crtbs,
+		prtbCache: prtbCache,
+		prtbs:     prtbs,
+	}
+
+	existingMeta := func(namespace, name, rule string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      map[string]string{claimRuleUserLabel: "u-jane", claimRuleProviderLabel: Name},
+			Annotations: map[string]string{claimRuleNameAnnotation: rule},
+		}
+	}
+	selector := labels.SelectorFromSet(labels.Set{claimRuleUserLabel: "u-jane", claimRuleProviderLabel: Name})
+
+	// the admin rule still matches, so its binding is kept, while the binding of the removed rule is deleted
+	grbCache.EXPECT().List(selector).Return([]*v32.GlobalRoleBinding{
+		{ObjectMeta: existingMeta("", "grb-kept", "admins"), GlobalRoleName: "admin"},
+		{ObjectMeta: existingMeta("", "grb-removed", "removed"), GlobalRoleName: "user"},
+	}, nil)
+	grbs.EXPECT().Delete("grb-removed", gomock.Any()).Return(nil)
+	grbs.EXPECT().Update(gomock.Any()).DoAndReturn(func(grb *v32.GlobalRoleBinding) (*v32.GlobalRoleBinding, error) {
+		assert.Equal(t, "grb-kept", grb.Name)
+		assert.NotEmpty(t, grb.Annotations[claimRuleConfirmedAnnotation])
+		return grb, nil
+	})
+
+	// the ops rule doesn't match anymore
+	crtbCache.EXPECT().List("", selector).Return([]*v32.ClusterRoleTemplateBinding{
+		{ObjectMeta: existingMeta("c-1", "crtb-ops", "ops"), ClusterName: "c-1", RoleTemplateName: "cluster-owner"},
+	}, nil)
+	crtbs.EXPECT().Delete("c-1", "crtb-ops", gomock.Any()).Return(nil)
+	crtbs.EXPECT().Create(gomock.Any()).DoAndReturn(func(crtb *v32.ClusterRoleTemplateBinding) (*v32.ClusterRoleTemplateBinding, error) {
+		assert.Equal(t, "c-2", crtb.Namespace)
+		assert.Equal(t, "c-2", crtb.ClusterName)
+		assert.Equal(t, "cluster-member", crtb.RoleTemplateName)
+		assert.Equal(t, "u-jane", crtb.UserName)
+		assert.Equal(t, "oidc_user://jane", crtb.UserPrincipalName)
+		assert.Equal(t, "devs", crtb.Annotations[claimRuleNameAnnotation])
+		assert.Equal(t, "u-jane", crtb.Labels[claimRuleUserLabel])
+		assert.NotEmpty(t, crtb.Annotations[claimRuleConfirmedAnnotation])
+		return crtb, nil
+	})
+
+	prtbCache.EXPECT().List("", selector).Return(nil, nil)
+	prtbs.EXPECT().Create(gomock.Any()).DoAndReturn(func(prtb *v32.ProjectRoleTemplateBinding) (*v32.ProjectRoleTemplateBinding, error) {
+		assert.Equal(t, "p-1", prtb.Namespace)
+		assert.Equal(t, "c-2:p-1", prtb.ProjectName)
+		assert.Equal(t, "project-member", prtb.RoleTemplateName)
+		return prtb, nil
+	})
+
+	rules := []v32.OIDCClaimRule{
+		{Name: "admins", Claim: "groups", Value: "admins", GlobalRoles: []string{"admin"}},
+		{
+			Name:         "ops",
+			Claim:        "groups",
+			Value:        "ops",
+			ClusterRoles: []v32.OIDCClaimRuleClusterRole{{ClusterName: "c-1", RoleTemplateName: "cluster-owner"}},
+		},
+		{
+			Name:         "devs",
+			Claim:        "department",
+			Operator:     "contains",
+			Value:        "engineering",
+			ClusterRoles: []v32.OIDCClaimRuleClusterRole{{ClusterName: "c-2", RoleTemplateName: "cluster-member"}},
+			ProjectRoles: []v32.OIDCClaimRuleProjectRole{{ProjectName: "c-2:p-1", RoleTemplateName: "project-member"}},
+		},
+	}
+	claims := map[string]interface{}{
+		"groups":     []interface{}{"admins"},
+		"department": "platform engineering",
+	}
+	require.NoError(t, binder.Apply(Name, "u-jane", "oidc_user://jane", rules, claims))
+}
+
+func TestClaimRuleBinderApplyInvalidRule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	binder := &ClaimRuleBinder{
+		grbCache: fake.NewMockNonNamespacedCacheInterface[*v32.GlobalRoleBinding](ctrl),
+	}
+	rules := []v32.OIDCClaimRule{{Name: "broken", Claim: "email", Operator: "matches", Value: "("}}
+
+	// no bindings are listed or deleted when a rule is invalid
+	assert.Error(t, binder.Apply(Name, "u-jane", "oidc_user://jane", rules, map[string]interface{}{"email": "jane@example.com"}))
+}
+
+func TestClaimRuleBinderReconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	grbCache := fake.NewMockNonNamespacedCacheInterface[*v32.GlobalRoleBinding](ctrl)
+	grbs := fake.NewMockNonNamespacedClientInterface[*v32.GlobalRoleBinding, *v32.GlobalRoleBindingList](ctrl)
+	crtbCache := fake.NewMockCacheInterface[*v32.ClusterRoleTemplateBinding](ctrl)
+	crtbs := fake.NewMockClientInterface[*v32.ClusterRoleTemplateBinding, *v32.ClusterRoleTemplateBindingList](ctrl)
+	prtbCache := fake.NewMockCacheInterface[*v32.ProjectRoleTemplateBinding](ctrl)
+	binder := &ClaimRuleBinder{
+		grbCache:  grbCache,
+		grbs:      grbs,
+		crtbCache: crtbCache,
+		crtbs:     crtbs,
+		prtbCache: prtbCache,
+	}
+
+	now := time.Now()
+	existingMeta := func(namespace, name, user, rule string, confirmed time.Time) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{claimRuleUserLabel: user, claimRuleProviderLabel: Name},
+			Annotations: map[string]string{
+				claimRuleNameAnnotation:      rule,
+				claimRuleConfirmedAnnotation: confirmed.UTC().Format(time.RFC3339),
+			},
+		}
+	}
+	selector := labels.SelectorFromSet(labels.Set{claimRuleProviderLabel: Name})
+
+	// bindings of other users are kept even if they grant the same role, while expired bindings are deleted
+	grbCache.EXPECT().List(selector).Return([]*v32.GlobalRoleBinding{
+		{ObjectMeta: existingMeta("", "grb-jane", "u-jane", "admins", now), GlobalRoleName: "admin"},
+		{ObjectMeta: existingMeta("", "grb-john", "u-john", "admins", now), GlobalRoleName: "admin"},
+		{ObjectMeta: existingMeta("", "grb-expired", "u-joe", "admins", now.Add(-2*time.Hour)), GlobalRoleName: "admin"},
+	}, nil)
+	grbs.EXPECT().Delete("grb-expired", gomock.Any()).Return(nil)
+
+	// the ops rule no longer grants cluster-owner
+	crtbCache.EXPECT().List("", selector).Return([]*v32.ClusterRoleTemplateBinding{
+		{ObjectMeta: existingMeta("c-1", "crtb-owner", "u-jane", "ops", now), ClusterName: "c-1", RoleTemplateName: "cluster-owner"},
+		{ObjectMeta: existingMeta("c-1", "crtb-member", "u-jane", "ops", now), ClusterName: "c-1", RoleTemplateName: "cluster-member"},
+	}, nil)
+	crtbs.EXPECT().Delete("c-1", "crtb-owner", gomock.Any()).Return(nil)
+
+	prtbCache.EXPECT().List("", selector).Return(nil, nil)
+
+	rules := []v32.OIDCClaimRule{
+		{Name: "admins", Claim: "groups", Value: "admins", GlobalRoles: []string{"admin"}},
+		{
+			Name:         "ops",
+			Claim:        "groups",
+			Value:        "ops",
+			ClusterRoles: []v32.OIDCClaimRuleClusterRole{{ClusterName: "c-1", RoleTemplateName: "cluster-member"}},
+		},
+	}
+	require.NoError(t, binder.Reconcile(Name, rules, now.Add(-time.Hour)))
+}
+
+func TestClaimRuleBinderCheckEscalation(t *testing.T) {
+	rules := []v32.OIDCClaimRule{
+		{
+			Name:         "ops",
+			Claim:        "groups",
+			Value:        "ops",
+			GlobalRoles:  []string{"user"},
+			ClusterRoles: []v32.OIDCClaimRuleClusterRole{{ClusterName: "c-1", RoleTemplateName: "cluster-owner"}},
+			ProjectRoles: []v32.OIDCClaimRuleProjectRole{{ProjectName: "c-1:p-1", RoleTemplateName: "project-member"}},
+		},
+	}
+	grbs := []*v32.GlobalRoleBinding{{UserName: "u-jane", GlobalRoleName: "user"}}
+	crtbs := []*v32.ClusterRoleTemplateBinding{{UserName: "u-jane", ClusterName: "c-1", RoleTemplateName: "cluster-owner"}}
+	prtbs := []*v32.ProjectRoleTemplateBinding{{UserName: "u-jane", ProjectName: "c-1:p-1", RoleTemplateName: "project-member"}}
+
+	tests := []struct {
+		name     string
+		userName string
+		canBind  bool
+		wantErr  bool
+	}{
+		{
+			name:     "user holds every role",
+			userName: "u-jane",
+		},
+		{
+			name:     "user can bind every role",
+			userName: "u-admin",
+			canBind:  true,
+		},
+		{
+			name:     "user holds no role",
+			userName: "u-john",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			grbCache := fake.NewMockNonNamespacedCacheInterface[*v32.GlobalRoleBinding](ctrl)
+			grbCache.EXPECT().List(labels.Everything()).Return(grbs, nil).AnyTimes()
+			crtbCache := fake.NewMockCacheInterface[*v32.ClusterRoleTemplateBinding](ctrl)
+			crtbCache.EXPECT().List("c-1", labels.Everything()).Return(crtbs, nil).AnyTimes()
+			prtbCache := fake.NewMockCacheInterface[*v32.ProjectRoleTemplateBinding](ctrl)
+			prtbCache.EXPECT().List("p-1", labels.Everything()).Return(prtbs, nil).AnyTimes()
+			binder := &ClaimRuleBinder{
+				grbCache:  grbCache,
+				crtbCache: crtbCache,
+				prtbCache: prtbCache,
+			}
+
+			err := binder.CheckEscalation(tt.userName, rules, func(string, string) bool { return tt.canBind })
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
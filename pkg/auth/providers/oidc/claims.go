@@ -0,0 +1,163 @@
+package oidc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// claimValues returns the values of the claim at the path, with the names of nested claims separated by dots. A
+// claim whose value is a list has one value per item. Values which aren't strings are formatted, and objects are
+// ignored.
+func claimValues(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if value, ok = object[name]; !ok {
+			return nil
+		}
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	var values []string
+	for _, item := range items {
+		switch item := item.(type) {
+		case nil, map[string]interface{}, []interface{}:
+		case string:
+			values = append(values, item)
+		default:
+			values = append(values, fmt.Sprint(item))
+		}
+	}
+	return values
+}
+
+// groupNames returns the names of the groups of the user: the values of the groups claim of the config if it has one,
+// else the groups of the groups claim or of the full_group_path claim, split into the groups of each path. The names
+// are then rewritten and prefixed as configured.
+func groupNames(config *v32.OIDCConfig, claimInfo ClaimInfo) []string {
+	var names []string
+	switch {
+	case config.GroupsClaim != "":
+		names = claimValues(claimInfo.Claims, config.GroupsClaim)
+	case claimInfo.FullGroupPath != nil:
+		for _, groupPath := range claimInfo.FullGroupPath {
+			for _, group := range strings.Split(groupPath, "/") {
+				if group != "" {
+					names = append(names, group)
+				}
+			}
+		}
+	default:
+		names = claimInfo.Groups
+	}
+
+	if len(config.GroupRewrites) == 0 && config.GroupPrefix == "" {
+		return names
+	}
+	rewrites := make([]*regexp.Regexp, len(config.GroupRewrites))
+	for i, rewrite := range config.GroupRewrites {
+		re, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			// the pattern is validated when the config is saved, so this only happens to configs edited directly
+			logrus.Errorf("[generic oidc] invalid group rewrite pattern %s: %v", rewrite.Pattern, err)
+			continue
+		}
+		rewrites[i] = re
+	}
+
+	var mapped []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		for i, re := range rewrites {
+			if re != nil {
+				name = re.ReplaceAllString(name, config.GroupRewrites[i].Replacement)
+			}
+		}
+		if name == "" {
+			continue
+		}
+		name = config.GroupPrefix + name
+		if !seen[name] {
+			seen[name] = true
+			mapped = append(mapped, name)
+		}
+	}
+	return mapped
+}
+
+// validateClaimMapping returns an error if a group rewrite or claim rule of the config is invalid.
+func validateClaimMapping(config *v32.OIDCConfig) error {
+	for _, rewrite := range config.GroupRewrites {
+		if _, err := regexp.Compile(rewrite.Pattern); err != nil {
+			return fmt.Errorf("invalid group rewrite pattern %s: %w", rewrite.Pattern, err)
+		}
+	}
+	names := map[string]bool{}
+	for _, rule := range config.ClaimRules {
+		if rule.Name == "" || rule.Claim == "" {
+			return fmt.Errorf("claim rules require a name and a claim")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate claim rule %s", rule.Name)
+		}
+		names[rule.Name] = true
+		if _, err := matchClaimRule(rule, nil); err != nil {
+			return err
+		}
+		for _, role := range rule.ProjectRoles {
+			if len(strings.SplitN(role.ProjectName, ":", 2)) != 2 {
+				return fmt.Errorf("invalid project %s of claim rule %s, expected <cluster name>:<project name>", role.ProjectName, rule.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// matchClaimRule returns true if any value of the claim of the rule matches it.
+func matchClaimRule(rule v32.OIDCClaimRule, claims map[string]interface{}) (bool, error) {
+	values := claimValues(claims, rule.Claim)
+	var match func(string) bool
+	switch rule.Operator {
+	case "", "equals":
+		match = func(value string) bool { return value == rule.Value }
+	case "contains":
+		match = func(value string) bool { return strings.Contains(value, rule.Value) }
+	case "matches":
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %s of claim rule %s: %w", rule.Value, rule.Name, err)
+		}
+		match = re.MatchString
+	case "exists":
+		return len(values) > 0, nil
+	default:
+		return false, fmt.Errorf("invalid operator %s of claim rule %s", rule.Operator, rule.Name)
+	}
+	for _, value := range values {
+		if match(value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (o *OpenIDCProvider) getGroupsFromClaimInfo(config *v32.OIDCConfig, claimInfo ClaimInfo) []v3.Principal {
+	var groupPrincipals []v3.Principal
+	for _, group := range groupNames(config, claimInfo) {
+		groupPrincipal := o.groupToPrincipal(group)
+		groupPrincipal.MemberOf = true
+		groupPrincipals = append(groupPrincipals, groupPrincipal)
+	}
+	return groupPrincipals
+}
@@ -0,0 +1,323 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimValues(t *testing.T) {
+	claims := map[string]interface{}{
+		"groups": []interface{}{"admins", "devs", 42.0, map[string]interface{}{"name": "ignored"}},
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"offline_access", "ops"},
+		},
+		"department": "engineering",
+		"verified":   true,
+	}
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{name: "list", path: "groups", want: []string{"admins", "devs", "42"}},
+		{name: "nested list", path: "realm_access.roles", want: []string{"offline_access", "ops"}},
+		{name: "string", path: "department", want: []string{"engineering"}},
+		{name: "bool", path: "verified", want: []string{"true"}},
+		{name: "object", path: "realm_access", want: nil},
+		{name: "missing", path: "missing", want: nil},
+		{name: "missing nested", path: "department.name", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, claimValues(claims, tt.path))
+		})
+	}
+}
+
+func TestGroupNames(t *testing.T) {
+	claimInfo := ClaimInfo{
+		Groups:        []string{"admins", "devs"},
+		FullGroupPath: nil,
+		Claims: map[string]interface{}{
+			"resource_access": map[string]interface{}{
+				"rancher": map[string]interface{}{
+					"roles": []interface{}{"team-blue", "team-red", "viewer"},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name      string
+		config    v32.OIDCConfig
+		claimInfo ClaimInfo
+		want      []string
+	}{
+		{
+			name:      "groups claim",
+			claimInfo: claimInfo,
+			want:      []string{"admins", "devs"},
+		},
+		{
+			name:      "full group path",
+			claimInfo: ClaimInfo{Groups: []string{"ignored"}, FullGroupPath: []string{"/org/admins", "/org/devs"}},
+			want:      []string{"org", "admins", "org", "devs"},
+		},
+		{
+			name:      "nested claim",
+			config:    v32.OIDCConfig{GroupsClaim: "resource_access.rancher.roles"},
+			claimInfo: claimInfo,
+			want:      []string{"team-blue", "team-red", "viewer"},
+		},
+		{
+			name: "rewrites and prefix",
+			config: v32.OIDCConfig{
+				GroupsClaim: "resource_access.rancher.roles",
+				GroupRewrites: []v32.OIDCGroupRewrite{
+					{Pattern: "^team-(.*)$", Replacement: "$1"},
+					{Pattern: "^viewer$"},
+				},
+				GroupPrefix: "oidc:",
+			},
+			claimInfo: claimInfo,
+			want:      []string{"oidc:blue", "oidc:red"},
+		},
+		{
+			name: "duplicates after rewrite",
+			config: v32.OIDCConfig{
+				GroupRewrites: []v32.OIDCGroupRewrite{{Pattern: "s$"}},
+			},
+			claimInfo: ClaimInfo{Groups: []string{"admin", "admins"}},
+			want:      []string{"admin"},
+		},
+		{
+			name:      "missing claim",
+			config:    v32.OIDCConfig{GroupsClaim: "missing"},
+			claimInfo: claimInfo,
+			want:      nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, groupNames(&tt.config, tt.claimInfo))
+		})
+	}
+}
+
+func TestMatchClaimRule(t *testing.T) {
+	claims := map[string]interface{}{
+		"email":  "jane@example.com",
+		"groups": []interface{}{"admins", "devs"},
+		"org":    map[string]interface{}{"tier": "gold"},
+	}
+	tests := []struct {
+		name    string
+		rule    v32.OIDCClaimRule
+		want    bool
+		wantErr bool
+	}{
+		{name: "equals", rule: v32.OIDCClaimRule{Claim: "groups", Value: "devs"}, want: true},
+		{name: "equals nested", rule: v32.OIDCClaimRule{Claim: "org.tier", Operator: "equals", Value: "gold"}, want: true},
+		{name: "not equals", rule: v32.OIDCClaimRule{Claim: "groups", Operator: "equals", Value: "dev"}, want: false},
+		{name: "contains", rule: v32.OIDCClaimRule{Claim: "email", Operator: "contains", Value: "@example."}, want: true},
+		{name: "matches", rule: v32.OIDCClaimRule{Claim: "email", Operator: "matches", Value: `@example\.com$`}, want: true},
+		{name: "does not match", rule: v32.OIDCClaimRule{Claim: "groups", Operator: "matches", Value: "^ops"}, want: false},
+		{name: "exists", rule: v32.OIDCClaimRule{Claim: "org.tier", Operator: "exists"}, want: true},
+		{name: "does not exist", rule: v32.OIDCClaimRule{Claim: "org.region", Operator: "exists"}, want: false},
+		{name: "invalid pattern", rule: v32.OIDCClaimRule{Claim: "email", Operator: "matches", Value: "("}, wantErr: true},
+		{name: "invalid operator", rule: v32.OIDCClaimRule{Claim: "email", Operator: "startsWith"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchClaimRule(tt.rule, claims)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateClaimMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  v32.OIDCConfig
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			config: v32.OIDCConfig{
+				GroupRewrites: []v32.OIDCGroupRewrite{{Pattern: "^team-"}},
+				ClaimRules: []v32.OIDCClaimRule{{
+					Name:         "ops",
+					Claim:        "groups",
+					ProjectRoles: []v32.OIDCClaimRuleProjectRole{{ProjectName: "c-1:p-1", RoleTemplateName: "project-member"}},
+				}},
+			},
+		},
+		{
+			name:    "invalid rewrite",
+			config:  v32.OIDCConfig{GroupRewrites: []v32.OIDCGroupRewrite{{Pattern: "["}}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate rule",
+			config:  v32.OIDCConfig{ClaimRules: []v32.OIDCClaimRule{{Name: "ops", Claim: "groups"}, {Name: "ops", Claim: "email"}}},
+			wantErr: true,
+		},
+		{
+			name:    "rule without claim",
+			config:  v32.OIDCConfig{ClaimRules: []v32.OIDCClaimRule{{Name: "ops"}}},
+			wantErr: true,
+		},
+		{
+			name: "invalid project",
+			config: v32.OIDCConfig{ClaimRules: []v32.OIDCClaimRule{{
+				Name:         "ops",
+				Claim:        "groups",
+				ProjectRoles: []v32.OIDCClaimRuleProjectRole{{ProjectName: "p-1", RoleTemplateName: "project-member"}},
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateClaimMapping(&tt.config)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// newMockOIDCServer returns a minimal OIDC provider which issues an access token signed with the key for any code, and
+// returns the claims from its userinfo endpoint.
+func newMockOIDCServer(t *testing.T, key *rsa.PrivateKey, clientID string, claims map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	writeJSON := func(rw http.ResponseWriter, body interface{}) {
+		rw.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(rw).Encode(body))
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, map[string]interface{}{
+			"issuer":                                server.URL,
+			"authorization_endpoint":                server.URL + "/auth",
+			"token_endpoint":                        server.URL + "/token",
+			"userinfo_endpoint":                     server.URL + "/userinfo",
+			"jwks_uri":                              server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   encode(key.N.Bytes()),
+				"e":   encode(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(rw http.ResponseWriter, req *http.Request) {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+		payload, _ := json.Marshal(map[string]interface{}{
+			"iss": server.URL,
+			"aud": clientID,
+			"sub": claims["sub"],
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		signingInput := encode(header) + "." + encode(payload)
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		writeJSON(rw, map[string]interface{}{
+			"access_token": signingInput + "." + encode(signature),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(rw http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(rw, claims)
+	})
+	return server
+}
+
+func TestGetUserInfoClaimMapping(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	server := newMockOIDCServer(t, key, "rancher", map[string]interface{}{
+		"sub":   "jane",
+		"email": "jane@example.com",
+		"name":  "Jane Doe",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"team-ops", "team-dev", "offline_access"},
+		},
+	})
+	defer server.Close()
+
+	config := &v32.OIDCConfig{
+		ClientID:    "rancher",
+		Issuer:      server.URL,
+		RancherURL:  "https://rancher.example.com/verify-auth",
+		Scopes:      "openid profile",
+		GroupsClaim: "realm_access.roles",
+		GroupRewrites: []v32.OIDCGroupRewrite{
+			{Pattern: "^team-(.*)$", Replacement: "$1"},
+			{Pattern: "^offline_access$"},
+		},
+		GroupPrefix: "idp-",
+		ClaimRules: []v32.OIDCClaimRule{
+			{Name: "ops", Claim: "realm_access.roles", Value: "team-ops", GlobalRoles: []string{"admin"}},
+			{Name: "other", Claim: "email", Operator: "matches", Value: "@other\\.com$", GlobalRoles: []string{"user"}},
+		},
+	}
+	provider := &OpenIDCProvider{Name: Name}
+	var claimInfo ClaimInfo
+	ctx := context.Background()
+	userInfo, token, err := provider.getUserInfo(&ctx, config, "code", &claimInfo, "")
+	require.NoError(t, err)
+	assert.True(t, token.Valid())
+	assert.Equal(t, "jane@example.com", userInfo.Email)
+	assert.Equal(t, "jane", claimInfo.Subject)
+
+	var groups []string
+	for _, group := range provider.getGroupsFromClaimInfo(config, claimInfo) {
+		groups = append(groups, group.Name)
+		assert.True(t, group.MemberOf)
+	}
+	assert.Equal(t, []string{Name + "_group://idp-ops", Name + "_group://idp-dev"}, groups)
+
+	for _, rule := range config.ClaimRules {
+		matched, err := matchClaimRule(rule, claimInfo.Claims)
+		require.NoError(t, err)
+		assert.Equal(t, rule.Name == "ops", matched, rule.Name)
+	}
+}
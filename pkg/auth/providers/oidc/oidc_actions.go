@@ -12,6 +12,7 @@
 	"github.com/rancher/norman/types"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	managementschema "github.com/rancher/rancher/pkg/schemas/management.cattle.io/v3"
 )
 
@@ -80,6 +81,12 @@ func (o *OpenIDCProvider) TestAndApply(actionName string, action *types.Action,
 		return errors.Wrap(err, "[generic oidc]: server error while authenticating")
 	}
 	oidcConfig.Issuer = issuerURL.String()
+	if err := validateClaimMapping(&oidcConfig); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("[generic oidc] testAndApply: %v", err))
+	}
+	if err := o.checkClaimRuleEscalation(request, oidcConfig.ClaimRules); err != nil {
+		return err
+	}
 
 	//call provider
 	userPrincipal, groupPrincipals, providerToken, claimInfo, err := o.LoginUser(request.Request.Context(), oidcLogin, &oidcConfig)
@@ -91,7 +98,11 @@ func (o *OpenIDCProvider) TestAndApply(actionName string, action *types.Action,
 	}
 	//setting a bool for group search flag
 	//this only needs updated when an auth provider is enabled or edited
-	if claimInfo.Groups == nil && claimInfo.FullGroupPath == nil {
+	hasGroups := claimInfo.Groups != nil || claimInfo.FullGroupPath != nil
+	if oidcConfig.GroupsClaim != "" {
+		hasGroups = claimValues(claimInfo.Claims, oidcConfig.GroupsClaim) != nil
+	}
+	if !hasGroups {
 		falseBool := false
 		oidcConfig.GroupSearchEnabled = &falseBool
 	} else {
@@ -108,7 +119,36 @@ func (o *OpenIDCProvider) TestAndApply(actionName string, action *types.Action,
 		return httperror.NewAPIError(httperror.ServerError, fmt.Sprintf("[generic oidc]: failed to save oidc config: %v", err))
 	}
 
+	o.applyClaimRules(&oidcConfig, user.Name, userPrincipal, claimInfo)
+
 	userExtraInfo := o.GetUserExtraAttributes(userPrincipal)
 
 	return o.TokenMGR.CreateTokenAndSetCookie(user.Name, userPrincipal, groupPrincipals, providerToken, 0, "Token via OIDC Configuration", request, userExtraInfo)
 }
+
+// Validator validates the claim mapping of a config saved through the API.
+func (o *OpenIDCProvider) Validator(request *types.APIContext, schema *types.Schema, data map[string]interface{}) error {
+	var oidcConfig v32.OIDCConfig
+	if err := common.Decode(data, &oidcConfig); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("[generic oidc] failed to decode config: %v", err))
+	}
+	if err := validateClaimMapping(&oidcConfig); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("[generic oidc] %v", err))
+	}
+	return o.checkClaimRuleEscalation(request, oidcConfig.ClaimRules)
+}
+
+// checkClaimRuleEscalation refuses claim rules granting roles the user saving them doesn't hold, as they would allow the
+// user to grant these roles to anyone whose claims the user controls.
+func (o *OpenIDCProvider) checkClaimRuleEscalation(request *types.APIContext, rules []v32.OIDCClaimRule) error {
+	if o.RoleBinder == nil || len(rules) == 0 {
+		return nil
+	}
+	canBind := func(resource, name string) bool {
+		return request.AccessControl.CanDo(v3.GlobalRoleGroupVersionKind.Group, resource, "bind", request, map[string]interface{}{"id": name}, request.Schema) == nil
+	}
+	if err := o.RoleBinder.CheckEscalation(o.UserMGR.GetUser(request), rules, canBind); err != nil {
+		return httperror.NewAPIError(httperror.PermissionDenied, fmt.Sprintf("[generic oidc] %v", err))
+	}
+	return nil
+}
@@ -41,6 +41,7 @@ type OpenIDCProvider struct {
 	Secrets     corev1.SecretInterface
 	UserMGR     user.Manager
 	TokenMGR    *tokens.Manager
+	RoleBinder  *ClaimRuleBinder
 }
 
 type ClaimInfo struct {
@@ -53,6 +54,8 @@ type ClaimInfo struct {
 	EmailVerified     bool     `json:"email_verified"`
 	Groups            []string `json:"groups"`
 	FullGroupPath     []string `json:"full_group_path"`
+	// Claims holds all the claims of the user, for the claim mapping and claim rules of the config.
+	Claims map[string]interface{} `json:"-"`
 }
 
 func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, userMGR user.Manager, tokenMGR *tokens.Manager) common.AuthProvider {
@@ -64,6 +67,7 @@ func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, userMGR user.
 		Secrets:     mgmtCtx.Core.Secrets(""),
 		UserMGR:     userMGR,
 		TokenMGR:    tokenMGR,
+		RoleBinder:  NewClaimRuleBinder(mgmtCtx.Wrangler.Mgmt),
 	}
 }
 
@@ -74,6 +78,7 @@ func (o *OpenIDCProvider) GetName() string {
 func (o *OpenIDCProvider) CustomizeSchema(schema *types.Schema) {
 	schema.ActionHandler = o.ActionHandler
 	schema.Formatter = o.Formatter
+	schema.Validator = o.Validator
 }
 
 func (o *OpenIDCProvider) AuthenticateUser(ctx context.Context, input interface{}) (v3.Principal, []v3.Principal, string, error) {
@@ -81,8 +86,31 @@ func (o *OpenIDCProvider) AuthenticateUser(ctx context.Context, input interface{
 	if !ok {
 		return v3.Principal{}, nil, "", fmt.Errorf("unexpected input type")
 	}
-	userPrincipal, groupPrincipals, providerToken, _, err := o.LoginUser(ctx, login, nil)
-	return userPrincipal, groupPrincipals, providerToken, err
+	config, err := o.GetOIDCConfig()
+	if err != nil {
+		return v3.Principal{}, nil, "", err
+	}
+	userPrincipal, groupPrincipals, providerToken, claimInfo, err := o.LoginUser(ctx, login, config)
+	if err != nil {
+		return userPrincipal, groupPrincipals, providerToken, err
+	}
+	user, err := o.UserMGR.EnsureUser(userPrincipal.Name, userPrincipal.DisplayName)
+	if err != nil {
+		return userPrincipal, groupPrincipals, providerToken, err
+	}
+	o.applyClaimRules(config, user.Name, userPrincipal, claimInfo)
+	return userPrincipal, groupPrincipals, providerToken, nil
+}
+
+// applyClaimRules grants the user the roles of the claim rules of the config its claims match. Failures are only
+// logged, so that users can still log in when a role of a rule can't be granted.
+func (o *OpenIDCProvider) applyClaimRules(config *v32.OIDCConfig, userName string, userPrincipal v3.Principal, claimInfo ClaimInfo) {
+	if o.RoleBinder == nil {
+		return
+	}
+	if err := o.RoleBinder.Apply(o.Name, userName, userPrincipal.Name, config.ClaimRules, claimInfo.Claims); err != nil {
+		logrus.Errorf("[generic oidc] failed to apply claim rules for user %s: %v", userName, err)
+	}
 }
 
 func (o *OpenIDCProvider) LoginUser(ctx context.Context, oauthLoginInfo *v32.OIDCLogin, config *v32.OIDCConfig) (v3.Principal, []v3.Principal, string, ClaimInfo, error) {
@@ -103,7 +131,7 @@ func (o *OpenIDCProvider) LoginUser(ctx context.Context, oauthLoginInfo *v32.OID
 	}
 	userPrincipal = o.userToPrincipal(userInfo, userClaimInfo)
 	userPrincipal.Me = true
-	groupPrincipals = o.getGroupsFromClaimInfo(userClaimInfo)
+	groupPrincipals = o.getGroupsFromClaimInfo(config, userClaimInfo)
 
 	logrus.Debugf("[generic oidc] loginuser: checking user's access to rancher")
 	allowed, err := o.UserMGR.CheckAccess(config.AccessMode, config.AllowedPrincipalIDs, userPrincipal.Name, groupPrincipals)
@@ -213,7 +241,9 @@ func (o *OpenIDCProvider) RefetchGroupPrincipals(principalID string, secret stri
 	if err != nil {
 		return groupPrincipals, err
 	}
-	return o.getGroupsFromClaimInfo(claimInfo), nil
+	// the claims are current, so the claim rules are applied to confirm or revoke the roles they granted
+	o.applyClaimRules(config, user.Name, v3.Principal{ObjectMeta: metav1.ObjectMeta{Name: principalID}}, claimInfo)
+	return o.getGroupsFromClaimInfo(config, claimInfo), nil
 }
 
 func (o *OpenIDCProvider) CanAccessWithGroupProviders(userPrincipalID string, groupPrincipals []v3.Principal) (bool, error) {
@@ -407,6 +437,9 @@ func (o *OpenIDCProvider) getUserInfo(ctx *context.Context, config *v32.OIDCConf
 	if err := userInfo.Claims(&claimInfo); err != nil {
 		return userInfo, oauth2Token, err
 	}
+	if err := userInfo.Claims(&claimInfo.Claims); err != nil {
+		return userInfo, oauth2Token, err
+	}
 
 	return userInfo, oauth2Token, nil
 }
@@ -433,30 +466,6 @@ func ConfigToOauthConfig(endpoint oauth2.Endpoint, config *v32.OIDCConfig) oauth
 	}
 }
 
-func (o *OpenIDCProvider) getGroupsFromClaimInfo(claimInfo ClaimInfo) []v3.Principal {
-	var groupPrincipals []v3.Principal
-
-	if claimInfo.FullGroupPath != nil {
-		for _, groupPath := range claimInfo.FullGroupPath {
-			groupsFromPath := strings.Split(groupPath, "/")
-			for _, group := range groupsFromPath {
-				if group != "" {
-					groupPrincipal := o.groupToPrincipal(group)
-					groupPrincipal.MemberOf = true
-					groupPrincipals = append(groupPrincipals, groupPrincipal)
-				}
-			}
-		}
-	} else {
-		for _, group := range claimInfo.Groups {
-			groupPrincipal := o.groupToPrincipal(group)
-			groupPrincipal.MemberOf = true
-			groupPrincipals = append(groupPrincipals, groupPrincipal)
-		}
-	}
-	return groupPrincipals
-}
-
 func (o *OpenIDCProvider) UpdateToken(refreshedToken *oauth2.Token, userID string) error {
 	var err error
 	logrus.Debugf("[generic oidc] UpdateToken: access token has been refreshed")
@@ -3,17 +3,23 @@
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/audit"
+	"github.com/rancher/rancher/pkg/auth/lockout"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/providers"
 	"github.com/rancher/rancher/pkg/auth/providers/activedirectory"
 	"github.com/rancher/rancher/pkg/auth/providers/azure"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/providers/github"
 	"github.com/rancher/rancher/pkg/auth/providers/googleoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/keycloakoidc"
@@ -21,29 +27,57 @@
 	"github.com/rancher/rancher/pkg/auth/providers/local"
 	"github.com/rancher/rancher/pkg/auth/providers/oidc"
 	"github.com/rancher/rancher/pkg/auth/providers/saml"
-	"github.com/rancher/rancher/pkg/auth/settings"
+	authsettings "github.com/rancher/rancher/pkg/auth/settings"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	"github.com/rancher/rancher/pkg/auth/util"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3public"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	schema "github.com/rancher/rancher/pkg/schemas/management.cattle.io/v3public"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/user"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
 	CookieName = "R_SESS"
 )
 
+var (
+	// mfaRequired is returned to local users with TOTP enabled who didn't send a code, so that the client asks for one
+	// and logs in again with it.
+	mfaRequired = httperror.ErrorCode{Code: "MFARequired", Status: http.StatusUnauthorized}
+	// mfaEnrollmentRequired is returned to admins who haven't enabled TOTP when MFA is required for admins, along with a
+	// token which can only be used to enroll a TOTP authenticator.
+	mfaEnrollmentRequired = httperror.ErrorCode{Code: "MFAEnrollmentRequired", Status: http.StatusForbidden}
+	// loginLocked is returned to password logins of usernames or from IPs locked out after too many failures.
+	loginLocked = httperror.ErrorCode{Code: "LoginLocked", Status: http.StatusTooManyRequests}
+)
+
+const (
+	// loginFailureAnnotation is added to the audit log of failed password logins with the reason of the failure.
+	loginFailureAnnotation = "authn.management.cattle.io/login-failure"
+	// lockedUntilAnnotation is added to the audit log of the failed password login which locked out a username.
+	lockedUntilAnnotation = "authn.management.cattle.io/locked-until"
+)
+
 func newLoginHandler(ctx context.Context, mgmt *config.ScaledContext) *loginHandler {
+	loginAttempts := lockout.NewTracker(mgmt.Wrangler.Core.ConfigMap(), mgmt.Wrangler.Core.ConfigMap().Cache(), lockout.SettingsPolicy)
+	loginAttempts.Start(ctx)
 	return &loginHandler{
 		scaledContext: mgmt,
 		userMGR:       mgmt.UserManager,
 		tokenMGR:      tokens.NewManager(ctx, mgmt),
 		clusterLister: mgmt.Management.Clusters("").Controller().Lister(),
+		settingLister: mgmt.Management.Settings("").Controller().Lister(),
 		secretLister:  mgmt.Core.Secrets("").Controller().Lister(),
+		grbCache:      mgmt.Wrangler.Mgmt.GlobalRoleBinding().Cache(),
+		totpStore:     mfa.NewStore(mgmt.Wrangler.Core.Secret(), mgmt.Wrangler.Core.Secret().Cache()),
+		loginAttempts: loginAttempts,
 	}
 }
 
@@ -52,7 +86,11 @@ type loginHandler struct {
 	userMGR       user.Manager
 	tokenMGR      *tokens.Manager
 	clusterLister v3.ClusterLister
+	settingLister v3.SettingLister
 	secretLister  v1.SecretLister
+	grbCache      mgmtcontrollers.GlobalRoleBindingCache
+	totpStore     *mfa.Store
+	loginAttempts *lockout.Tracker
 }
 
 func (h *loginHandler) login(actionName string, action *types.Action, request *types.APIContext) error {
@@ -63,6 +101,15 @@ func (h *loginHandler) login(actionName string, action *types.Action, request *t
 	w := request.Response
 
 	token, unhashedTokenKey, responseType, err := h.createLoginToken(request)
+	if err != nil && token.Name != "" {
+		// admins who must enroll a TOTP authenticator get a token which can only be used to enroll it. Cookie logins
+		// still get the error, so that the UI shows the enrollment.
+		if responseType == "cookie" {
+			setTokenCookie(w, token, unhashedTokenKey)
+			return err
+		}
+		return writeToken(request, token, unhashedTokenKey, map[string]interface{}{"mfaEnrollmentRequired": true})
+	}
 	if err != nil {
 		// if user fails to authenticate, hide the details of the exact error. bad credentials will already be APIErrors
 		// otherwise, return a generic error message
@@ -73,28 +120,41 @@ func (h *loginHandler) login(actionName string, action *types.Action, request *t
 	}
 
 	if responseType == "cookie" {
-		tokenCookie := &http.Cookie{
-			Name:     CookieName,
-			Value:    token.ObjectMeta.Name + ":" + unhashedTokenKey,
-			Secure:   true,
-			Path:     "/",
-			HttpOnly: true,
-		}
-		http.SetCookie(w, tokenCookie)
+		setTokenCookie(w, token, unhashedTokenKey)
 	} else if responseType == "saml" {
 		return nil
 	} else {
-		tokenData, err := tokens.ConvertTokenResource(request.Schemas.Schema(&schema.PublicVersion, client.TokenType), token)
-		if err != nil {
-			return httperror.WrapAPIError(err, httperror.ServerError, "Server error while authenticating")
-		}
-		tokenData["token"] = token.ObjectMeta.Name + ":" + unhashedTokenKey
-		request.WriteResponse(http.StatusCreated, tokenData)
+		return writeToken(request, token, unhashedTokenKey, nil)
 	}
 
 	return nil
 }
 
+func setTokenCookie(w http.ResponseWriter, token v3.Token, unhashedTokenKey string) {
+	tokenCookie := &http.Cookie{
+		Name:     CookieName,
+		Value:    token.ObjectMeta.Name + ":" + unhashedTokenKey,
+		Secure:   true,
+		Path:     "/",
+		HttpOnly: true,
+	}
+	http.SetCookie(w, tokenCookie)
+}
+
+// writeToken writes the token and its key in the response, with the extra fields.
+func writeToken(request *types.APIContext, token v3.Token, unhashedTokenKey string, extra map[string]interface{}) error {
+	tokenData, err := tokens.ConvertTokenResource(request.Schemas.Schema(&schema.PublicVersion, client.TokenType), token)
+	if err != nil {
+		return httperror.WrapAPIError(err, httperror.ServerError, "Server error while authenticating")
+	}
+	tokenData["token"] = token.ObjectMeta.Name + ":" + unhashedTokenKey
+	for key, value := range extra {
+		tokenData[key] = value
+	}
+	request.WriteResponse(http.StatusCreated, tokenData)
+	return nil
+}
+
 // createLoginToken returns token, unhashed token key (where applicable), responseType and error
 func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, string, string, error) {
 	var userPrincipal v3.Principal
@@ -118,7 +178,7 @@ func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, st
 	description := generic.Description
 	ttl := generic.TTLMillis
 
-	authTimeout := settings.AuthUserSessionTTLMinutes.Get()
+	authTimeout := authsettings.AuthUserSessionTTLMinutes.Get()
 	if minutes, err := strconv.ParseInt(authTimeout, 10, 64); err == nil {
 		ttl = minutes * 60 * 1000
 	}
@@ -189,8 +249,20 @@ func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, st
 	}
 
 	ctx := context.WithValue(request.Request.Context(), util.RequestKey, request.Request)
+
+	// only password logins are locked out, other providers are protected by the identity provider
+	basicLogin, _ := input.(*v32.BasicLogin)
+	clientIP := tokens.ClientIP(request.Request)
+	if basicLogin != nil {
+		if lockedUntil := h.loginAttempts.LockedUntil(providerName, basicLogin.Username, clientIP); !lockedUntil.IsZero() {
+			audit.AddAnnotation(ctx, loginFailureAnnotation, "locked")
+			return v3.Token{}, "", "", httperror.NewAPIError(loginLocked, "too many failed login attempts, try again later")
+		}
+	}
+
 	userPrincipal, groupPrincipals, providerToken, err = providers.AuthenticateUser(ctx, input, providerName)
 	if err != nil {
+		h.loginFailed(ctx, providerName, basicLogin, clientIP, err)
 		return v3.Token{}, "", "", err
 	}
 
@@ -207,6 +279,25 @@ func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, st
 		return v3.Token{}, "", "", httperror.NewAPIError(httperror.PermissionDenied, "Permission Denied")
 	}
 
+	if providerName == local.Name {
+		if err := h.verifyTOTP(currUser, groupPrincipals, basicLogin); err != nil {
+			var apiErr *httperror.APIError
+			if errors.As(err, &apiErr) && apiErr.Code == mfaEnrollmentRequired {
+				return h.mfaEnrollmentToken(currUser, userPrincipal, basicLogin, clientIP, responseType, err)
+			}
+			h.loginFailed(ctx, providerName, basicLogin, clientIP, err)
+			return v3.Token{}, "", "", err
+		}
+		if err := h.expirePassword(currUser); err != nil {
+			logrus.Warnf("Failed to expire the password of user %s: %v", currUser.Name, err)
+		}
+	}
+	if basicLogin != nil {
+		if err := h.loginAttempts.Success(providerName, basicLogin.Username); err != nil {
+			logrus.Warnf("Failed to reset the failed logins of %s: %v", basicLogin.Username, err)
+		}
+	}
+
 	if strings.HasPrefix(responseType, tokens.KubeconfigResponseType) {
 		token, tokenValue, err := tokens.GetKubeConfigToken(currUser.Name, responseType, h.userMGR, userPrincipal)
 		if err != nil {
@@ -220,3 +311,177 @@ func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, st
 	rToken, unhashedTokenKey, err := h.tokenMGR.NewLoginToken(currUser.Name, userPrincipal, groupPrincipals, providerToken, ttl, description, userExtraInfo)
 	return rToken, unhashedTokenKey, responseType, err
 }
+
+// mfaEnrollmentToken returns a token which can only be used to enroll a TOTP authenticator for an admin whose password
+// is valid but who must enroll one before logging in, along with the enrollment error. Kubeconfig logins only get the
+// error, as the authenticator can't be enrolled from the CLI.
+func (h *loginHandler) mfaEnrollmentToken(user *v3.User, userPrincipal v3.Principal, login *v32.BasicLogin, clientIP, responseType string, enrollmentErr error) (v3.Token, string, string, error) {
+	if err := h.loginAttempts.Success(local.Name, login.Username); err != nil {
+		logrus.Warnf("Failed to reset the failed logins of %s: %v", login.Username, err)
+	}
+	if strings.HasPrefix(responseType, tokens.KubeconfigResponseType) {
+		return v3.Token{}, "", "", enrollmentErr
+	}
+	token, unhashedTokenKey, err := h.tokenMGR.NewMFAEnrollmentToken(user.Name, userPrincipal)
+	if err != nil {
+		return v3.Token{}, "", "", err
+	}
+	return token, unhashedTokenKey, responseType, enrollmentErr
+}
+
+// verifyTOTP checks the TOTP code or recovery code of local users who enabled TOTP, and denies admins who haven't
+// enabled it when MFA is required for admins. The login is stateless: clients which get an MFARequired error log in
+// again with the password and a code.
+func (h *loginHandler) verifyTOTP(user *v3.User, groupPrincipals []v3.Principal, login *v32.BasicLogin) error {
+	enabled, err := h.totpStore.Enabled(user.Name)
+	if err != nil {
+		return err
+	}
+	if user.MFAEnabled != enabled {
+		// users who enabled TOTP before the field was kept up to date
+		if err := h.setMFAEnabled(user, enabled); err != nil {
+			logrus.Warnf("Failed to set the TOTP status of user %s: %v", user.Name, err)
+		}
+	}
+
+	if !enabled {
+		if !strings.EqualFold(authsettings.AuthMFARequiredForAdmins.Get(), "true") {
+			return nil
+		}
+		grbs, err := h.grbCache.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		groups := make([]string, 0, len(groupPrincipals))
+		for _, group := range groupPrincipals {
+			groups = append(groups, group.Name)
+		}
+		if mfa.HasAdminRole(grbs, user.Name, groups) {
+			return httperror.NewAPIError(mfaEnrollmentRequired, "multi-factor authentication is required for administrators")
+		}
+		return nil
+	}
+
+	if login.TOTPCode == "" && login.RecoveryCode == "" {
+		return httperror.NewAPIError(mfaRequired, "TOTP code required")
+	}
+	if err := h.totpStore.Verify(user.Name, login.TOTPCode, login.RecoveryCode); errors.Is(err, mfa.ErrInvalidCode) {
+		return httperror.NewAPIError(httperror.Unauthorized, "authentication failed")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// setMFAEnabled sets whether the user enabled TOTP in its MFAEnabled field.
+func (h *loginHandler) setMFAEnabled(user *v3.User, enabled bool) error {
+	user = user.DeepCopy()
+	user.MFAEnabled = enabled
+	_, err := h.scaledContext.Management.Users("").Update(user)
+	return err
+}
+
+// expirePassword requires the local user to change its password if it is older than the password-max-age-days setting.
+// Passwords are aged from when the setting was enabled at the earliest, so that enabling it doesn't expire the
+// passwords of all users at once.
+func (h *loginHandler) expirePassword(user *v3.User) error {
+	maxAgeDays, err := strconv.Atoi(settings.PasswordMaxAgeDays.Get())
+	if err != nil || maxAgeDays <= 0 || user.MustChangePassword {
+		return nil
+	}
+	setting, err := h.settingLister.Get("", settings.PasswordMaxAgeDays.Name)
+	if err != nil {
+		return err
+	}
+	enabledAt, ok := settings.EnabledAt(setting)
+	if !ok {
+		// the setting was just enabled and isn't annotated yet
+		return nil
+	}
+	changedAt := user.CreationTimestamp.Time
+	if user.Status.PasswordChangedAt != nil {
+		changedAt = user.Status.PasswordChangedAt.Time
+	}
+	if enabledAt.After(changedAt) {
+		changedAt = enabledAt
+	}
+	if time.Since(changedAt) < time.Duration(maxAgeDays)*24*time.Hour {
+		return nil
+	}
+	user = user.DeepCopy()
+	user.MustChangePassword = true
+	_, err = h.scaledContext.Management.Users("").Update(user)
+	return err
+}
+
+// loginFailed records a password login which failed because of invalid credentials, which locks out the username or
+// the IP after too many failures, and adds the failure to the audit log of the request.
+func (h *loginHandler) loginFailed(ctx context.Context, providerName string, login *v32.BasicLogin, clientIP string, loginErr error) {
+	var apiErr *httperror.APIError
+	if login == nil || !errors.As(loginErr, &apiErr) || apiErr.Code != httperror.Unauthorized {
+		return
+	}
+	audit.AddAnnotation(ctx, loginFailureAnnotation, "invalid-credentials")
+
+	// only the failures of usernames which a user logs in with are recorded, so that made up usernames don't add records
+	userID, err := h.findUserID(providerName, login.Username)
+	if err != nil {
+		logrus.Errorf("Failed to find user of failed login of %s: %v", login.Username, err)
+		return
+	}
+	lockedUntil, err := h.loginAttempts.Failure(providerName, login.Username, clientIP, userID != "")
+	if err != nil {
+		logrus.Errorf("Failed to record failed login of %s: %v", login.Username, err)
+		return
+	}
+	if lockedUntil.IsZero() {
+		return
+	}
+	audit.AddAnnotation(ctx, lockedUntilAnnotation, lockedUntil.UTC().Format(time.RFC3339))
+	logrus.Warnf("Locked out logins of %s with provider %s until %s after too many failed attempts", login.Username, providerName, lockedUntil)
+	if err := h.setLockedUntil(userID, lockedUntil); err != nil {
+		logrus.Warnf("Failed to set the lockout of user %s: %v", login.Username, err)
+	}
+}
+
+// setLockedUntil shows the lockout in the status of the user, so that it can be unlocked.
+func (h *loginHandler) setLockedUntil(userID string, lockedUntil time.Time) error {
+	user, err := h.scaledContext.Management.Users("").Controller().Lister().Get("", userID)
+	if err != nil {
+		return err
+	}
+	user = user.DeepCopy()
+	user.Status.LockedUntil = &metav1.Time{Time: lockedUntil}
+	_, err = h.scaledContext.Management.Users("").Update(user)
+	return err
+}
+
+// findUserID returns the ID of the user who logs in to the provider with the username, or "" if there is none. Users of
+// providers other than local are only found once they logged in, which stored their username in their UserAttribute.
+func (h *loginHandler) findUserID(providerName, username string) (string, error) {
+	if providerName == local.Name {
+		users, err := h.scaledContext.Management.Users("").Controller().Lister().List("", labels.Everything())
+		if err != nil {
+			return "", err
+		}
+		for _, user := range users {
+			if user.Username == username {
+				return user.Name, nil
+			}
+		}
+		return "", nil
+	}
+
+	attribs, err := h.scaledContext.Management.UserAttributes("").Controller().Lister().List("", labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	for _, attrib := range attribs {
+		for _, name := range attrib.ExtraByProvider[providerName][common.UserAttributeUserName] {
+			if strings.EqualFold(name, username) {
+				return attrib.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -59,6 +59,7 @@ func ToAuthMiddleware(a Authenticator) auth.Middleware {
 func NewAuthenticator(ctx context.Context, clusterRouter ClusterRouter, mgmtCtx *config.ScaledContext) Authenticator {
 	tokenInformer := mgmtCtx.Management.Tokens("").Controller().Informer()
 	tokenInformer.AddIndexers(map[string]cache.IndexFunc{tokenKeyIndex: tokenKeyIndexer})
+	tokens.StartUsageRecorder(ctx, mgmtCtx.Management.Tokens(""))
 
 	return &tokenAuthenticator{
 		ctx:                 ctx,
@@ -111,6 +112,14 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 	if token.ClusterName != "" && token.ClusterName != a.clusterRouter(req) {
 		return nil, errors.Wrapf(ErrMustAuthenticate, "clusterID does not match")
 	}
+	if err := tokens.CheckScope(token.Scope, a.clusterRouter(req), req); err != nil {
+		return nil, errors.Wrapf(ErrMustAuthenticate, "request is outside of the token's scope: %v", err)
+	}
+	if tokens.IsMFAEnrollmentToken(token) {
+		if err := tokens.CheckMFAEnrollment(req); err != nil {
+			return nil, errors.Wrapf(ErrMustAuthenticate, "%v", err)
+		}
+	}
 
 	attribs, err := a.userAttributeLister.Get("", token.UserID)
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -159,6 +168,7 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 	authResp.Groups = groups
 	authResp.Extras = getUserExtraInfo(token, u, attribs)
 	logrus.Debugf("Extras returned %v", authResp.Extras)
+	tokens.RecordUsage(token, req)
 
 	return authResp, nil
 }
@@ -235,6 +245,7 @@ func (a *tokenAuthenticator) TokenFromRequest(req *http.Request) (*v3.Token, err
 	if _, err := tokens.VerifyToken(storedToken, tokenName, tokenKey); err != nil {
 		return nil, errors.Wrapf(ErrMustAuthenticate, "failed to verify token: %v", err)
 	}
+	tokens.RehashIfNeeded(a.tokenClient, storedToken, tokenKey)
 
 	return storedToken, nil
 }
@@ -0,0 +1,52 @@
+package scim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// filter is a list filter comparing an attribute with a value. Identity providers only use equality filters on
+// userName, externalId or displayName to find existing resources, so other operators and logical expressions aren't
+// supported.
+type filter struct {
+	attribute string
+	value     string
+}
+
+// parseFilter parses a filter of the form `attribute eq "value"`. Attribute names and the operator are
+// case-insensitive.
+func parseFilter(expression string) (*filter, error) {
+	invalid := newError(http.StatusBadRequest, "invalidFilter", "unsupported filter "+expression)
+
+	fields := strings.SplitN(strings.TrimSpace(expression), " ", 3)
+	if len(fields) != 3 || !strings.EqualFold(fields[1], "eq") {
+		return nil, invalid
+	}
+	value := strings.TrimSpace(fields[2])
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, invalid
+		}
+		value = unquoted
+	}
+	attribute := fields[0]
+	// the attribute may be prefixed by the URN of its schema
+	if i := strings.LastIndex(attribute, ":"); i >= 0 {
+		attribute = attribute[i+1:]
+	}
+	return &filter{attribute: strings.ToLower(attribute), value: value}, nil
+}
+
+// matches returns true if the value of the attribute in attributes, by lower case name, is the value of the filter.
+// userName is compared case-insensitively, as required by its schema.
+func (f *filter) matches(attributes map[string]string) bool {
+	if f == nil {
+		return true
+	}
+	if f.attribute == "username" {
+		return strings.EqualFold(attributes[f.attribute], f.value)
+	}
+	return attributes[f.attribute] == f.value
+}
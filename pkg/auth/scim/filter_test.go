@@ -0,0 +1,73 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   *filter
+		expectsErr bool
+	}{
+		{
+			name:       "quoted value",
+			expression: `userName eq "jdoe@example.com"`,
+			expected:   &filter{attribute: "username", value: "jdoe@example.com"},
+		},
+		{
+			name:       "case insensitive operator",
+			expression: `externalId EQ "00u1"`,
+			expected:   &filter{attribute: "externalid", value: "00u1"},
+		},
+		{
+			name:       "value with spaces",
+			expression: `displayName eq "Site Reliability"`,
+			expected:   &filter{attribute: "displayname", value: "Site Reliability"},
+		},
+		{
+			name:       "schema urn",
+			expression: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jdoe"`,
+			expected:   &filter{attribute: "username", value: "jdoe"},
+		},
+		{
+			name:       "unsupported operator",
+			expression: `userName sw "j"`,
+			expectsErr: true,
+		},
+		{
+			name:       "logical expression",
+			expression: `userName eq "a" or userName eq "b"`,
+			expectsErr: true,
+		},
+		{
+			name:       "missing value",
+			expression: `userName eq`,
+			expectsErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := parseFilter(test.expression)
+			if test.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, f)
+		})
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	attributes := map[string]string{"username": "JDoe", "externalid": "00u1"}
+	assert.True(t, (*filter)(nil).matches(attributes))
+	assert.True(t, (&filter{attribute: "username", value: "jdoe"}).matches(attributes))
+	assert.True(t, (&filter{attribute: "externalid", value: "00u1"}).matches(attributes))
+	assert.False(t, (&filter{attribute: "externalid", value: "00U1"}).matches(attributes))
+	assert.False(t, (&filter{attribute: "displayname", value: "jdoe"}).matches(attributes))
+}
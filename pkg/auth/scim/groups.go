@@ -0,0 +1,446 @@
+package scim
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// GroupLabel is set on the config maps of the cattle-global-data namespace storing the groups provisioned by SCIM.
+	GroupLabel = "authn.management.cattle.io/scim-group"
+
+	groupNamePrefix  = "scim-group-"
+	keyDisplayName   = "displayName"
+	keyExternalID    = "externalId"
+	keyPrincipalID   = "principalId"
+	excludeAttribute = "excludedAttributes"
+)
+
+// Group is the SCIM representation of a group. Its id is the name of the config map storing it, and its members are
+// the users whose UserAttribute has its principal.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Member is a member of a group, whose value is the id of a user.
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+func toGroup(cm *corev1.ConfigMap, members []Member) Group {
+	if members == nil {
+		members = []Member{}
+	}
+	return Group{
+		Schemas:     []string{groupSchema},
+		ID:          cm.Name,
+		ExternalID:  cm.Data[keyExternalID],
+		DisplayName: cm.Data[keyDisplayName],
+		Members:     members,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      cm.CreationTimestamp.UTC().Format(time.RFC3339),
+			Location:     Endpoint + "/Groups/" + cm.Name,
+		},
+	}
+}
+
+// groupPrincipal returns the principal of the group, which is stored in the group principals of the UserAttributes
+// of its members.
+func groupPrincipal(cm *corev1.ConfigMap, provider string) v3.Principal {
+	return v3.Principal{
+		ObjectMeta:    metav1.ObjectMeta{Name: cm.Data[keyPrincipalID]},
+		DisplayName:   cm.Data[keyDisplayName],
+		PrincipalType: "group",
+		MemberOf:      true,
+		Provider:      provider,
+	}
+}
+
+func (h *Handler) listGroups(rw http.ResponseWriter, req *http.Request) {
+	params, err := parseListParams(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	cms, err := h.configMapCache.List(namespace.GlobalNamespace, labels.SelectorFromSet(labels.Set{GroupLabel: "true"}))
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	sort.Slice(cms, func(i, j int) bool { return cms[i].Name < cms[j].Name })
+
+	var members map[string][]Member
+	if !excludesMembers(req) {
+		if members, err = h.membersByPrincipal(); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	var resources []any
+	for _, cm := range cms {
+		group := toGroup(cm, members[cm.Data[keyPrincipalID]])
+		if params.filter.matches(map[string]string{
+			"id":          group.ID,
+			"externalid":  group.ExternalID,
+			"displayname": group.DisplayName,
+		}) {
+			resources = append(resources, group)
+		}
+	}
+	writeResponse(rw, http.StatusOK, params.page(resources))
+}
+
+func (h *Handler) getGroup(rw http.ResponseWriter, req *http.Request) {
+	cm, err := h.getGroupConfigMap(mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	var members []Member
+	if !excludesMembers(req) {
+		all, err := h.membersByPrincipal()
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		members = all[cm.Data[keyPrincipalID]]
+	}
+	writeResponse(rw, http.StatusOK, toGroup(cm, members))
+}
+
+// createGroup stores the group in a config map, and adds its principal to the UserAttribute of its members. The
+// principal of the group is set when it is created, from its externalId or its displayName depending on the auth
+// provider.
+func (h *Handler) createGroup(rw http.ResponseWriter, req *http.Request) {
+	var group Group
+	if err := decodeBody(req, &group); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if group.DisplayName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "displayName is required"))
+		return
+	}
+
+	principalID, err := groupPrincipalID(h.provider(), group)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	cms, err := h.configMapCache.List(namespace.GlobalNamespace, labels.SelectorFromSet(labels.Set{GroupLabel: "true"}))
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	for _, cm := range cms {
+		if cm.Data[keyPrincipalID] == principalID {
+			writeError(rw, newError(http.StatusConflict, "uniqueness", "group "+group.DisplayName+" already exists"))
+			return
+		}
+	}
+
+	for _, member := range group.Members {
+		if _, err := h.users.Get(member.Value, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			writeError(rw, newError(http.StatusBadRequest, "invalidValue", "user "+member.Value+" not found"))
+			return
+		} else if err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+
+	cm, err := h.configMaps.Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: groupNamePrefix,
+			Namespace:    namespace.GlobalNamespace,
+			Labels:       map[string]string{GroupLabel: "true"},
+		},
+		Data: map[string]string{
+			keyDisplayName: group.DisplayName,
+			keyExternalID:  group.ExternalID,
+			keyPrincipalID: principalID,
+		},
+	})
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	logrus.Infof("[%s] Provisioning group %s for principal %s", logPrefix, cm.Name, principalID)
+
+	members, err := h.setMembers(cm, nil, group.Members)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	writeResponse(rw, http.StatusCreated, toGroup(cm, members))
+}
+
+func (h *Handler) replaceGroup(rw http.ResponseWriter, req *http.Request) {
+	var group Group
+	if err := decodeBody(req, &group); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if group.DisplayName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "displayName is required"))
+		return
+	}
+	h.updateGroup(rw, mux.Vars(req)["id"], func(existing *Group) error {
+		existing.DisplayName = group.DisplayName
+		existing.ExternalID = group.ExternalID
+		existing.Members = group.Members
+		return nil
+	})
+}
+
+func (h *Handler) patchGroup(rw http.ResponseWriter, req *http.Request) {
+	var patch patchRequest
+	if err := decodeBody(req, &patch); err != nil {
+		writeError(rw, err)
+		return
+	}
+	operations, err := patch.operations()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.updateGroup(rw, mux.Vars(req)["id"], func(group *Group) error {
+		return applyGroupPatch(group, operations)
+	})
+}
+
+// updateGroup applies update to the group with the given id, and updates the memberships of the users who were added
+// or removed.
+func (h *Handler) updateGroup(rw http.ResponseWriter, id string, update func(*Group) error) {
+	cm, err := h.getGroupConfigMap(id)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	all, err := h.membersByPrincipal()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	current := all[cm.Data[keyPrincipalID]]
+
+	group := toGroup(cm, append([]Member(nil), current...))
+	if err := update(&group); err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	if cm.Data[keyDisplayName] != group.DisplayName || cm.Data[keyExternalID] != group.ExternalID {
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			cm, err = h.configMaps.Get(namespace.GlobalNamespace, id, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			cm = cm.DeepCopy()
+			cm.Data[keyDisplayName] = group.DisplayName
+			cm.Data[keyExternalID] = group.ExternalID
+			cm, err = h.configMaps.Update(cm)
+			return err
+		})
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+
+	members, err := h.setMembers(cm, current, group.Members)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	writeResponse(rw, http.StatusOK, toGroup(cm, members))
+}
+
+// deleteGroup removes the principal of the group from the UserAttribute of its members, then deletes the group.
+func (h *Handler) deleteGroup(rw http.ResponseWriter, req *http.Request) {
+	cm, err := h.getGroupConfigMap(mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	all, err := h.membersByPrincipal()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	logrus.Infof("[%s] Deprovisioning group %s", logPrefix, cm.Name)
+	if _, err := h.setMembers(cm, all[cm.Data[keyPrincipalID]], nil); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.configMaps.Delete(namespace.GlobalNamespace, cm.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getGroupConfigMap(id string) (*corev1.ConfigMap, error) {
+	cm, err := h.configMaps.Get(namespace.GlobalNamespace, id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && cm.Labels[GroupLabel] != "true") {
+		return nil, newError(http.StatusNotFound, "", "group "+id+" not found")
+	} else if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// membersByPrincipal returns the members of the groups of the auth provider by principal ID.
+func (h *Handler) membersByPrincipal() (map[string][]Member, error) {
+	provider := h.provider()
+	attribs, err := h.userAttributeCache.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(attribs, func(i, j int) bool { return attribs[i].Name < attribs[j].Name })
+	members := map[string][]Member{}
+	for _, attrib := range attribs {
+		for _, principal := range attrib.GroupPrincipals[tokens.SCIMGroupPrincipalsKey(provider)].Items {
+			members[principal.Name] = append(members[principal.Name], Member{Value: attrib.Name})
+		}
+	}
+	return members, nil
+}
+
+// setMembers adds the principal of the group to the UserAttribute of the users of desired who aren't in current, and
+// removes it from the users of current who aren't in desired. It returns the resulting members of the group. Removed
+// members lose the permissions of the group right away, as requests are authenticated with the group principals of
+// the UserAttribute.
+func (h *Handler) setMembers(cm *corev1.ConfigMap, current, desired []Member) ([]Member, error) {
+	principal := groupPrincipal(cm, h.provider())
+	keep := map[string]bool{}
+	var members []Member
+	for _, member := range desired {
+		if keep[member.Value] {
+			continue
+		}
+		keep[member.Value] = true
+		if err := h.setMembership(member.Value, principal, true); err != nil {
+			return nil, err
+		}
+		members = append(members, Member{Value: member.Value})
+	}
+	for _, member := range current {
+		if keep[member.Value] {
+			continue
+		}
+		if err := h.setMembership(member.Value, principal, false); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Value < members[j].Value })
+	return members, nil
+}
+
+// setMembership adds the group principal to the UserAttribute of the user, or removes it, creating the UserAttribute
+// if the user has none yet. The memberships are stored under their own key, so that they aren't replaced by the groups
+// of the auth provider when the user logs in or its groups are refreshed.
+func (h *Handler) setMembership(userID string, principal v3.Principal, member bool) error {
+	key := tokens.SCIMGroupPrincipalsKey(principal.Provider)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		attribs, err := h.userAttributes.Get(userID, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if !member {
+				return nil
+			}
+			user, err := h.users.Get(userID, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return newError(http.StatusBadRequest, "invalidValue", "user "+userID+" not found")
+			} else if err != nil {
+				return err
+			}
+			attribs = &v3.UserAttribute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: userID,
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "management.cattle.io/v3",
+						Kind:       "User",
+						Name:       user.Name,
+						UID:        user.UID,
+					}},
+				},
+				GroupPrincipals: map[string]v3.Principals{},
+				ExtraByProvider: map[string]map[string][]string{},
+			}
+		} else if err != nil {
+			return err
+		} else {
+			attribs = attribs.DeepCopy()
+		}
+		if attribs.GroupPrincipals == nil {
+			attribs.GroupPrincipals = map[string]v3.Principals{}
+		}
+
+		var items []v3.Principal
+		found, changed := false, false
+		for _, item := range attribs.GroupPrincipals[key].Items {
+			if item.Name != principal.Name {
+				items = append(items, item)
+				continue
+			}
+			found = true
+			if !member {
+				changed = true
+			} else if item.DisplayName != principal.DisplayName {
+				items = append(items, principal)
+				changed = true
+			} else {
+				items = append(items, item)
+			}
+		}
+		if member && !found {
+			items = append(items, principal)
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		attribs.GroupPrincipals[key] = v3.Principals{Items: items}
+
+		if attribs.ResourceVersion == "" {
+			_, err = h.userAttributes.Create(attribs)
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(v3.Resource("userattributes"), userID, err)
+			}
+			return err
+		}
+		_, err = h.userAttributes.Update(attribs)
+		return err
+	})
+}
+
+// excludesMembers returns true if the request excludes the members of groups from the response, which identity
+// providers do to avoid listing the members of large groups.
+func excludesMembers(req *http.Request) bool {
+	for _, attribute := range strings.Split(req.URL.Query().Get(excludeAttribute), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
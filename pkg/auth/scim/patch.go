@@ -0,0 +1,200 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+)
+
+// patchRequest is the body of a PATCH request, see RFC 7644 section 3.5.2.
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// operations returns the operations of the request, with lower case ops. Operations without a path whose value is an
+// object are split into one operation per attribute of the object, which is how some identity providers send them.
+func (p *patchRequest) operations() ([]patchOperation, error) {
+	var operations []patchOperation
+	for _, operation := range p.Operations {
+		operation.Op = strings.ToLower(operation.Op)
+		switch operation.Op {
+		case opAdd, opReplace, opRemove:
+		default:
+			return nil, newError(http.StatusBadRequest, "invalidSyntax", "unsupported operation "+operation.Op)
+		}
+		if operation.Path != "" {
+			operations = append(operations, operation)
+			continue
+		}
+		if operation.Op == opRemove {
+			return nil, newError(http.StatusBadRequest, "noTarget", "remove operations require a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "operations without a path require an object value")
+		}
+		for path, value := range values {
+			operations = append(operations, patchOperation{Op: operation.Op, Path: path, Value: value})
+		}
+	}
+	return operations, nil
+}
+
+// applyUserPatch applies the operations to the user. Operations on attributes which aren't stored are ignored.
+func applyUserPatch(user *User, operations []patchOperation) error {
+	for _, operation := range operations {
+		path := strings.ToLower(operation.Path)
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			path = path[i+1:]
+		}
+		if path == "active" {
+			active := false
+			if operation.Op != opRemove {
+				var err error
+				if active, err = parseBool(operation.Value); err != nil {
+					return err
+				}
+			}
+			user.Active = &active
+			continue
+		}
+
+		var field *string
+		switch path {
+		case "username":
+			field = &user.UserName
+		case "externalid":
+			field = &user.ExternalID
+		case "displayname":
+			field = &user.DisplayName
+		case "name.formatted", "name.givenname", "name.familyname":
+			if user.Name == nil {
+				user.Name = &Name{}
+			}
+			switch path {
+			case "name.formatted":
+				field = &user.Name.Formatted
+			case "name.givenname":
+				field = &user.Name.GivenName
+			default:
+				field = &user.Name.FamilyName
+			}
+		case "name":
+			if operation.Op == opRemove {
+				user.Name = nil
+			} else if err := json.Unmarshal(operation.Value, &user.Name); err != nil {
+				return newError(http.StatusBadRequest, "invalidValue", "invalid value of name")
+			}
+			continue
+		default:
+			continue
+		}
+		if operation.Op == opRemove {
+			*field = ""
+		} else if err := json.Unmarshal(operation.Value, field); err != nil {
+			return newError(http.StatusBadRequest, "invalidValue", "invalid value of "+operation.Path)
+		}
+	}
+	if user.UserName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	return nil
+}
+
+// applyGroupPatch applies the operations to the group. Members can be removed with a path of the form
+// `members[value eq "id"]`.
+func applyGroupPatch(group *Group, operations []patchOperation) error {
+	for _, operation := range operations {
+		path := operation.Path
+		if i := strings.LastIndex(path, ":"); i >= 0 && !strings.Contains(path[:i], "[") {
+			path = path[i+1:]
+		}
+		lowerPath := strings.ToLower(path)
+		switch {
+		case lowerPath == "displayname" || lowerPath == "externalid":
+			field := &group.DisplayName
+			if lowerPath == "externalid" {
+				field = &group.ExternalID
+			}
+			if operation.Op == opRemove {
+				*field = ""
+			} else if err := json.Unmarshal(operation.Value, field); err != nil {
+				return newError(http.StatusBadRequest, "invalidValue", "invalid value of "+operation.Path)
+			}
+		case lowerPath == "members":
+			var members []Member
+			if len(operation.Value) > 0 {
+				if err := json.Unmarshal(operation.Value, &members); err != nil {
+					return newError(http.StatusBadRequest, "invalidValue", "invalid value of members")
+				}
+			}
+			switch {
+			case operation.Op == opReplace:
+				group.Members = members
+			case operation.Op == opAdd:
+				group.Members = append(group.Members, members...)
+			case len(members) == 0:
+				group.Members = nil
+			default:
+				for _, member := range members {
+					group.Members = removeMember(group.Members, member.Value)
+				}
+			}
+		case strings.HasPrefix(lowerPath, "members[") && strings.HasSuffix(lowerPath, "]"):
+			if operation.Op != opRemove {
+				return newError(http.StatusBadRequest, "invalidPath", "only remove operations are supported on "+operation.Path)
+			}
+			f, err := parseFilter(path[len("members[") : len(path)-1])
+			if err != nil {
+				return err
+			}
+			if f.attribute != "value" {
+				return newError(http.StatusBadRequest, "invalidFilter", "unsupported filter "+operation.Path)
+			}
+			group.Members = removeMember(group.Members, f.value)
+		}
+	}
+	if group.DisplayName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	return nil
+}
+
+func removeMember(members []Member, id string) []Member {
+	var result []Member
+	for _, member := range members {
+		if member.Value != id {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+// parseBool parses a boolean, which some identity providers send as a string.
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, newError(http.StatusBadRequest, "invalidValue", "invalid boolean "+string(value))
+}
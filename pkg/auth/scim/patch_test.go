@@ -0,0 +1,140 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patchOperations(t *testing.T, body string) ([]patchOperation, error) {
+	var patch patchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &patch))
+	return patch.operations()
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	active := true
+	tests := []struct {
+		name       string
+		body       string
+		expected   User
+		expectsErr bool
+	}{
+		{
+			name:     "deactivate",
+			body:     `{"Operations":[{"op":"replace","path":"active","value":false}]}`,
+			expected: User{UserName: "jdoe", DisplayName: "John", Active: new(bool)},
+		},
+		{
+			name:     "deactivate with string value",
+			body:     `{"Operations":[{"op":"Replace","path":"active","value":"False"}]}`,
+			expected: User{UserName: "jdoe", DisplayName: "John", Active: new(bool)},
+		},
+		{
+			name:     "operation without path",
+			body:     `{"Operations":[{"op":"replace","value":{"userName":"john","externalId":"00u1"}}]}`,
+			expected: User{UserName: "john", ExternalID: "00u1", DisplayName: "John", Active: &active},
+		},
+		{
+			name:     "remove display name and set name",
+			body:     `{"Operations":[{"op":"remove","path":"displayName"},{"op":"add","path":"name.givenName","value":"Johnny"}]}`,
+			expected: User{UserName: "jdoe", Name: &Name{GivenName: "Johnny"}, Active: &active},
+		},
+		{
+			name:     "unsupported attribute is ignored",
+			body:     `{"Operations":[{"op":"replace","path":"emails[type eq \"work\"].value","value":"jdoe@example.com"}]}`,
+			expected: User{UserName: "jdoe", DisplayName: "John", Active: &active},
+		},
+		{
+			name:       "remove userName",
+			body:       `{"Operations":[{"op":"remove","path":"userName"}]}`,
+			expectsErr: true,
+		},
+		{
+			name:       "invalid boolean",
+			body:       `{"Operations":[{"op":"replace","path":"active","value":"no"}]}`,
+			expectsErr: true,
+		},
+		{
+			name:       "unsupported operation",
+			body:       `{"Operations":[{"op":"move","path":"active"}]}`,
+			expectsErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enabled := true
+			user := User{UserName: "jdoe", DisplayName: "John", Active: &enabled}
+			operations, err := patchOperations(t, test.body)
+			if err == nil {
+				err = applyUserPatch(&user, operations)
+			}
+			if test.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, user)
+		})
+	}
+}
+
+func TestApplyGroupPatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expected   []Member
+		expectsErr bool
+	}{
+		{
+			name:     "add members",
+			body:     `{"Operations":[{"op":"add","path":"members","value":[{"value":"u-c"}]}]}`,
+			expected: []Member{{Value: "u-a"}, {Value: "u-b"}, {Value: "u-c"}},
+		},
+		{
+			name:     "remove members by value",
+			body:     `{"Operations":[{"op":"remove","path":"members","value":[{"value":"u-a"}]}]}`,
+			expected: []Member{{Value: "u-b"}},
+		},
+		{
+			name:     "remove members by filter",
+			body:     `{"Operations":[{"op":"remove","path":"members[value eq \"u-b\"]"}]}`,
+			expected: []Member{{Value: "u-a"}},
+		},
+		{
+			name: "remove all members",
+			body: `{"Operations":[{"op":"remove","path":"members"}]}`,
+		},
+		{
+			name:     "replace members",
+			body:     `{"Operations":[{"op":"replace","value":{"members":[{"value":"u-c"}]}}]}`,
+			expected: []Member{{Value: "u-c"}},
+		},
+		{
+			name:       "filter on other attribute",
+			body:       `{"Operations":[{"op":"remove","path":"members[display eq \"a\"]"}]}`,
+			expectsErr: true,
+		},
+		{
+			name:       "remove display name",
+			body:       `{"Operations":[{"op":"remove","path":"displayName"}]}`,
+			expectsErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			group := Group{DisplayName: "Engineering", Members: []Member{{Value: "u-a"}, {Value: "u-b"}}}
+			operations, err := patchOperations(t, test.body)
+			require.NoError(t, err)
+			err = applyGroupPatch(&group, operations)
+			if test.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, group.Members)
+		})
+	}
+}
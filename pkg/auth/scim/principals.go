@@ -0,0 +1,59 @@
+package scim
+
+import (
+	"net/http"
+)
+
+// principalFormat is how an auth provider identifies the principals of its users and groups, so that the users and
+// groups provisioned by SCIM have the principals their users log in with.
+type principalFormat struct {
+	// userByExternalID is true if users are identified by the ID the identity provider sends as their externalId,
+	// rather than by their userName.
+	userByExternalID bool
+	// groupByExternalID is true if groups are identified by the ID the identity provider sends as their externalId,
+	// rather than by their displayName.
+	groupByExternalID bool
+}
+
+// principalFormats are the formats of the auth providers SCIM provisioning supports, by auth provider name. The IDs of
+// the LDAP providers are distinguished names, and the groups of GitHub are either organizations or teams, which can't
+// be told apart from their SCIM representation, so these providers aren't supported.
+var principalFormats = map[string]principalFormat{
+	// users and groups are identified by their object ID
+	"azuread": {userByExternalID: true, groupByExternalID: true},
+	// users and groups are identified by their Google ID
+	"googleoauth": {userByExternalID: true, groupByExternalID: true},
+	// users are identified by their subject, and groups by the name in the groups claim
+	"oidc":         {userByExternalID: true},
+	"keycloakoidc": {userByExternalID: true},
+	// users are identified by their UID attribute, and groups by their name
+	"adfs":       {},
+	"keycloak":   {},
+	"okta":       {},
+	"ping":       {},
+	"shibboleth": {},
+}
+
+// userPrincipalID returns the ID of the principal of the user for the auth provider.
+func userPrincipalID(provider string, user User) (string, error) {
+	id := user.UserName
+	if principalFormats[provider].userByExternalID {
+		if user.ExternalID == "" {
+			return "", newError(http.StatusBadRequest, "invalidValue", "externalId is required by auth provider "+provider)
+		}
+		id = user.ExternalID
+	}
+	return userPrincipalPrefix(provider) + id, nil
+}
+
+// groupPrincipalID returns the ID of the principal of the group for the auth provider.
+func groupPrincipalID(provider string, group Group) (string, error) {
+	id := group.DisplayName
+	if principalFormats[provider].groupByExternalID {
+		if group.ExternalID == "" {
+			return "", newError(http.StatusBadRequest, "invalidValue", "externalId is required by auth provider "+provider)
+		}
+		id = group.ExternalID
+	}
+	return provider + "_group://" + id, nil
+}
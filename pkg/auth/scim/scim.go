@@ -0,0 +1,292 @@
+// Package scim provides a SCIM 2.0 server (RFC 7643 and RFC 7644) which lets an identity provider push its users and
+// group memberships to Rancher as soon as they change, instead of Rancher learning about them at login or on the next
+// provider refresh. Users are provisioned as v3.User objects with a principal of the auth provider configured by the
+// scim-auth-provider setting, and group memberships are stored in the group principals of their UserAttribute, under a
+// key of their own which logins and provider refreshes leave alone.
+package scim
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/rancher/rancher/pkg/user"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Endpoint is the path prefix of the SCIM server, which identity providers use as its base URL.
+	Endpoint = "/v1-scim"
+
+	// TokenSecretName is the name of the secret of the cattle-global-data namespace holding the bearer token which
+	// identity providers authenticate with, in its TokenSecretKey key.
+	TokenSecretName = "scim-token"
+	TokenSecretKey  = "token"
+
+	contentType = "application/scim+json"
+	logPrefix   = "scim"
+
+	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listSchema                  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	patchSchema                 = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	errorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	defaultCount = 100
+	maxCount     = 1000
+)
+
+// Handler implements http.Handler and serves the /Users, /Groups and /ServiceProviderConfig resources of the SCIM
+// server under Endpoint. It authenticates requests itself, so it should be registered as an unauthenticated route.
+type Handler struct {
+	users              mgmtcontrollers.UserClient
+	userCache          mgmtcontrollers.UserCache
+	userAttributes     mgmtcontrollers.UserAttributeClient
+	userAttributeCache mgmtcontrollers.UserAttributeCache
+	tokens             mgmtcontrollers.TokenClient
+	tokenCache         mgmtcontrollers.TokenCache
+	configMaps         corecontrollers.ConfigMapClient
+	configMapCache     corecontrollers.ConfigMapCache
+	secretCache        corecontrollers.SecretCache
+	userManager        user.Manager
+	provider           func() string
+	router             *mux.Router
+}
+
+// NewHandler returns a Handler using the clients and caches of scaledContext.
+func NewHandler(scaledContext *config.ScaledContext) *Handler {
+	mgmt := scaledContext.Wrangler.Mgmt
+	core := scaledContext.Wrangler.Core
+	return newHandler(&Handler{
+		users:              mgmt.User(),
+		userCache:          mgmt.User().Cache(),
+		userAttributes:     mgmt.UserAttribute(),
+		userAttributeCache: mgmt.UserAttribute().Cache(),
+		tokens:             mgmt.Token(),
+		tokenCache:         mgmt.Token().Cache(),
+		configMaps:         core.ConfigMap(),
+		configMapCache:     core.ConfigMap().Cache(),
+		secretCache:        core.Secret().Cache(),
+		userManager:        scaledContext.UserManager,
+		provider:           settings.SCIMAuthProvider.Get,
+	})
+}
+
+func newHandler(h *Handler) *Handler {
+	router := mux.NewRouter().PathPrefix(Endpoint).Subrouter()
+	router.UseEncodedPath()
+	router.Path("/ServiceProviderConfig").Methods(http.MethodGet).HandlerFunc(h.serviceProviderConfig)
+	router.Path("/Users").Methods(http.MethodGet).HandlerFunc(h.listUsers)
+	router.Path("/Users").Methods(http.MethodPost).HandlerFunc(h.createUser)
+	router.Path("/Users/{id}").Methods(http.MethodGet).HandlerFunc(h.getUser)
+	router.Path("/Users/{id}").Methods(http.MethodPut).HandlerFunc(h.replaceUser)
+	router.Path("/Users/{id}").Methods(http.MethodPatch).HandlerFunc(h.patchUser)
+	router.Path("/Users/{id}").Methods(http.MethodDelete).HandlerFunc(h.deleteUser)
+	router.Path("/Groups").Methods(http.MethodGet).HandlerFunc(h.listGroups)
+	router.Path("/Groups").Methods(http.MethodPost).HandlerFunc(h.createGroup)
+	router.Path("/Groups/{id}").Methods(http.MethodGet).HandlerFunc(h.getGroup)
+	router.Path("/Groups/{id}").Methods(http.MethodPut).HandlerFunc(h.replaceGroup)
+	router.Path("/Groups/{id}").Methods(http.MethodPatch).HandlerFunc(h.patchGroup)
+	router.Path("/Groups/{id}").Methods(http.MethodDelete).HandlerFunc(h.deleteGroup)
+	router.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		writeError(rw, newError(http.StatusNotFound, "", "resource not found"))
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		writeError(rw, newError(http.StatusMethodNotAllowed, "", "method not allowed"))
+	})
+	h.router = router
+	return h
+}
+
+// ServeHTTP implements http.Handler - it serves the request if SCIM provisioning is enabled and the request has the
+// bearer token of the scim-token secret.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	provider := h.provider()
+	if provider == "" {
+		writeError(rw, newError(http.StatusNotFound, "", "SCIM provisioning is not enabled"))
+		return
+	}
+	if _, ok := principalFormats[provider]; !ok {
+		writeError(rw, newError(http.StatusNotImplemented, "", "SCIM provisioning is not supported for auth provider "+provider))
+		return
+	}
+	if !h.authenticate(req) {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(rw, newError(http.StatusUnauthorized, "", "invalid bearer token"))
+		return
+	}
+	h.router.ServeHTTP(rw, req)
+}
+
+// authenticate returns true if the request has the bearer token of the scim-token secret, which is compared in
+// constant time.
+func (h *Handler) authenticate(req *http.Request) bool {
+	auth := req.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return false
+	}
+	secret, err := h.secretCache.Get(namespace.GlobalNamespace, TokenSecretName)
+	if err != nil {
+		logrus.Debugf("[%s] Failed to get secret %s: %v", logPrefix, TokenSecretName, err)
+		return false
+	}
+	token := secret.Data[TokenSecretKey]
+	if len(token) == 0 {
+		return false
+	}
+	expected := sha256.Sum256(token)
+	actual := sha256.Sum256([]byte(strings.TrimSpace(auth[7:])))
+	return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+}
+
+func (h *Handler) serviceProviderConfig(rw http.ResponseWriter, req *http.Request) {
+	writeResponse(rw, http.StatusOK, map[string]any{
+		"schemas":        []string{serviceProviderConfigSchema},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": maxCount},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "The token of the " + TokenSecretName + " secret of the " + namespace.GlobalNamespace + " namespace",
+		}},
+	})
+}
+
+// Meta is the metadata of a resource.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+// listResponse is a page of the resources matching a list request.
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// listParams are the filter and pagination query parameters of a list request.
+type listParams struct {
+	filter     *filter
+	startIndex int
+	count      int
+}
+
+func parseListParams(req *http.Request) (listParams, error) {
+	query := req.URL.Query()
+	params := listParams{startIndex: 1, count: defaultCount}
+	if value := query.Get("filter"); value != "" {
+		f, err := parseFilter(value)
+		if err != nil {
+			return params, err
+		}
+		params.filter = f
+	}
+	if value := query.Get("startIndex"); value != "" {
+		startIndex, err := strconv.Atoi(value)
+		if err != nil {
+			return params, newError(http.StatusBadRequest, "invalidValue", "invalid startIndex "+value)
+		}
+		if startIndex > 1 {
+			params.startIndex = startIndex
+		}
+	}
+	if value := query.Get("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return params, newError(http.StatusBadRequest, "invalidValue", "invalid count "+value)
+		}
+		params.count = count
+		if count < 0 {
+			params.count = 0
+		} else if count > maxCount {
+			params.count = maxCount
+		}
+	}
+	return params, nil
+}
+
+// page returns the list response of the page of resources requested by params.
+func (p listParams) page(resources []any) listResponse {
+	response := listResponse{
+		Schemas:      []string{listSchema},
+		TotalResults: len(resources),
+		StartIndex:   p.startIndex,
+		Resources:    []any{},
+	}
+	if start := p.startIndex - 1; start < len(resources) {
+		end := start + p.count
+		if end > len(resources) {
+			end = len(resources)
+		}
+		response.Resources = resources[start:end]
+	}
+	response.ItemsPerPage = len(response.Resources)
+	return response
+}
+
+// Error is a SCIM error response, which is also returned as an error by the functions serving requests.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func newError(status int, scimType, detail string) *Error {
+	return &Error{
+		Schemas:  []string{errorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Status, e.SCIMType, e.Detail)
+}
+
+// writeError writes err as a SCIM error. Errors which aren't SCIM errors are logged and written as internal errors,
+// so that their details aren't sent to the identity provider.
+func writeError(rw http.ResponseWriter, err error) {
+	scimErr, ok := err.(*Error)
+	if !ok {
+		logrus.Errorf("[%s] %v", logPrefix, err)
+		scimErr = newError(http.StatusInternalServerError, "", http.StatusText(http.StatusInternalServerError))
+	}
+	status, _ := strconv.Atoi(scimErr.Status)
+	writeResponse(rw, status, scimErr)
+}
+
+func writeResponse(rw http.ResponseWriter, status int, body any) {
+	rw.Header().Set("Content-Type", contentType)
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		logrus.Warnf("[%s] Failed to write response: %v", logPrefix, err)
+	}
+}
+
+func decodeBody(req *http.Request, into any) error {
+	if err := json.NewDecoder(req.Body).Decode(into); err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", "invalid request body: "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,536 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/user"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testToken = "scim-test-token"
+
+type object interface {
+	comparable
+	runtime.Object
+	metav1.Object
+}
+
+// store is an in-memory store of objects by namespace and name, whose updates fail with a conflict unless the
+// resource version matches.
+type store[T object] struct {
+	resource string
+	objects  map[string]T
+	version  int
+}
+
+func newStore[T object](resource string) *store[T] {
+	return &store[T]{resource: resource, objects: map[string]T{}}
+}
+
+func (s *store[T]) get(ns, name string) (T, error) {
+	if obj, ok := s.objects[ns+"/"+name]; ok {
+		return obj.DeepCopyObject().(T), nil
+	}
+	var zero T
+	return zero, apierrors.NewNotFound(v3.Resource(s.resource), name)
+}
+
+func (s *store[T]) save(obj T) T {
+	s.version++
+	obj = obj.DeepCopyObject().(T)
+	if obj.GetName() == "" {
+		obj.SetName(obj.GetGenerateName() + strconv.Itoa(s.version))
+	}
+	obj.SetResourceVersion(strconv.Itoa(s.version))
+	s.objects[obj.GetNamespace()+"/"+obj.GetName()] = obj
+	return obj.DeepCopyObject().(T)
+}
+
+func (s *store[T]) create(obj T) (T, error) {
+	if _, ok := s.objects[obj.GetNamespace()+"/"+obj.GetName()]; ok && obj.GetName() != "" {
+		var zero T
+		return zero, apierrors.NewAlreadyExists(v3.Resource(s.resource), obj.GetName())
+	}
+	return s.save(obj), nil
+}
+
+func (s *store[T]) update(obj T) (T, error) {
+	if existing, ok := s.objects[obj.GetNamespace()+"/"+obj.GetName()]; !ok || existing.GetResourceVersion() != obj.GetResourceVersion() {
+		var zero T
+		return zero, apierrors.NewConflict(v3.Resource(s.resource), obj.GetName(), nil)
+	}
+	return s.save(obj), nil
+}
+
+func (s *store[T]) delete(ns, name string) error {
+	if _, ok := s.objects[ns+"/"+name]; !ok {
+		return apierrors.NewNotFound(v3.Resource(s.resource), name)
+	}
+	delete(s.objects, ns+"/"+name)
+	return nil
+}
+
+func (s *store[T]) list(ns string, selector labels.Selector) []T {
+	var list []T
+	for _, obj := range s.objects {
+		if (ns == "" || obj.GetNamespace() == ns) && selector.Matches(labels.Set(obj.GetLabels())) {
+			list = append(list, obj.DeepCopyObject().(T))
+		}
+	}
+	return list
+}
+
+func nonNamespacedFakes[T object, TList runtime.Object](ctrl *gomock.Controller, s *store[T]) (*fake.MockNonNamespacedClientInterface[T, TList], *fake.MockNonNamespacedCacheInterface[T]) {
+	client := fake.NewMockNonNamespacedClientInterface[T, TList](ctrl)
+	client.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(name string, _ metav1.GetOptions) (T, error) {
+		return s.get("", name)
+	}).AnyTimes()
+	client.EXPECT().Create(gomock.Any()).DoAndReturn(s.create).AnyTimes()
+	client.EXPECT().Update(gomock.Any()).DoAndReturn(s.update).AnyTimes()
+	client.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(func(name string, _ *metav1.DeleteOptions) error {
+		return s.delete("", name)
+	}).AnyTimes()
+	cache := fake.NewMockNonNamespacedCacheInterface[T](ctrl)
+	cache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (T, error) {
+		return s.get("", name)
+	}).AnyTimes()
+	cache.EXPECT().List(gomock.Any()).DoAndReturn(func(selector labels.Selector) ([]T, error) {
+		return s.list("", selector), nil
+	}).AnyTimes()
+	return client, cache
+}
+
+func namespacedFakes[T object, TList runtime.Object](ctrl *gomock.Controller, s *store[T]) (*fake.MockClientInterface[T, TList], *fake.MockCacheInterface[T]) {
+	client := fake.NewMockClientInterface[T, TList](ctrl)
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ns, name string, _ metav1.GetOptions) (T, error) {
+		return s.get(ns, name)
+	}).AnyTimes()
+	client.EXPECT().Create(gomock.Any()).DoAndReturn(s.create).AnyTimes()
+	client.EXPECT().Update(gomock.Any()).DoAndReturn(s.update).AnyTimes()
+	client.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ns, name string, _ *metav1.DeleteOptions) error {
+		return s.delete(ns, name)
+	}).AnyTimes()
+	cache := fake.NewMockCacheInterface[T](ctrl)
+	cache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(s.get).AnyTimes()
+	cache.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(ns string, selector labels.Selector) ([]T, error) {
+		return s.list(ns, selector), nil
+	}).AnyTimes()
+	return client, cache
+}
+
+// fakeUserManager creates users by principal like the user manager does at login.
+type fakeUserManager struct {
+	user.Manager
+	users *store[*v3.User]
+}
+
+func (m *fakeUserManager) EnsureUser(principalName, displayName string) (*v3.User, error) {
+	for _, u := range m.users.list("", labels.Everything()) {
+		for _, id := range u.PrincipalIDs {
+			if id == principalName {
+				return u, nil
+			}
+		}
+	}
+	return m.users.create(&v3.User{
+		ObjectMeta:   metav1.ObjectMeta{GenerateName: "u-"},
+		DisplayName:  displayName,
+		PrincipalIDs: []string{principalName},
+	})
+}
+
+type testHandler struct {
+	*Handler
+	users          *store[*v3.User]
+	userAttributes *store[*v3.UserAttribute]
+	tokens         *store[*v3.Token]
+	configMaps     *store[*corev1.ConfigMap]
+	provider       string
+}
+
+func newTestHandler(t *testing.T) *testHandler {
+	ctrl := gomock.NewController(t)
+	th := &testHandler{
+		users:          newStore[*v3.User]("users"),
+		userAttributes: newStore[*v3.UserAttribute]("userattributes"),
+		tokens:         newStore[*v3.Token]("tokens"),
+		configMaps:     newStore[*corev1.ConfigMap]("configmaps"),
+		provider:       "okta",
+	}
+	secrets := newStore[*corev1.Secret]("secrets")
+	secrets.save(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: TokenSecretName, Namespace: namespace.GlobalNamespace},
+		Data:       map[string][]byte{TokenSecretKey: []byte(testToken)},
+	})
+
+	h := &Handler{
+		userManager: &fakeUserManager{users: th.users},
+		provider:    func() string { return th.provider },
+	}
+	h.users, h.userCache = nonNamespacedFakes[*v3.User, *v3.UserList](ctrl, th.users)
+	h.userAttributes, h.userAttributeCache = nonNamespacedFakes[*v3.UserAttribute, *v3.UserAttributeList](ctrl, th.userAttributes)
+	h.tokens, h.tokenCache = nonNamespacedFakes[*v3.Token, *v3.TokenList](ctrl, th.tokens)
+	h.configMaps, h.configMapCache = namespacedFakes[*corev1.ConfigMap, *corev1.ConfigMapList](ctrl, th.configMaps)
+	_, h.secretCache = namespacedFakes[*corev1.Secret, *corev1.SecretList](ctrl, secrets)
+	th.Handler = newHandler(h)
+	return th
+}
+
+// do serves the request with the test token and decodes the response into out, if not nil.
+func (th *testHandler) do(t *testing.T, method, path, body string, out any) int {
+	req := httptest.NewRequest(method, Endpoint+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	th.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), out), rec.Body.String())
+	}
+	return rec.Code
+}
+
+func (th *testHandler) groupPrincipals(userID string) []string {
+	var names []string
+	if attribs, ok := th.userAttributes.objects["/"+userID]; ok {
+		for _, principal := range attribs.GroupPrincipals[tokens.SCIMGroupPrincipalsKey(th.provider)].Items {
+			names = append(names, principal.Name)
+		}
+	}
+	return names
+}
+
+func TestAuthentication(t *testing.T) {
+	th := newTestHandler(t)
+
+	tests := []struct {
+		name          string
+		provider      string
+		authorization string
+		expected      int
+	}{
+		{
+			name:          "valid token",
+			provider:      "okta",
+			authorization: "Bearer " + testToken,
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "scheme is case insensitive",
+			provider:      "okta",
+			authorization: "bearer " + testToken,
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "invalid token",
+			provider:      "okta",
+			authorization: "Bearer " + testToken + "x",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:     "no token",
+			provider: "okta",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:          "disabled",
+			authorization: "Bearer " + testToken,
+			expected:      http.StatusNotFound,
+		},
+		{
+			name:          "unsupported provider",
+			provider:      "openldap",
+			authorization: "Bearer " + testToken,
+			expected:      http.StatusNotImplemented,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			th.provider = test.provider
+			req := httptest.NewRequest(http.MethodGet, Endpoint+"/ServiceProviderConfig", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rec := httptest.NewRecorder()
+			th.ServeHTTP(rec, req)
+			assert.Equal(t, test.expected, rec.Code)
+			assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestUserProvisioning(t *testing.T) {
+	th := newTestHandler(t)
+
+	var created User
+	code := th.do(t, http.MethodPost, "/Users", `{"schemas":["`+userSchema+`"],"userName":"jdoe@example.com","externalId":"00u1","name":{"givenName":"John","familyName":"Doe"},"active":true}`, &created)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "jdoe@example.com", created.UserName)
+	assert.Equal(t, "00u1", created.ExternalID)
+	assert.Equal(t, "John Doe", created.DisplayName)
+	user := th.users.objects["/"+created.ID]
+	require.NotNil(t, user)
+	assert.Equal(t, []string{"okta_user://jdoe@example.com"}, user.PrincipalIDs)
+
+	code = th.do(t, http.MethodPost, "/Users", `{"userName":"jdoe@example.com","externalId":"00u1"}`, nil)
+	assert.Equal(t, http.StatusConflict, code)
+
+	var list listResponse
+	code = th.do(t, http.MethodGet, `/Users?filter=userName+eq+%22JDOE@example.com%22`, "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, list.TotalResults)
+	code = th.do(t, http.MethodGet, `/Users?filter=userName+eq+%22other%22`, "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, list.TotalResults)
+
+	for i := 0; i < 2; i++ {
+		th.tokens.save(&v3.Token{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("token-%d", i), Labels: map[string]string{tokens.UserIDLabel: created.ID}},
+			UserID:     created.ID,
+		})
+	}
+	th.tokens.save(&v3.Token{
+		ObjectMeta: metav1.ObjectMeta{Name: "token-other", Labels: map[string]string{tokens.UserIDLabel: "u-other"}},
+		UserID:     "u-other",
+	})
+
+	// deactivation disables the user and invalidates its tokens
+	var updated User
+	code = th.do(t, http.MethodPatch, "/Users/"+created.ID, `{"schemas":["`+patchSchema+`"],"Operations":[{"op":"Replace","path":"active","value":"False"}]}`, &updated)
+	require.Equal(t, http.StatusOK, code)
+	require.NotNil(t, updated.Active)
+	assert.False(t, *updated.Active)
+	assert.False(t, *th.users.objects["/"+created.ID].Enabled)
+	assert.Len(t, th.tokens.objects, 1)
+
+	code = th.do(t, http.MethodPatch, "/Users/"+created.ID, `{"Operations":[{"op":"replace","value":{"active":true,"displayName":"Johnny"}}]}`, &updated)
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, *updated.Active)
+	assert.Equal(t, "Johnny", updated.DisplayName)
+	assert.Equal(t, "jdoe@example.com", updated.UserName)
+
+	code = th.do(t, http.MethodDelete, "/Users/"+created.ID, "", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Empty(t, th.users.objects)
+	code = th.do(t, http.MethodGet, "/Users/"+created.ID, "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestUserProvisioningAdoptsExistingUser(t *testing.T) {
+	th := newTestHandler(t)
+	existing := th.users.save(&v3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-existing"},
+		PrincipalIDs: []string{"okta_user://jdoe"},
+	})
+	th.users.save(&v3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-local"},
+		PrincipalIDs: []string{"local://u-local"},
+	})
+
+	var list listResponse
+	code := th.do(t, http.MethodGet, "/Users", "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, list.TotalResults, "only provisioned users are listed")
+	code = th.do(t, http.MethodGet, "/Users/"+existing.Name, "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	var created User
+	code = th.do(t, http.MethodPost, "/Users", `{"userName":"jdoe"}`, &created)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, existing.Name, created.ID)
+	code = th.do(t, http.MethodGet, "/Users", "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, list.TotalResults)
+
+	code = th.do(t, http.MethodGet, "/Users/u-local", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestLocalUserIsOnlyUnlinked(t *testing.T) {
+	th := newTestHandler(t)
+	th.users.save(&v3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-admin"},
+		Username:     "admin",
+		PrincipalIDs: []string{"local://u-admin", "okta_user://admin"},
+	})
+	for _, provider := range []string{"local", "okta"} {
+		th.tokens.save(&v3.Token{
+			ObjectMeta:   metav1.ObjectMeta{Name: "token-" + provider, Labels: map[string]string{tokens.UserIDLabel: "u-admin"}},
+			UserID:       "u-admin",
+			AuthProvider: provider,
+		})
+	}
+
+	var created User
+	code := th.do(t, http.MethodPost, "/Users", `{"userName":"admin"}`, &created)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "u-admin", created.ID)
+
+	code = th.do(t, http.MethodPatch, "/Users/u-admin", `{"Operations":[{"op":"replace","path":"active","value":false}]}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Nil(t, th.users.objects["/u-admin"].Enabled)
+	assert.Len(t, th.tokens.objects, 2)
+
+	code = th.do(t, http.MethodDelete, "/Users/u-admin", "", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	user := th.users.objects["/u-admin"]
+	require.NotNil(t, user, "local users aren't deleted")
+	assert.Equal(t, []string{"local://u-admin"}, user.PrincipalIDs)
+	assert.NotContains(t, user.Annotations, userNameAnnotation)
+	assert.Contains(t, th.tokens.objects, "/token-local")
+	assert.NotContains(t, th.tokens.objects, "/token-okta")
+
+	code = th.do(t, http.MethodGet, "/Users/u-admin", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGroupProvisioning(t *testing.T) {
+	th := newTestHandler(t)
+	var alice, bob User
+	require.Equal(t, http.StatusCreated, th.do(t, http.MethodPost, "/Users", `{"userName":"alice"}`, &alice))
+	require.Equal(t, http.StatusCreated, th.do(t, http.MethodPost, "/Users", `{"userName":"bob"}`, &bob))
+
+	var group Group
+	code := th.do(t, http.MethodPost, "/Groups", `{"displayName":"Engineering","members":[{"value":"`+alice.ID+`"}]}`, &group)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, []Member{{Value: alice.ID}}, group.Members)
+	assert.Equal(t, []string{"okta_group://Engineering"}, th.groupPrincipals(alice.ID))
+	assert.NotContains(t, th.userAttributes.objects["/"+alice.ID].GroupPrincipals, "okta", "the groups of the auth provider are replaced at login")
+
+	code = th.do(t, http.MethodPost, "/Groups", `{"displayName":"Engineering"}`, nil)
+	assert.Equal(t, http.StatusConflict, code)
+	code = th.do(t, http.MethodPost, "/Groups", `{"displayName":"Unknown","members":[{"value":"u-unknown"}]}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code = th.do(t, http.MethodPatch, "/Groups/"+group.ID, `{"Operations":[`+
+		`{"op":"add","path":"members","value":[{"value":"`+bob.ID+`"}]},`+
+		`{"op":"remove","path":"members[value eq \"`+alice.ID+`\"]"}]}`, &group)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []Member{{Value: bob.ID}}, group.Members)
+	assert.Empty(t, th.groupPrincipals(alice.ID))
+	assert.Equal(t, []string{"okta_group://Engineering"}, th.groupPrincipals(bob.ID))
+
+	code = th.do(t, http.MethodGet, "/Groups/"+group.ID, "", &group)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []Member{{Value: bob.ID}}, group.Members)
+
+	code = th.do(t, http.MethodPut, "/Groups/"+group.ID, `{"displayName":"Platform","members":[{"value":"`+alice.ID+`"}]}`, &group)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Platform", group.DisplayName)
+	assert.Empty(t, th.groupPrincipals(bob.ID))
+	assert.Equal(t, []string{"okta_group://Engineering"}, th.groupPrincipals(alice.ID), "the principal of a group doesn't change")
+	assert.Equal(t, "Platform", th.userAttributes.objects["/"+alice.ID].GroupPrincipals["okta-scim"].Items[0].DisplayName)
+
+	var list listResponse
+	code = th.do(t, http.MethodGet, `/Groups?filter=displayName+eq+%22Platform%22&excludedAttributes=members`, "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, list.TotalResults)
+
+	code = th.do(t, http.MethodDelete, "/Groups/"+group.ID, "", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Empty(t, th.groupPrincipals(alice.ID))
+	assert.Empty(t, th.configMaps.objects)
+}
+
+func TestListPagination(t *testing.T) {
+	th := newTestHandler(t)
+	for i := 0; i < 5; i++ {
+		th.users.save(&v3.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("u-%d", i),
+				Annotations: map[string]string{userNameAnnotation: fmt.Sprintf("user%d", i)},
+			},
+			PrincipalIDs: []string{fmt.Sprintf("okta_user://user%d", i)},
+		})
+	}
+
+	var list listResponse
+	code := th.do(t, http.MethodGet, "/Users?startIndex=2&count=2", "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, list.TotalResults)
+	assert.Equal(t, 2, list.StartIndex)
+	assert.Equal(t, 2, list.ItemsPerPage)
+	require.Len(t, list.Resources, 2)
+	assert.Equal(t, "u-1", list.Resources[0].(map[string]any)["id"])
+
+	code = th.do(t, http.MethodGet, "/Users?startIndex=10", "", &list)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, list.ItemsPerPage)
+}
+
+func TestPrincipalIDs(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      string
+		user          User
+		group         Group
+		expectedUser  string
+		expectedGroup string
+		expectedErr   string
+	}{
+		{
+			name:          "saml providers use the user name and group name",
+			provider:      "okta",
+			user:          User{UserName: "jdoe@example.com", ExternalID: "00u1"},
+			group:         Group{DisplayName: "Engineering", ExternalID: "00g1"},
+			expectedUser:  "okta_user://jdoe@example.com",
+			expectedGroup: "okta_group://Engineering",
+		},
+		{
+			name:          "oidc uses the subject and group name",
+			provider:      "oidc",
+			user:          User{UserName: "jdoe", ExternalID: "sub-1"},
+			group:         Group{DisplayName: "Engineering", ExternalID: "g-1"},
+			expectedUser:  "oidc_user://sub-1",
+			expectedGroup: "oidc_group://Engineering",
+		},
+		{
+			name:          "azure ad uses object IDs",
+			provider:      "azuread",
+			user:          User{UserName: "jdoe", ExternalID: "8f1c"},
+			group:         Group{DisplayName: "Engineering", ExternalID: "2b7e"},
+			expectedUser:  "azuread_user://8f1c",
+			expectedGroup: "azuread_group://2b7e",
+		},
+		{
+			name:        "object IDs are required",
+			provider:    "azuread",
+			user:        User{UserName: "jdoe"},
+			group:       Group{DisplayName: "Engineering"},
+			expectedErr: "externalId is required by auth provider azuread",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userID, err := userPrincipalID(test.provider, test.user)
+			if test.expectedErr != "" {
+				assert.ErrorContains(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedUser, userID)
+			}
+			groupID, err := groupPrincipalID(test.provider, test.group)
+			if test.expectedErr != "" {
+				assert.ErrorContains(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedGroup, groupID)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package scim
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	userNameAnnotation   = "authn.management.cattle.io/scim-username"
+	externalIDAnnotation = "authn.management.cattle.io/scim-external-id"
+)
+
+// User is the SCIM representation of a user. Its id is the name of the v3.User.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Name is the name of a user, which is only used as its display name when it has none.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// userPrincipalPrefix returns the prefix of the principal IDs of the users of the auth provider.
+func userPrincipalPrefix(provider string) string {
+	return provider + "_user://"
+}
+
+// toUser returns the SCIM representation of the user, and false if the user wasn't provisioned by SCIM or has no
+// principal of the auth provider. Only provisioned users are managed through SCIM, other users are adopted when their
+// identity provider provisions them.
+func toUser(user *v3.User, provider string) (User, bool) {
+	userName, ok := user.Annotations[userNameAnnotation]
+	if !ok || !hasPrincipal(user, userPrincipalPrefix(provider)) {
+		return User{}, false
+	}
+	active := user.Enabled == nil || *user.Enabled
+	return User{
+		Schemas:     []string{userSchema},
+		ID:          user.Name,
+		ExternalID:  user.Annotations[externalIDAnnotation],
+		UserName:    userName,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      user.CreationTimestamp.UTC().Format(time.RFC3339),
+			Location:     Endpoint + "/Users/" + user.Name,
+		},
+	}, true
+}
+
+// hasPrincipal returns true if the user has a principal whose ID starts with prefix.
+func hasPrincipal(user *v3.User, prefix string) bool {
+	for _, id := range user.PrincipalIDs {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocal returns true if the user can log in to the local auth provider, like the admin who enabled the auth
+// provider. Such users can't be disabled or deleted through SCIM, so that the identity provider can't lock them out.
+func isLocal(user *v3.User) bool {
+	return hasPrincipal(user, "local://")
+}
+
+// displayName returns the display name of the user, falling back to its name and then its userName.
+func (u *User) displayName() string {
+	switch {
+	case u.DisplayName != "":
+		return u.DisplayName
+	case u.Name != nil && u.Name.Formatted != "":
+		return u.Name.Formatted
+	case u.Name != nil && (u.Name.GivenName != "" || u.Name.FamilyName != ""):
+		return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+	return u.UserName
+}
+
+func (h *Handler) listUsers(rw http.ResponseWriter, req *http.Request) {
+	params, err := parseListParams(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	users, err := h.userCache.List(labels.Everything())
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	provider := h.provider()
+	var resources []any
+	for _, user := range users {
+		scimUser, ok := toUser(user, provider)
+		if !ok {
+			continue
+		}
+		if params.filter.matches(map[string]string{
+			"id":          scimUser.ID,
+			"username":    scimUser.UserName,
+			"externalid":  scimUser.ExternalID,
+			"displayname": scimUser.DisplayName,
+		}) {
+			resources = append(resources, scimUser)
+		}
+	}
+	writeResponse(rw, http.StatusOK, params.page(resources))
+}
+
+func (h *Handler) getUser(rw http.ResponseWriter, req *http.Request) {
+	user, err := h.getProviderUser(mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	scimUser, _ := toUser(user, h.provider())
+	writeResponse(rw, http.StatusOK, scimUser)
+}
+
+// createUser provisions a user the same way as its first login would, so that it keeps the same v3.User when it logs
+// in. Users who already logged in are adopted, as their identity provider doesn't know about them yet.
+func (h *Handler) createUser(rw http.ResponseWriter, req *http.Request) {
+	var scimUser User
+	if err := decodeBody(req, &scimUser); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if scimUser.UserName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "userName is required"))
+		return
+	}
+
+	principalID, err := userPrincipalID(h.provider(), scimUser)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	user, err := h.userManager.EnsureUser(principalID, scimUser.displayName())
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if _, ok := user.Annotations[userNameAnnotation]; ok {
+		writeError(rw, newError(http.StatusConflict, "uniqueness", "user "+scimUser.UserName+" already exists"))
+		return
+	}
+	logrus.Infof("[%s] Provisioning user %s for principal %s", logPrefix, user.Name, principalID)
+
+	user, err = h.saveUser(user.Name, &scimUser)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	created, _ := toUser(user, h.provider())
+	writeResponse(rw, http.StatusCreated, created)
+}
+
+func (h *Handler) replaceUser(rw http.ResponseWriter, req *http.Request) {
+	var scimUser User
+	if err := decodeBody(req, &scimUser); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if scimUser.UserName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "userName is required"))
+		return
+	}
+	h.updateUser(rw, mux.Vars(req)["id"], &scimUser)
+}
+
+func (h *Handler) patchUser(rw http.ResponseWriter, req *http.Request) {
+	var patch patchRequest
+	if err := decodeBody(req, &patch); err != nil {
+		writeError(rw, err)
+		return
+	}
+	operations, err := patch.operations()
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	user, err := h.getProviderUser(mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	scimUser, _ := toUser(user, h.provider())
+	if err := applyUserPatch(&scimUser, operations); err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.updateUser(rw, user.Name, &scimUser)
+}
+
+func (h *Handler) updateUser(rw http.ResponseWriter, id string, scimUser *User) {
+	if _, err := h.getProviderUser(id); err != nil {
+		writeError(rw, err)
+		return
+	}
+	user, err := h.saveUser(id, scimUser)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	updated, _ := toUser(user, h.provider())
+	writeResponse(rw, http.StatusOK, updated)
+}
+
+// deleteUser deletes the user. Its tokens are deleted first so that they can't be used while the user is removed.
+// Local users are only unlinked from the auth provider.
+func (h *Handler) deleteUser(rw http.ResponseWriter, req *http.Request) {
+	user, err := h.getProviderUser(mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if isLocal(user) {
+		if err := h.unlinkUser(user.Name); err != nil {
+			writeError(rw, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	logrus.Infof("[%s] Deprovisioning user %s", logPrefix, user.Name)
+	if err := h.deleteTokens(user.Name, ""); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.users.Delete(user.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		writeError(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// unlinkUser removes the principal of the auth provider and the SCIM attributes from the local user, and deletes the
+// tokens the user got by logging in to the auth provider.
+func (h *Handler) unlinkUser(id string) error {
+	provider := h.provider()
+	logrus.Infof("[%s] Unlinking local user %s from auth provider %s", logPrefix, id, provider)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		user, err := h.users.Get(id, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		user = user.DeepCopy()
+		var principalIDs []string
+		for _, principalID := range user.PrincipalIDs {
+			if !strings.HasPrefix(principalID, userPrincipalPrefix(provider)) {
+				principalIDs = append(principalIDs, principalID)
+			}
+		}
+		user.PrincipalIDs = principalIDs
+		delete(user.Annotations, userNameAnnotation)
+		delete(user.Annotations, externalIDAnnotation)
+		_, err = h.users.Update(user)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return h.deleteTokens(id, provider)
+}
+
+// getProviderUser returns the user with the id, or a not found error if it doesn't exist, wasn't provisioned by SCIM
+// or has no principal of the auth provider.
+func (h *Handler) getProviderUser(id string) (*v3.User, error) {
+	user, err := h.users.Get(id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, newError(http.StatusNotFound, "", "user "+id+" not found")
+	} else if err != nil {
+		return nil, err
+	}
+	if _, ok := toUser(user, h.provider()); !ok {
+		return nil, newError(http.StatusNotFound, "", "user "+id+" not found")
+	}
+	return user, nil
+}
+
+// saveUser stores the attributes of the SCIM user in the user. Users who become inactive are disabled and their
+// tokens are deleted, as disabled users can't use them anyway and may be deleted next. Local users can't be disabled.
+func (h *Handler) saveUser(id string, scimUser *User) (*v3.User, error) {
+	var user *v3.User
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		user, err = h.users.Get(id, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if scimUser.Active != nil && !*scimUser.Active && isLocal(user) {
+			return newError(http.StatusBadRequest, "mutability", "user "+id+" is a local user and can't be deactivated")
+		}
+		user = user.DeepCopy()
+		if user.Annotations == nil {
+			user.Annotations = map[string]string{}
+		}
+		user.Annotations[userNameAnnotation] = scimUser.UserName
+		if scimUser.ExternalID != "" {
+			user.Annotations[externalIDAnnotation] = scimUser.ExternalID
+		} else {
+			delete(user.Annotations, externalIDAnnotation)
+		}
+		user.DisplayName = scimUser.displayName()
+		if scimUser.Active != nil {
+			user.Enabled = scimUser.Active
+		}
+		user, err = h.users.Update(user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if user.Enabled != nil && !*user.Enabled {
+		if err := h.deleteTokens(user.Name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// deleteTokens deletes the tokens of the user, or only those of the auth provider if provider isn't "", so that its
+// sessions and API keys are invalidated right away.
+func (h *Handler) deleteTokens(userName, provider string) error {
+	userTokens, err := h.tokenCache.List(labels.SelectorFromSet(labels.Set{tokens.UserIDLabel: userName}))
+	if err != nil {
+		return err
+	}
+	var deleted int
+	for _, token := range userTokens {
+		if provider != "" && token.AuthProvider != provider {
+			continue
+		}
+		if err := h.tokens.Delete(token.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		logrus.Infof("[%s] Deleted %d tokens of user %s", logPrefix, deleted, userName)
+	}
+	return nil
+}
@@ -4,7 +4,9 @@
 	AuthUserInfoResyncCron    = newSetting("0 0 * * *")
 	AuthUserSessionTTLMinutes = newSetting("960")  // 16 hours
 	AuthUserInfoMaxAgeSeconds = newSetting("3600") // 1 hour
+	AuthMFARequiredForAdmins  = newSetting("false")
 	FirstLogin                = newSetting("true")
+	TokenHashAlgorithm        = newSetting("sha3")
 )
 
 type Setting interface {
@@ -0,0 +1,89 @@
+package hashers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2idHashFormat = "$%d:%d:%d:%d:%s:%s" // $version:time:memory:threads:salt:hash -> $4:2:19456:1:abc:def
+	// argon2id cost parameters for new hashes, following the OWASP recommendation of 19 MiB of memory and 2 iterations
+	argon2idTime    = 2
+	argon2idMemory  = 19 * 1024
+	argon2idThreads = 1
+	argon2idKeyLen  = 32
+)
+
+// Argon2idHasher implements the Hasher interface using a backing algorithm of Argon2id.
+type Argon2idHasher struct{}
+
+// CreateHash hashes secretKey using a random salt and Argon2id. The cost parameters are stored in the hash so
+// that they can be raised for new hashes without invalidating existing ones.
+func (a Argon2idHasher) CreateHash(secretKey string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to read random values for salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(secretKey), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+	encSalt := base64.RawStdEncoding.EncodeToString(salt)
+	encKey := base64.RawStdEncoding.EncodeToString(key)
+	return fmt.Sprintf(argon2idHashFormat, Argon2idVersion, argon2idTime, argon2idMemory, argon2idThreads, encSalt, encKey), nil
+}
+
+// VerifyHash compares a key with the hash, and will produce an error if the hash does not match or if the hash is not
+// a valid Argon2id hash.
+func (a Argon2idHasher) VerifyHash(hash, secretKey string) error {
+	if !strings.HasPrefix(hash, "$") {
+		return errors.New("hash format invalid")
+	}
+	splitHash := strings.Split(strings.TrimPrefix(hash, "$"), ":")
+	if len(splitHash) != 6 {
+		return errors.New("hash format invalid")
+	}
+
+	version, err := strconv.Atoi(splitHash[0])
+	if err != nil {
+		return err
+	}
+	if HashVersion(version) != Argon2idVersion {
+		return fmt.Errorf("hash version %d does not match package version %d", version, Argon2idVersion)
+	}
+
+	timeCost, err := strconv.ParseUint(splitHash[1], 10, 32)
+	if err != nil || timeCost == 0 {
+		return errors.New("invalid argon2id time parameter")
+	}
+	memory, err := strconv.ParseUint(splitHash[2], 10, 32)
+	if err != nil || memory == 0 {
+		return errors.New("invalid argon2id memory parameter")
+	}
+	threads, err := strconv.ParseUint(splitHash[3], 10, 8)
+	if err != nil || threads == 0 {
+		return errors.New("invalid argon2id threads parameter")
+	}
+
+	decodedSalt, err := base64.RawStdEncoding.DecodeString(splitHash[4])
+	if err != nil {
+		return err
+	}
+	decodedKey, err := base64.RawStdEncoding.DecodeString(splitHash[5])
+	if err != nil {
+		return err
+	}
+	if len(decodedKey) < 1 {
+		return errors.New("secretKey hash does not match") // Don't allow accidental empty string to succeed
+	}
+
+	key := argon2.IDKey([]byte(secretKey), decodedSalt, uint32(timeCost), uint32(memory), uint8(threads), uint32(len(decodedKey)))
+	if subtle.ConstantTimeCompare(decodedKey, key) == 0 {
+		return errors.New("secretKey hash does not match")
+	}
+	return nil
+}
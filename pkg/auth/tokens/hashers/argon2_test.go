@@ -0,0 +1,98 @@
+package hashers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicArgon2idHash(t *testing.T) {
+	secretKey := "hello world"
+	hasher := Argon2idHasher{}
+	hash, err := hasher.CreateHash(secretKey)
+	require.Nil(t, err)
+	require.NotNil(t, hash)
+	splitHash := strings.Split(hash, ":")
+	require.Len(t, splitHash, 6)
+	require.Equal(t, strconv.Itoa(int(Argon2idVersion)), splitHash[0][1:])
+	require.Equal(t, strconv.Itoa(argon2idTime), splitHash[1])
+	require.Equal(t, strconv.Itoa(argon2idMemory), splitHash[2])
+	require.Equal(t, strconv.Itoa(argon2idThreads), splitHash[3])
+	// Now check it
+	require.Nil(t, hasher.VerifyHash(hash, secretKey))
+	require.NotNil(t, hasher.VerifyHash(hash, "incorrect"))
+}
+
+func TestArgon2idVerifyHash(t *testing.T) {
+	const secretKey = "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	// hash of secretKey with non-default cost parameters, these must be read from the hash
+	const hash = "$4:1:1024:2:c29tZXNhbHQ:uEKWf0bByK6Fh1MbJ0NV7KvXSU0guYu/3ne1WwQkxyI"
+
+	tests := []struct {
+		name      string
+		hash      string
+		secretKey string
+		wantError bool
+	}{
+		{
+			name:      "valid hash",
+			hash:      hash,
+			secretKey: secretKey,
+		},
+		{
+			name:      "invalid secret key",
+			hash:      hash,
+			secretKey: "wrong",
+			wantError: true,
+		},
+		{
+			name:      "changed cost parameters",
+			hash:      "$4:2:1024:2:c29tZXNhbHQ:uEKWf0bByK6Fh1MbJ0NV7KvXSU0guYu/3ne1WwQkxyI",
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "invalid hash format",
+			hash:      "$4:1:1024:2:c29tZXNhbHQ",
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "invalid hash version",
+			hash:      "$3:1:1024:2:c29tZXNhbHQ:uEKWf0bByK6Fh1MbJ0NV7KvXSU0guYu/3ne1WwQkxyI",
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "zero memory",
+			hash:      "$4:1:0:2:c29tZXNhbHQ:uEKWf0bByK6Fh1MbJ0NV7KvXSU0guYu/3ne1WwQkxyI",
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "missing $ prefix",
+			hash:      "4:1:1024:2:c29tZXNhbHQ:uEKWf0bByK6Fh1MbJ0NV7KvXSU0guYu/3ne1WwQkxyI",
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "non base64 character in hash",
+			hash:      "$4:1:1024:2:c29tZXNhbHQ:#EKWf0bByK6Fh1MbJ0NV7KvXSU0guYu/3ne1WwQkxyI",
+			secretKey: secretKey,
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasher := Argon2idHasher{}
+			err := hasher.VerifyHash(test.hash, test.secretKey)
+			if test.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/rancher/rancher/pkg/auth/settings"
 )
 
 type HashVersion int
@@ -13,8 +15,17 @@
 	ScryptVersion HashVersion = iota + 1
 	SHA256Version
 	SHA3Version
+	Argon2idVersion
+	PBKDF2Version
 )
 
+// hashAlgorithms maps the values of the token-hash-algorithm setting to the version of the hasher they select.
+var hashAlgorithms = map[string]HashVersion{
+	"sha3":          SHA3Version,
+	"argon2id":      Argon2idVersion,
+	"pbkdf2-sha512": PBKDF2Version,
+}
+
 // Hasher describes an interface which allows a user to create a hash for a value or verify that a hash is correct.
 type Hasher interface {
 	// CreateHash creates a hash for a secret, returns nil, err if it encounters an error
@@ -29,13 +40,21 @@ func GetHasherForHash(hash string) (Hasher, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to determine version for hash, %w", err)
 	}
-	switch HashVersion(version) {
+	return getHasherForVersion(version)
+}
+
+func getHasherForVersion(version HashVersion) (Hasher, error) {
+	switch version {
 	case ScryptVersion:
 		return ScryptHasher{}, nil
 	case SHA256Version:
 		return Sha256Hasher{}, nil
 	case SHA3Version:
 		return Sha3Hasher{}, nil
+	case Argon2idVersion:
+		return Argon2idHasher{}, nil
+	case PBKDF2Version:
+		return Pbkdf2Hasher{}, nil
 	default:
 		return nil, fmt.Errorf("invalid version %d, no hasher exists for that version", version)
 	}
@@ -43,7 +62,17 @@ func GetHasherForHash(hash string) (Hasher, error) {
 
 // GetHasher produces the hasher which should be used for new tokens, for verifying existing tokens use GetHasherForHash.
 func GetHasher() Hasher {
-	return Sha3Hasher{}
+	hasher, _ := getHasherForVersion(GetHasherVersion())
+	return hasher
+}
+
+// GetHasherVersion returns the version of the hasher selected by the token-hash-algorithm setting for new tokens.
+// Unknown values fall back to SHA3.
+func GetHasherVersion() HashVersion {
+	if version, ok := hashAlgorithms[settings.TokenHashAlgorithm.Get()]; ok {
+		return version
+	}
+	return SHA3Version
 }
 
 // GetHashVersion produces the hash version for a given hash.
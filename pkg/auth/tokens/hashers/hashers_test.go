@@ -3,7 +3,9 @@
 import (
 	"testing"
 
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetHasherForHash(t *testing.T) {
@@ -14,6 +16,10 @@ func TestGetHasherForHash(t *testing.T) {
 	assert.NoError(t, err, "error when creating sha256 hash")
 	sha3Hash, err := Sha3Hasher{}.CreateHash(testSecret)
 	assert.NoError(t, err, "error when creating sha3 hash")
+	argon2idHash, err := Argon2idHasher{}.CreateHash(testSecret)
+	assert.NoError(t, err, "error when creating argon2id hash")
+	pbkdf2Hash, err := Pbkdf2Hasher{}.CreateHash(testSecret)
+	assert.NoError(t, err, "error when creating pbkdf2 hash")
 
 	tests := []struct {
 		name       string
@@ -39,6 +45,18 @@ func TestGetHasherForHash(t *testing.T) {
 			wantHasher: Sha3Hasher{},
 			wantErr:    false,
 		},
+		{
+			name:       "argon2id hash",
+			hash:       argon2idHash,
+			wantHasher: Argon2idHasher{},
+			wantErr:    false,
+		},
+		{
+			name:       "pbkdf2 hash",
+			hash:       pbkdf2Hash,
+			wantHasher: Pbkdf2Hasher{},
+			wantErr:    false,
+		},
 		{
 			name:       "invalid hash",
 			hash:       "thisisnotahash",
@@ -47,7 +65,7 @@ func TestGetHasherForHash(t *testing.T) {
 		},
 		{
 			name:       "invalid hash version",
-			hash:       "$4:some-salt-here:some-secret-here",
+			hash:       "$6:some-salt-here:some-secret-here",
 			wantHasher: nil,
 			wantErr:    true,
 		},
@@ -68,8 +86,24 @@ func TestGetHasherForHash(t *testing.T) {
 }
 
 func TestGetHasher(t *testing.T) {
-	t.Parallel()
 	assert.IsTypef(t, Sha3Hasher{}, GetHasher(), "expected SHA3 to be the default hasher")
+
+	tests := []struct {
+		algorithm   string
+		wantHasher  Hasher
+		wantVersion HashVersion
+	}{
+		{algorithm: "argon2id", wantHasher: Argon2idHasher{}, wantVersion: Argon2idVersion},
+		{algorithm: "pbkdf2-sha512", wantHasher: Pbkdf2Hasher{}, wantVersion: PBKDF2Version},
+		{algorithm: "sha3", wantHasher: Sha3Hasher{}, wantVersion: SHA3Version},
+		{algorithm: "unknown", wantHasher: Sha3Hasher{}, wantVersion: SHA3Version},
+	}
+	defer settings.TokenHashAlgorithm.Set(settings.TokenHashAlgorithm.Default)
+	for _, test := range tests {
+		require.NoError(t, settings.TokenHashAlgorithm.Set(test.algorithm))
+		assert.IsTypef(t, test.wantHasher, GetHasher(), "unexpected hasher for algorithm %s", test.algorithm)
+		assert.Equal(t, test.wantVersion, GetHasherVersion())
+	}
 }
 
 func TestGetHashVersion(t *testing.T) {
@@ -0,0 +1,82 @@
+package hashers
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	pbkdf2HashFormat = "$%d:%d:%s:%s" // $version:iterations:salt:hash -> $5:210000:abc:def
+	// pbkdf2Iterations is the cost of new hashes, following the OWASP recommendation for PBKDF2-HMAC-SHA512
+	pbkdf2Iterations = 210000
+	pbkdf2KeyLen     = sha512.Size
+	pbkdf2SaltLength = 16
+)
+
+// Pbkdf2Hasher implements the Hasher interface using a backing algorithm of PBKDF2 with HMAC-SHA512, which is
+// acceptable for FIPS 140 validated deployments.
+type Pbkdf2Hasher struct{}
+
+// CreateHash hashes secretKey using a random salt and PBKDF2-SHA512. The iteration count is stored in the hash so
+// that it can be raised for new hashes without invalidating existing ones.
+func (p Pbkdf2Hasher) CreateHash(secretKey string) (string, error) {
+	salt := make([]byte, pbkdf2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to read random values for salt: %w", err)
+	}
+	key := pbkdf2.Key([]byte(secretKey), salt, pbkdf2Iterations, pbkdf2KeyLen, sha512.New)
+	encSalt := base64.RawStdEncoding.EncodeToString(salt)
+	encKey := base64.RawStdEncoding.EncodeToString(key)
+	return fmt.Sprintf(pbkdf2HashFormat, PBKDF2Version, pbkdf2Iterations, encSalt, encKey), nil
+}
+
+// VerifyHash compares a key with the hash, and will produce an error if the hash does not match or if the hash is not
+// a valid PBKDF2 hash.
+func (p Pbkdf2Hasher) VerifyHash(hash, secretKey string) error {
+	if !strings.HasPrefix(hash, "$") {
+		return errors.New("hash format invalid")
+	}
+	splitHash := strings.Split(strings.TrimPrefix(hash, "$"), ":")
+	if len(splitHash) != 4 {
+		return errors.New("hash format invalid")
+	}
+
+	version, err := strconv.Atoi(splitHash[0])
+	if err != nil {
+		return err
+	}
+	if HashVersion(version) != PBKDF2Version {
+		return fmt.Errorf("hash version %d does not match package version %d", version, PBKDF2Version)
+	}
+
+	iterations, err := strconv.Atoi(splitHash[1])
+	if err != nil || iterations < 1 {
+		return errors.New("invalid pbkdf2 iterations parameter")
+	}
+
+	decodedSalt, err := base64.RawStdEncoding.DecodeString(splitHash[2])
+	if err != nil {
+		return err
+	}
+	decodedKey, err := base64.RawStdEncoding.DecodeString(splitHash[3])
+	if err != nil {
+		return err
+	}
+	if len(decodedKey) < 1 {
+		return errors.New("secretKey hash does not match") // Don't allow accidental empty string to succeed
+	}
+
+	key := pbkdf2.Key([]byte(secretKey), decodedSalt, iterations, len(decodedKey), sha512.New)
+	if subtle.ConstantTimeCompare(decodedKey, key) == 0 {
+		return errors.New("secretKey hash does not match")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package hashers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicPbkdf2Hash(t *testing.T) {
+	secretKey := "hello world"
+	hasher := Pbkdf2Hasher{}
+	hash, err := hasher.CreateHash(secretKey)
+	require.Nil(t, err)
+	require.NotNil(t, hash)
+	splitHash := strings.Split(hash, ":")
+	require.Len(t, splitHash, 4)
+	require.Equal(t, strconv.Itoa(int(PBKDF2Version)), splitHash[0][1:])
+	require.Equal(t, strconv.Itoa(pbkdf2Iterations), splitHash[1])
+	// Now check it
+	require.Nil(t, hasher.VerifyHash(hash, secretKey))
+	require.NotNil(t, hasher.VerifyHash(hash, "incorrect"))
+}
+
+func TestPbkdf2VerifyHash(t *testing.T) {
+	const secretKey = "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	// hash of secretKey with 1000 iterations, the iteration count must be read from the hash
+	const hash = "$5:1000:c29tZXNhbHQ:iT+n0D13gnLZvn0yt7Rkmp9rB9E3VxCPrNOCsmn0q+cy7lnLJOQPs5lQMPRzaZkyHAT8kHPOqSCiUqI6edK0xw"
+
+	tests := []struct {
+		name      string
+		hash      string
+		secretKey string
+		wantError bool
+	}{
+		{
+			name:      "valid hash",
+			hash:      hash,
+			secretKey: secretKey,
+		},
+		{
+			name:      "invalid secret key",
+			hash:      hash,
+			secretKey: "wrong",
+			wantError: true,
+		},
+		{
+			name:      "changed iterations",
+			hash:      strings.Replace(hash, ":1000:", ":1001:", 1),
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "invalid iterations",
+			hash:      strings.Replace(hash, ":1000:", ":0:", 1),
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "invalid hash format",
+			hash:      "$5:1000:c29tZXNhbHQ",
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "invalid hash version",
+			hash:      strings.Replace(hash, "$5:", "$3:", 1),
+			secretKey: secretKey,
+			wantError: true,
+		},
+		{
+			name:      "missing $ prefix",
+			hash:      strings.TrimPrefix(hash, "$"),
+			secretKey: secretKey,
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasher := Pbkdf2Hasher{}
+			err := hasher.VerifyHash(test.hash, test.secretKey)
+			if test.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@
 	"net/http"
 	"reflect"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/rancher/norman/httperror"
@@ -68,6 +69,7 @@ func NewManager(ctx context.Context, apiContext *config.ScaledContext) *Manager
 		userLister:          apiContext.Management.Users("").Controller().Lister(),
 		secrets:             apiContext.Core.Secrets(""),
 		secretLister:        apiContext.Core.Secrets("").Controller().Lister(),
+		settingLister:       apiContext.Management.Settings("").Controller().Lister(),
 	}
 }
 
@@ -81,6 +83,7 @@ type Manager struct {
 	userLister          v3.UserLister
 	secrets             v1.SecretInterface
 	secretLister        v1.SecretLister
+	settingLister       v3.SettingLister
 }
 
 func userPrincipalIndexer(obj interface{}) ([]string, error) {
@@ -100,12 +103,20 @@ func (m *Manager) createDerivedToken(jsonInput clientv3.Token, tokenAuthValue st
 	if err != nil {
 		return v3.Token{}, "", 401, err
 	}
+	if IsMFAEnrollmentToken(token) {
+		return v3.Token{}, "", 403, errors.New("tokens can't be created with a token which can only be used to enroll a TOTP authenticator")
+	}
 
 	tokenTTL, err := ClampToMaxTTL(time.Duration(int64(jsonInput.TTLMillis)) * time.Millisecond)
 	if err != nil {
 		return v3.Token{}, "", 500, fmt.Errorf("error validating max-ttl %v", err)
 	}
 
+	scope, err := derivedTokenScope(token.Scope, jsonInput)
+	if err != nil {
+		return v3.Token{}, "", 403, err
+	}
+
 	var unhashedTokenKey string
 	derivedToken := v3.Token{
 		UserPrincipal: token.UserPrincipal,
@@ -116,6 +127,7 @@ func (m *Manager) createDerivedToken(jsonInput clientv3.Token, tokenAuthValue st
 		ProviderInfo:  token.ProviderInfo,
 		Description:   jsonInput.Description,
 		ClusterName:   jsonInput.ClusterID,
+		Scope:         scope,
 	}
 	derivedToken, unhashedTokenKey, err = m.createToken(&derivedToken)
 
@@ -334,8 +346,36 @@ func (m *Manager) listTokens(request *types.APIContext) error {
 		return err
 	}
 
+	// idleDays only lists the tokens which haven't been used for at least that many days
+	var (
+		idleTimeout time.Duration
+		idleSince   time.Time
+		now         = time.Now()
+	)
+	if idleDays := r.URL.Query().Get("idleDays"); idleDays != "" {
+		days, err := strconv.Atoi(idleDays)
+		if err != nil || days < 0 {
+			return httperror.NewAPIError(httperror.InvalidOption, fmt.Sprintf("invalid idleDays %q", idleDays))
+		}
+		idleTimeout = time.Duration(days) * 24 * time.Hour
+
+		// tokens are idle the way the idle timeout handles them: from when it was enabled at the earliest, and no token
+		// is idle while it isn't enabled
+		setting, err := m.settingLister.Get("", settings.TokenIdleTimeoutDays.Name)
+		if err != nil {
+			return err
+		}
+		var enabled bool
+		if idleSince, enabled = settings.EnabledAt(setting); !enabled {
+			idleSince = now
+		}
+	}
+
 	tokensFromStore := make([]map[string]interface{}, len(tokens))
 	for _, token := range tokens {
+		if idleTimeout > 0 && (IdleTimeoutExempt(&token) || !IsIdle(&token, idleTimeout, idleSince, now)) {
+			continue
+		}
 		token.Current = currentAuthToken.Name == token.Name && !currentAuthToken.IsDerived
 		tokenData, err := ConvertTokenResource(request.Schema, token)
 		if err != nil {
@@ -572,6 +612,13 @@ func (m *Manager) EnsureAndGetUserAttribute(userID string) (*v3.UserAttribute, b
 	return attribs, true, nil
 }
 
+// SCIMGroupPrincipalsKey returns the key of the group principals of a UserAttribute which are the groups of the auth
+// provider the user was made a member of by SCIM provisioning. They are kept apart from the group principals of the
+// auth provider, which are replaced when the user logs in and when its groups are refreshed.
+func SCIMGroupPrincipalsKey(provider string) string {
+	return provider + "-scim"
+}
+
 func (m *Manager) UserAttributeCreateOrUpdate(userID, provider string, groupPrincipals []v3.Principal, userExtraInfo map[string][]string) error {
 	attribs, needCreate, err := m.EnsureAndGetUserAttribute(userID)
 	if err != nil {
@@ -710,6 +757,7 @@ func (m *Manager) GetGroupsForTokenAuthProvider(token *v3.Token) []v3.Principal
 				}
 			}
 		}
+		groups = append(groups, attribs.GroupPrincipals[SCIMGroupPrincipalsKey(token.AuthProvider)].Items...)
 	}
 
 	// fallback to legacy token groupPrincipals
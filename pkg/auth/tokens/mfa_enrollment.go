@@ -0,0 +1,61 @@
+package tokens
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// MFAEnrollmentTokenKind is the kind of the tokens given to admins who must enroll a TOTP authenticator before they
+	// can log in, which can only be used to enroll it.
+	MFAEnrollmentTokenKind = "mfa-enrollment"
+	// mfaEnrollmentTokenTTL is how long an admin has to enroll a TOTP authenticator after logging in with a password.
+	mfaEnrollmentTokenTTL = 10 * time.Minute
+)
+
+// mfaEnrollmentActions are the actions of the users collection that MFA enrollment tokens can be used for.
+var mfaEnrollmentActions = sets.NewString("enrolltotp", "activatetotp")
+
+// IsMFAEnrollmentToken returns true if the token can only be used to enroll a TOTP authenticator.
+func IsMFAEnrollmentToken(token *v3.Token) bool {
+	return token.Labels[TokenKindLabel] == MFAEnrollmentTokenKind
+}
+
+// CheckMFAEnrollment returns an error unless the request enrolls a TOTP authenticator for the current user, or gets the
+// current user, which are the only requests MFA enrollment tokens can be used for.
+func CheckMFAEnrollment(req *http.Request) error {
+	if strings.TrimSuffix(req.URL.Path, "/") == "/v3/users" {
+		query := req.URL.Query()
+		if req.Method == http.MethodPost && mfaEnrollmentActions.Has(query.Get("action")) {
+			return nil
+		}
+		if req.Method == http.MethodGet && query.Get("me") == "true" {
+			return nil
+		}
+	}
+	return errors.New("token can only be used to enroll a TOTP authenticator")
+}
+
+// NewMFAEnrollmentToken creates a short-lived token for the user which can only be used to enroll a TOTP authenticator.
+// Once it is enrolled, the user logs in again with a TOTP code to get a session token.
+func (m *Manager) NewMFAEnrollmentToken(userID string, userPrincipal v3.Principal) (v3.Token, string, error) {
+	token := &v3.Token{
+		UserPrincipal: userPrincipal,
+		TTLMillis:     mfaEnrollmentTokenTTL.Milliseconds(),
+		UserID:        userID,
+		AuthProvider:  userPrincipal.Provider,
+		Description:   "MFA enrollment",
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				TokenKindLabel: MFAEnrollmentTokenKind,
+			},
+		},
+	}
+	return m.createToken(token)
+}
@@ -0,0 +1,75 @@
+package tokens
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckMFAEnrollment(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		allowed bool
+	}{
+		{
+			name:    "enroll",
+			method:  http.MethodPost,
+			target:  "/v3/users?action=enrolltotp",
+			allowed: true,
+		},
+		{
+			name:    "activate",
+			method:  http.MethodPost,
+			target:  "/v3/users/?action=activatetotp",
+			allowed: true,
+		},
+		{
+			name:    "current user",
+			method:  http.MethodGet,
+			target:  "/v3/users?me=true",
+			allowed: true,
+		},
+		{
+			name:   "other user actions",
+			method: http.MethodPost,
+			target: "/v3/users?action=changepassword",
+		},
+		{
+			name:   "list users",
+			method: http.MethodGet,
+			target: "/v3/users",
+		},
+		{
+			name:   "other resources",
+			method: http.MethodGet,
+			target: "/v3/clusters",
+		},
+		{
+			name:   "kubernetes API",
+			method: http.MethodGet,
+			target: "/k8s/clusters/local/api/v1/secrets",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckMFAEnrollment(httptest.NewRequest(tt.method, tt.target, nil))
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestIsMFAEnrollmentToken(t *testing.T) {
+	assert.True(t, IsMFAEnrollmentToken(&v3.Token{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{TokenKindLabel: MFAEnrollmentTokenKind}}}))
+	assert.False(t, IsMFAEnrollmentToken(&v3.Token{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{TokenKindLabel: "session"}}}))
+	assert.False(t, IsMFAEnrollmentToken(&v3.Token{}))
+}
@@ -7,14 +7,21 @@
 	"github.com/rancher/norman/clientbase"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/pointer"
 )
 
-const intervalSeconds int64 = 3600
+const (
+	intervalSeconds int64 = 3600
+
+	IdleActionDisable = "disable"
+	IdleActionDelete  = "delete"
+)
 
 func StartPurgeDaemon(ctx context.Context, mgmt *config.ManagementContext) {
 	p := &purger{
@@ -22,6 +29,7 @@ func StartPurgeDaemon(ctx context.Context, mgmt *config.ManagementContext) {
 		tokens:           mgmt.Management.Tokens(""),
 		samlTokensLister: mgmt.Management.SamlTokens("").Controller().Lister(),
 		samlTokens:       mgmt.Management.SamlTokens(""),
+		settingLister:    mgmt.Management.Settings("").Controller().Lister(),
 	}
 	go wait.JitterUntil(p.purge, time.Duration(intervalSeconds)*time.Second, .1, true, ctx.Done())
 }
@@ -31,6 +39,7 @@ type purger struct {
 	tokens           v3.TokenInterface
 	samlTokens       v3.SamlTokenInterface
 	samlTokensLister v3.SamlTokenLister
+	settingLister    v3.SettingLister
 }
 
 func (p *purger) purge() {
@@ -54,6 +63,8 @@ func (p *purger) purge() {
 		logrus.Infof("Purged %v expired tokens", count)
 	}
 
+	p.purgeIdle(allTokens, time.Now())
+
 	// saml tokens store encrypted token for login request from rancher cli
 	samlTokens, err := p.samlTokensLister.List(namespace.GlobalNamespace, labels.Everything())
 	if err != nil {
@@ -76,3 +87,55 @@ func (p *purger) purge() {
 		logrus.Infof("Purged %v saml tokens", count)
 	}
 }
+
+// purgeIdle disables or deletes the tokens which have been idle for longer than the token idle timeout.
+func (p *purger) purgeIdle(allTokens []*v3.Token, now time.Time) {
+	idleDays := settings.TokenIdleTimeoutDays.GetInt()
+	if idleDays <= 0 {
+		return
+	}
+	idleTimeout := time.Duration(idleDays) * 24 * time.Hour
+	action := settings.TokenIdleAction.Get()
+	if action != IdleActionDisable && action != IdleActionDelete {
+		logrus.Errorf("Invalid value %q for setting %s, must be %s or %s", action, settings.TokenIdleAction.Name, IdleActionDisable, IdleActionDelete)
+		return
+	}
+
+	// tokens are idle from when the timeout was enabled at the earliest, as their use may not have been recorded before
+	setting, err := p.settingLister.Get("", settings.TokenIdleTimeoutDays.Name)
+	if err != nil {
+		logrus.Errorf("Error getting setting %s: %v", settings.TokenIdleTimeoutDays.Name, err)
+		return
+	}
+	enabledAt, ok := settings.EnabledAt(setting)
+	if !ok {
+		// the setting was just enabled and isn't annotated yet
+		return
+	}
+
+	var count int
+	for _, token := range allTokens {
+		if IsExpired(*token) || IdleTimeoutExempt(token) || !IsIdle(token, idleTimeout, enabledAt, now) {
+			continue
+		}
+		var err error
+		if action == IdleActionDelete {
+			err = p.tokens.Delete(token.Name, &metav1.DeleteOptions{})
+		} else {
+			if token.Enabled != nil && !*token.Enabled {
+				continue
+			}
+			token = token.DeepCopy()
+			token.Enabled = pointer.Bool(false)
+			_, err = p.tokens.Update(token)
+		}
+		if err != nil && !clientbase.IsNotFound(err) {
+			logrus.Errorf("Error: while applying idle action %s to token %v: %v", action, token.Name, err)
+			continue
+		}
+		count++
+	}
+	if count > 0 {
+		logrus.Infof("Handled %v tokens idle for more than %v days with action %s", count, idleDays, action)
+	}
+}
@@ -0,0 +1,221 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/util"
+	clientv3 "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const normanAPIGroup = "management.cattle.io"
+
+var (
+	readOnlyMethods = sets.NewString(http.MethodGet, http.MethodHead, http.MethodOptions)
+
+	// connectSubresources run commands in or open connections to pods, nodes and clusters, so read-only tokens can't
+	// use them even though they can be reached with a GET request.
+	connectSubresources = sets.NewString("exec", "attach", "portforward", "proxy", "shell")
+
+	scopeRequestInfoFactory = &request.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("apis", "api"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+)
+
+// scopeRequest is the part of a request that token scopes are checked against.
+type scopeRequest struct {
+	apiGroup    string
+	resource    string
+	subresource string
+	// isResourceRequest is false for requests which aren't for an API resource, like discovery.
+	isResourceRequest bool
+}
+
+// CheckScope returns an error if the token's scope doesn't allow the request. clusterID is the cluster the request is
+// routed to, or "" for requests to the management cluster API, in which case the cluster is found from the request.
+func CheckScope(scope *v32.TokenScope, clusterID string, req *http.Request) error {
+	if scope == nil {
+		return nil
+	}
+
+	if clusterID == "" {
+		clusterID = requestClusterID(req)
+	}
+	if len(scope.Clusters) > 0 && !sets.NewString(scope.Clusters...).Has(clusterID) {
+		return fmt.Errorf("token is not scoped to cluster [%s]", clusterID)
+	}
+
+	info := newScopeRequest(req)
+	if scope.ReadOnly {
+		if !readOnlyMethods.Has(req.Method) {
+			return errors.New("token is read-only")
+		}
+		if connectSubresources.Has(info.subresource) {
+			return fmt.Errorf("token is read-only and can't use the %s subresource", info.subresource)
+		}
+	}
+
+	if len(scope.Resources) == 0 {
+		return nil
+	}
+	if !info.isResourceRequest {
+		// discovery and other non-resource endpoints can be read, but not written to
+		if readOnlyMethods.Has(req.Method) {
+			return nil
+		}
+		return fmt.Errorf("token is scoped to resources and can't be used for [%s %s]", req.Method, req.URL.Path)
+	}
+	for _, allowed := range scope.Resources {
+		if allowed.APIGroup != "*" && allowed.APIGroup != info.apiGroup {
+			continue
+		}
+		if len(allowed.Resources) == 0 {
+			return nil
+		}
+		for _, resource := range allowed.Resources {
+			if resource == "*" || strings.EqualFold(resource, info.resource) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("token is not scoped to resource [%s] in API group [%s]", info.resource, info.apiGroup)
+}
+
+// derivedTokenScope returns the scope of a token created from the given input with a token of the given scope. A token
+// created with a scoped token can't be given more access than the token used to create it, so it inherits its scope.
+func derivedTokenScope(scope *v32.TokenScope, input clientv3.Token) (*v32.TokenScope, error) {
+	if scope == nil {
+		return tokenScopeFromInput(input.Scope), nil
+	}
+	if input.Scope != nil {
+		return nil, errors.New("tokens created with a scoped token inherit its scope and can't set their own")
+	}
+	if input.ClusterID != "" && len(scope.Clusters) > 0 && !sets.NewString(scope.Clusters...).Has(input.ClusterID) {
+		return nil, fmt.Errorf("token is not scoped to cluster [%s]", input.ClusterID)
+	}
+	return scope.DeepCopy(), nil
+}
+
+// tokenScopeFromInput converts the scope of a token creation request.
+func tokenScopeFromInput(input *clientv3.TokenScope) *v32.TokenScope {
+	if input == nil {
+		return nil
+	}
+	scope := &v32.TokenScope{
+		Clusters: input.Clusters,
+		ReadOnly: input.ReadOnly,
+	}
+	for _, resource := range input.Resources {
+		scope.Resources = append(scope.Resources, v32.TokenScopeResource{
+			APIGroup:  resource.APIGroup,
+			Resources: resource.Resources,
+		})
+	}
+	return scope
+}
+
+// requestClusterID returns the cluster which a request to the management cluster API is for, or "" if it isn't for a
+// single cluster. Management resources of a cluster are the cluster itself and the resources in its namespace, like its
+// projects, and requests for other resources of the /v1 API are for the local cluster.
+func requestClusterID(req *http.Request) string {
+	switch path := req.URL.Path; {
+	case strings.HasPrefix(path, "/v1/"):
+		segments := util.SplitPath(strings.TrimPrefix(path, "/v1/"))
+		if len(segments) == 0 {
+			return ""
+		}
+		if !strings.HasPrefix(segments[0], normanAPIGroup+".") {
+			return "local"
+		}
+		switch {
+		case segments[0] == normanAPIGroup+".clusters" && len(segments) == 2:
+			return segments[1]
+		case len(segments) == 3:
+			return segments[1]
+		}
+	case strings.HasPrefix(path, "/v3/"):
+		segments := util.SplitPath(strings.TrimPrefix(path, "/v3/"))
+		if len(segments) < 2 {
+			return ""
+		}
+		switch segments[0] {
+		case "clusters", "cluster":
+			return segments[1]
+		case "projects", "project":
+			// project IDs are <cluster>:<project>
+			clusterID, _, _ := strings.Cut(segments[1], ":")
+			return clusterID
+		}
+	}
+	return ""
+}
+
+// newScopeRequest finds the API group and resource of Kubernetes API requests, including those proxied through
+// /k8s/clusters/<cluster>, and of requests to the /v1 and /v3 Rancher APIs.
+func newScopeRequest(req *http.Request) scopeRequest {
+	path := req.URL.Path
+	if strings.HasPrefix(path, "/k8s/clusters/") {
+		// strip /k8s/clusters/<cluster>
+		parts := strings.SplitN(strings.TrimPrefix(path, "/k8s/clusters/"), "/", 2)
+		path = "/"
+		if len(parts) == 2 {
+			path += parts[1]
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/apis/"):
+		k8sReq := req.Clone(req.Context())
+		k8sReq.URL.Path = path
+		info, err := scopeRequestInfoFactory.NewRequestInfo(k8sReq)
+		if err != nil || !info.IsResourceRequest {
+			return scopeRequest{}
+		}
+		return scopeRequest{
+			apiGroup:          info.APIGroup,
+			resource:          info.Resource,
+			subresource:       info.Subresource,
+			isResourceRequest: true,
+		}
+	case strings.HasPrefix(path, "/v1/"):
+		// steve types are <group>.<resource>, or just <resource> for the core group
+		segments := util.SplitPath(strings.TrimPrefix(path, "/v1/"))
+		if len(segments) == 0 {
+			return scopeRequest{}
+		}
+		scopeReq := scopeRequest{
+			resource:          segments[0],
+			subresource:       req.URL.Query().Get("link"),
+			isResourceRequest: true,
+		}
+		if i := strings.LastIndex(segments[0], "."); i >= 0 {
+			scopeReq.apiGroup, scopeReq.resource = segments[0][:i], segments[0][i+1:]
+		}
+		return scopeReq
+	case strings.HasPrefix(path, "/v3/"):
+		segments := util.SplitPath(strings.TrimPrefix(path, "/v3/"))
+		// /v3/cluster/<id>/<type> and /v3/project/<id>/<type> are scoped collections
+		if len(segments) > 2 && (segments[0] == "cluster" || segments[0] == "project") {
+			segments = segments[2:]
+		}
+		if len(segments) == 0 {
+			return scopeRequest{}
+		}
+		scopeReq := scopeRequest{
+			apiGroup:          normanAPIGroup,
+			resource:          segments[0],
+			isResourceRequest: true,
+		}
+		if req.URL.Query().Get("shell") == "true" {
+			scopeReq.subresource = "shell"
+		}
+		return scopeReq
+	}
+	return scopeRequest{}
+}
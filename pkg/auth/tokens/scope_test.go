@@ -0,0 +1,252 @@
+package tokens
+
+import (
+	"net/http"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	clientv3 "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckScope(t *testing.T) {
+	clusterScope := &v32.TokenScope{Clusters: []string{"c-abcde"}}
+	readOnlyScope := &v32.TokenScope{ReadOnly: true}
+	resourceScope := &v32.TokenScope{
+		Resources: []v32.TokenScopeResource{
+			{APIGroup: "", Resources: []string{"pods", "configmaps"}},
+			{APIGroup: "apps"},
+			{APIGroup: "management.cattle.io", Resources: []string{"clusters"}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		scope     *v32.TokenScope
+		clusterID string
+		method    string
+		uri       string
+		wantErr   bool
+	}{
+		{
+			name:   "no scope",
+			method: http.MethodDelete,
+			uri:    "/v3/clusters/c-abcde",
+		},
+		{
+			name:      "cluster in scope",
+			scope:     clusterScope,
+			clusterID: "c-abcde",
+			method:    http.MethodGet,
+			uri:       "/k8s/clusters/c-abcde/api/v1/pods",
+		},
+		{
+			name:      "cluster API in scope",
+			scope:     clusterScope,
+			clusterID: "c-abcde",
+			method:    http.MethodGet,
+			uri:       "/k8s/clusters/c-abcde/v1/apps.deployments/default/foo",
+		},
+		{
+			name:   "management cluster in scope",
+			scope:  clusterScope,
+			method: http.MethodGet,
+			uri:    "/v1/management.cattle.io.clusters/c-abcde",
+		},
+		{
+			name:   "management resource of cluster in scope",
+			scope:  clusterScope,
+			method: http.MethodGet,
+			uri:    "/v1/management.cattle.io.projects/c-abcde/p-xyz",
+		},
+		{
+			name:   "norman cluster in scope",
+			scope:  clusterScope,
+			method: http.MethodPut,
+			uri:    "/v3/clusters/c-abcde",
+		},
+		{
+			name:   "norman project of cluster in scope",
+			scope:  clusterScope,
+			method: http.MethodGet,
+			uri:    "/v3/projects/c-abcde:p-xyz",
+		},
+		{
+			name:    "management cluster out of scope",
+			scope:   clusterScope,
+			method:  http.MethodGet,
+			uri:     "/v1/management.cattle.io.clusters/c-fghij",
+			wantErr: true,
+		},
+		{
+			name:    "management clusters list with cluster scope",
+			scope:   clusterScope,
+			method:  http.MethodGet,
+			uri:     "/v1/management.cattle.io.clusters",
+			wantErr: true,
+		},
+		{
+			name:    "local cluster API with cluster scope",
+			scope:   clusterScope,
+			method:  http.MethodGet,
+			uri:     "/v1/apps.deployments/default/foo",
+			wantErr: true,
+		},
+		{
+			name:   "local cluster API in scope",
+			scope:  &v32.TokenScope{Clusters: []string{"local"}},
+			method: http.MethodGet,
+			uri:    "/v1/apps.deployments/default/foo",
+		},
+		{
+			name:      "cluster out of scope",
+			scope:     clusterScope,
+			clusterID: "c-fghij",
+			method:    http.MethodGet,
+			uri:       "/k8s/clusters/c-fghij/api/v1/pods",
+			wantErr:   true,
+		},
+		{
+			name:    "management API with cluster scope",
+			scope:   clusterScope,
+			method:  http.MethodGet,
+			uri:     "/v1/management.cattle.io.settings",
+			wantErr: true,
+		},
+		{
+			name:   "read-only get",
+			scope:  readOnlyScope,
+			method: http.MethodGet,
+			uri:    "/v1/apps.deployments/default/foo",
+		},
+		{
+			name:    "read-only create",
+			scope:   readOnlyScope,
+			method:  http.MethodPost,
+			uri:     "/v1/apps.deployments",
+			wantErr: true,
+		},
+		{
+			name:    "read-only exec",
+			scope:   readOnlyScope,
+			method:  http.MethodGet,
+			uri:     "/k8s/clusters/c-abcde/api/v1/namespaces/default/pods/foo/exec?command=sh",
+			wantErr: true,
+		},
+		{
+			name:    "read-only shell",
+			scope:   readOnlyScope,
+			method:  http.MethodGet,
+			uri:     "/v1/management.cattle.io.clusters/local?link=shell",
+			wantErr: true,
+		},
+		{
+			name:   "core resource in scope",
+			scope:  resourceScope,
+			method: http.MethodPut,
+			uri:    "/k8s/clusters/c-abcde/api/v1/namespaces/default/configmaps/foo",
+		},
+		{
+			name:    "core resource out of scope",
+			scope:   resourceScope,
+			method:  http.MethodGet,
+			uri:     "/api/v1/namespaces/default/secrets/foo",
+			wantErr: true,
+		},
+		{
+			name:   "every resource of group",
+			scope:  resourceScope,
+			method: http.MethodGet,
+			uri:    "/v1/apps.statefulsets",
+		},
+		{
+			name:   "norman resource in scope",
+			scope:  resourceScope,
+			method: http.MethodGet,
+			uri:    "/v3/clusters/c-abcde",
+		},
+		{
+			name:    "norman resource out of scope",
+			scope:   resourceScope,
+			method:  http.MethodPost,
+			uri:     "/v3/tokens",
+			wantErr: true,
+		},
+		{
+			name:   "discovery",
+			scope:  resourceScope,
+			method: http.MethodGet,
+			uri:    "/k8s/clusters/c-abcde/apis",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.uri, nil)
+			require.NoError(t, err)
+			err = CheckScope(tt.scope, tt.clusterID, req)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDerivedTokenScope(t *testing.T) {
+	scope := &v32.TokenScope{Clusters: []string{"c-abcde"}, ReadOnly: true}
+
+	tests := []struct {
+		name    string
+		scope   *v32.TokenScope
+		input   clientv3.Token
+		want    *v32.TokenScope
+		wantErr bool
+	}{
+		{
+			name: "unscoped token without scope",
+		},
+		{
+			name:  "unscoped token sets scope",
+			input: clientv3.Token{Scope: &clientv3.TokenScope{ReadOnly: true}},
+			want:  &v32.TokenScope{ReadOnly: true},
+		},
+		{
+			name:  "scoped token inherits scope",
+			scope: scope,
+			want:  scope,
+		},
+		{
+			name:  "scoped token for cluster in scope",
+			scope: scope,
+			input: clientv3.Token{ClusterID: "c-abcde"},
+			want:  scope,
+		},
+		{
+			name:    "scoped token for cluster out of scope",
+			scope:   scope,
+			input:   clientv3.Token{ClusterID: "c-fghij"},
+			wantErr: true,
+		},
+		{
+			name:    "scoped token sets scope",
+			scope:   scope,
+			input:   clientv3.Token{Scope: &clientv3.TokenScope{}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := derivedTokenScope(tt.scope, tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
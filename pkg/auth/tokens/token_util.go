@@ -1,10 +1,13 @@
 package tokens
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,6 +18,9 @@
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/user"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/util/retry"
 )
 
 func getAuthProviderName(principalID string) string {
@@ -130,6 +136,19 @@ func extractClusterIDFromResponseType(responseType string) string {
 	return responseSplit[1]
 }
 
+// verifiedTokenTTL is how long a verified token key is remembered, so that the key doesn't have to be hashed again
+// for every request using it. Hashers like argon2id are deliberately slow.
+const verifiedTokenTTL = time.Minute
+
+// verifiedTokens maps the sha256 of the name and key of recently verified tokens to the hash they were verified
+// against. An entry only applies as long as the stored token still has that hash.
+var verifiedTokens = cache.NewLRUExpireCache(4096)
+
+func verifiedTokenCacheKey(tokenName, tokenKey string) string {
+	sum := sha256.Sum256([]byte(tokenName + ":" + tokenKey))
+	return hex.EncodeToString(sum[:])
+}
+
 // Given a stored token with hashed key, check if the provided (unhashed) tokenKey matches and is valid
 func VerifyToken(storedToken *v3.Token, tokenName, tokenKey string) (int, error) {
 	invalidAuthTokenErr := errors.New("Invalid auth token value")
@@ -137,14 +156,18 @@ func VerifyToken(storedToken *v3.Token, tokenName, tokenKey string) (int, error)
 		return http.StatusUnprocessableEntity, invalidAuthTokenErr
 	}
 	if storedToken.Annotations != nil && storedToken.Annotations[TokenHashed] == "true" {
-		hasher, err := hashers.GetHasherForHash(storedToken.Token)
-		if err != nil {
-			logrus.Errorf("unable to get a hasher for token with error %v", err)
-			return http.StatusInternalServerError, fmt.Errorf("unable to verify hash")
-		}
-		if err := hasher.VerifyHash(storedToken.Token, tokenKey); err != nil {
-			logrus.Errorf("VerifyHash failed with error: %v", err)
-			return http.StatusUnprocessableEntity, invalidAuthTokenErr
+		cacheKey := verifiedTokenCacheKey(tokenName, tokenKey)
+		if hash, ok := verifiedTokens.Get(cacheKey); !ok || hash != storedToken.Token {
+			hasher, err := hashers.GetHasherForHash(storedToken.Token)
+			if err != nil {
+				logrus.Errorf("unable to get a hasher for token with error %v", err)
+				return http.StatusInternalServerError, fmt.Errorf("unable to verify hash")
+			}
+			if err := hasher.VerifyHash(storedToken.Token, tokenKey); err != nil {
+				logrus.Errorf("VerifyHash failed with error: %v", err)
+				return http.StatusUnprocessableEntity, invalidAuthTokenErr
+			}
+			verifiedTokens.Add(cacheKey, storedToken.Token, verifiedTokenTTL)
 		}
 	} else {
 		if storedToken.Token != tokenKey {
@@ -157,14 +180,83 @@ func VerifyToken(storedToken *v3.Token, tokenName, tokenKey string) (int, error)
 	return http.StatusOK, nil
 }
 
+// NeedsRehash returns true if the token is hashed with a different version than the one used for new tokens.
+func NeedsRehash(token *v3.Token) bool {
+	if token == nil || !features.TokenHashing.Enabled() || token.Annotations[TokenHashed] != "true" {
+		return false
+	}
+	// tokens of a cluster are synced to it for the authorized cluster endpoint, and must keep a hash it can verify
+	if token.ClusterName != "" {
+		return false
+	}
+	version, err := hashers.GetHashVersion(token.Token)
+	if err != nil {
+		return false
+	}
+	return version != hashers.GetHasherVersion()
+}
+
+// rehashing holds the names of the tokens currently being re-hashed, so that concurrent requests using the same token
+// don't all try to update it.
+var rehashing sync.Map
+
+// RehashIfNeeded re-hashes the key of a token which was hashed with an older version, using the hasher for new tokens.
+// tokenKey must have been verified against storedToken. The update happens in the background so that it never delays
+// or fails the request that used the token.
+func RehashIfNeeded(tokenClient v3.TokenInterface, storedToken *v3.Token, tokenKey string) {
+	if !NeedsRehash(storedToken) {
+		return
+	}
+	if _, loaded := rehashing.LoadOrStore(storedToken.Name, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer rehashing.Delete(storedToken.Name)
+		if err := rehashToken(tokenClient, storedToken.Name, tokenKey); err != nil {
+			logrus.Errorf("Failed to re-hash token [%s]: %v", storedToken.Name, err)
+		}
+	}()
+}
+
+func rehashToken(tokenClient v3.TokenInterface, tokenName, tokenKey string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		token, err := tokenClient.Get(tokenName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !NeedsRehash(token) {
+			return nil
+		}
+		// the token may have changed since the key was verified
+		if _, err := VerifyToken(token, tokenName, tokenKey); err != nil {
+			return err
+		}
+		hashedToken, err := hashers.GetHasher().CreateHash(tokenKey)
+		if err != nil {
+			return fmt.Errorf("failed to generate hash from token: %w", err)
+		}
+		token.Token = hashedToken
+		_, err = tokenClient.Update(token)
+		return err
+	})
+}
+
+// hasherForToken returns the hasher for the key of a new token. Tokens of a cluster are always hashed with SHA3, as
+// their hash is synced to the cluster for the authorized cluster endpoint, which doesn't support the newer hashers.
+func hasherForToken(token *v3.Token) hashers.Hasher {
+	if token.ClusterName != "" {
+		return hashers.Sha3Hasher{}
+	}
+	return hashers.GetHasher()
+}
+
 // ConvertTokenKeyToHash takes a token with an un-hashed key and converts it to a hashed key
 func ConvertTokenKeyToHash(token *v3.Token) error {
 	if !features.TokenHashing.Enabled() {
 		return nil
 	}
 	if token != nil && len(token.Token) > 0 {
-		hasher := hashers.GetHasher()
-		hashedToken, err := hasher.CreateHash(token.Token)
+		hashedToken, err := hasherForToken(token).CreateHash(token.Token)
 		if err != nil {
 			logrus.Errorf("Failed to generate hash from token: %v", err)
 			return errors.New("failed to generate hash from token")
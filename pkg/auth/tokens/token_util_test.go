@@ -7,7 +7,10 @@
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
 	"github.com/rancher/rancher/pkg/features"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -207,9 +210,209 @@ func TestConvertTokenKeyToHash(t *testing.T) {
 	}
 }
 
+func TestConvertTokenKeyToHashOfClusterToken(t *testing.T) {
+	defer features.TokenHashing.Set(features.TokenHashing.Enabled())
+	defer settings.TokenHashAlgorithm.Set(settings.TokenHashAlgorithm.Default)
+	features.TokenHashing.Set(true)
+	require.NoError(t, settings.TokenHashAlgorithm.Set("argon2id"))
+
+	plaintextToken := "cccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	token := &v3.Token{ObjectMeta: metav1.ObjectMeta{Name: "cluster-token"}, ClusterName: "c-abcde", Token: plaintextToken}
+	require.NoError(t, ConvertTokenKeyToHash(token))
+	version, err := hashers.GetHashVersion(token.Token)
+	require.NoError(t, err)
+	require.Equal(t, hashers.SHA3Version, version, "tokens of a cluster are hashed with SHA3 regardless of the algorithm")
+	require.NoError(t, hashers.Sha3Hasher{}.VerifyHash(token.Token, plaintextToken))
+}
+
 func expireToken(token *v3.Token) *v3.Token {
 	newToken := token.DeepCopy()
 	newToken.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Second * 10))
 	newToken.TTLMillis = 1
 	return newToken
 }
+
+func TestNeedsRehash(t *testing.T) {
+	// SHA3 hash of "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	sha3Token := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "sha3-token",
+			Annotations: map[string]string{TokenHashed: "true"},
+		},
+		Token: "$3:1:uFrxm43ggfw:zsN1zEFC7SvABTdR58o7yjIqfrI4cQ/HSYz3jBwwVnx5X+/ph4etGDIU9dvIYuy1IvnYUVe6a/Ar95xE+gfjhA",
+	}
+	clusterToken := sha3Token.DeepCopy()
+	clusterToken.ClusterName = "c-abcde"
+	unhashedToken := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{Name: "unhashed-token"},
+		Token:      "dddddddddddddddddddddddddddddddddddddddddddddddddddddd",
+	}
+
+	tests := []struct {
+		name                string
+		tokenHashingEnabled bool
+		algorithm           string
+		token               *v3.Token
+		want                bool
+	}{
+		{
+			name:                "hashed with the configured algorithm",
+			tokenHashingEnabled: true,
+			algorithm:           "sha3",
+			token:               sha3Token,
+		},
+		{
+			name:                "hashed with an older algorithm",
+			tokenHashingEnabled: true,
+			algorithm:           "argon2id",
+			token:               sha3Token,
+			want:                true,
+		},
+		{
+			name:                "token of a cluster keeps the hash the cluster can verify",
+			tokenHashingEnabled: true,
+			algorithm:           "argon2id",
+			token:               clusterToken,
+		},
+		{
+			name:                "token hashing disabled",
+			tokenHashingEnabled: false,
+			algorithm:           "argon2id",
+			token:               sha3Token,
+		},
+		{
+			name:                "unhashed token",
+			tokenHashingEnabled: true,
+			algorithm:           "argon2id",
+			token:               unhashedToken,
+		},
+		{
+			name:                "nil token",
+			tokenHashingEnabled: true,
+			algorithm:           "argon2id",
+		},
+	}
+
+	defer settings.TokenHashAlgorithm.Set(settings.TokenHashAlgorithm.Default)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			features.TokenHashing.Set(test.tokenHashingEnabled)
+			require.NoError(t, settings.TokenHashAlgorithm.Set(test.algorithm))
+			require.Equal(t, test.want, NeedsRehash(test.token))
+		})
+	}
+}
+
+func TestVerifyTokenCache(t *testing.T) {
+	tokenKey := "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	otherTokenKey := "cccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	hasher := hashers.GetHasher()
+	hash, err := hasher.CreateHash(tokenKey)
+	require.NoError(t, err)
+	otherHash, err := hasher.CreateHash(otherTokenKey)
+	require.NoError(t, err)
+
+	token := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cached-token",
+			Annotations: map[string]string{TokenHashed: "true"},
+		},
+		Token: hash,
+	}
+	_, err = VerifyToken(token, token.Name, tokenKey)
+	require.NoError(t, err)
+	cached, ok := verifiedTokens.Get(verifiedTokenCacheKey(token.Name, tokenKey))
+	require.True(t, ok)
+	require.Equal(t, hash, cached)
+
+	// the cached verification no longer applies once the key of the token changed
+	token.Token = otherHash
+	_, err = VerifyToken(token, token.Name, tokenKey)
+	require.Error(t, err)
+	_, err = VerifyToken(token, token.Name, otherTokenKey)
+	require.NoError(t, err)
+
+	// expiry is checked even if the key is cached
+	_, err = VerifyToken(expireToken(token), token.Name, otherTokenKey)
+	require.Error(t, err)
+}
+
+func TestRehashToken(t *testing.T) {
+	tokenKey := "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	// SHA3 hash of tokenKey
+	sha3Hash := "$3:1:uFrxm43ggfw:zsN1zEFC7SvABTdR58o7yjIqfrI4cQ/HSYz3jBwwVnx5X+/ph4etGDIU9dvIYuy1IvnYUVe6a/Ar95xE+gfjhA"
+
+	tests := []struct {
+		name        string
+		algorithm   string
+		tokenKey    string
+		conflicts   int
+		wantErr     bool
+		wantUpdates int
+	}{
+		{
+			name:        "rehash with the configured algorithm",
+			algorithm:   "argon2id",
+			tokenKey:    tokenKey,
+			wantUpdates: 1,
+		},
+		{
+			name:        "retry on conflict",
+			algorithm:   "argon2id",
+			tokenKey:    tokenKey,
+			conflicts:   1,
+			wantUpdates: 2,
+		},
+		{
+			name:      "already hashed with the configured algorithm",
+			algorithm: "sha3",
+			tokenKey:  tokenKey,
+		},
+		{
+			name:      "wrong key",
+			algorithm: "argon2id",
+			tokenKey:  "cccccccccccccccccccccccccccccccccccccccccccccccccccccc",
+			wantErr:   true,
+		},
+	}
+
+	defer features.TokenHashing.Set(features.TokenHashing.Enabled())
+	defer settings.TokenHashAlgorithm.Set(settings.TokenHashAlgorithm.Default)
+	features.TokenHashing.Set(true)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, settings.TokenHashAlgorithm.Set(test.algorithm))
+			updates := 0
+			tokenClient := &fakes.TokenInterfaceMock{
+				GetFunc: func(name string, opts metav1.GetOptions) (*v3.Token, error) {
+					return &v3.Token{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        name,
+							Annotations: map[string]string{TokenHashed: "true"},
+						},
+						Token: sha3Hash,
+					}, nil
+				},
+				UpdateFunc: func(token *v3.Token) (*v3.Token, error) {
+					updates++
+					if updates <= test.conflicts {
+						return nil, apierrors.NewConflict(v3.TokenGroupVersionResource.GroupResource(), token.Name, nil)
+					}
+					version, err := hashers.GetHashVersion(token.Token)
+					require.NoError(t, err)
+					require.Equal(t, hashers.Argon2idVersion, version)
+					require.NoError(t, hashers.GetHasher().VerifyHash(token.Token, tokenKey))
+					return token, nil
+				},
+			}
+
+			err := rehashToken(tokenClient, "test-token", test.tokenKey)
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, test.wantUpdates, updates)
+		})
+	}
+}
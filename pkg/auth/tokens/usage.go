@@ -0,0 +1,186 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// usageGranularity is how stale the recorded last use of a token can be before it is updated again.
+	usageGranularity = time.Minute
+	// usageFlushInterval is how often recorded token usage is written to the tokens.
+	usageFlushInterval = 30 * time.Second
+)
+
+var (
+	// systemTokenKinds are the kinds of the tokens which Rancher creates for its own components, like the agents.
+	systemTokenKinds = sets.NewString("agent", "compose", "helm", "provisioning", "telemetry")
+
+	usage         = &usageRecorder{pending: map[string]tokenUsage{}}
+	usageStarting sync.Once
+)
+
+type tokenUsage struct {
+	at time.Time
+	ip string
+}
+
+// usageRecorder keeps the last use of tokens in memory and writes it to the tokens in batches, so that authenticating a
+// request never waits for, or adds much load to, the API server.
+type usageRecorder struct {
+	lock    sync.Mutex
+	pending map[string]tokenUsage
+	tokens  v3.TokenInterface
+}
+
+// StartUsageRecorder starts writing the usage recorded by RecordUsage to the tokens. Only the first call has any effect.
+func StartUsageRecorder(ctx context.Context, tokens v3.TokenInterface) {
+	usageStarting.Do(func() {
+		usage.tokens = tokens
+		go wait.Until(usage.flush, usageFlushInterval, ctx.Done())
+	})
+}
+
+// RecordUsage records that the token was used to authenticate req. Nothing is recorded if the token's last use is
+// already up to date.
+func RecordUsage(token *v3.Token, req *http.Request) {
+	usage.record(token, ClientIP(req), time.Now())
+}
+
+func (u *usageRecorder) record(token *v3.Token, ip string, now time.Time) {
+	if token.LastUsedAt != nil && now.Sub(token.LastUsedAt.Time) < usageGranularity && token.LastUsedIP == ip {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if pending, ok := u.pending[token.Name]; ok && now.Sub(pending.at) < usageGranularity && pending.ip == ip {
+		return
+	}
+	u.pending[token.Name] = tokenUsage{at: now, ip: ip}
+}
+
+func (u *usageRecorder) flush() {
+	u.lock.Lock()
+	pending := u.pending
+	u.pending = map[string]tokenUsage{}
+	u.lock.Unlock()
+
+	for name, used := range pending {
+		if err := u.patch(name, used); err != nil && !apierrors.IsNotFound(err) {
+			logrus.Errorf("Failed to record usage of token [%s]: %v", name, err)
+		}
+	}
+	if len(pending) > 0 {
+		logrus.Debugf("Recorded usage of %d tokens", len(pending))
+	}
+}
+
+func (u *usageRecorder) patch(name string, used tokenUsage) error {
+	lastUsedAt := metav1.NewTime(used.at)
+	data, err := json.Marshal(map[string]interface{}{
+		"lastUsedAt": lastUsedAt,
+		"lastUsedIP": used.ip,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = u.tokens.ObjectClient().Patch(name, &v3.Token{}, types.MergePatchType, data)
+	return err
+}
+
+// ClientIP returns the address of the client which sent the request. When the request comes from one of the proxies
+// of the trusted-proxy-cidrs setting, it is the last X-Forwarded-For entry which isn't a trusted proxy, as the entries
+// before it may have been set by the client.
+func ClientIP(req *http.Request) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	forwardedFor := req.Header.Values("X-Forwarded-For")
+	if len(forwardedFor) == 0 {
+		return ip
+	}
+	trusted := trustedProxies()
+	if !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+	entries := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(entries[i])
+		if entry == "" {
+			continue
+		}
+		ip = entry
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxies returns the networks of the trusted-proxy-cidrs setting, ignoring invalid entries.
+func trustedProxies() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(settings.TrustedProxyCIDRs.Get(), ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logrus.Errorf("Invalid CIDR %q in setting %s: %v", cidr, settings.TrustedProxyCIDRs.Name, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func isTrustedProxy(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IdleTimeoutExempt returns true if the token is never disabled or deleted for being idle: system tokens, which
+// Rancher's own components depend on, and tokens of a cluster, which can be used through the authorized cluster
+// endpoint without Rancher recording their use.
+func IdleTimeoutExempt(token *v3.Token) bool {
+	return systemTokenKinds.Has(token.Labels[TokenKindLabel]) || token.ClusterName != ""
+}
+
+// IsIdle returns true if the token hasn't been used for longer than idleTimeout. Tokens which have never been used are
+// idle from their creation, or from since if it is later, so that tokens which existed before usage was recorded, or
+// before the idle timeout was enabled, aren't idle right away.
+func IsIdle(token *v3.Token, idleTimeout time.Duration, since, now time.Time) bool {
+	lastUsed := token.CreationTimestamp.Time
+	if since.After(lastUsed) {
+		lastUsed = since
+	}
+	if token.LastUsedAt != nil && token.LastUsedAt.Time.After(lastUsed) {
+		lastUsed = token.LastUsedAt.Time
+	}
+	return now.Sub(lastUsed) > idleTimeout
+}
@@ -0,0 +1,290 @@
+package tokens
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestUsageRecorderRecord(t *testing.T) {
+	now := time.Now()
+	recentlyUsed := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{Name: "recently-used"},
+		LastUsedAt: &metav1.Time{Time: now.Add(-10 * time.Second)},
+		LastUsedIP: "10.0.0.1",
+	}
+	staleUsage := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{Name: "stale-usage"},
+		LastUsedAt: &metav1.Time{Time: now.Add(-time.Hour)},
+		LastUsedIP: "10.0.0.1",
+	}
+	neverUsed := &v3.Token{ObjectMeta: metav1.ObjectMeta{Name: "never-used"}}
+
+	recorder := &usageRecorder{pending: map[string]tokenUsage{}}
+	recorder.record(recentlyUsed, "10.0.0.1", now)
+	recorder.record(staleUsage, "10.0.0.1", now)
+	recorder.record(neverUsed, "10.0.0.2", now)
+	assert.Len(t, recorder.pending, 2)
+	assert.NotContains(t, recorder.pending, recentlyUsed.Name)
+
+	// a new address is recorded even if the last use is recent
+	recorder.record(recentlyUsed, "10.0.0.3", now)
+	assert.Equal(t, tokenUsage{at: now, ip: "10.0.0.3"}, recorder.pending[recentlyUsed.Name])
+
+	// pending usage isn't replaced until it is stale
+	recorder.record(neverUsed, "10.0.0.2", now.Add(time.Second))
+	assert.Equal(t, now, recorder.pending[neverUsed.Name].at)
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		trusted      string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:       "remote address",
+			remoteAddr: "10.0.0.1:45678",
+			want:       "10.0.0.1",
+		},
+		{
+			name:         "forwarded for is ignored without trusted proxies",
+			remoteAddr:   "10.0.0.1:45678",
+			forwardedFor: []string{"192.168.0.1"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:         "forwarded for is ignored from untrusted proxies",
+			trusted:      "172.16.0.0/12",
+			remoteAddr:   "10.0.0.1:45678",
+			forwardedFor: []string{"192.168.0.1"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:         "client behind a trusted proxy",
+			trusted:      "10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:45678",
+			forwardedFor: []string{"192.168.0.1"},
+			want:         "192.168.0.1",
+		},
+		{
+			name:         "entries set by the client are ignored",
+			trusted:      "10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:45678",
+			forwardedFor: []string{"1.2.3.4, 192.168.0.1"},
+			want:         "192.168.0.1",
+		},
+		{
+			name:         "chain of trusted proxies",
+			trusted:      "10.0.0.0/8, 172.16.0.0/12",
+			remoteAddr:   "10.0.0.1:45678",
+			forwardedFor: []string{"1.2.3.4, 192.168.0.1", "172.16.0.5"},
+			want:         "192.168.0.1",
+		},
+		{
+			name:         "invalid CIDRs are ignored",
+			trusted:      "invalid, 10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:45678",
+			forwardedFor: []string{"192.168.0.1"},
+			want:         "192.168.0.1",
+		},
+	}
+
+	defer settings.TrustedProxyCIDRs.Set(settings.TrustedProxyCIDRs.Default)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, settings.TrustedProxyCIDRs.Set(tt.trusted))
+			req, err := http.NewRequest(http.MethodGet, "/v3/tokens", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, tt.want, ClientIP(req))
+		})
+	}
+}
+
+func TestIsIdle(t *testing.T) {
+	now := time.Now()
+	old, recent := now.Add(-60*24*time.Hour), now.Add(-24*time.Hour)
+	idleTimeout := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		created  time.Time
+		lastUsed *time.Time
+		since    time.Time
+		want     bool
+	}{
+		{
+			name:    "never used since creation",
+			created: old,
+			want:    true,
+		},
+		{
+			name:    "created recently",
+			created: recent,
+		},
+		{
+			name:    "timeout enabled recently",
+			created: old,
+			since:   recent,
+		},
+		{
+			name:     "used recently",
+			created:  old,
+			lastUsed: &recent,
+		},
+		{
+			name:     "used long ago",
+			created:  old,
+			lastUsed: &old,
+			since:    old,
+			want:     true,
+		},
+		{
+			name:     "used before the timeout was enabled",
+			created:  old,
+			lastUsed: &old,
+			since:    recent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &v3.Token{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(tt.created)}}
+			if tt.lastUsed != nil {
+				token.LastUsedAt = &metav1.Time{Time: *tt.lastUsed}
+			}
+			assert.Equal(t, tt.want, IsIdle(token, idleTimeout, tt.since, now))
+		})
+	}
+}
+
+func TestIdleTimeoutExempt(t *testing.T) {
+	assert.False(t, IdleTimeoutExempt(&v3.Token{}))
+	assert.False(t, IdleTimeoutExempt(&v3.Token{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{TokenKindLabel: "session"}}}))
+	assert.True(t, IdleTimeoutExempt(&v3.Token{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{TokenKindLabel: "agent"}}}))
+	assert.True(t, IdleTimeoutExempt(&v3.Token{ClusterName: "c-abcde"}))
+}
+
+func TestPurgeIdle(t *testing.T) {
+	now := time.Now()
+	newToken := func(name string, created time.Time, lastUsed *time.Time) *v3.Token {
+		token := &v3.Token{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+		}
+		if lastUsed != nil {
+			token.LastUsedAt = &metav1.Time{Time: *lastUsed}
+		}
+		return token
+	}
+	recent, old := now.Add(-24*time.Hour), now.Add(-60*24*time.Hour)
+	allTokens := []*v3.Token{
+		newToken("used-recently", old, &recent),
+		newToken("used-long-ago", old, &old),
+		newToken("never-used", old, nil),
+		newToken("created-recently", recent, nil),
+	}
+	disabled := newToken("already-disabled", old, nil)
+	disabled.Enabled = pointer.Bool(false)
+	system := newToken("system", old, nil)
+	system.Labels = map[string]string{TokenKindLabel: "agent"}
+	cluster := newToken("cluster", old, nil)
+	cluster.ClusterName = "c-abcde"
+	allTokens = append(allTokens, disabled, system, cluster)
+
+	tests := []struct {
+		name        string
+		idleDays    string
+		action      string
+		enabledAt   string
+		wantUpdated []string
+		wantDeleted []string
+	}{
+		{
+			name:     "timeout disabled",
+			idleDays: "0",
+			action:   IdleActionDelete,
+		},
+		{
+			name:        "disable idle tokens",
+			idleDays:    "30",
+			action:      IdleActionDisable,
+			enabledAt:   old.Format(time.RFC3339),
+			wantUpdated: []string{"used-long-ago", "never-used"},
+		},
+		{
+			name:        "delete idle tokens",
+			idleDays:    "30",
+			action:      IdleActionDelete,
+			enabledAt:   old.Format(time.RFC3339),
+			wantDeleted: []string{"used-long-ago", "never-used", "already-disabled"},
+		},
+		{
+			name:      "timeout enabled recently",
+			idleDays:  "30",
+			action:    IdleActionDelete,
+			enabledAt: recent.Format(time.RFC3339),
+		},
+		{
+			name:     "timeout not annotated yet",
+			idleDays: "30",
+			action:   IdleActionDelete,
+		},
+		{
+			name:      "invalid action",
+			idleDays:  "30",
+			action:    "archive",
+			enabledAt: old.Format(time.RFC3339),
+		},
+	}
+
+	defer settings.TokenIdleTimeoutDays.Set(settings.TokenIdleTimeoutDays.Default)
+	defer settings.TokenIdleAction.Set(settings.TokenIdleAction.Default)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, settings.TokenIdleTimeoutDays.Set(tt.idleDays))
+			require.NoError(t, settings.TokenIdleAction.Set(tt.action))
+
+			setting := &v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: settings.TokenIdleTimeoutDays.Name}}
+			if tt.enabledAt != "" {
+				setting.Annotations = map[string]string{settings.EnabledAtAnnotation: tt.enabledAt}
+			}
+
+			var updated, deleted []string
+			p := &purger{
+				settingLister: &fakes.SettingListerMock{
+					GetFunc: func(_, name string) (*v3.Setting, error) {
+						assert.Equal(t, settings.TokenIdleTimeoutDays.Name, name)
+						return setting, nil
+					},
+				},
+				tokens: &fakes.TokenInterfaceMock{
+					UpdateFunc: func(token *v3.Token) (*v3.Token, error) {
+						assert.False(t, *token.Enabled)
+						updated = append(updated, token.Name)
+						return token, nil
+					},
+					DeleteFunc: func(name string, _ *metav1.DeleteOptions) error {
+						deleted = append(deleted, name)
+						return nil
+					},
+				},
+			}
+			p.purgeIdle(allTokens, now)
+			assert.Equal(t, tt.wantUpdated, updated)
+			assert.Equal(t, tt.wantDeleted, deleted)
+		})
+	}
+}
@@ -4,6 +4,7 @@
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -53,6 +54,17 @@ func GetHost(req *http.Request) string {
 	return host
 }
 
+// SplitPath returns the non-empty segments of a URL path.
+func SplitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
 // AuthError structure contains the error resource definition
 type AuthError struct {
 	Type    string `json:"type"`
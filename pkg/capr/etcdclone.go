@@ -0,0 +1,98 @@
+package capr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/name"
+)
+
+// serverTokenRotationVersions are the Kubernetes versions of RKE2 and K3s that have the token rotate command, which is
+// used to rotate the server token of a cloned cluster away from the server token of the cluster it was cloned from.
+const serverTokenRotationVersions = ">= 1.25.13-0 < 1.26.0-0 || >= 1.26.8-0 < 1.27.0-0 || >= 1.27.5-0"
+
+// SupportsServerTokenRotation returns true if RKE2 or K3s of the given Kubernetes version can rotate the server token.
+func SupportsServerTokenRotation(kubernetesVersion string) (bool, error) {
+	constraint, err := semver.NewConstraint(serverTokenRotationVersions)
+	if err != nil {
+		return false, err
+	}
+	version, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse Kubernetes version %s: %w", kubernetesVersion, err)
+	}
+	return constraint.Check(version), nil
+}
+
+// ETCDSnapshotSourceServerToken returns the server token of the cluster the snapshot was taken from. The bootstrap data
+// in the snapshot is encrypted with it, so it is required to restore the snapshot into another cluster.
+func ETCDSnapshotSourceServerToken(secretCache corecontrollers.SecretCache, snapshot *rkev1.ETCDSnapshot) (string, error) {
+	secret, err := secretCache.Get(snapshot.Namespace, name.SafeConcatName(snapshot.Spec.ClusterName, "rke", "state"))
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve the server token of cluster %s that etcd snapshot %s was taken from: %w", snapshot.Spec.ClusterName, snapshot.Name, err)
+	}
+	if secret.Type != SecretTypeClusterState {
+		return "", fmt.Errorf("secret %s/%s type %s did not match expected type %s", secret.Namespace, secret.Name, secret.Type, SecretTypeClusterState)
+	}
+	serverToken := string(secret.Data["serverToken"])
+	if serverToken == "" {
+		return "", fmt.Errorf("cluster %s that etcd snapshot %s was taken from has no server token", snapshot.Spec.ClusterName, snapshot.Name)
+	}
+	return serverToken, nil
+}
+
+// ETCDSnapshotCloneAuthorization returns the authorization of the given restore to clone the cluster it names into the
+// cluster with the given namespace and name. It is keyed with the server token of the cluster that is cloned, so it can
+// only be computed by those who can already read that token, and it is bound to the generation of the restore so that
+// it can't be reused for another restore.
+func ETCDSnapshotCloneAuthorization(sourceServerToken, namespace, clusterName string, restore *rkev1.ETCDSnapshotRestore) string {
+	mac := hmac.New(sha256.New, []byte(sourceServerToken))
+	mac.Write([]byte(strings.Join([]string{
+		namespace,
+		clusterName,
+		restore.CloneFromCluster,
+		restore.Name,
+		strconv.Itoa(restore.Generation),
+	}, "/")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateETCDSnapshotClone returns true if restoring the snapshot into the cluster with the given namespace and name
+// clones the cluster the snapshot was taken from. The snapshot of another cluster can only be restored if the restore
+// explicitly requests a clone of that cluster, the snapshot is stored in S3, and the clone has been authorized.
+func ValidateETCDSnapshotClone(secretCache corecontrollers.SecretCache, namespace, clusterName string, restore *rkev1.ETCDSnapshotRestore, snapshot *rkev1.ETCDSnapshot) (bool, error) {
+	if snapshot.Spec.ClusterName == "" || snapshot.Spec.ClusterName == clusterName {
+		if restore.CloneFromCluster != "" {
+			return false, fmt.Errorf("etcd snapshot %s/%s was taken from cluster %s, not from cluster %s that is requested to be cloned",
+				snapshot.Namespace, snapshot.Name, clusterName, restore.CloneFromCluster)
+		}
+		return false, nil
+	}
+
+	if restore.CloneFromCluster != snapshot.Spec.ClusterName {
+		return false, fmt.Errorf("etcd snapshot %s/%s was taken from cluster %s: restoring it into cluster %s requires a clone of cluster %s to be requested",
+			snapshot.Namespace, snapshot.Name, snapshot.Spec.ClusterName, clusterName, snapshot.Spec.ClusterName)
+	}
+	if snapshot.SnapshotFile.S3 == nil {
+		return false, fmt.Errorf("unable to clone cluster %s from etcd snapshot %s/%s of cluster %s: only snapshots stored in S3 can be restored into another cluster",
+			clusterName, snapshot.Namespace, snapshot.Name, snapshot.Spec.ClusterName)
+	}
+
+	sourceServerToken, err := ETCDSnapshotSourceServerToken(secretCache, snapshot)
+	if err != nil {
+		return false, err
+	}
+	authorization := ETCDSnapshotCloneAuthorization(sourceServerToken, namespace, clusterName, restore)
+	if !hmac.Equal([]byte(authorization), []byte(restore.CloneAuthorization)) {
+		return false, fmt.Errorf("clone of cluster %s into cluster %s from etcd snapshot %s/%s has not been authorized",
+			snapshot.Spec.ClusterName, clusterName, snapshot.Namespace, snapshot.Name)
+	}
+	return true, nil
+}
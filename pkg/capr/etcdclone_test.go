@@ -0,0 +1,33 @@
+package capr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportsServerTokenRotation(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "v1.24.17+rke2r1", expected: false},
+		{version: "v1.25.12+k3s1", expected: false},
+		{version: "v1.25.13+rke2r1", expected: true},
+		{version: "v1.26.7+k3s1", expected: false},
+		{version: "v1.26.8+k3s1", expected: true},
+		{version: "v1.27.4+rke2r1", expected: false},
+		{version: "v1.27.5+rke2r1", expected: true},
+		{version: "v1.28.2+k3s1", expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			supported, err := SupportsServerTokenRotation(tt.version)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, supported)
+		})
+	}
+
+	_, err := SupportsServerTokenRotation("latest")
+	assert.Error(t, err)
+}
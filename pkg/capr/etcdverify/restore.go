@@ -0,0 +1,142 @@
+package etcdverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	scratchMemberName = "scratch"
+	healthInterval    = time.Second
+)
+
+// ScratchRestore restores the snapshot at the given path into a throwaway etcd member with etcdutl, and waits until
+// the member reports that it is healthy. The etcd and etcdutl binaries, which are shipped in the Rancher image, are
+// looked up in the PATH. The data of the member is restored into a directory in dir, and the member and its data are
+// removed when it is healthy or the context is done.
+func ScratchRestore(ctx context.Context, dir, snapshotPath string) error {
+	etcd, err := exec.LookPath("etcd")
+	if err != nil {
+		return fmt.Errorf("etcd is required for scratch restores: %w", err)
+	}
+	etcdutl, err := exec.LookPath("etcdutl")
+	if err != nil {
+		return fmt.Errorf("etcdutl is required for scratch restores: %w", err)
+	}
+
+	dir, err = os.MkdirTemp(dir, "scratch-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	clientURL, err := localURL()
+	if err != nil {
+		return err
+	}
+	peerURL, err := localURL()
+	if err != nil {
+		return err
+	}
+	dataDir := filepath.Join(dir, "data")
+	initialCluster := scratchMemberName + "=" + peerURL
+
+	var output bytes.Buffer
+	restore := exec.CommandContext(ctx, etcdutl, "snapshot", "restore", snapshotPath,
+		"--data-dir", dataDir,
+		"--name", scratchMemberName,
+		"--initial-cluster", initialCluster,
+		"--initial-advertise-peer-urls", peerURL)
+	restore.Stdout = &output
+	restore.Stderr = &output
+	if err := restore.Run(); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w: %s", err, lastLine(output.Bytes()))
+	}
+
+	output.Reset()
+	member := exec.Command(etcd,
+		"--name", scratchMemberName,
+		"--data-dir", dataDir,
+		"--listen-client-urls", clientURL,
+		"--advertise-client-urls", clientURL,
+		"--listen-peer-urls", peerURL,
+		"--initial-advertise-peer-urls", peerURL,
+		"--initial-cluster", initialCluster)
+	member.Stdout = &output
+	member.Stderr = &output
+	if err := member.Start(); err != nil {
+		return fmt.Errorf("failed to start etcd: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- member.Wait()
+	}()
+	defer func() {
+		if err := member.Process.Kill(); err != nil {
+			logrus.Debugf("[etcdverify] failed to stop scratch etcd member: %v", err)
+		}
+		<-exited
+	}()
+
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("restored etcd member did not become healthy: %w", ctx.Err())
+		case err := <-exited:
+			exited <- err
+			return fmt.Errorf("restored etcd member exited: %v: %s", err, lastLine(output.Bytes()))
+		case <-ticker.C:
+			if healthy(ctx, clientURL) {
+				return nil
+			}
+		}
+	}
+}
+
+// localURL returns an http URL on the loopback interface with a free port.
+func localURL() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return "http://" + l.Addr().String(), nil
+}
+
+// healthy returns whether the health endpoint of the etcd member reports that it is healthy.
+func healthy(ctx context.Context, clientURL string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, clientURL+"/health", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	health := struct {
+		Health string `json:"health"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return false
+	}
+	return health.Health == "true"
+}
+
+func lastLine(output []byte) string {
+	lines := bytes.Split(bytes.TrimSpace(output), []byte("\n"))
+	return string(lines[len(lines)-1])
+}
@@ -0,0 +1,212 @@
+// Package etcdverify verifies that etcd snapshots are intact and can be restored.
+package etcdverify
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// hashTrailerAlignment is the alignment of the size of an etcd database. etcd appends the sha256 hash of the
+	// database to snapshots, which is detected by the remainder of the size of the snapshot.
+	hashTrailerAlignment = 512
+	// maxCompressionRatio bounds the size of a decompressed snapshot by the size of the compressed one, so that a
+	// malicious archive can't fill the disk of Rancher.
+	maxCompressionRatio = 100
+)
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	// etcdBuckets are buckets that every etcd database has
+	etcdBuckets = []string{"key", "meta"}
+)
+
+// Result is the result of a successful verification.
+type Result struct {
+	// SHA256 is the hex encoded sha256 hash of the snapshot file as it is stored
+	SHA256 string
+	// Size is the size of the snapshot file as it is stored
+	Size int64
+	// HasHash is true if the snapshot has the hash etcd appends to snapshots, which was checked
+	HasHash bool
+	// Keys is the number of keys in the snapshot, including all revisions
+	Keys int
+	// SnapshotPath is the path to the decompressed snapshot, which can be restored by etcdutl
+	SnapshotPath string
+}
+
+// Verify verifies the etcd snapshot at the given path. If expectedSize is not zero, the size of the snapshot file must
+// match it. Snapshots compressed by RKE2 and K3s are decompressed next to the snapshot. The hash etcd appends to the
+// snapshot is checked if the snapshot has one, and the integrity of the database is checked.
+func Verify(path string, expectedSize int64) (*Result, error) {
+	result := &Result{
+		SnapshotPath: path,
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	result.Size, err = io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if expectedSize != 0 && result.Size != expectedSize {
+		return nil, fmt.Errorf("snapshot has a size of %d bytes but %d bytes were recorded, it is likely truncated", result.Size, expectedSize)
+	}
+
+	magic := make([]byte, len(zipMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if bytes.Equal(magic, zipMagic) {
+		result.SnapshotPath = path + ".db"
+		if err := decompress(f, result.Size, result.SnapshotPath); err != nil {
+			return nil, fmt.Errorf("failed to decompress snapshot: %w", err)
+		}
+	}
+
+	dbPath := path + ".check"
+	defer os.Remove(dbPath)
+	result.HasHash, err = checkHash(result.SnapshotPath, dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Keys, err = checkDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decompress extracts the only file of the zip archive to the given path. The file may be at most maxCompressionRatio
+// times as large as the archive.
+func decompress(r io.ReaderAt, size int64, path string) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	if len(archive.File) != 1 {
+		return fmt.Errorf("archive has %d files instead of a single snapshot", len(archive.File))
+	}
+
+	maxSize := size * maxCompressionRatio
+	if archive.File[0].UncompressedSize64 > uint64(maxSize) {
+		return fmt.Errorf("decompressed snapshot of %d bytes is larger than the limit of %d bytes", archive.File[0].UncompressedSize64, maxSize)
+	}
+	src, err := archive.File[0].Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// the size in the archive isn't trusted, the decompressed content is limited as well
+	if err := writeFile(path, io.LimitReader(src, maxSize+1)); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() > maxSize {
+		os.Remove(path)
+		return fmt.Errorf("decompressed snapshot is larger than the limit of %d bytes", maxSize)
+	}
+	return nil
+}
+
+// checkHash checks the hash etcd appends to the snapshot, if it has one, and copies the database without the hash to
+// dbPath.
+func checkHash(snapshotPath, dbPath string) (bool, error) {
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	size := info.Size()
+	switch size % hashTrailerAlignment {
+	case 0:
+		return false, writeFile(dbPath, f)
+	case sha256.Size:
+	default:
+		return false, fmt.Errorf("snapshot database has an invalid size of %d bytes, it is likely truncated", size)
+	}
+
+	hash := sha256.New()
+	db := io.TeeReader(io.LimitReader(f, size-sha256.Size), hash)
+	if err := writeFile(dbPath, db); err != nil {
+		return false, err
+	}
+
+	expected := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(f, expected); err != nil {
+		return false, err
+	}
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		return false, fmt.Errorf("snapshot hash %x does not match the hash of the database %x", expected, hash.Sum(nil))
+	}
+	return true, nil
+}
+
+// checkDatabase checks the consistency of the etcd database and returns the number of keys in it.
+func checkDatabase(path string) (int, error) {
+	db, err := bolt.Open(path, 0400, &bolt.Options{ReadOnly: true, Timeout: 10 * time.Second})
+	if err != nil {
+		return 0, fmt.Errorf("failed to open snapshot database: %w", err)
+	}
+	defer db.Close()
+
+	var keys int
+	err = db.View(func(tx *bolt.Tx) error {
+		// The channel has to be drained, as the check keeps reading the database until it is closed.
+		var checkErr error
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+		if checkErr != nil {
+			return fmt.Errorf("snapshot database is corrupt: %w", checkErr)
+		}
+		for _, name := range etcdBuckets {
+			if tx.Bucket([]byte(name)) == nil {
+				return fmt.Errorf("snapshot database has no %s bucket, it is not an etcd database", name)
+			}
+		}
+		keys = tx.Bucket([]byte("key")).Stats().KeyN
+		return nil
+	})
+	return keys, err
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,191 @@
+package etcdverify
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newDatabase returns the contents of a bolt database with the buckets of an etcd database.
+func newDatabase(t *testing.T, buckets ...string) []byte {
+	path := filepath.Join(t.TempDir(), "db")
+	db, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			bucket, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(name+"-key"), []byte("value")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
+
+func withHash(db []byte) []byte {
+	hash := sha256.Sum256(db)
+	return append(append([]byte{}, db...), hash[:]...)
+}
+
+func compressed(t *testing.T, snapshot []byte) []byte {
+	path := filepath.Join(t.TempDir(), "snapshot.zip")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	archive := zip.NewWriter(f)
+	w, err := archive.Create("snapshot")
+	require.NoError(t, err)
+	_, err = w.Write(snapshot)
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	db := newDatabase(t, "key", "meta")
+	snapshot := withHash(db)
+	corrupt := append([]byte{}, snapshot...)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	tests := []struct {
+		name         string
+		snapshot     []byte
+		expectedSize int64
+		wantHash     bool
+		wantKeys     int
+		wantErr      string
+	}{
+		{
+			name:     "snapshot with hash",
+			snapshot: snapshot,
+			wantHash: true,
+			wantKeys: 1,
+		},
+		{
+			name:     "snapshot without hash",
+			snapshot: db,
+			wantKeys: 1,
+		},
+		{
+			name:     "compressed snapshot",
+			snapshot: compressed(t, snapshot),
+			wantHash: true,
+			wantKeys: 1,
+		},
+		{
+			name:     "compressed snapshot exceeds the compression ratio",
+			snapshot: compressed(t, make([]byte, 64<<20)),
+			wantErr:  "larger than the limit",
+		},
+		{
+			name:         "size does not match",
+			snapshot:     snapshot,
+			expectedSize: int64(len(snapshot)) + 4096,
+			wantErr:      "it is likely truncated",
+		},
+		{
+			name:     "truncated snapshot",
+			snapshot: snapshot[:len(snapshot)-100],
+			wantErr:  "snapshot database has an invalid size",
+		},
+		{
+			name:     "hash does not match",
+			snapshot: corrupt,
+			wantErr:  "does not match the hash of the database",
+		},
+		{
+			name:     "not an etcd database",
+			snapshot: withHash(newDatabase(t, "key")),
+			wantErr:  "has no meta bucket",
+		},
+		{
+			name:     "not a database",
+			snapshot: make([]byte, 4096),
+			wantErr:  "failed to open snapshot database",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot")
+			require.NoError(t, os.WriteFile(path, tt.snapshot, 0600))
+
+			result, err := Verify(path, tt.expectedSize)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.snapshot)), result.Size)
+			assert.Equal(t, tt.wantHash, result.HasHash)
+			assert.Equal(t, tt.wantKeys, result.Keys)
+			assert.Len(t, result.SHA256, 64)
+			_, err = os.Stat(result.SnapshotPath)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// TestScratchRestore saves a snapshot of a real etcd member and restores it, if etcd, etcdctl and etcdutl are in the
+// PATH.
+func TestScratchRestore(t *testing.T) {
+	etcdctl, err := exec.LookPath("etcdctl")
+	if err != nil {
+		t.Skip("etcdctl is not in the PATH")
+	}
+	etcd, err := exec.LookPath("etcd")
+	if err != nil {
+		t.Skip("etcd is not in the PATH")
+	}
+	if _, err := exec.LookPath("etcdutl"); err != nil {
+		t.Skip("etcdutl is not in the PATH")
+	}
+
+	dir := t.TempDir()
+	clientURL, err := localURL()
+	require.NoError(t, err)
+	peerURL, err := localURL()
+	require.NoError(t, err)
+	member := exec.Command(etcd, "--data-dir", filepath.Join(dir, "data"),
+		"--listen-client-urls", clientURL, "--advertise-client-urls", clientURL,
+		"--listen-peer-urls", peerURL, "--initial-advertise-peer-urls", peerURL,
+		"--initial-cluster", "default="+peerURL)
+	require.NoError(t, member.Start())
+	defer func() {
+		_ = member.Process.Kill()
+		_ = member.Wait()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.Eventually(t, func() bool { return healthy(ctx, clientURL) }, 30*time.Second, time.Second)
+
+	snapshotPath := filepath.Join(dir, "snapshot")
+	require.NoError(t, exec.Command(etcdctl, "--endpoints", clientURL, "put", "foo", "bar").Run())
+	require.NoError(t, exec.Command(etcdctl, "--endpoints", clientURL, "snapshot", "save", snapshotPath).Run())
+
+	result, err := Verify(snapshotPath, 0)
+	require.NoError(t, err)
+	assert.True(t, result.HasHash)
+	assert.NoError(t, ScratchRestore(ctx, dir, result.SnapshotPath))
+}
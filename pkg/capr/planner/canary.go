@@ -0,0 +1,133 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+)
+
+// canaryRollout holds plan changes that drain or restart nodes of a tier until they were rolled out to the canaries
+// of the tier, the canaries were healthy for the soak duration, and the rollout was approved. A nil canaryRollout lets
+// all changes through.
+type canaryRollout struct {
+	count int
+	soak  time.Duration
+	// field is the path of the canary in the spec of the control plane, to tell users how to approve the rollout
+	field string
+}
+
+// canaryState is the state of the canaries of a rollout, which is shared by the tiers the rollout spans.
+type canaryState struct {
+	canaries map[string]bool
+	// hold is the reason the changes of the other nodes are held
+	hold string
+	// soakRemaining is how long until the canaries are soaked
+	soakRemaining time.Duration
+	// held is set by the tiers when they hold the change of a node
+	held bool
+}
+
+// newCanaryRollout returns a canaryRollout if the canary is set and the rollout is not approved yet, which is the case
+// while the approved generation of the canary matches the given generation of the status.
+func newCanaryRollout(canary *rkev1.RolloutCanary, field string, statusGeneration int64) (*canaryRollout, error) {
+	if canary == nil || canary.ApprovedGeneration != statusGeneration {
+		return nil, nil
+	}
+
+	rollout := &canaryRollout{
+		count: canary.Count,
+		field: field,
+	}
+	if rollout.count < 0 {
+		return nil, fmt.Errorf("%s count %d must not be negative", field, canary.Count)
+	} else if rollout.count == 0 {
+		rollout.count = 1
+	}
+	if canary.SoakDuration != "" {
+		soak, err := time.ParseDuration(canary.SoakDuration)
+		if err != nil || soak < 0 {
+			return nil, fmt.Errorf("%s soakDuration %q must be a non-negative duration", field, canary.SoakDuration)
+		}
+		rollout.soak = soak
+	}
+	return rollout, nil
+}
+
+// state picks the canaries of each of the roles, which are the init node followed by the other nodes by name that are
+// not deleting, and determines why the changes of the other nodes are held. A node with several of the roles can be a
+// canary for each of them.
+func (c *canaryRollout) state(reconcilables []*reconcilable, roles []roleFilter, now time.Time) *canaryState {
+	var candidates []*reconcilable
+	picked := map[string]bool{}
+	for _, role := range roles {
+		var roleCandidates []*reconcilable
+		for _, r := range reconcilables {
+			if role(r.entry) && !isDeleting(r.entry) {
+				roleCandidates = append(roleCandidates, r)
+			}
+		}
+		sort.Slice(roleCandidates, func(i, j int) bool {
+			if isInitNode(roleCandidates[i].entry) != isInitNode(roleCandidates[j].entry) {
+				return isInitNode(roleCandidates[i].entry)
+			}
+			return roleCandidates[i].entry.Machine.Name < roleCandidates[j].entry.Machine.Name
+		})
+		if len(roleCandidates) > c.count {
+			roleCandidates = roleCandidates[:c.count]
+		}
+		for _, r := range roleCandidates {
+			if !picked[r.entry.Machine.Name] {
+				picked[r.entry.Machine.Name] = true
+				candidates = append(candidates, r)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].entry.Machine.Name < candidates[j].entry.Machine.Name
+	})
+
+	state := &canaryState{
+		canaries: map[string]bool{},
+	}
+	var failed, updating, soaking []string
+	for _, r := range candidates {
+		name := r.entry.Machine.Name
+		state.canaries[name] = true
+
+		if r.entry.Plan != nil && r.entry.Plan.Failed {
+			failed = append(failed, name)
+			continue
+		}
+		if r.entry.Plan == nil || r.change || r.minorChange || !r.entry.Plan.InSync || !r.entry.Plan.Healthy {
+			updating = append(updating, name)
+			continue
+		}
+		probesPassed, err := time.Parse(time.RFC3339, r.entry.Metadata.Annotations[capr.PlanProbesPassedAnnotation])
+		if err != nil {
+			// The probes have not passed since the plan was updated.
+			updating = append(updating, name)
+			continue
+		}
+		if remaining := probesPassed.Add(c.soak).Sub(now); remaining > 0 {
+			soaking = append(soaking, name)
+			if remaining > state.soakRemaining {
+				state.soakRemaining = remaining
+			}
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		state.hold = fmt.Sprintf("rollout stopped as canary node(s) %s failed", atMostThree(failed))
+	case len(updating) > 0:
+		state.hold = fmt.Sprintf("waiting for canary node(s) %s to be updated", atMostThree(updating))
+	case len(soaking) > 0:
+		state.hold = fmt.Sprintf("waiting for canary node(s) %s to be healthy for %s", atMostThree(soaking), c.soak)
+	default:
+		state.hold = fmt.Sprintf("waiting for rollout to be approved by incrementing %s.approvedGeneration", c.field)
+	}
+	return state
+}
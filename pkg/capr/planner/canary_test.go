@@ -0,0 +1,220 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestNewCanaryRollout(t *testing.T) {
+	tests := []struct {
+		name             string
+		canary           *rkev1.RolloutCanary
+		statusGeneration int64
+		want             *canaryRollout
+		wantErr          bool
+	}{
+		{
+			name: "no canary",
+		},
+		{
+			name:   "defaults",
+			canary: &rkev1.RolloutCanary{},
+			want:   &canaryRollout{count: 1, field: "upgradeStrategy.workerCanary"},
+		},
+		{
+			name:             "not approved",
+			canary:           &rkev1.RolloutCanary{Count: 2, SoakDuration: "30m", ApprovedGeneration: 3},
+			statusGeneration: 3,
+			want:             &canaryRollout{count: 2, soak: 30 * time.Minute, field: "upgradeStrategy.workerCanary"},
+		},
+		{
+			name:             "approved",
+			canary:           &rkev1.RolloutCanary{ApprovedGeneration: 4},
+			statusGeneration: 3,
+		},
+		{
+			name:    "negative count",
+			canary:  &rkev1.RolloutCanary{Count: -1},
+			wantErr: true,
+		},
+		{
+			name:    "invalid soak duration",
+			canary:  &rkev1.RolloutCanary{SoakDuration: "a while"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCanaryRollout(tt.canary, "upgradeStrategy.workerCanary", tt.statusGeneration)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCanaryRolloutState(t *testing.T) {
+	now := time.Date(2023, time.October, 11, 12, 0, 0, 0, time.UTC)
+	rollout := &canaryRollout{count: 2, soak: time.Hour, field: "upgradeStrategy.workerCanary"}
+
+	node := func(name string, inSync, healthy, failed bool, probesPassed time.Time, change bool) *reconcilable {
+		annotations := map[string]string{}
+		if !probesPassed.IsZero() {
+			annotations[capr.PlanProbesPassedAnnotation] = probesPassed.Format(time.RFC3339)
+		}
+		labels := map[string]string{capr.WorkerRoleLabel: "true"}
+		if name == "init" {
+			labels[capr.InitNodeLabel] = "true"
+		}
+		return &reconcilable{
+			entry: &planEntry{
+				Machine:  &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}},
+				Metadata: &plan.Metadata{Annotations: annotations, Labels: labels},
+				Plan:     &plan.Node{InSync: inSync, Healthy: healthy, Failed: failed},
+			},
+			change: change,
+		}
+	}
+	soaked := now.Add(-2 * time.Hour)
+	soaking := now.Add(-15 * time.Minute)
+
+	tests := []struct {
+		name              string
+		reconcilables     []*reconcilable
+		wantCanaries      map[string]bool
+		wantHold          string
+		wantSoakRemaining time.Duration
+	}{
+		{
+			name: "canaries are updating",
+			reconcilables: []*reconcilable{
+				node("c", true, true, false, soaked, true),
+				node("a", true, true, false, soaked, true),
+				node("b", false, false, false, time.Time{}, false),
+			},
+			wantCanaries: map[string]bool{"a": true, "b": true},
+			wantHold:     "waiting for canary node(s) a,b to be updated",
+		},
+		{
+			name: "canaries are soaking",
+			reconcilables: []*reconcilable{
+				node("a", true, true, false, soaked, false),
+				node("b", true, true, false, soaking, false),
+				node("c", true, true, false, soaked, true),
+			},
+			wantCanaries:      map[string]bool{"a": true, "b": true},
+			wantHold:          "waiting for canary node(s) b to be healthy for 1h0m0s",
+			wantSoakRemaining: 45 * time.Minute,
+		},
+		{
+			name: "canaries wait for approval",
+			reconcilables: []*reconcilable{
+				node("a", true, true, false, soaked, false),
+				node("b", true, true, false, soaked, false),
+				node("c", true, true, false, soaked, true),
+			},
+			wantCanaries: map[string]bool{"a": true, "b": true},
+			wantHold:     "waiting for rollout to be approved by incrementing upgradeStrategy.workerCanary.approvedGeneration",
+		},
+		{
+			name: "failed canary stops the rollout",
+			reconcilables: []*reconcilable{
+				node("a", false, false, true, time.Time{}, false),
+				node("b", false, false, false, time.Time{}, true),
+				node("c", true, true, false, soaked, true),
+			},
+			wantCanaries: map[string]bool{"a": true, "b": true},
+			wantHold:     "rollout stopped as canary node(s) a failed",
+		},
+		{
+			name: "the init node is the first canary",
+			reconcilables: []*reconcilable{
+				node("b", true, true, false, soaked, false),
+				node("c", true, true, false, soaked, false),
+				node("init", true, true, false, soaked, false),
+				node("d", true, true, false, soaked, true),
+			},
+			wantCanaries: map[string]bool{"b": true, "init": true},
+			wantHold:     "waiting for rollout to be approved by incrementing upgradeStrategy.workerCanary.approvedGeneration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rollout.state(tt.reconcilables, []roleFilter{isWorker}, now)
+			assert.Equal(t, tt.wantCanaries, got.canaries)
+			assert.Equal(t, tt.wantHold, got.hold)
+			assert.Equal(t, tt.wantSoakRemaining, got.soakRemaining)
+		})
+	}
+}
+
+func TestCanaryRolloutStatePerRole(t *testing.T) {
+	rollout := &canaryRollout{count: 1, field: "upgradeStrategy.controlPlaneCanary"}
+	node := func(name string, roles ...string) *reconcilable {
+		labels := map[string]string{}
+		for _, role := range roles {
+			labels[role] = "true"
+		}
+		return &reconcilable{
+			entry: &planEntry{
+				Machine:  &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}},
+				Metadata: &plan.Metadata{Labels: labels},
+				Plan:     &plan.Node{},
+			},
+			change: true,
+		}
+	}
+	deleting := node("a-deleting", capr.EtcdRoleLabel)
+	deleting.entry.Machine.DeletionTimestamp = &metav1.Time{}
+
+	tests := []struct {
+		name          string
+		reconcilables []*reconcilable
+		wantCanaries  map[string]bool
+	}{
+		{
+			name: "etcd and control plane nodes each have a canary",
+			reconcilables: []*reconcilable{
+				node("init", capr.EtcdRoleLabel, capr.InitNodeLabel),
+				node("etcd-2", capr.EtcdRoleLabel),
+				node("cp-1", capr.ControlPlaneRoleLabel),
+				node("cp-2", capr.ControlPlaneRoleLabel),
+			},
+			wantCanaries: map[string]bool{"init": true, "cp-1": true},
+		},
+		{
+			name: "a node of both roles is the canary of both",
+			reconcilables: []*reconcilable{
+				node("init", capr.EtcdRoleLabel, capr.ControlPlaneRoleLabel, capr.InitNodeLabel),
+				node("a", capr.EtcdRoleLabel, capr.ControlPlaneRoleLabel),
+			},
+			wantCanaries: map[string]bool{"init": true},
+		},
+		{
+			name: "deleting nodes are not canaries",
+			reconcilables: []*reconcilable{
+				deleting,
+				node("etcd-2", capr.EtcdRoleLabel),
+				node("cp-1", capr.ControlPlaneRoleLabel),
+			},
+			wantCanaries: map[string]bool{"etcd-2": true, "cp-1": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rollout.state(tt.reconcilables, []roleFilter{isEtcd, isControlPlane}, time.Now())
+			assert.Equal(t, tt.wantCanaries, got.canaries)
+		})
+	}
+}
@@ -128,6 +128,12 @@ func addRoleConfig(config map[string]interface{}, controlPlane *rkev1.RKEControl
 		config["disable-etcd"] = true
 	}
 
+	if isControlPlane(entry) && isEtcdSnapshotCloneIdentityCleanup(controlPlane) {
+		// no pods are created or scheduled until the agent credentials of the cluster that was cloned are removed
+		config["disable-scheduler"] = true
+		config["disable-controller-manager"] = true
+	}
+
 	if pr := image.GetPrivateRepoURLFromControlPlane(controlPlane); pr != "" && !isOnlyWorker(entry) {
 		config["system-default-registry"] = pr
 	}
@@ -52,19 +52,25 @@ func optionsToString(options rkev1.DrainOptions, disable bool) (string, error) {
 	return string(data), nil
 }
 
-func (p *Planner) drain(oldPlan *plan.NodePlan, newPlan plan.NodePlan, entry *planEntry, clusterPlan *plan.Plan, options rkev1.DrainOptions) (bool, error) {
+// drainNeeded returns true if changing the plan of the entry from the old to the new plan requires draining the node or
+// running drain hooks with the given options.
+func drainNeeded(oldPlan *plan.NodePlan, newPlan plan.NodePlan, entry *planEntry, clusterPlan *plan.Plan, options rkev1.DrainOptions) bool {
 	if entry == nil || entry.Metadata == nil || entry.Metadata.Annotations == nil || entry.Machine == nil || entry.Machine.Status.NodeRef == nil {
-		return true, nil
+		return false
 	}
 
 	// Short circuit if there is nothing to do, don't set annotations and move on
 	if (!options.Enabled || len(clusterPlan.Machines) == 1) &&
 		len(options.PreDrainHooks) == 0 &&
 		len(options.PostDrainHooks) == 0 {
-		return true, nil
+		return false
 	}
 
-	if !shouldDrain(oldPlan, newPlan) {
+	return shouldDrain(oldPlan, newPlan)
+}
+
+func (p *Planner) drain(oldPlan *plan.NodePlan, newPlan plan.NodePlan, entry *planEntry, clusterPlan *plan.Plan, options rkev1.DrainOptions) (bool, error) {
+	if !drainNeeded(oldPlan, newPlan, entry, clusterPlan, options) {
 		return true, nil
 	}
 
@@ -0,0 +1,312 @@
+package planner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/wrangler/pkg/name"
+	"github.com/rancher/wrangler/pkg/randomtoken"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// pendingServerTokenKey is the key of the RKE state secret that holds the server token a cloned cluster is rotated
+	// to, until the rotation completed.
+	pendingServerTokenKey = "pendingServerToken"
+
+	defaultFleetAgentNamespace = "cattle-fleet-system"
+
+	etcdRestoreCloneIdentityCleanupPath   = "clean_up_clone_identity.sh"
+	etcdRestoreCloneIdentityCleanupScript = `
+#!/bin/sh
+
+if [ -z "$KUBECTL" ]; then
+        echo "Must define KUBECTL environment variable"
+        exit 1
+fi
+
+if [ -z "$KUBECONFIG" ]; then
+        echo "Must define KUBECONFIG environment variable"
+        exit 1
+fi
+
+CLUSTERNAME="$1"
+FLEETNAMESPACE="$2"
+
+if [ -z "$CLUSTERNAME" ] || [ -z "$FLEETNAMESPACE" ]; then
+        echo "Must define cluster name and fleet agent namespace"
+        exit 1
+fi
+
+i=0
+until ${KUBECTL} get --raw=/readyz >/dev/null 2>&1; do
+        i=$((i + 1))
+        if [ $i -ge 30 ]; then
+                echo "API server did not become ready"
+                exit 1
+        fi
+        sleep 10
+done
+
+if ! SECRETS=$(${KUBECTL} get secrets -n cattle-system --no-headers -o=jsonpath='{range .items[*]}{.metadata.name}{"\n"}{end}'); then
+        echo "Error listing cattle-system secrets"
+        exit 1
+fi
+
+for SECRET in ${SECRETS}; do
+        case "${SECRET}" in
+        cattle-credentials-*)
+                OWNER=$(${KUBECTL} get secret -n cattle-system "${SECRET}" -o=jsonpath='{.data.namespace}' | base64 -d)
+                if [ "${OWNER}" != "${CLUSTERNAME}" ]; then
+                        echo "Deleting cluster agent credentials ${SECRET} of cluster ${OWNER}"
+                        ${KUBECTL} delete secret -n cattle-system "${SECRET}"
+                fi
+                ;;
+        esac
+done
+
+${KUBECTL} delete secret -n "${FLEETNAMESPACE}" fleet-agent --ignore-not-found
+`
+)
+
+// validateEtcdSnapshotClone returns true if restoring the snapshot clones the cluster it was taken from, after
+// validating that the clone was requested and authorized. The local cluster can't be cloned into.
+func (p *Planner) validateEtcdSnapshotClone(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot) (bool, error) {
+	if snapshot == nil {
+		if controlPlane.Spec.ETCDSnapshotRestore.CloneFromCluster != "" {
+			return false, fmt.Errorf("unable to clone cluster %s: etcd snapshot %s/%s not found", controlPlane.Spec.ETCDSnapshotRestore.CloneFromCluster, controlPlane.Namespace, controlPlane.Spec.ETCDSnapshotRestore.Name)
+		}
+		return false, nil
+	}
+	clone, err := capr.ValidateETCDSnapshotClone(p.secretCache, controlPlane.Namespace, controlPlane.Spec.ClusterName, controlPlane.Spec.ETCDSnapshotRestore, snapshot)
+	if err != nil || !clone {
+		return false, err
+	}
+	if controlPlane.Spec.ManagementClusterName == "local" {
+		return false, fmt.Errorf("unable to clone the local cluster from etcd snapshot %s/%s", snapshot.Namespace, snapshot.Name)
+	}
+	return true, nil
+}
+
+// waitForEtcdSnapshotCloneKubernetesVersion waits until the Kubernetes version of the snapshot has been restored to
+// the cluster if the RKE config is restored, as a new cluster is provisioned before its spec is restored.
+func waitForEtcdSnapshotCloneKubernetesVersion(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot) error {
+	restoreRKEConfig := controlPlane.Spec.ETCDSnapshotRestore.RestoreRKEConfig
+	if restoreRKEConfig == "" || restoreRKEConfig == "none" {
+		return nil
+	}
+	clusterSpec, err := capr.ParseSnapshotClusterSpecOrError(snapshot)
+	if err != nil {
+		return err
+	}
+	if clusterSpec == nil {
+		return fmt.Errorf("etcd snapshot %s/%s has no cluster spec to restore", snapshot.Namespace, snapshot.Name)
+	}
+	if clusterSpec.KubernetesVersion != controlPlane.Spec.KubernetesVersion {
+		return errWaitingf("waiting for Kubernetes version %s of etcd snapshot %s to be restored to the cluster", clusterSpec.KubernetesVersion, snapshot.Name)
+	}
+	return nil
+}
+
+// sourceServerToken returns the server token of the cluster the snapshot was taken from.
+func (p *Planner) sourceServerToken(snapshot *rkev1.ETCDSnapshot) (string, error) {
+	return capr.ETCDSnapshotSourceServerToken(p.secretCache, snapshot)
+}
+
+// ensureSourceServerToken sets the server token of the cluster to the server token of the cluster the snapshot was
+// taken from, and waits until the given tokens secret has it.
+func (p *Planner) ensureSourceServerToken(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot, tokensSecret plan.Secret) error {
+	sourceToken, err := p.sourceServerToken(snapshot)
+	if err != nil {
+		return err
+	}
+	if tokensSecret.ServerToken == sourceToken {
+		return nil
+	}
+
+	secret, err := p.secretCache.Get(controlPlane.Namespace, name.SafeConcatName(controlPlane.Name, "rke", "state"))
+	if err != nil {
+		return err
+	}
+	if string(secret.Data["serverToken"]) != sourceToken {
+		logrus.Infof("[planner] rkecluster %s/%s: using server token of cluster %s to restore etcd snapshot %s/%s", controlPlane.Namespace, controlPlane.Name, snapshot.Spec.ClusterName, snapshot.Namespace, snapshot.Name)
+		secret = secret.DeepCopy()
+		secret.Data["serverToken"] = []byte(sourceToken)
+		if _, err := p.secretClient.Update(secret); err != nil {
+			return err
+		}
+	}
+	return errWaitingf("waiting for server token of cluster %s to restore etcd snapshot %s", snapshot.Spec.ClusterName, snapshot.Name)
+}
+
+// waitForRotatedServerToken waits until the given tokens secret no longer has the server token of the cluster the
+// snapshot was taken from, so that the cluster is not restarted with it after it was rotated.
+func (p *Planner) waitForRotatedServerToken(snapshot *rkev1.ETCDSnapshot, tokensSecret plan.Secret) error {
+	sourceToken, err := p.sourceServerToken(snapshot)
+	if err != nil {
+		return err
+	}
+	if tokensSecret.ServerToken == sourceToken {
+		return errWaiting("waiting for rotated server token")
+	}
+	return nil
+}
+
+// runEtcdSnapshotCloneServerTokenRotation rotates the server token of a cloned cluster away from the server token of
+// the cluster it was cloned from. A new token is stored in the RKE state secret until the init node rotated to it, after
+// which it replaces the server token.
+func (p *Planner) runEtcdSnapshotCloneServerTokenRotation(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan) error {
+	secret, err := p.secretCache.Get(controlPlane.Namespace, name.SafeConcatName(controlPlane.Name, "rke", "state"))
+	if err != nil {
+		return err
+	}
+
+	newToken := string(secret.Data[pendingServerTokenKey])
+	if newToken == "" {
+		newToken, err = randomtoken.Generate()
+		if err != nil {
+			return err
+		}
+		secret = secret.DeepCopy()
+		secret.Data[pendingServerTokenKey] = []byte(newToken)
+		if _, err := p.secretClient.Update(secret); err != nil {
+			return err
+		}
+		return errWaiting("generated new server token for cloned cluster")
+	}
+
+	initNodes := collect(clusterPlan, isInitNode)
+	if len(initNodes) != 1 {
+		return fmt.Errorf("multiple init nodes found")
+	}
+	initNode := initNodes[0]
+
+	initNodePlan, _, err := p.desiredPlan(controlPlane, tokensSecret, initNode, "")
+	if err != nil {
+		return err
+	}
+
+	// The new token is part of the value, so that the token is rotated again if a different token is generated.
+	initNodePlan.Instructions = append(initNodePlan.Instructions, idempotentInstruction(
+		"etcd-restore/rotate-server-token",
+		fmt.Sprintf("%v-%s", controlPlane.Status.ETCDSnapshotRestore, name.Hex(newToken, 10)),
+		capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
+		[]string{
+			"token",
+			"rotate",
+			"--token",
+			tokensSecret.ServerToken,
+			"--new-token",
+			newToken,
+		},
+		[]string{}))
+	if err := assignAndCheckPlan(p.store, ETCDRestoreMessage, initNode, initNodePlan, "", 5, 5); err != nil {
+		return err
+	}
+
+	logrus.Infof("[planner] rkecluster %s/%s: rotated server token of cloned cluster", controlPlane.Namespace, controlPlane.Name)
+	secret = secret.DeepCopy()
+	secret.Data["serverToken"] = []byte(newToken)
+	delete(secret.Data, pendingServerTokenKey)
+	_, err = p.secretClient.Update(secret)
+	return err
+}
+
+// isEtcdSnapshotCloneIdentityCleanup returns true while the agent credentials of the cluster that was cloned are
+// removed from the restored datastore. Until then the cluster is started without the scheduler and the controller
+// manager on its control plane nodes, so no pod that could authenticate as the other cluster is created or scheduled.
+func isEtcdSnapshotCloneIdentityCleanup(controlPlane *rkev1.RKEControlPlane) bool {
+	return controlPlane.Status.ETCDSnapshotRestorePhase == rkev1.ETCDSnapshotPhaseRemoveSourceIdentity
+}
+
+// runEtcdSnapshotCloneIdentityCleanupPlan starts the restored cluster without scheduling workloads, and removes the agent
+// credentials of the cluster it was cloned from on the first control plane node.
+func (p *Planner) runEtcdSnapshotCloneIdentityCleanupPlan(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan) error {
+	initNodes := collect(clusterPlan, isInitNode)
+	if len(initNodes) != 1 {
+		return fmt.Errorf("multiple init nodes found")
+	}
+	initNode := initNodes[0]
+
+	var podSelectors []string
+	if p.retrievalFunctions.SystemPodLabelSelectors != nil {
+		podSelectors = p.retrievalFunctions.SystemPodLabelSelectors(controlPlane)
+	}
+	cleanupFiles, cleanupInstructions := generateEtcdRestoreCloneIdentityCleanupFilesAndInstruction(controlPlane, podSelectors)
+
+	initNodePlan, _, err := p.desiredPlan(controlPlane, tokensSecret, initNode, "")
+	if err != nil {
+		return err
+	}
+
+	if isControlPlane(initNode) {
+		initNodePlan.Files = append(initNodePlan.Files, cleanupFiles...)
+		initNodePlan.Instructions = append(initNodePlan.Instructions, cleanupInstructions...)
+		return assignAndCheckPlan(p.store, ETCDRestoreMessage, initNode, initNodePlan, "", 5, 5)
+	}
+
+	if err := assignAndCheckPlan(p.store, ETCDRestoreMessage, initNode, initNodePlan, "", 5, 5); err != nil {
+		return err
+	}
+
+	_, joinServer, _, err := p.findInitNode(controlPlane, clusterPlan)
+	if joinServer == "" {
+		return errWaitingf("waiting for join server")
+	}
+	if err != nil {
+		return err
+	}
+
+	controlPlaneEntries := collect(clusterPlan, roleAnd(isControlPlane, roleNot(isDeleting)))
+	if len(controlPlaneEntries) == 0 {
+		return fmt.Errorf("no suitable controlplane entries found to remove the identity of cluster %s during etcd restoration", controlPlane.Spec.ETCDSnapshotRestore.CloneFromCluster)
+	}
+	controlPlaneEntry := controlPlaneEntries[0]
+
+	firstControlPlanePlan, joinedServer, err := p.desiredPlan(controlPlane, tokensSecret, controlPlaneEntry, joinServer)
+	if err != nil {
+		return err
+	}
+	firstControlPlanePlan.Files = append(firstControlPlanePlan.Files, cleanupFiles...)
+	firstControlPlanePlan.Instructions = append(firstControlPlanePlan.Instructions, cleanupInstructions...)
+	return assignAndCheckPlan(p.store, ETCDRestoreMessage, controlPlaneEntry, firstControlPlanePlan, joinedServer, 5, 5)
+}
+
+// generateEtcdRestoreCloneIdentityCleanupFilesAndInstruction generates a file that contains a script that removes the
+// cluster agent and fleet agent credentials of the cluster the snapshot was taken from, and the instruction to run it.
+// The agents are then registered with the credentials of the cloned cluster when their pods are cleaned up.
+func generateEtcdRestoreCloneIdentityCleanupFilesAndInstruction(controlPlane *rkev1.RKEControlPlane, podSelectors []string) ([]plan.File, []plan.OneTimeInstruction) {
+	kubectl, kubeconfig := capr.GetKubectlAndKubeconfigPaths(controlPlane.Spec.KubernetesVersion)
+	if kubectl == "" || kubeconfig == "" {
+		return nil, nil
+	}
+
+	fleetAgentNamespace := defaultFleetAgentNamespace
+	for _, podSelector := range podSelectors {
+		if namespace, labelSelector, usable := strings.Cut(podSelector, ":"); usable && labelSelector == "app=fleet-agent" {
+			fleetAgentNamespace = namespace
+		}
+	}
+
+	return []plan.File{
+		{
+			Content: base64.StdEncoding.EncodeToString([]byte(etcdRestoreCloneIdentityCleanupScript)),
+			Path:    etcdRestoreScriptPath(controlPlane, etcdRestoreCloneIdentityCleanupPath),
+			Dynamic: true,
+		},
+	}, []plan.OneTimeInstruction{
+		idempotentInstruction(
+			"etcd-restore/clone-identity-cleanup",
+			fmt.Sprintf("%v", controlPlane.Status.ETCDSnapshotRestore),
+			"/bin/sh",
+			[]string{etcdRestoreScriptPath(controlPlane, etcdRestoreCloneIdentityCleanupPath), controlPlane.Spec.ManagementClusterName, fleetAgentNamespace},
+			[]string{
+				fmt.Sprintf("%s=%s", "KUBECTL", kubectl),
+				fmt.Sprintf("%s=%s", "KUBECONFIG", kubeconfig),
+			}),
+	}
+}
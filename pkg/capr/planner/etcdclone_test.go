@@ -0,0 +1,302 @@
+package planner
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newCloneControlPlane() *rkev1.RKEControlPlane {
+	controlPlane := &rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "fleet-default",
+			Name:      "staging",
+		},
+		Spec: rkev1.RKEControlPlaneSpec{
+			ETCDSnapshotRestore: &rkev1.ETCDSnapshotRestore{
+				Name:             "production-etcd-snapshot-s3",
+				Generation:       1,
+				RestoreRKEConfig: "kubernetesVersion",
+				CloneFromCluster: "production",
+			},
+			ClusterName:           "staging",
+			ManagementClusterName: "c-m-staging",
+			KubernetesVersion:     "v1.26.8+rke2r1",
+		},
+	}
+	controlPlane.Spec.ETCDSnapshotRestore.CloneAuthorization = capr.ETCDSnapshotCloneAuthorization("source", "fleet-default", "staging", controlPlane.Spec.ETCDSnapshotRestore)
+	return controlPlane
+}
+
+func newCloneSnapshot(s3 bool) *rkev1.ETCDSnapshot {
+	snapshot := &rkev1.ETCDSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "fleet-default",
+			Name:      "production-etcd-snapshot-s3",
+		},
+		Spec: rkev1.ETCDSnapshotSpec{
+			ClusterName: "production",
+		},
+	}
+	if s3 {
+		snapshot.SnapshotFile.S3 = &rkev1.ETCDSnapshotS3{Bucket: "snapshots"}
+	}
+	return snapshot
+}
+
+func newStateSecret(namespace, name, serverToken string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Data: map[string][]byte{
+			"serverToken": []byte(serverToken),
+			"agentToken":  []byte("agent"),
+		},
+		Type: capr.SecretTypeClusterState,
+	}
+}
+
+func TestValidateEtcdSnapshotClone(t *testing.T) {
+	tests := []struct {
+		name         string
+		controlPlane func(*rkev1.RKEControlPlane)
+		snapshot     func(*rkev1.ETCDSnapshot)
+		readsToken   bool
+		wantClone    bool
+		wantErr      string
+	}{
+		{
+			name:       "authorized clone",
+			readsToken: true,
+			wantClone:  true,
+		},
+		{
+			name: "own snapshot",
+			controlPlane: func(cp *rkev1.RKEControlPlane) {
+				cp.Spec.ETCDSnapshotRestore.CloneFromCluster = ""
+			},
+			snapshot: func(snapshot *rkev1.ETCDSnapshot) {
+				snapshot.Spec.ClusterName = "staging"
+			},
+		},
+		{
+			name: "own snapshot requested as clone",
+			snapshot: func(snapshot *rkev1.ETCDSnapshot) {
+				snapshot.Spec.ClusterName = "staging"
+			},
+			wantErr: "not from cluster production",
+		},
+		{
+			name: "clone not requested",
+			controlPlane: func(cp *rkev1.RKEControlPlane) {
+				cp.Spec.ETCDSnapshotRestore.CloneFromCluster = ""
+				cp.Spec.ETCDSnapshotRestore.CloneAuthorization = ""
+			},
+			wantErr: "requires a clone of cluster production to be requested",
+		},
+		{
+			name: "clone of another cluster requested",
+			controlPlane: func(cp *rkev1.RKEControlPlane) {
+				cp.Spec.ETCDSnapshotRestore.CloneFromCluster = "development"
+			},
+			wantErr: "requires a clone of cluster production to be requested",
+		},
+		{
+			name: "clone not authorized",
+			controlPlane: func(cp *rkev1.RKEControlPlane) {
+				cp.Spec.ETCDSnapshotRestore.CloneAuthorization = ""
+			},
+			readsToken: true,
+			wantErr:    "has not been authorized",
+		},
+		{
+			name: "authorization of another restore",
+			controlPlane: func(cp *rkev1.RKEControlPlane) {
+				cp.Spec.ETCDSnapshotRestore.Generation++
+			},
+			readsToken: true,
+			wantErr:    "has not been authorized",
+		},
+		{
+			name: "local snapshot",
+			snapshot: func(snapshot *rkev1.ETCDSnapshot) {
+				snapshot.SnapshotFile.S3 = nil
+			},
+			wantErr: "only snapshots stored in S3",
+		},
+		{
+			name: "local cluster",
+			controlPlane: func(cp *rkev1.RKEControlPlane) {
+				cp.Spec.ManagementClusterName = "local"
+			},
+			readsToken: true,
+			wantErr:    "unable to clone the local cluster",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := newMockPlanner(t, InfoFunctions{})
+			if tt.readsToken {
+				mp.secretCache.EXPECT().Get("fleet-default", "production-rke-state").Return(newStateSecret("fleet-default", "production-rke-state", "source"), nil)
+			}
+			controlPlane := newCloneControlPlane()
+			if tt.controlPlane != nil {
+				tt.controlPlane(controlPlane)
+			}
+			snapshot := newCloneSnapshot(true)
+			if tt.snapshot != nil {
+				tt.snapshot(snapshot)
+			}
+
+			clone, err := mp.planner.validateEtcdSnapshotClone(controlPlane, snapshot)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantClone, clone)
+		})
+	}
+}
+
+func TestEnsureSourceServerToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokensSecret plan.Secret
+		stateToken   string
+		wantUpdate   bool
+		wantWaiting  bool
+	}{
+		{
+			name:         "source token in use",
+			tokensSecret: plan.Secret{ServerToken: "source"},
+		},
+		{
+			name:         "source token is set",
+			tokensSecret: plan.Secret{ServerToken: "clone"},
+			stateToken:   "clone",
+			wantUpdate:   true,
+			wantWaiting:  true,
+		},
+		{
+			name:         "source token was set",
+			tokensSecret: plan.Secret{ServerToken: "clone"},
+			stateToken:   "source",
+			wantWaiting:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := newMockPlanner(t, InfoFunctions{})
+			mp.secretCache.EXPECT().Get("fleet-default", "production-rke-state").Return(newStateSecret("fleet-default", "production-rke-state", "source"), nil)
+			if tt.stateToken != "" {
+				mp.secretCache.EXPECT().Get("fleet-default", "staging-rke-state").Return(newStateSecret("fleet-default", "staging-rke-state", tt.stateToken), nil)
+			}
+			if tt.wantUpdate {
+				mp.secretClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+					assert.Equal(t, "source", string(secret.Data["serverToken"]))
+					assert.Equal(t, "agent", string(secret.Data["agentToken"]))
+					return secret, nil
+				})
+			}
+
+			err := mp.planner.ensureSourceServerToken(newCloneControlPlane(), newCloneSnapshot(true), tt.tokensSecret)
+			if tt.wantWaiting {
+				assert.True(t, IsErrWaiting(err), "expected waiting error, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRunEtcdSnapshotCloneServerTokenRotationGeneratesToken(t *testing.T) {
+	mp := newMockPlanner(t, InfoFunctions{})
+	mp.secretCache.EXPECT().Get("fleet-default", "staging-rke-state").Return(newStateSecret("fleet-default", "staging-rke-state", "source"), nil)
+	mp.secretClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		assert.Equal(t, "source", string(secret.Data["serverToken"]))
+		assert.NotEmpty(t, secret.Data[pendingServerTokenKey])
+		return secret, nil
+	})
+
+	err := mp.planner.runEtcdSnapshotCloneServerTokenRotation(newCloneControlPlane(), plan.Secret{ServerToken: "source"}, &plan.Plan{})
+	assert.True(t, IsErrWaiting(err), "expected waiting error, got %v", err)
+}
+
+func TestGenerateEtcdRestoreCloneIdentityCleanupFilesAndInstruction(t *testing.T) {
+	controlPlane := newCloneControlPlane()
+
+	files, instructions := generateEtcdRestoreCloneIdentityCleanupFilesAndInstruction(controlPlane, []string{
+		"cattle-system:app=cattle-cluster-agent",
+		"custom-fleet-system:app=fleet-agent",
+	})
+	require.Len(t, files, 1)
+	require.Len(t, instructions, 1)
+
+	script, err := base64.StdEncoding.DecodeString(files[0].Content)
+	require.NoError(t, err)
+	assert.Equal(t, etcdRestoreCloneIdentityCleanupScript, string(script))
+	assert.Equal(t, "/var/lib/rancher/rke2/capr/etcd-restore/bin/clean_up_clone_identity.sh", files[0].Path)
+
+	assert.Contains(t, instructions[0].Args, "c-m-staging")
+	assert.Contains(t, instructions[0].Args, "custom-fleet-system")
+	assert.Contains(t, instructions[0].Env, "KUBECONFIG=/etc/rancher/rke2/rke2.yaml")
+
+	_, instructions = generateEtcdRestoreCloneIdentityCleanupFilesAndInstruction(controlPlane, nil)
+	require.Len(t, instructions, 1)
+	assert.Contains(t, instructions[0].Args, defaultFleetAgentNamespace)
+}
+
+func TestEtcdSnapshotCloneIdentityCleanupStartsNoWorkloads(t *testing.T) {
+	tests := []struct {
+		name          string
+		phase         rkev1.ETCDSnapshotPhase
+		wantWorkloads bool
+	}{
+		{
+			name:  "source identity is removed",
+			phase: rkev1.ETCDSnapshotPhaseRemoveSourceIdentity,
+		},
+		{
+			name:          "pods are cleaned up",
+			phase:         rkev1.ETCDSnapshotPhasePostRestorePodCleanup,
+			wantWorkloads: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := newMockPlanner(t, InfoFunctions{})
+			controlPlane := newCloneControlPlane()
+			controlPlane.Status.ETCDSnapshotRestorePhase = tt.phase
+			entry := createTestPlanEntry("linux")
+			entry.Metadata.Labels[capr.ControlPlaneRoleLabel] = "true"
+			entry.Metadata.Labels[capr.EtcdRoleLabel] = "true"
+			entry.Metadata.Labels[capr.InitNodeLabel] = "true"
+
+			config := map[string]interface{}{}
+			addRoleConfig(config, controlPlane, entry, "")
+			probes, err := mp.planner.generateProbes(controlPlane, entry, config)
+			require.NoError(t, err)
+
+			// without the controller manager and the scheduler, the agent pods of the cluster that was cloned are neither
+			// recreated nor scheduled, so they can't authenticate as that cluster before its credentials are removed
+			assert.Equal(t, !tt.wantWorkloads, config["disable-controller-manager"] == true)
+			assert.Equal(t, !tt.wantWorkloads, config["disable-scheduler"] == true)
+			assert.Contains(t, probes, "kube-apiserver")
+			_, kcmProbe := probes["kube-controller-manager"]
+			_, schedulerProbe := probes["kube-scheduler"]
+			assert.Equal(t, tt.wantWorkloads, kcmProbe)
+			assert.Equal(t, tt.wantWorkloads, schedulerProbe)
+		})
+	}
+}
@@ -756,6 +756,8 @@ func (p *Planner) forceDeleteAllDeletingEtcdMachines(cp *rkev1.RKEControlPlane,
 // Started -> When the phase is started, it gets set to shutdown
 // Shutdown -> When the phase is shutdown, it attempts to shut down etcd on all nodes (stop etcd)
 // Restore ->  When the phase is restore, it attempts to restore etcd
+// RemoveSourceIdentity -> When the cluster is cloned from the snapshot of another cluster, the agent credentials of that cluster are removed before any workload is scheduled
+// RotateServerToken -> When the cluster is cloned from the snapshot of another cluster, the server token of that cluster is rotated
 // Finished -> When the phase is finished, Restore returns nil.
 func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan, currentVersion *semver.Version) (rkev1.RKEControlPlaneStatus, error) {
 	if cp.Spec.ETCDSnapshotRestore == nil || cp.Spec.ETCDSnapshotRestore.Name == "" {
@@ -771,6 +773,11 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		return status, err
 	}
 
+	clone, err := p.validateEtcdSnapshotClone(cp, snapshot)
+	if err != nil {
+		return status, err
+	}
+
 	// validate the snapshot can be restored by checking to see if the snapshot version is < 1.25.x and the current version is 1.25 or newer.
 	if snapshot != nil {
 		clusterSpec, err := capr.ParseSnapshotClusterSpecOrError(snapshot)
@@ -797,6 +804,14 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		status, _ = p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseShutdown)
 		return status, errWaitingf("shutting down cluster")
 	case rkev1.ETCDSnapshotPhaseShutdown:
+		if clone {
+			if err := waitForEtcdSnapshotCloneKubernetesVersion(cp, snapshot); err != nil {
+				return status, err
+			}
+			if err := p.ensureSourceServerToken(cp, snapshot, tokensSecret); err != nil {
+				return status, err
+			}
+		}
 		if err = p.runEtcdRestoreServiceStop(cp, snapshot, tokensSecret, clusterPlan); err != nil {
 			return status, err
 		}
@@ -806,10 +821,23 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		status, _ = p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseRestore)
 		return status, errWaiting("cluster shutdown complete, running etcd restore")
 	case rkev1.ETCDSnapshotPhaseRestore:
+		if clone {
+			if err := p.ensureSourceServerToken(cp, snapshot, tokensSecret); err != nil {
+				return status, err
+			}
+		}
 		if err = p.runEtcdSnapshotRestorePlan(cp, snapshot, cp.Spec.ETCDSnapshotRestore.Name, tokensSecret, clusterPlan); err != nil {
 			return status, err
 		}
 		status.ConfigGeneration++ // Increment config generation to cause the restart_stamp to change
+		if clone {
+			return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseRemoveSourceIdentity)
+		}
+		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhasePostRestorePodCleanup)
+	case rkev1.ETCDSnapshotPhaseRemoveSourceIdentity:
+		if err = p.runEtcdSnapshotCloneIdentityCleanupPlan(cp, tokensSecret, clusterPlan); err != nil {
+			return status, err
+		}
 		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhasePostRestorePodCleanup)
 	case rkev1.ETCDSnapshotPhasePostRestorePodCleanup:
 		if err = p.runEtcdSnapshotPostRestorePodCleanupPlan(cp, tokensSecret, clusterPlan); err != nil {
@@ -830,8 +858,21 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		if err = p.runEtcdSnapshotPostRestoreNodeCleanupPlan(cp, tokensSecret, clusterPlan); err != nil {
 			return status, err
 		}
+		if clone {
+			return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseRotateServerToken)
+		}
+		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseRestartCluster)
+	case rkev1.ETCDSnapshotPhaseRotateServerToken:
+		if err = p.runEtcdSnapshotCloneServerTokenRotation(cp, tokensSecret, clusterPlan); err != nil {
+			return status, err
+		}
 		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseRestartCluster)
 	case rkev1.ETCDSnapshotPhaseRestartCluster:
+		if clone {
+			if err := p.waitForRotatedServerToken(snapshot, tokensSecret); err != nil {
+				return status, err
+			}
+		}
 		if err := p.pauseCAPICluster(cp, false); err != nil {
 			return status, err
 		}
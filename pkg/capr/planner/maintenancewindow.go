@@ -0,0 +1,143 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/robfig/cron"
+)
+
+const timeOfDayFormat = "15:04"
+
+// maintenanceHold holds plan changes that drain or restart nodes while the cluster is outside of its maintenance
+// windows. A nil hold lets all changes through.
+type maintenanceHold struct {
+	// next is when the next maintenance window opens, or zero if no window opens anymore
+	next time.Time
+	// held is set once a plan change was held by the reconcile of a tier
+	held bool
+}
+
+func (h *maintenanceHold) message() string {
+	if h.next.IsZero() {
+		return "no maintenance window is scheduled"
+	}
+	return "next maintenance window opens at " + h.next.Format(time.RFC3339)
+}
+
+// getMaintenanceHold returns a hold if the upgrade strategy has maintenance windows, none of them is open at the given
+// time and the windows are not overridden.
+func getMaintenanceHold(strategy rkev1.ClusterUpgradeStrategy, now time.Time) (*maintenanceHold, error) {
+	if len(strategy.MaintenanceWindows) == 0 {
+		return nil, nil
+	}
+	if strategy.MaintenanceWindowOverrideUntil != nil && now.Before(strategy.MaintenanceWindowOverrideUntil.Time) {
+		return nil, nil
+	}
+
+	hold := &maintenanceHold{}
+	for i, window := range strategy.MaintenanceWindows {
+		open, next, err := maintenanceWindowOpen(window, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %d: %w", i, err)
+		}
+		if open {
+			return nil, nil
+		}
+		if !next.IsZero() && (hold.next.IsZero() || next.Before(hold.next)) {
+			hold.next = next
+		}
+	}
+	return hold, nil
+}
+
+// maintenanceWindowOpen returns whether the window is open at the given time, and when it opens next otherwise.
+func maintenanceWindowOpen(window rkev1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	loc, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid time zone %s: %w", window.TimeZone, err)
+	}
+	now = now.In(loc)
+
+	if window.Schedule != "" {
+		if window.StartTime != "" || window.EndTime != "" || len(window.Days) > 0 {
+			return false, time.Time{}, fmt.Errorf("schedule can not be combined with days, startTime or endTime")
+		}
+		return scheduleWindowOpen(window, now)
+	}
+	return timeRangeWindowOpen(window, now)
+}
+
+// scheduleWindowOpen checks a window opened by a cron schedule for a duration, which is open if the schedule fired
+// within the duration before now.
+func scheduleWindowOpen(window rkev1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	schedule, err := cron.ParseStandard(window.Schedule)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid schedule %s: %w", window.Schedule, err)
+	}
+	duration, err := time.ParseDuration(window.Duration)
+	if err != nil || duration <= 0 {
+		return false, time.Time{}, fmt.Errorf("duration %q must be a positive duration", window.Duration)
+	}
+
+	if start := schedule.Next(now.Add(-duration)); !start.After(now) {
+		return true, time.Time{}, nil
+	}
+	return false, schedule.Next(now), nil
+}
+
+// timeRangeWindowOpen checks a window spanning a daily time range on the given days of the week. The window that
+// started on the previous day is checked too, as windows may end on the next day.
+func timeRangeWindowOpen(window rkev1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	start, err := time.Parse(timeOfDayFormat, window.StartTime)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("startTime %q is not a time of day in HH:MM format", window.StartTime)
+	}
+	end, err := time.Parse(timeOfDayFormat, window.EndTime)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("endTime %q is not a time of day in HH:MM format", window.EndTime)
+	}
+	days, err := parseWeekdays(window.Days)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	var next time.Time
+	for offset := -1; offset <= 7; offset++ {
+		windowStart := time.Date(now.Year(), now.Month(), now.Day()+offset, start.Hour(), start.Minute(), 0, 0, now.Location())
+		if len(days) > 0 && !days[windowStart.Weekday()] {
+			continue
+		}
+		windowEnd := time.Date(now.Year(), now.Month(), now.Day()+offset, end.Hour(), end.Minute(), 0, 0, now.Location())
+		if !windowEnd.After(windowStart) {
+			windowEnd = windowEnd.AddDate(0, 0, 1)
+		}
+		if !now.Before(windowStart) && now.Before(windowEnd) {
+			return true, time.Time{}, nil
+		}
+		if windowStart.After(now) && next.IsZero() {
+			next = windowStart
+		}
+	}
+	return false, next, nil
+}
+
+func parseWeekdays(values []string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, value := range values {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(value, day.String()) || strings.EqualFold(value, day.String()[:3]) {
+				days[day] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid day %s", value)
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,148 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetMaintenanceHold(t *testing.T) {
+	// Wednesday
+	now := time.Date(2023, time.October, 11, 23, 30, 0, 0, time.UTC)
+	overrideUntil := metav1.NewTime(now.Add(time.Hour))
+	overrideExpired := metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name     string
+		strategy rkev1.ClusterUpgradeStrategy
+		wantHold bool
+		wantNext time.Time
+		wantErr  bool
+	}{
+		{
+			name: "no windows",
+		},
+		{
+			name: "schedule window is open",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{Schedule: "0 22 * * *", Duration: "2h"},
+			}},
+		},
+		{
+			name: "schedule window is closed",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{Schedule: "0 22 * * *", Duration: "1h"},
+			}},
+			wantHold: true,
+			wantNext: time.Date(2023, time.October, 12, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "time range crossing midnight is open",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "23:00", EndTime: "02:00"},
+			}},
+		},
+		{
+			name: "time range crossing midnight from the previous day has ended",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "22:00", EndTime: "01:00", Days: []string{"Tue"}},
+			}},
+			wantHold: true,
+			wantNext: time.Date(2023, time.October, 17, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "time range on other days",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "22:00", EndTime: "23:59", Days: []string{"saturday", "Sun"}},
+			}},
+			wantHold: true,
+			wantNext: time.Date(2023, time.October, 14, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "time range in time zone",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "01:00", EndTime: "03:00", TimeZone: "Europe/Berlin"},
+			}},
+		},
+		{
+			name: "earliest window is next",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "04:00", EndTime: "05:00"},
+				{StartTime: "02:00", EndTime: "03:00"},
+			}},
+			wantHold: true,
+			wantNext: time.Date(2023, time.October, 12, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "override",
+			strategy: rkev1.ClusterUpgradeStrategy{
+				MaintenanceWindows:             []rkev1.MaintenanceWindow{{StartTime: "02:00", EndTime: "03:00"}},
+				MaintenanceWindowOverrideUntil: &overrideUntil,
+			},
+		},
+		{
+			name: "expired override",
+			strategy: rkev1.ClusterUpgradeStrategy{
+				MaintenanceWindows:             []rkev1.MaintenanceWindow{{StartTime: "02:00", EndTime: "03:00"}},
+				MaintenanceWindowOverrideUntil: &overrideExpired,
+			},
+			wantHold: true,
+			wantNext: time.Date(2023, time.October, 12, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "invalid schedule",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{Schedule: "every night", Duration: "1h"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "schedule without duration",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{Schedule: "0 22 * * *"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "schedule with time range",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{Schedule: "0 22 * * *", Duration: "1h", StartTime: "22:00"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "invalid day",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "22:00", EndTime: "23:00", Days: []string{"someday"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "invalid time zone",
+			strategy: rkev1.ClusterUpgradeStrategy{MaintenanceWindows: []rkev1.MaintenanceWindow{
+				{StartTime: "22:00", EndTime: "23:00", TimeZone: "Nowhere/Special"},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hold, err := getMaintenanceHold(tt.strategy, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if !tt.wantHold {
+				assert.Nil(t, hold)
+				return
+			}
+			require.NotNil(t, hold)
+			assert.True(t, tt.wantNext.Equal(hold.next), "expected next window at %s, got %s", tt.wantNext, hold.next)
+		})
+	}
+}
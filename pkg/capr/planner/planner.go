@@ -11,6 +11,7 @@
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/moby/locker"
@@ -137,10 +138,15 @@ type InfoFunctions struct {
 	SystemPodLabelSelectors func(plane *rkev1.RKEControlPlane) []string
 }
 
-func New(ctx context.Context, clients *wrangler.Context, functions InfoFunctions) *Planner {
+// RegisterIndexers adds the indexers the planner relies on. It must be called once before planners are created, as a
+// planner is created both for the controllers and for the plan preview API.
+func RegisterIndexers(clients *wrangler.Context) {
 	clients.Mgmt.ClusterRegistrationToken().Cache().AddIndexer(clusterRegToken, func(obj *v3.ClusterRegistrationToken) ([]string, error) {
 		return []string{obj.Spec.ClusterName}, nil
 	})
+}
+
+func New(ctx context.Context, clients *wrangler.Context, functions InfoFunctions) *Planner {
 	store := NewStore(clients.Core.Secret(),
 		clients.CAPI.Machine().Cache())
 	return &Planner{
@@ -357,6 +363,8 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		firstIgnoreError                             error
 		controlPlaneDrainOptions, workerDrainOptions rkev1.DrainOptions
 		controlPlaneConcurrency, workerConcurrency   string
+		hold                                         *maintenanceHold
+		controlPlaneCanary, workerCanary             *canaryRollout
 	)
 
 	if !ignoreDrainAndConcurrency {
@@ -364,13 +372,28 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		workerDrainOptions = cp.Spec.UpgradeStrategy.WorkerDrainOptions
 		controlPlaneConcurrency = cp.Spec.UpgradeStrategy.ControlPlaneConcurrency
 		workerConcurrency = cp.Spec.UpgradeStrategy.WorkerConcurrency
+		// Maintenance windows hold plan changes that would restart nodes, but never hold an etcd restore.
+		hold, err = getMaintenanceHold(cp.Spec.UpgradeStrategy, time.Now())
+		if err != nil {
+			return status, err
+		}
+		controlPlaneCanary, err = newCanaryRollout(cp.Spec.UpgradeStrategy.ControlPlaneCanary, "upgradeStrategy.controlPlaneCanary", status.ControlPlaneCanaryGeneration)
+		if err != nil {
+			return status, err
+		}
+		workerCanary, err = newCanaryRollout(cp.Spec.UpgradeStrategy.WorkerCanary, "upgradeStrategy.workerCanary", status.WorkerCanaryGeneration)
+		if err != nil {
+			return status, err
+		}
 	}
 
-	// select all etcd and then filter to just initNodes so that unavailable count is correct
+	// select all etcd and then filter to just initNodes so that unavailable count is correct. The init node is always
+	// the first canary of the control plane rollout, so its changes are never held.
 	err = p.reconcile(cp, clusterSecretTokens, plan, true, bootstrapTier, isEtcd, isNotInitNodeOrIsDeleting,
 		"1", "",
-		controlPlaneDrainOptions)
+		controlPlaneDrainOptions, hold, nil)
 	capr.Bootstrapped.True(&status)
+	controlPlaneRolledOut := err == nil
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
 		return status, err
@@ -387,10 +410,18 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		}
 	}
 
+	// The control plane rollout spans the etcd and control plane tiers, and has canaries of both roles, so that the
+	// nodes of neither role are updated before a canary of the role was.
+	controlPlaneCanaries, err := p.canaryState(cp, clusterSecretTokens, plan, controlPlaneCanary, []roleFilter{isEtcd, isControlPlane}, joinServer)
+	if err != nil {
+		return status, err
+	}
+
 	// Process all nodes that have the etcd role and are NOT an init node or deleting. Only process 1 node at a time.
 	err = p.reconcile(cp, clusterSecretTokens, plan, true, etcdTier, isEtcd, isInitNodeOrDeleting,
 		"1", joinServer,
-		controlPlaneDrainOptions)
+		controlPlaneDrainOptions, hold, controlPlaneCanaries)
+	controlPlaneRolledOut = controlPlaneRolledOut && err == nil
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
 		return status, err
@@ -399,12 +430,26 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 	// Process all nodes that have the controlplane role and are NOT an init node or deleting.
 	err = p.reconcile(cp, clusterSecretTokens, plan, true, controlPlaneTier, isControlPlane, isInitNodeOrDeleting,
 		controlPlaneConcurrency, joinServer,
-		controlPlaneDrainOptions)
+		controlPlaneDrainOptions, hold, controlPlaneCanaries)
+	controlPlaneRolledOut = controlPlaneRolledOut && err == nil
+	if controlPlaneRolledOut && cp.Spec.UpgradeStrategy.ControlPlaneCanary != nil {
+		// The rollout is complete, so the next rollout has to be approved again.
+		status.ControlPlaneCanaryGeneration = cp.Spec.UpgradeStrategy.ControlPlaneCanary.ApprovedGeneration
+	}
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
 		return status, err
 	}
 
+	// Workers are not updated while changes of the control plane are held, so that no kubelet is newer than the API
+	// servers.
+	if controlPlaneCanaries != nil && controlPlaneCanaries.held {
+		return status, errWaiting("waiting for control plane canary rollout: " + controlPlaneCanaries.hold)
+	}
+	if hold != nil && hold.held {
+		return status, errWaiting("waiting for maintenance window to update control plane: " + hold.message())
+	}
+
 	// If there are any suitable controlplane nodes with join URL annotations
 	if len(collect(plan, roleAnd(isControlPlane, roleAnd(hasJoinURL, roleNot(isDeleting))))) == 0 {
 		return status, errWaiting("waiting for control plane to be available")
@@ -416,10 +461,18 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		return status, errWaiting("marking control plane as initialized and ready")
 	}
 
+	workerCanaries, err := p.canaryState(cp, clusterSecretTokens, plan, workerCanary, []roleFilter{isOnlyWorker}, "")
+	if err != nil {
+		return status, err
+	}
+
 	// Process all nodes that are ONLY worker nodes.
 	err = p.reconcile(cp, clusterSecretTokens, plan, false, workerTier, isOnlyWorker, isInitNodeOrDeleting,
 		workerConcurrency, "",
-		workerDrainOptions)
+		workerDrainOptions, hold, workerCanaries)
+	if err == nil && cp.Spec.UpgradeStrategy.WorkerCanary != nil {
+		status.WorkerCanaryGeneration = cp.Spec.UpgradeStrategy.WorkerCanary.ApprovedGeneration
+	}
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
 		return status, err
@@ -880,11 +933,62 @@ type reconcilable struct {
 	minorChange bool
 }
 
+// newReconcilable renders the desired plan of the entry with the join URL determined from the forced join URL, and
+// determines how it differs from the current plan of the entry.
+func (p *Planner) newReconcilable(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan, entry *planEntry, forcedJoinURL string) (*reconcilable, error) {
+	joinURL, err := determineJoinURL(controlPlane, entry, clusterPlan, forcedJoinURL)
+	if err != nil {
+		return nil, err
+	}
+	plan, joinedURL, err := p.desiredPlan(controlPlane, tokensSecret, entry, joinURL)
+	if err != nil {
+		return nil, err
+	}
+	return &reconcilable{
+		entry:       entry,
+		desiredPlan: plan,
+		joinedURL:   joinedURL,
+		change:      entry.Plan != nil && !equality.Semantic.DeepEqual(entry.Plan.Plan, plan),
+		minorChange: entry.Plan != nil && minorPlanChangeDetected(entry.Plan.Plan, plan),
+	}, nil
+}
+
+// canaryState renders the desired plans of the nodes the canary rollout spans, which are the nodes with any of the
+// roles, and returns the state of its canaries. The init node is rendered without a join URL like in the bootstrap
+// tier, and the other nodes with the given join URL. It returns nil if there is no canary rollout.
+func (p *Planner) canaryState(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan, rollout *canaryRollout, roles []roleFilter, joinURL string) (*canaryState, error) {
+	if rollout == nil {
+		return nil, nil
+	}
+	var reconcilables []*reconcilable
+	for _, entry := range collect(clusterPlan, func(entry *planEntry) bool { return hasAnyRole(entry, roles) && !isDeleting(entry) }) {
+		forcedJoinURL := joinURL
+		if isInitNode(entry) {
+			forcedJoinURL = ""
+		}
+		r, err := p.newReconcilable(controlPlane, tokensSecret, clusterPlan, entry, forcedJoinURL)
+		if err != nil {
+			return nil, err
+		}
+		reconcilables = append(reconcilables, r)
+	}
+	return rollout.state(reconcilables, roles, time.Now()), nil
+}
+
+func hasAnyRole(entry *planEntry, roles []roleFilter) bool {
+	for _, role := range roles {
+		if role(entry) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan, required bool,
-	tierName string, include, exclude roleFilter, maxUnavailable string, forcedJoinURL string, drainOptions rkev1.DrainOptions) error {
+	tierName string, include, exclude roleFilter, maxUnavailable string, forcedJoinURL string, drainOptions rkev1.DrainOptions, hold *maintenanceHold, canaries *canaryState) error {
 	var (
-		ready, outOfSync, nonReady, errMachines, draining, uncordoned []string
-		messages                                                      = map[string][]string{}
+		ready, outOfSync, nonReady, errMachines, draining, uncordoned, held, awaitingCanaries []string
+		messages                                                                              = map[string][]string{}
 	)
 
 	entries := collect(clusterPlan, include)
@@ -896,23 +1000,12 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 			continue
 		}
 
-		joinURL, err := determineJoinURL(controlPlane, entry, clusterPlan, forcedJoinURL)
-		if err != nil {
-			return err
-		}
-
-		logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - rendering desired plan for machine %s/%s with join URL: (%s)", controlPlane.Namespace, controlPlane.Name, tierName, entry.Machine.Namespace, entry.Machine.Name, joinURL)
-		plan, joinedURL, err := p.desiredPlan(controlPlane, tokensSecret, entry, joinURL)
+		logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - rendering desired plan for machine %s/%s", controlPlane.Namespace, controlPlane.Name, tierName, entry.Machine.Namespace, entry.Machine.Name)
+		r, err := p.newReconcilable(controlPlane, tokensSecret, clusterPlan, entry, forcedJoinURL)
 		if err != nil {
 			return err
 		}
-		reconcilables = append(reconcilables, &reconcilable{
-			entry:       entry,
-			desiredPlan: plan,
-			joinedURL:   joinedURL,
-			change:      entry.Plan != nil && !equality.Semantic.DeepEqual(entry.Plan.Plan, plan),
-			minorChange: entry.Plan != nil && minorPlanChangeDetected(entry.Plan.Plan, plan),
-		})
+		reconcilables = append(reconcilables, r)
 	}
 
 	concurrency, unavailable, err := calculateConcurrency(maxUnavailable, reconcilables, exclude)
@@ -957,6 +1050,19 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 			if err := p.store.UpdatePlan(r.entry, r.desiredPlan, r.joinedURL, -1, 1); err != nil {
 				return err
 			}
+		} else if r.change && hold != nil && !isInDrain(r.entry) {
+			// Changes that may drain or restart the node are held until a maintenance window opens. A node that is
+			// already draining is allowed to finish so that it is not left cordoned.
+			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - holding plan change for machine %s/%s until the next maintenance window", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
+			hold.held = true
+			held = append(held, r.entry.Machine.Name)
+			messages[r.entry.Machine.Name] = append(messages[r.entry.Machine.Name], hold.message())
+		} else if r.change && canaries != nil && !canaries.canaries[r.entry.Machine.Name] && !isInDrain(r.entry) {
+			// Changes of nodes that are not canaries are held until the canaries are healthy and the rollout is approved.
+			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - holding plan change for machine %s/%s: %s", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name, canaries.hold)
+			canaries.held = true
+			awaitingCanaries = append(awaitingCanaries, r.entry.Machine.Name)
+			messages[r.entry.Machine.Name] = append(messages[r.entry.Machine.Name], canaries.hold)
 		} else if r.change {
 			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - plan for machine %s/%s did not match, appending to outOfSync", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
 			outOfSync = append(outOfSync, r.entry.Machine.Name)
@@ -1042,6 +1148,30 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 		firstError = err
 	}
 
+	// Held changes don't block the following tiers, so that the changes which aren't held there, such as minor changes
+	// and the changes of draining nodes, are still reconciled. The tier only returns an error to be ignored for them.
+	var heldErr error
+	if len(held) > 0 && !hold.next.IsZero() {
+		// errWaiting does not requeue, so the control plane is enqueued for when the window opens.
+		p.rkeControlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, time.Until(hold.next))
+	}
+	if err := p.setMachineConditionStatus(clusterPlan, held, fmt.Sprintf("waiting for maintenance window to update %s node(s) ", tierName), messages); IsErrWaiting(err) {
+		heldErr = errIgnore(err.Error())
+	} else if err != nil && firstError == nil {
+		firstError = err
+	}
+
+	if len(awaitingCanaries) > 0 && canaries.soakRemaining > 0 {
+		p.rkeControlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, canaries.soakRemaining)
+	}
+	if err := p.setMachineConditionStatus(clusterPlan, awaitingCanaries, fmt.Sprintf("waiting for canary rollout to update %s node(s) ", tierName), messages); IsErrWaiting(err) {
+		if heldErr == nil {
+			heldErr = errIgnore(err.Error())
+		}
+	} else if err != nil && firstError == nil {
+		firstError = err
+	}
+
 	// Ensure that the conditions that we control are updated.
 	if err := p.setMachineConditionStatus(clusterPlan, ready, "", nil); err != nil && firstError == nil {
 		firstError = err
@@ -1061,7 +1191,7 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 		return errIgnore("non-ready " + tierName + " machine(s) " + atMostThree(nonReady) + detailedMessage(nonReady, messages))
 	}
 
-	return nil
+	return heldErr
 }
 
 // generatePlanWithConfigFiles will generate a node plan with the corresponding config files for the entry in question.
@@ -1208,11 +1338,20 @@ func (p *Planner) ensureRKEStateSecret(controlPlane *rkev1.RKEControlPlane, newC
 		return "", plan.Secret{}, err
 	}
 
+	tokens, err := rkeStateSecretTokens(secret)
+	if err != nil {
+		return "", plan.Secret{}, err
+	}
+	return secret.Name, tokens, nil
+}
+
+// rkeStateSecretTokens returns the serverToken and agentToken of the RKE state secret of a cluster.
+func rkeStateSecretTokens(secret *corev1.Secret) (plan.Secret, error) {
 	if secret.Type != capr.SecretTypeClusterState {
-		return "", plan.Secret{}, fmt.Errorf("secret %s/%s type %s did not match expected type %s", secret.Namespace, secret.Name, secret.Type, capr.SecretTypeClusterState)
+		return plan.Secret{}, fmt.Errorf("secret %s/%s type %s did not match expected type %s", secret.Namespace, secret.Name, secret.Type, capr.SecretTypeClusterState)
 	}
 
-	return secret.Name, plan.Secret{
+	return plan.Secret{
 		ServerToken: string(secret.Data["serverToken"]),
 		AgentToken:  string(secret.Data["agentToken"]),
 	}, nil
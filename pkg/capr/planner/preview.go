@@ -0,0 +1,324 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/wrangler/pkg/name"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+const (
+	changeAdded   = "added"
+	changeChanged = "changed"
+	changeRemoved = "removed"
+)
+
+// PlanPreview lists the changes the planner would make to the plans of the machines of a cluster for a proposed
+// control plane spec.
+type PlanPreview struct {
+	Nodes []NodePlanPreview `json:"nodes,omitempty"`
+}
+
+// NodePlanPreview describes how the desired plan of a machine differs from the plan applied to it. The contents of
+// files, and the arguments and environment of instructions are left out, as they hold the tokens of the cluster.
+type NodePlanPreview struct {
+	MachineName string   `json:"machineName,omitempty"`
+	NodeName    string   `json:"nodeName,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	// Changed is true if the desired plan differs from the plan delivered to the machine.
+	Changed bool `json:"changed"`
+	// MinorChange is true if the change only touches minor files, so the plan is delivered without drain or restart.
+	MinorChange bool `json:"minorChange"`
+	// Restart is true if the restart stamp of the plan changes, which restarts the distribution on the machine.
+	Restart bool `json:"restart"`
+	// Drain is true if the machine would be drained, or its drain hooks run, before the plan is delivered.
+	Drain                bool         `json:"drain"`
+	Files                []PlanChange `json:"files,omitempty"`
+	Instructions         []PlanChange `json:"instructions,omitempty"`
+	PeriodicInstructions []PlanChange `json:"periodicInstructions,omitempty"`
+	Probes               []PlanChange `json:"probes,omitempty"`
+	Error                string       `json:"error,omitempty"`
+}
+
+// PlanChange is a file, instruction or probe that is added, changed or removed by a plan. Fields lists the changed
+// fields of changed instructions and probes.
+type PlanChange struct {
+	Name   string   `json:"name,omitempty"`
+	Change string   `json:"change,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	Minor  bool     `json:"minor,omitempty"`
+}
+
+// Preview renders the desired plan of every machine of the cluster of the control plane, which holds the proposed
+// spec, and compares it to the plan applied to the machine. It does not change any object: no init node is elected, the
+// RKE state secret is not created and no plan is delivered.
+func (p *Planner) Preview(cp *rkev1.RKEControlPlane) (*PlanPreview, error) {
+	capiCluster, err := capr.GetOwnerCAPICluster(cp, p.capiClusters)
+	if err != nil {
+		return nil, err
+	}
+	if capiCluster == nil {
+		return nil, fmt.Errorf("CAPI cluster of rkecontrolplane %s/%s does not exist", cp.Namespace, cp.Name)
+	}
+
+	clusterPlan, _, err := p.store.Load(capiCluster, cp)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens plan.Secret
+	if !cp.Spec.UnmanagedConfig {
+		secret, err := p.secretCache.Get(cp.Namespace, name.SafeConcatName(cp.Name, "rke", "state"))
+		if err != nil {
+			return nil, err
+		}
+		if tokens, err = rkeStateSecretTokens(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	// the init node is not elected here, nodes joining through it are previewed with its current join URL
+	var joinServer string
+	for _, entry := range collect(clusterPlan, isInitNode) {
+		if joinURL := entry.Metadata.Annotations[capr.JoinURLAnnotation]; joinURL != "" {
+			joinServer = joinURL
+			break
+		}
+	}
+
+	preview := &PlanPreview{}
+	for _, entry := range collect(clusterPlan, isNotDeleting) {
+		nodePreview := NodePlanPreview{
+			MachineName: entry.Machine.Name,
+			Roles:       entryRoles(entry),
+		}
+		if entry.Machine.Status.NodeRef != nil {
+			nodePreview.NodeName = entry.Machine.Status.NodeRef.Name
+		}
+
+		forcedJoinURL := ""
+		if !isInitNode(entry) && !isOnlyWorker(entry) {
+			forcedJoinURL = joinServer
+		}
+		joinURL, err := determineJoinURL(cp, entry, clusterPlan, forcedJoinURL)
+		if err != nil {
+			nodePreview.Error = err.Error()
+			preview.Nodes = append(preview.Nodes, nodePreview)
+			continue
+		}
+		desiredPlan, _, err := p.desiredPlan(cp, tokens, entry, joinURL)
+		if err != nil {
+			return nil, err
+		}
+
+		drainOptions := cp.Spec.UpgradeStrategy.ControlPlaneDrainOptions
+		if isOnlyWorker(entry) {
+			drainOptions = cp.Spec.UpgradeStrategy.WorkerDrainOptions
+		}
+		preview.Nodes = append(preview.Nodes, previewNodePlan(nodePreview, entry, clusterPlan, desiredPlan, drainOptions))
+	}
+	return preview, nil
+}
+
+// previewNodePlan fills the preview of the entry with the differences between its applied plan and the desired plan.
+// The plan is considered changed and minor the same way reconcile does, by comparing it to the delivered plan.
+func previewNodePlan(nodePreview NodePlanPreview, entry *planEntry, clusterPlan *plan.Plan, desiredPlan plan.NodePlan, drainOptions rkev1.DrainOptions) NodePlanPreview {
+	var appliedPlan *plan.NodePlan
+	if entry.Plan != nil {
+		appliedPlan = entry.Plan.AppliedPlan
+		nodePreview.Changed = !equality.Semantic.DeepEqual(entry.Plan.Plan, desiredPlan)
+		nodePreview.MinorChange = minorPlanChangeDetected(entry.Plan.Plan, desiredPlan)
+	} else {
+		nodePreview.Changed = true
+	}
+	nodePreview.Restart = shouldDrain(appliedPlan, desiredPlan)
+	nodePreview.Drain = nodePreview.Changed && !nodePreview.MinorChange && drainNeeded(appliedPlan, desiredPlan, entry, clusterPlan, drainOptions)
+
+	if appliedPlan == nil {
+		appliedPlan = &plan.NodePlan{}
+	}
+	nodePreview.Files = diffFiles(appliedPlan.Files, desiredPlan.Files)
+	nodePreview.Instructions = diffInstructions(appliedPlan.Instructions, desiredPlan.Instructions)
+	nodePreview.PeriodicInstructions = diffPeriodicInstructions(appliedPlan.PeriodicInstructions, desiredPlan.PeriodicInstructions)
+	nodePreview.Probes = diffProbes(appliedPlan.Probes, desiredPlan.Probes)
+	return nodePreview
+}
+
+func entryRoles(entry *planEntry) []string {
+	var roles []string
+	if isEtcd(entry) {
+		roles = append(roles, "etcd")
+	}
+	if isControlPlane(entry) {
+		roles = append(roles, "controlplane")
+	}
+	if isWorker(entry) {
+		roles = append(roles, "worker")
+	}
+	return roles
+}
+
+func diffFiles(old, new []plan.File) []PlanChange {
+	oldFiles := map[string]plan.File{}
+	for _, file := range old {
+		oldFiles[file.Path] = file
+	}
+	newFiles := map[string]plan.File{}
+	for _, file := range new {
+		newFiles[file.Path] = file
+	}
+
+	var changes []PlanChange
+	for _, path := range sortedUnion(oldFiles, newFiles) {
+		oldFile, inOld := oldFiles[path]
+		newFile, inNew := newFiles[path]
+		switch {
+		case !inOld:
+			changes = append(changes, PlanChange{Name: path, Change: changeAdded, Minor: newFile.Minor})
+		case !inNew:
+			changes = append(changes, PlanChange{Name: path, Change: changeRemoved, Minor: oldFile.Minor})
+		default:
+			var fields []string
+			if oldFile.Content != newFile.Content {
+				fields = append(fields, "content")
+			}
+			if oldFile.Permissions != newFile.Permissions {
+				fields = append(fields, "permissions")
+			}
+			if oldFile.Dynamic != newFile.Dynamic {
+				fields = append(fields, "dynamic")
+			}
+			if len(fields) > 0 {
+				changes = append(changes, PlanChange{Name: path, Change: changeChanged, Fields: fields, Minor: newFile.Minor})
+			}
+		}
+	}
+	return changes
+}
+
+func diffInstructions(old, new []plan.OneTimeInstruction) []PlanChange {
+	oldInstructions := map[string]plan.OneTimeInstruction{}
+	for _, instruction := range old {
+		oldInstructions[instruction.Name] = instruction
+	}
+	newInstructions := map[string]plan.OneTimeInstruction{}
+	for _, instruction := range new {
+		newInstructions[instruction.Name] = instruction
+	}
+
+	var changes []PlanChange
+	for _, instructionName := range sortedUnion(oldInstructions, newInstructions) {
+		oldInstruction, inOld := oldInstructions[instructionName]
+		newInstruction, inNew := newInstructions[instructionName]
+		switch {
+		case !inOld:
+			changes = append(changes, PlanChange{Name: instructionName, Change: changeAdded})
+		case !inNew:
+			changes = append(changes, PlanChange{Name: instructionName, Change: changeRemoved})
+		default:
+			fields := changedFields(map[string]bool{
+				"image":      oldInstruction.Image == newInstruction.Image,
+				"env":        equality.Semantic.DeepEqual(oldInstruction.Env, newInstruction.Env),
+				"args":       equality.Semantic.DeepEqual(oldInstruction.Args, newInstruction.Args),
+				"command":    oldInstruction.Command == newInstruction.Command,
+				"saveOutput": oldInstruction.SaveOutput == newInstruction.SaveOutput,
+			})
+			if len(fields) > 0 {
+				changes = append(changes, PlanChange{Name: instructionName, Change: changeChanged, Fields: fields})
+			}
+		}
+	}
+	return changes
+}
+
+func diffPeriodicInstructions(old, new []plan.PeriodicInstruction) []PlanChange {
+	oldInstructions := map[string]plan.PeriodicInstruction{}
+	for _, instruction := range old {
+		oldInstructions[instruction.Name] = instruction
+	}
+	newInstructions := map[string]plan.PeriodicInstruction{}
+	for _, instruction := range new {
+		newInstructions[instruction.Name] = instruction
+	}
+
+	var changes []PlanChange
+	for _, instructionName := range sortedUnion(oldInstructions, newInstructions) {
+		oldInstruction, inOld := oldInstructions[instructionName]
+		newInstruction, inNew := newInstructions[instructionName]
+		switch {
+		case !inOld:
+			changes = append(changes, PlanChange{Name: instructionName, Change: changeAdded})
+		case !inNew:
+			changes = append(changes, PlanChange{Name: instructionName, Change: changeRemoved})
+		default:
+			fields := changedFields(map[string]bool{
+				"image":         oldInstruction.Image == newInstruction.Image,
+				"env":           equality.Semantic.DeepEqual(oldInstruction.Env, newInstruction.Env),
+				"args":          equality.Semantic.DeepEqual(oldInstruction.Args, newInstruction.Args),
+				"command":       oldInstruction.Command == newInstruction.Command,
+				"periodSeconds": oldInstruction.PeriodSeconds == newInstruction.PeriodSeconds,
+			})
+			if len(fields) > 0 {
+				changes = append(changes, PlanChange{Name: instructionName, Change: changeChanged, Fields: fields})
+			}
+		}
+	}
+	return changes
+}
+
+func diffProbes(old, new map[string]plan.Probe) []PlanChange {
+	var changes []PlanChange
+	for _, probeName := range sortedUnion(old, new) {
+		oldProbe, inOld := old[probeName]
+		newProbe, inNew := new[probeName]
+		switch {
+		case !inOld:
+			changes = append(changes, PlanChange{Name: probeName, Change: changeAdded})
+		case !inNew:
+			changes = append(changes, PlanChange{Name: probeName, Change: changeRemoved})
+		default:
+			fields := changedFields(map[string]bool{
+				"initialDelaySeconds": oldProbe.InitialDelaySeconds == newProbe.InitialDelaySeconds,
+				"timeoutSeconds":      oldProbe.TimeoutSeconds == newProbe.TimeoutSeconds,
+				"successThreshold":    oldProbe.SuccessThreshold == newProbe.SuccessThreshold,
+				"failureThreshold":    oldProbe.FailureThreshold == newProbe.FailureThreshold,
+				"httpGet":             equality.Semantic.DeepEqual(oldProbe.HTTPGetAction, newProbe.HTTPGetAction),
+			})
+			if len(fields) > 0 {
+				changes = append(changes, PlanChange{Name: probeName, Change: changeChanged, Fields: fields})
+			}
+		}
+	}
+	return changes
+}
+
+// changedFields returns the sorted names of the fields whose values are not equal
+func changedFields(equal map[string]bool) []string {
+	var fields []string
+	for field, isEqual := range equal {
+		if !isEqual {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// sortedUnion returns the sorted keys of both maps
+func sortedUnion[T any](old, new map[string]T) []string {
+	keys := make([]string, 0, len(old)+len(new))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,143 @@
+package planner
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestPreviewNodePlan(t *testing.T) {
+	appliedPlan := plan.NodePlan{
+		Files: []plan.File{
+			{Path: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Content: "old"},
+			{Path: "/var/lib/rancher/rke2/server/manifests/rancher/addons.yaml", Content: "addons", Minor: true},
+		},
+		Instructions: []plan.OneTimeInstruction{
+			{Name: "install", Image: "rancher/system-agent-installer-rke2:v1.26.8-rke2r1", Env: []string{"RESTART_STAMP=1"}},
+		},
+		Probes: map[string]plan.Probe{
+			"kubelet": {HTTPGetAction: plan.HTTPGetAction{URL: "http://127.0.0.1:10248/healthz"}},
+		},
+	}
+	machine := func(nodeRef bool) *capi.Machine {
+		m := &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: "m-1"}}
+		if nodeRef {
+			m.Status.NodeRef = &corev1.ObjectReference{Name: "node-1"}
+		}
+		return m
+	}
+	metadata := &plan.Metadata{
+		Labels:      map[string]string{capr.WorkerRoleLabel: "true"},
+		Annotations: map[string]string{},
+	}
+	clusterPlan := &plan.Plan{Machines: map[string]*capi.Machine{"m-1": machine(true), "m-2": {}}}
+	drainOptions := rkev1.DrainOptions{Enabled: true}
+
+	tests := []struct {
+		name        string
+		entry       *planEntry
+		desiredPlan plan.NodePlan
+		want        NodePlanPreview
+	}{
+		{
+			name:        "unchanged",
+			entry:       &planEntry{Machine: machine(true), Metadata: metadata, Plan: &plan.Node{Plan: appliedPlan, AppliedPlan: &appliedPlan}},
+			desiredPlan: appliedPlan,
+			want:        NodePlanPreview{},
+		},
+		{
+			name:  "minor file change",
+			entry: &planEntry{Machine: machine(true), Metadata: metadata, Plan: &plan.Node{Plan: appliedPlan, AppliedPlan: &appliedPlan}},
+			desiredPlan: plan.NodePlan{
+				Files: []plan.File{
+					appliedPlan.Files[0],
+					{Path: "/var/lib/rancher/rke2/server/manifests/rancher/addons.yaml", Content: "new addons", Minor: true},
+				},
+				Instructions: appliedPlan.Instructions,
+				Probes:       appliedPlan.Probes,
+			},
+			want: NodePlanPreview{
+				Changed:     true,
+				MinorChange: true,
+				Files: []PlanChange{
+					{Name: "/var/lib/rancher/rke2/server/manifests/rancher/addons.yaml", Change: changeChanged, Fields: []string{"content"}, Minor: true},
+				},
+			},
+		},
+		{
+			name:  "restart with drain",
+			entry: &planEntry{Machine: machine(true), Metadata: metadata, Plan: &plan.Node{Plan: appliedPlan, AppliedPlan: &appliedPlan}},
+			desiredPlan: plan.NodePlan{
+				Files: []plan.File{
+					{Path: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Content: "new"},
+					{Path: "/etc/rancher/rke2/registries.yaml", Content: "mirrors"},
+				},
+				Instructions: []plan.OneTimeInstruction{
+					{Name: "install", Image: "rancher/system-agent-installer-rke2:v1.27.5-rke2r1", Env: []string{"RESTART_STAMP=2"}},
+				},
+				Probes: map[string]plan.Probe{
+					"kubelet": {HTTPGetAction: plan.HTTPGetAction{URL: "http://127.0.0.1:10248/healthz"}, FailureThreshold: 5},
+				},
+			},
+			want: NodePlanPreview{
+				Changed: true,
+				Restart: true,
+				Drain:   true,
+				Files: []PlanChange{
+					{Name: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Change: changeChanged, Fields: []string{"content"}},
+					{Name: "/etc/rancher/rke2/registries.yaml", Change: changeAdded},
+					{Name: "/var/lib/rancher/rke2/server/manifests/rancher/addons.yaml", Change: changeRemoved, Minor: true},
+				},
+				Instructions: []PlanChange{
+					{Name: "install", Change: changeChanged, Fields: []string{"env", "image"}},
+				},
+				Probes: []PlanChange{
+					{Name: "kubelet", Change: changeChanged, Fields: []string{"failureThreshold"}},
+				},
+			},
+		},
+		{
+			name:  "restart without node is not drained",
+			entry: &planEntry{Machine: machine(false), Metadata: metadata, Plan: &plan.Node{Plan: appliedPlan, AppliedPlan: &appliedPlan}},
+			desiredPlan: plan.NodePlan{
+				Files: appliedPlan.Files,
+				Instructions: []plan.OneTimeInstruction{
+					{Name: "install", Image: appliedPlan.Instructions[0].Image, Env: []string{"RESTART_STAMP=2"}},
+				},
+				Probes: appliedPlan.Probes,
+			},
+			want: NodePlanPreview{
+				Changed: true,
+				Restart: true,
+				Instructions: []PlanChange{
+					{Name: "install", Change: changeChanged, Fields: []string{"env"}},
+				},
+			},
+		},
+		{
+			name:  "new machine",
+			entry: &planEntry{Machine: machine(false), Metadata: metadata},
+			desiredPlan: plan.NodePlan{
+				Instructions: appliedPlan.Instructions,
+			},
+			want: NodePlanPreview{
+				Changed: true,
+				Instructions: []PlanChange{
+					{Name: "install", Change: changeAdded},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := previewNodePlan(NodePlanPreview{}, tt.entry, clusterPlan, tt.desiredPlan, drainOptions)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -126,16 +126,21 @@ func (p *Planner) generateProbes(controlPlane *rkev1.RKEControlPlane, entry *pla
 	if runtime != capr.RuntimeK3S && isEtcd(entry) {
 		probeNames = append(probeNames, "etcd")
 	}
+	// the controller manager and scheduler, and therefore the CNI, don't run while the identity of a cloned cluster is
+	// removed
+	cloneIdentityCleanup := isEtcdSnapshotCloneIdentityCleanup(controlPlane)
 	if isControlPlane(entry) {
 		probeNames = append(probeNames, "kube-apiserver")
-		probeNames = append(probeNames, "kube-controller-manager")
-		probeNames = append(probeNames, "kube-scheduler")
+		if !cloneIdentityCleanup {
+			probeNames = append(probeNames, "kube-controller-manager")
+			probeNames = append(probeNames, "kube-scheduler")
+		}
 	}
 	if !(IsOnlyEtcd(entry) && runtime == capr.RuntimeK3S) {
 		// k3s doesn't run the kubelet on etcd only nodes
 		probeNames = append(probeNames, "kubelet")
 	}
-	if !IsOnlyEtcd(entry) && isCalico(controlPlane, runtime) && roleNot(windows)(entry) {
+	if !IsOnlyEtcd(entry) && isCalico(controlPlane, runtime) && roleNot(windows)(entry) && !cloneIdentityCleanup {
 		probeNames = append(probeNames, "calico")
 	}
 
@@ -147,7 +152,7 @@ func (p *Planner) generateProbes(controlPlane *rkev1.RKEControlPlane, entry *pla
 
 	loopbackAddress := capr.GetLoopbackAddress(controlPlane)
 
-	if isControlPlane(entry) {
+	if isControlPlane(entry) && !cloneIdentityCleanup {
 		kcmProbe, err := renderSecureProbe(config[KubeControllerManagerArg], probes["kube-controller-manager"], runtime, loopbackAddress, DefaultKubeControllerManagerDefaultSecurePort, DefaultKubeControllerManagerCertDir, DefaultKubeControllerManagerCert)
 		if err != nil {
 			return probes, err
@@ -32,6 +32,8 @@
 	"github.com/rancher/rancher/pkg/catalogv2/git"
 	"github.com/rancher/rancher/pkg/catalogv2/helm"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
+	"github.com/rancher/rancher/pkg/catalogv2/provenance"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/settings"
 	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
@@ -44,15 +46,16 @@
 )
 
 // Manager is a struct that provides a set of functionalities to interact with Helm repositories.
-// It is primarily used to create an index file of a Helm repository (represented as a ClusterRepo custom resource),
+// It is primarily used to create an index file of a Helm repository (represented as a ClusterRepo or Repo custom resource),
 // retrieve chart information and chart icons from the index file.
 //
 // The Manager struct uses clientsets provided by the wrangler library
-// to interact with the Kubernetes API and quickly fetch instances of ConfigMaps, Secrets, and ClusterRepos.
+// to interact with the Kubernetes API and quickly fetch instances of ConfigMaps, Secrets, ClusterRepos and Repos.
 type Manager struct {
 	configMaps   corecontrollers.ConfigMapCache      // clientset cache for ConfigMaps.
 	secrets      corecontrollers.SecretCache         // clientset cache for Secrets.
 	clusterRepos catalogcontrollers.ClusterRepoCache // clientset cache for ClusterRepo custom resources.
+	repos        catalogcontrollers.RepoCache        // clientset cache for Repo custom resources.
 	discovery    discovery.DiscoveryInterface        // An interface to the Kubernetes Discovery API. Provides information about the Kubernetes API server.
 	IndexCache   map[string]indexCache               // cache for Helm repository index files. Used to store and retrieve index files for faster access.
 	lock         sync.RWMutex                        // read-write mutex used to ensure that some Manager's operations are thread-safe.
@@ -72,17 +75,25 @@ type repoDef struct {
 	status   *v1.RepoStatus     // The current status of the repository, including its commit SHA and the name of the ConfigMap that holds its index
 }
 
+// publicOnlyIPs returns true for namespaced repos, which can be created by project members, so that their charts and
+// icons are only downloaded from public IPs.
+func (r repoDef) publicOnlyIPs() bool {
+	return r.metadata.Namespace != ""
+}
+
 // NewManager creates a new pointer for Manager struct
 func NewManager(
 	discovery discovery.DiscoveryInterface,
 	configMaps corecontrollers.ConfigMapCache,
 	secrets corecontrollers.SecretCache,
-	clusterRepos catalogcontrollers.ClusterRepoCache) *Manager {
+	clusterRepos catalogcontrollers.ClusterRepoCache,
+	repos catalogcontrollers.RepoCache) *Manager {
 	return &Manager{
 		discovery:    discovery,
 		configMaps:   configMaps,
 		secrets:      secrets,
 		clusterRepos: clusterRepos,
+		repos:        repos,
 		IndexCache:   map[string]indexCache{},
 	}
 }
@@ -101,6 +112,14 @@ func (c *Manager) Index(namespace, name, targetK8sVersion string, skipFilter boo
 	if err != nil {
 		return nil, err
 	}
+	// the index of a Repo must be in its namespace, and the index of any repo must be owned by it, so that a repo cannot
+	// serve the index of another one
+	if r.metadata.Namespace != "" && cm.Namespace != r.metadata.Namespace {
+		return nil, validation.Unauthorized
+	}
+	if len(cm.OwnerReferences) == 0 || cm.OwnerReferences[0].UID != r.metadata.UID {
+		return nil, validation.Unauthorized
+	}
 	var k8sVersion *semver.Version
 	if targetK8sVersion != "" {
 		k8sVersion, err = semver.NewVersion(targetK8sVersion)
@@ -123,10 +142,6 @@ func (c *Manager) Index(namespace, name, targetK8sVersion string, skipFilter boo
 	}
 	c.lock.RUnlock()
 
-	if len(cm.OwnerReferences) == 0 || cm.OwnerReferences[0].UID != r.metadata.UID {
-		return nil, validation.Unauthorized
-	}
-
 	data, err := c.readBytes(cm)
 	if err != nil {
 		return nil, err
@@ -183,12 +198,17 @@ func (c *Manager) Icon(namespace, name, chartName, version string) (io.ReadClose
 		return git.Icon(namespace, name, repo.status.URL, chart)
 	}
 
+	// charts in OCI registries can only have icons hosted elsewhere
+	if !isHTTP(chart.Icon) && oci.IsOCI(repo.status.URL) {
+		return nil, "", fmt.Errorf("failed to find icon of chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+
 	secret, err := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
 	if err != nil {
 		return nil, "", err
 	}
 
-	return helmhttp.Icon(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.spec.DisableSameOriginCheck, chart)
+	return helmhttp.Icon(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.spec.DisableSameOriginCheck, repo.publicOnlyIPs(), chart)
 }
 
 // Chart retrieves a specific Helm chart from a Helm repository.
@@ -231,7 +251,87 @@ func (c *Manager) Chart(namespace, name, chartName, version string, skipFilter b
 		return nil, err
 	}
 
-	return helmhttp.Chart(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.spec.DisableSameOriginCheck, chart)
+	if oci.IsOCI(repo.status.URL) {
+		return oci.Chart(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.publicOnlyIPs(), chart)
+	}
+
+	return helmhttp.Chart(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.spec.DisableSameOriginCheck, repo.publicOnlyIPs(), chart)
+}
+
+// Verify verifies the signature of the chart archive of a chart version of the repository with the keys of its keyring
+// secret, so that only signed charts are installed from repositories which have one.
+//
+// Charts of OCI repositories are verified with their cosign signature and the "cosign.pub" key of the secret,
+// while charts of other repositories are verified with their .prov file and the "keyring" key of the secret.
+//
+// The function returns nil if the repository has no keyring secret, and an error if the chart couldn't be verified.
+func (c *Manager) Verify(namespace, name, chartName, version string, chart []byte) (*v1.ChartVerification, error) {
+	repo, err := c.getRepo(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	keyringSecret, err := catalogv2.GetKeyringSecret(c.secrets, repo.spec, repo.metadata.Namespace)
+	if err != nil || keyringSecret == nil {
+		return nil, err
+	}
+
+	index, err := c.Index(namespace, name, "", true)
+	if err != nil {
+		return nil, err
+	}
+	chartVersion, err := index.Get(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &v1.ChartVerification{
+		Chart:   chartVersion.Name,
+		Version: chartVersion.Version,
+		Digest:  provenance.Digest(chart),
+	}
+
+	if oci.IsOCI(repo.status.URL) {
+		publicKey, ok := keyringSecret.Data[provenance.CosignKey]
+		if !ok {
+			return nil, fmt.Errorf("keyring secret %s has no %s key", keyringSecret.Name, provenance.CosignKey)
+		}
+		secret, err := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if err := oci.VerifyChart(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.publicOnlyIPs(), chartVersion, chart, publicKey); err != nil {
+			return nil, err
+		}
+		verification.Method = "cosign"
+		return verification, nil
+	}
+
+	keyring, ok := keyringSecret.Data[provenance.KeyringKey]
+	if !ok {
+		return nil, fmt.Errorf("keyring secret %s has no %s key", keyringSecret.Name, provenance.KeyringKey)
+	}
+	var (
+		prov      []byte
+		chartFile string
+	)
+	if repo.status.Commit != "" {
+		prov, chartFile, err = git.Provenance(namespace, name, repo.status.URL, chartVersion)
+	} else {
+		secret, secretErr := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
+		if secretErr != nil {
+			return nil, secretErr
+		}
+		prov, chartFile, err = helmhttp.Provenance(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.spec.DisableSameOriginCheck, repo.publicOnlyIPs(), chartVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	verification.Signer, err = provenance.VerifyProvenance(keyring, chartFile, chart, prov)
+	if err != nil {
+		return nil, err
+	}
+	verification.Method = "provenance"
+	return verification, nil
 }
 
 // Info retrieves detailed information about a specific Helm chart from a Helm repository.
@@ -254,11 +354,8 @@ func (c *Manager) Info(namespace, name, chartName, version string) (*types.Chart
 	return helm.InfoFromTarball(chart)
 }
 
-// getRepo returns a cluster repository based on the name
-//
-// namespace should never be empty
-//
-// getRepo will get ClusterRepo struct defined for catalog.cattle.io, convert it to repoDef and return it
+// getRepo returns the ClusterRepo with the name if the namespace is empty, or the Repo with the name in the namespace
+// otherwise, converted to a repoDef
 func (c *Manager) getRepo(namespace, name string) (repoDef, error) {
 	if namespace == "" {
 		cr, err := c.clusterRepos.Get(name)
@@ -273,7 +370,16 @@ func (c *Manager) getRepo(namespace, name string) (repoDef, error) {
 		}, nil
 	}
 
-	panic("namespace should never be empty")
+	r, err := c.repos.Get(namespace, name)
+	if err != nil {
+		return repoDef{}, err
+	}
+	return repoDef{
+		typedata: &r.TypeMeta,
+		metadata: &r.ObjectMeta,
+		spec:     &r.Spec,
+		status:   &r.Status,
+	}, nil
 }
 
 // readBytes returns its "content" as a byte slice, concatenated with the content of any ConfigMaps linked to it via the "catalog.cattle.io/next" annotation.
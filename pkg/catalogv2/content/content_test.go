@@ -1,15 +1,24 @@
 package content
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/golang/mock/gomock"
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
 	"github.com/stretchr/testify/assert"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestFilterReleasesSemver(t *testing.T) {
@@ -520,3 +529,48 @@ func TestFilteringReleaseKubeVersionAnnotation(t *testing.T) {
 		})
 	}
 }
+
+func TestIndexOfRepo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	configMaps := fake.NewMockCacheInterface[*corev1.ConfigMap](ctrl)
+	repos := fake.NewMockCacheInterface[*v1.Repo](ctrl)
+	contentManager := NewManager(nil, configMaps, nil, nil, repos)
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	index := repo.NewIndexFile()
+	assert.NoError(t, index.MustAdd(&chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "1.0.0"}, "demo-1.0.0.tgz", "https://charts.example.com", ""))
+	assert.NoError(t, json.NewEncoder(gz).Encode(index))
+	assert.NoError(t, gz.Close())
+	indexConfigMap := func(namespace string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "charts-0-uid",
+				Namespace:       namespace,
+				ResourceVersion: "1",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Repo", Name: "charts", UID: "uid"}},
+			},
+			BinaryData: map[string][]byte{"content": buf.Bytes()},
+		}
+	}
+
+	repos.EXPECT().Get("team-a", "charts").Return(&v1.Repo{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "charts", UID: "uid"},
+		Status:     v1.RepoStatus{IndexConfigMapNamespace: "team-a", IndexConfigMapName: "charts-0-uid"},
+	}, nil)
+	configMaps.EXPECT().Get("team-a", "charts-0-uid").Return(indexConfigMap("team-a"), nil)
+
+	result, err := contentManager.Index("team-a", "charts", "v1.27.0", true)
+	assert.NoError(t, err)
+	assert.Len(t, result.Entries["demo"], 1)
+
+	// a repo cannot serve an index from another namespace, even if it owns it
+	repos.EXPECT().Get("team-b", "charts").Return(&v1.Repo{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "charts", UID: "uid"},
+		Status:     v1.RepoStatus{IndexConfigMapNamespace: "team-a", IndexConfigMapName: "charts-0-uid"},
+	}, nil)
+	configMaps.EXPECT().Get("team-a", "charts-0-uid").Return(indexConfigMap("team-a"), nil)
+
+	_, err = contentManager.Index("team-b", "charts", "v1.27.0", true)
+	assert.ErrorIs(t, err, validation.Unauthorized)
+}
@@ -54,6 +54,30 @@ func Chart(namespace, name, gitURL string, chartVersion *repo.ChartVersion) (io.
 	return archive.Open()
 }
 
+// Provenance returns the .prov file next to the chart archive of a signed chart, and the name of the file of the
+// archive, which is what the provenance file refers to it by. Charts stored as directories can't be signed, as their
+// archives are created when they are downloaded.
+func Provenance(namespace, name, gitURL string, chartVersion *repo.ChartVersion) ([]byte, string, error) {
+	dir := gitDir(namespace, name, gitURL)
+
+	if len(chartVersion.URLs) == 0 {
+		return nil, "", fmt.Errorf("failed to find chartName %s version %s: %w", chartVersion.Name, chartVersion.Version, validation.NotFound)
+	}
+
+	file, err := relative(dir, gitURL, chartVersion.URLs[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if s, err := os.Stat(file); err != nil {
+		return nil, "", err
+	} else if s.IsDir() {
+		return nil, "", fmt.Errorf("chartName %s version %s is not a packaged chart and can't have a provenance file", chartVersion.Name, chartVersion.Version)
+	}
+
+	data, err := os.ReadFile(file + ".prov")
+	return data, filepath.Base(file), err
+}
+
 func relative(base, publicURL, path string) (string, error) {
 	if strings.HasPrefix(path, publicURL) {
 		path = path[len(publicURL):]
@@ -105,6 +105,17 @@ func gitForRepo(secret *corev1.Secret, namespace, name, gitURL string, insecureS
 	if err != nil {
 		return nil, fmt.Errorf("%w: only http(s) or ssh:// supported", err)
 	}
+	// namespaced repos can be created by project members, so they may only be cloned from public hosts
+	var resolve string
+	publicOnlyIPs := namespace != ""
+	if publicOnlyIPs {
+		if isGitSSH(gitURL) {
+			return nil, fmt.Errorf("invalid git URL: %s: only http(s) is supported for namespaced repos", gitURL)
+		}
+		if resolve, err = publicResolve(gitURL); err != nil {
+			return nil, err
+		}
+	}
 
 	dir := gitDir(namespace, name, gitURL)
 	headers := map[string]string{}
@@ -121,5 +132,7 @@ func gitForRepo(secret *corev1.Secret, namespace, name, gitURL string, insecureS
 		Headers:           headers,
 		InsecureTLSVerify: insecureSkipTLS,
 		CABundle:          caBundle,
+		PublicOnlyIPs:     publicOnlyIPs,
+		Resolve:           resolve,
 	})
 }
@@ -29,6 +29,12 @@ type Options struct {
 	CABundle          []byte
 	InsecureTLSVerify bool
 	Headers           map[string]string
+	// PublicOnlyIPs is set for the repos whose URL was checked to be public, so that redirects which could lead
+	// elsewhere aren't followed.
+	PublicOnlyIPs bool
+	// Resolve is the <host>:<port>:<address> entry which pins the host of the URL to the address it was checked to
+	// resolve to, so that git doesn't resolve it again when connecting to it.
+	Resolve string
 }
 
 type git struct {
@@ -41,6 +47,8 @@ type git struct {
 	secret            *corev1.Secret
 	headers           map[string]string
 	knownHosts        []byte
+	publicOnlyIPs     bool
+	resolve           string
 }
 
 func newGit(directory, url string, opts *Options) (*git, error) {
@@ -55,6 +63,8 @@ func newGit(directory, url string, opts *Options) (*git, error) {
 		insecureTLSVerify: opts.InsecureTLSVerify,
 		secret:            opts.Credential,
 		headers:           opts.Headers,
+		publicOnlyIPs:     opts.PublicOnlyIPs,
+		resolve:           opts.Resolve,
 	}
 	return g, g.setCredential(opts.Credential)
 }
@@ -288,7 +298,14 @@ func (g *git) gitOutput(args ...string) (string, error) {
 
 func (g *git) gitCmd(output io.Writer, args ...string) error {
 	kv := fmt.Sprintf("credential.helper=%s", `/bin/sh -c 'echo "password=$GIT_PASSWORD"'`)
-	cmd := exec.Command("git", append([]string{"-c", kv}, args...)...)
+	config := []string{"-c", kv}
+	if g.publicOnlyIPs {
+		config = append(config, "-c", "http.followRedirects=false")
+	}
+	if g.resolve != "" {
+		config = append(config, "-c", "http.curloptResolve="+g.resolve)
+	}
+	cmd := exec.Command("git", append(config, args...)...)
 	cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_PASSWORD=%s", g.password))
 	stderrBuf := &bytes.Buffer{}
 	cmd.Stderr = stderrBuf
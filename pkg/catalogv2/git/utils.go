@@ -13,6 +13,7 @@
 	"strings"
 
 	"github.com/pkg/errors"
+	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
 )
 
 const (
@@ -78,6 +79,32 @@ func validateURL(gitURL string) error {
 	return nil
 }
 
+// publicResolve checks that the host of the http(s) git URL only resolves to public IPs, and returns the entry which
+// pins it to one of them, so that the host can't resolve to a non-public IP when git connects to it. The entry isn't
+// used when git connects through a proxy, which resolves the host itself.
+func publicResolve(gitURL string) (string, error) {
+	u, err := url.Parse(gitURL)
+	if err != nil {
+		return "", err
+	}
+	ip, err := helmhttp.ResolvePublicHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	address := ip.String()
+	if ip.To4() == nil {
+		address = "[" + address + "]"
+	}
+	return fmt.Sprintf("%s:%s:%s", u.Hostname(), port, address), nil
+}
+
 func hash(gitURL string) string {
 	b := sha256.Sum256([]byte(gitURL))
 	return hex.EncodeToString(b[:])
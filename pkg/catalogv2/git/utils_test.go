@@ -35,6 +35,31 @@ func Test_isGitSSH(t *testing.T) {
 	}
 }
 
+func Test_publicResolve(t *testing.T) {
+	testCases := []struct {
+		gitURL      string
+		expected    string
+		expectedErr string
+	}{
+		{gitURL: "https://8.8.8.8/user/repo.git", expected: "8.8.8.8:443:8.8.8.8"},
+		{gitURL: "http://8.8.8.8/user/repo.git", expected: "8.8.8.8:80:8.8.8.8"},
+		{gitURL: "https://8.8.8.8:8443/user/repo.git", expected: "8.8.8.8:8443:8.8.8.8"},
+		{gitURL: "https://[2001:4860:4860::8888]/user/repo.git", expected: "2001:4860:4860::8888:443:[2001:4860:4860::8888]"},
+		{gitURL: "https://127.0.0.1/user/repo.git", expectedErr: "non-public address 127.0.0.1"},
+		{gitURL: "https://10.43.0.1/user/repo.git", expectedErr: "non-public address 10.43.0.1"},
+	}
+	assert := assertlib.New(t)
+	for _, tc := range testCases {
+		actual, err := publicResolve(tc.gitURL)
+		if tc.expectedErr != "" {
+			assert.ErrorContainsf(err, tc.expectedErr, "testcase: %v", tc)
+			continue
+		}
+		assert.NoErrorf(err, "testcase: %v", tc)
+		assert.Equalf(tc.expected, actual, "testcase: %v", tc)
+	}
+}
+
 func Test_gitDir(t *testing.T) {
 	assert := assertlib.New(t)
 	testCases := []struct {
@@ -1,20 +1,25 @@
 package helm
 
 import (
+	"sort"
+
 	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
 )
 
 type Client struct {
 	actRun           func(*action.List) ([]*release.Release, error)
 	newList          func(*action.Configuration) *action.List
+	newHistory       func(*action.Configuration) *action.History
 	restClientGetter genericclioptions.RESTClientGetter
 }
 
 func NewClient(restClientGetter genericclioptions.RESTClientGetter) *Client {
-	return &Client{restClientGetter: restClientGetter, actRun: runAction, newList: action.NewList}
+	return &Client{restClientGetter: restClientGetter, actRun: runAction, newList: action.NewList, newHistory: action.NewHistory}
 }
 
 func (c *Client) ListReleases(namespace, name string, stateMask action.ListStates) ([]*release.Release, error) {
@@ -28,6 +33,53 @@ func (c *Client) ListReleases(namespace, name string, stateMask action.ListState
 	return c.actRun(l)
 }
 
+// History returns every stored revision of the release with the given name in the namespace, oldest first.
+func (c *Client) History(namespace, name string) ([]*release.Release, error) {
+	return c.history(c.restClientGetter, namespace, name)
+}
+
+// HistoryAs returns every stored revision of the release like History, but reads them as the user, so that the
+// releases are only returned if the user can read the secrets they are stored in.
+func (c *Client) HistoryAs(user user.Info, namespace, name string) ([]*release.Release, error) {
+	return c.history(&impersonatingGetter{RESTClientGetter: c.restClientGetter, user: user}, namespace, name)
+}
+
+func (c *Client) history(restClientGetter genericclioptions.RESTClientGetter, namespace, name string) ([]*release.Release, error) {
+	helmCfg := &action.Configuration{}
+	if err := helmCfg.Init(restClientGetter, namespace, "", logrus.Infof); err != nil {
+		return nil, err
+	}
+	releases, err := c.newHistory(helmCfg).Run(name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version < releases[j].Version
+	})
+	return releases, nil
+}
+
+// impersonatingGetter returns the config of the RESTClientGetter with the user impersonated
+type impersonatingGetter struct {
+	genericclioptions.RESTClientGetter
+	user user.Info
+}
+
+func (i *impersonatingGetter) ToRESTConfig() (*rest.Config, error) {
+	cfg, err := i.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg = rest.CopyConfig(cfg)
+	cfg.Impersonate = rest.ImpersonationConfig{
+		UserName: i.user.GetName(),
+		UID:      i.user.GetUID(),
+		Groups:   i.user.GetGroups(),
+		Extra:    i.user.GetExtra(),
+	}
+	return cfg, nil
+}
+
 func runAction(l *action.List) ([]*release.Release, error) {
 	return l.Run()
 }
@@ -15,7 +15,9 @@
 	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	"io/ioutil"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
 	testing2 "k8s.io/kubectl/pkg/cmd/testing"
 )
 
@@ -160,3 +162,65 @@ func deepCopy(dst, src interface{}) error {
 	}
 	return gob.NewDecoder(bytes.NewBuffer(buf.Bytes())).Decode(dst)
 }
+
+func TestHistory(t *testing.T) {
+	asserts := assert.New(t)
+	r, _ := registry.NewClient()
+	mockCfg := &action.Configuration{
+		Releases:       storage.Init(driver.NewMemory()),
+		KubeClient:     &kubefake.FailingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}},
+		Capabilities:   chartutil.DefaultCapabilities,
+		RegistryClient: r,
+		Log:            func(format string, v ...interface{}) {},
+	}
+	for _, rel := range []*release.Release{
+		{Name: "test", Version: 2, Info: &release.Info{Status: release.StatusDeployed}},
+		{Name: "test", Version: 1, Info: &release.Info{Status: release.StatusSuperseded}},
+		{Name: "other", Version: 1, Info: &release.Info{Status: release.StatusDeployed}},
+	} {
+		asserts.NoError(mockCfg.Releases.Create(rel))
+	}
+	client := Client{
+		restClientGetter: testing2.NewTestFactory(),
+		newHistory: func(c *action.Configuration) *action.History {
+			return action.NewHistory(mockCfg)
+		},
+	}
+
+	releases, err := client.History("", "test")
+	asserts.NoError(err)
+	if asserts.Len(releases, 2) {
+		asserts.Equal(1, releases[0].Version)
+		asserts.Equal(2, releases[1].Version)
+	}
+
+	_, err = client.History("", "missing")
+	asserts.Error(err)
+}
+
+func TestImpersonatingGetter(t *testing.T) {
+	asserts := assert.New(t)
+	factory := testing2.NewTestFactory()
+	getter := &impersonatingGetter{
+		RESTClientGetter: factory,
+		user: &user.DefaultInfo{
+			Name:   "u-abc",
+			UID:    "u-abc",
+			Groups: []string{"system:authenticated"},
+			Extra:  map[string][]string{"principalid": {"local://u-abc"}},
+		},
+	}
+
+	cfg, err := getter.ToRESTConfig()
+	asserts.NoError(err)
+	asserts.Equal(rest.ImpersonationConfig{
+		UserName: "u-abc",
+		UID:      "u-abc",
+		Groups:   []string{"system:authenticated"},
+		Extra:    map[string][]string{"principalid": {"local://u-abc"}},
+	}, cfg.Impersonate)
+
+	adminCfg, err := factory.ToRESTConfig()
+	asserts.NoError(err)
+	asserts.Empty(adminCfg.Impersonate.UserName)
+}
@@ -17,10 +17,12 @@
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
 	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
 	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
@@ -37,6 +39,8 @@
 	rbacv1controllers "github.com/rancher/wrangler/pkg/generated/controllers/rbac/v1"
 	"github.com/rancher/wrangler/pkg/name"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -149,12 +153,22 @@ type Operations struct {
 	contentManager *content.Manager                     // manager struct to retrieve information about helm repos and its charts
 	Impersonator   *podimpersonation.PodImpersonation   // the impersonator used to manage pods created using the service account of the logged in user
 	clusterRepos   catalogcontrollers.ClusterRepoClient // client for cluster repo custom resource
+	repos          catalogcontrollers.RepoClient        // client for namespaced repo custom resource
 	ops            catalogcontrollers.OperationClient   // client for operation custom resource
 	pods           corev1controllers.PodClient          // client for pod kubernetes resource
 	apps           catalogcontrollers.AppClient         // client for apps custom resource
 	roles          rbacv1controllers.RoleClient         // client for role kubernetes resource
 	roleBindings   rbacv1controllers.RoleBindingClient  // client for rolebinding kubernetes resource
 	cg             proxy.ClientGetter                   // dynamic kubernetes client factory
+	releases       HelmClient                           // client to read the stored revisions of helm releases
+}
+
+// HelmClient reads the stored revisions of helm releases
+type HelmClient interface {
+	// History returns every stored revision of the release with the given name in the namespace, oldest first
+	History(namespace, name string) ([]*release.Release, error)
+	// HistoryAs returns the stored revisions of the release like History, read as the user
+	HistoryAs(user user.Info, namespace, name string) ([]*release.Release, error)
 }
 
 // NewOperations creates a new Operations struct with all fields initialized
@@ -163,7 +177,8 @@ func NewOperations(
 	catalog catalogcontrollers.Interface,
 	rbac rbacv1controllers.Interface,
 	contentManager *content.Manager,
-	pods corev1controllers.PodClient) *Operations {
+	pods corev1controllers.PodClient,
+	releases HelmClient) *Operations {
 	return &Operations{
 		cg:             cg,
 		contentManager: contentManager,
@@ -171,10 +186,12 @@ func NewOperations(
 		Impersonator:   podimpersonation.New("helm-op", cg, time.Hour, settings.FullShellImage),
 		pods:           pods,
 		clusterRepos:   catalog.ClusterRepo(),
+		repos:          catalog.Repo(),
 		ops:            catalog.Operation(),
 		apps:           catalog.App(),
 		roleBindings:   rbac.RoleBinding(),
 		roles:          rbac.Role(),
+		releases:       releases,
 	}
 }
 
@@ -194,6 +211,62 @@ func (s *Operations) Uninstall(ctx context.Context, user user.Info, namespace, n
 	return s.createOperation(ctx, user, status, cmds, imageOverride)
 }
 
+// Rollback gets the rollback command using the given namespace, name and options and gets the user information using the isApp flag as true.
+// Returns a catalog.Operation that represents the helm operation to be created
+func (s *Operations) Rollback(ctx context.Context, user user.Info, namespace, name string, options io.Reader, imageOverride string) (*catalog.Operation, error) {
+	status, cmds, err := s.getRollbackArgs(namespace, name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err = s.getUser(user, namespace, name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createOperation(ctx, user, status, cmds, imageOverride)
+}
+
+// History receives the namespace and name of an app and returns the stored revisions of its release, newest first,
+// with the chart version and the user supplied values of each revision. The revisions are read as the user, so the
+// user must be able to read the release secrets.
+func (s *Operations) History(user user.Info, namespace, name string) (*types2.ChartHistory, error) {
+	app, err := s.apps.Get(namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := s.releases.HistoryAs(user, app.Namespace, app.Spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &types2.ChartHistory{}
+	for i := len(releases) - 1; i >= 0; i-- {
+		history.Revisions = append(history.Revisions, toReleaseRevision(releases[i]))
+	}
+	return history, nil
+}
+
+// toReleaseRevision converts a helm3 release into the revision returned by the history link of an app
+func toReleaseRevision(rel *release.Release) types2.ReleaseRevision {
+	revision := types2.ReleaseRevision{
+		Revision: rel.Version,
+		Values:   rel.Config,
+	}
+	if rel.Info != nil {
+		revision.Status = rel.Info.Status.String()
+		revision.Description = rel.Info.Description
+		revision.Updated = metav1.NewTime(rel.Info.LastDeployed.Time)
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		revision.ChartName = rel.Chart.Metadata.Name
+		revision.ChartVersion = rel.Chart.Metadata.Version
+		revision.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return revision
+}
+
 // Upgrade gets the upgrade commands using the given namespace, name and options and gets the user using the isApp flag as false.
 // Returns a catalog.Operation that represents the helm operation to be created
 func (s *Operations) Upgrade(ctx context.Context, user user.Info, namespace, name string, options io.Reader, imageOverride string) (*catalog.Operation, error) {
@@ -275,90 +348,117 @@ func (s *Operations) proxyLogRequest(rw http.ResponseWriter, req *http.Request,
 // Log receives a response writer, a http request, the namespace and name of an operation.
 // Gets the pod of the operation and proxies the request to get logs of said pod
 func (s *Operations) Log(rw http.ResponseWriter, req *http.Request, namespace, name string) error {
-	op, err := s.ops.Get(namespace, name, metav1.GetOptions{})
+	_, pod, err := s.getOperationPod(namespace, name)
 	if err != nil {
 		return err
 	}
 
-	pod, err := s.pods.Get(op.Status.PodNamespace, op.Status.PodName, metav1.GetOptions{})
+	client, err := s.cg.AdminK8sInterface()
 	if err != nil {
 		return err
 	}
 
+	return s.proxyLogRequest(rw, req, pod, client)
+}
+
+// getOperationPod receives the namespace and name of an operation.
+// Returns the operation and its pod, or a not found error if the pod doesn't belong to the operation
+func (s *Operations) getOperationPod(namespace, name string) (*catalog.Operation, *v1.Pod, error) {
+	op, err := s.ops.Get(namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pod, err := s.pods.Get(op.Status.PodNamespace, op.Status.PodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// check if the pod and op have objects depended by them and that they aren't the same
 	if len(pod.OwnerReferences) == 0 || len(op.OwnerReferences) == 0 || pod.OwnerReferences[0].UID != op.OwnerReferences[0].UID {
-		return validation.NotFound
+		return nil, nil, validation.NotFound
 	}
 
 	if pod.Labels[podimpersonation.TokenLabel] != op.Status.Token {
-		return validation.NotFound
+		return nil, nil, validation.NotFound
 	}
 
-	client, err := s.cg.AdminK8sInterface()
-	if err != nil {
-		return err
-	}
-
-	return s.proxyLogRequest(rw, req, pod, client)
+	return op, pod, nil
 }
 
 // getSpec receives the namespace and name of either an app or a repo according to the value of the isApp flag.
-// If the isApp flag is true, gets the app and then check the annotations of the chart of the release to see if it's a cluster repo and to get it's name.
+// If the isApp flag is true, gets the app and then check the annotations of the chart of the release to see which repo it
+// was installed from. Apps of a cluster repo name it, and apps of a namespaced repo name a repo in the namespace of the app.
 // Returns the found catalog.RepoSpec and doesn't return errors if the repo isn't found.
 //
-// If the isApp flag is false, gets the cluster repo directly using its name. Panics if the namespace isn't empty.
+// If the isApp flag is false, gets the cluster repo directly using its name if the namespace is empty, or the repo in the namespace otherwise.
 // Returns a catalog.RepoSec struct or an error if it's not found.
-func (s *Operations) getSpec(namespace, name string, isApp bool) (*catalog.RepoSpec, error) {
+//
+// The returned bool is true if the spec is the one of a namespaced repo.
+func (s *Operations) getSpec(namespace, name string, isApp bool) (*catalog.RepoSpec, bool, error) {
 	if isApp {
 		rel, err := s.apps.Get(namespace, name, metav1.GetOptions{})
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		if rel.Spec.Chart != nil && rel.Spec.Chart.Metadata != nil {
-			isClusterRepo := rel.Spec.Chart.Metadata.Annotations["catalog.cattle.io/ui-source-repo-type"]
-			if isClusterRepo != "cluster" {
-				return &catalog.RepoSpec{}, nil
-			}
-			clusterRepoName := rel.Spec.Chart.Metadata.Annotations["catalog.cattle.io/ui-source-repo"]
-			clusterRepo, err := s.clusterRepos.Get(clusterRepoName, metav1.GetOptions{})
-			if err != nil {
-				// don't report error if annotation doesn't exist
-				return &catalog.RepoSpec{}, nil
+			repoName := rel.Spec.Chart.Metadata.Annotations["catalog.cattle.io/ui-source-repo"]
+			switch rel.Spec.Chart.Metadata.Annotations["catalog.cattle.io/ui-source-repo-type"] {
+			case "cluster":
+				clusterRepo, err := s.clusterRepos.Get(repoName, metav1.GetOptions{})
+				if err != nil {
+					// don't report error if annotation doesn't exist
+					return &catalog.RepoSpec{}, false, nil
+				}
+				return &clusterRepo.Spec, false, nil
+			case "namespace":
+				// the repo must be in the namespace of the app
+				repo, err := s.repos.Get(namespace, repoName, metav1.GetOptions{})
+				if err != nil {
+					return &catalog.RepoSpec{}, true, nil
+				}
+				return &repo.Spec, true, nil
 			}
-			return &clusterRepo.Spec, nil
 		}
-		return &catalog.RepoSpec{}, nil
+		return &catalog.RepoSpec{}, false, nil
 	}
 	if namespace == "" {
 		clusterRepo, err := s.clusterRepos.Get(name, metav1.GetOptions{})
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		return &clusterRepo.Spec, nil
+		return &clusterRepo.Spec, false, nil
 	}
 
-	panic("namespace should be empty")
+	repo, err := s.repos.Get(namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, true, err
+	}
+	return &repo.Spec, true, nil
 }
 
 // getUser receives the user info, the namespace of the repo and the name of either an app or a repo according to the value of the isApp flag.
 // Gets the repoSpec and uses it to build a user.DefaultInfo struct with a default name and groups that will be used to create an operation in either the
 // namespace of the repo or the one given.
 // Returns a user.Info struct to create an operation.
+//
+// The service account of namespaced repos is ignored, since the users who can manage the repos of a namespace could
+// otherwise act as any service account in it, and their operations run as the user.
 func (s *Operations) getUser(userInfo user.Info, namespace, name string, isApp bool) (user.Info, error) {
-	repoSpec, err := s.getSpec(namespace, name, isApp)
+	repoSpec, namespaced, err := s.getSpec(namespace, name, isApp)
 	if err != nil {
 		return nil, err
 	}
 
-	if repoSpec.ServiceAccount == "" {
+	if repoSpec.ServiceAccount == "" || namespaced {
 		return userInfo, nil
 	}
 	serviceAccountNS := repoSpec.ServiceAccountNamespace
 	if namespace != "" {
 		serviceAccountNS = namespace
 	}
-	if serviceAccountNS == "" || strings.Contains(repoSpec.ServiceAccountNamespace, ":") {
+	if serviceAccountNS == "" || strings.Contains(serviceAccountNS, ":") {
 		return userInfo, nil
 	}
 	return &user.DefaultInfo{
@@ -402,6 +502,43 @@ func (s *Operations) getUninstallArgs(appNamespace, appName string, body io.Read
 	return status, Commands{cmd}, nil
 }
 
+// getRollbackArgs receives the app namespace, app name and body of the request.
+// Returns a rollback Command to the requested revision of the release of the app, or to the previous one if the request
+// doesn't specify a revision, and also returns the status of the operation that will be created to run the command
+func (s *Operations) getRollbackArgs(appNamespace, appName string, body io.Reader) (catalog.OperationStatus, Commands, error) {
+	rel, err := s.apps.Get(appNamespace, appName, metav1.GetOptions{})
+	if err != nil {
+		return catalog.OperationStatus{}, nil, err
+	}
+
+	rollbackArgs := &types2.ChartRollbackAction{}
+	if err := json.NewDecoder(body).Decode(rollbackArgs); err != nil {
+		return catalog.OperationStatus{}, nil, err
+	}
+	if rollbackArgs.Revision < 0 {
+		return catalog.OperationStatus{}, nil, apierror.NewAPIError(validation.InvalidBodyContent,
+			fmt.Sprintf("invalid revision %d", rollbackArgs.Revision))
+	}
+
+	cmd := Command{
+		Operation: "rollback",
+		ArgObjects: []interface{}{
+			rollbackArgs,
+		},
+		ReleaseName:      rel.Spec.Name,
+		ReleaseNamespace: rel.Namespace,
+		Revision:         rollbackArgs.Revision,
+	}
+
+	status := catalog.OperationStatus{
+		Action:    cmd.Operation,
+		Release:   rel.Spec.Name,
+		Namespace: appNamespace,
+	}
+
+	return status, Commands{cmd}, nil
+}
+
 // getUpgradeCommand receives the repository namespace and name and body of the request.
 // Returns the status of the operation that will be created and a list of Command to upgrade the charts received in the request
 func (s *Operations) getUpgradeCommand(repoNamespace, repoName string, body io.Reader) (catalog.OperationStatus, Commands, error) {
@@ -422,6 +559,7 @@ func (s *Operations) getUpgradeCommand(repoNamespace, repoName string, body io.R
 	status := catalog.OperationStatus{
 		Action:    "upgrade",
 		Namespace: namespace(upgradeArgs.Namespace),
+		DryRun:    upgradeArgs.DryRun,
 	}
 
 	for _, chartUpgrade := range upgradeArgs.Charts {
@@ -431,6 +569,7 @@ func (s *Operations) getUpgradeCommand(repoNamespace, repoName string, body io.R
 		}
 		cmd.ReleaseName = chartUpgrade.ReleaseName
 		cmd.Operation = "upgrade"
+		cmd.DryRun = upgradeArgs.DryRun
 		cmd.ArgObjects = []interface{}{
 			chartUpgrade,
 			upgradeArgs,
@@ -445,15 +584,18 @@ func (s *Operations) getUpgradeCommand(repoNamespace, repoName string, body io.R
 
 // Command represents a command that will be run inside a helm operation
 type Command struct {
-	Operation        string        // type of operation, eg upgrade, install, uninstall
-	ArgObjects       []interface{} // the arguments that will be used in the command
-	ValuesFile       string        // name of the values.yaml file
-	Values           []byte        // content of the values.yaml file
-	ChartFile        string        // name of the chart tar file
-	Chart            []byte        // content of the chart file
-	ReleaseName      string        // name of the release
-	ReleaseNamespace string        // namespace of the release
-	Kustomize        bool          // flag to inform if it should use kustomize.sh
+	Operation        string                     // type of operation, eg upgrade, install, uninstall, rollback
+	ArgObjects       []interface{}              // the arguments that will be used in the command
+	ValuesFile       string                     // name of the values.yaml file
+	Values           []byte                     // content of the values.yaml file
+	ChartFile        string                     // name of the chart tar file
+	Chart            []byte                     // content of the chart file
+	ReleaseName      string                     // name of the release
+	ReleaseNamespace string                     // namespace of the release
+	Kustomize        bool                       // flag to inform if it should use kustomize.sh
+	Revision         int                        // revision of the release to roll back to, zero for the previous one
+	DryRun           bool                       // flag to inform if the command only renders the release and prints it as JSON
+	Verification     *catalog.ChartVerification // result of the verification of the signature of the chart, if its repo requires one
 }
 
 type Commands []Command
@@ -545,6 +687,7 @@ func (c Command) renderArgs() ([]string, error) {
 	delete(dataMap, "releaseName")
 	delete(dataMap, "chartName")
 	delete(dataMap, "projectId")
+	delete(dataMap, "revision")
 	if v, ok := dataMap["disableOpenAPIValidation"]; ok {
 		delete(dataMap, "disableOpenAPIValidation")
 		dataMap["disableOpenapiValidation"] = v
@@ -574,6 +717,11 @@ func (c Command) renderArgs() ([]string, error) {
 		args = append(args, "--values="+filepath.Join(runPath, c.ValuesFile))
 	}
 
+	// the release rendered by a dry run is printed as JSON so that it can be read back from the logs of the pod
+	if c.DryRun {
+		args = append(args, "--output=json")
+	}
+
 	if c.ReleaseNamespace != "" {
 		args = append(args, "--namespace="+c.ReleaseNamespace)
 	}
@@ -582,6 +730,9 @@ func (c Command) renderArgs() ([]string, error) {
 	if c.ReleaseName != "" {
 		args = append(args, c.ReleaseName)
 	}
+	if c.Revision > 0 {
+		args = append(args, strconv.Itoa(c.Revision))
+	}
 	if len(c.Chart) > 0 {
 		args = append(args, filepath.Join(runPath, c.ChartFile))
 	}
@@ -705,16 +856,37 @@ func (s *Operations) getChartCommand(namespace, name, chartName, chartVersion st
 		return Command{}, err
 	}
 
+	// the archive is verified before annotations are injected, as that changes its digest
+	verification, err := s.contentManager.Verify(namespace, name, chartName, chartVersion, chartData)
+	if err != nil {
+		return Command{}, apierror.NewAPIError(validation.InvalidState,
+			fmt.Sprintf("failed to verify signature of chart %s version %s: %v", chartName, chartVersion, err))
+	}
+
+	chrt, err := loader.LoadArchive(bytes.NewReader(chartData))
+	if err != nil {
+		return Command{}, err
+	}
+	// helm reuses the values of the release when it is upgraded without values, so they are left alone, as the defaults
+	// of the questions would replace them and the questions would be validated against values that aren't used.
+	if !upgrade || len(values) > 0 {
+		values, err = validateValues(chrt, values)
+		if err != nil {
+			return Command{}, err
+		}
+	}
+
 	chartData, err = injectAnnotation(chartData, annotations)
 	if err != nil {
 		return Command{}, err
 	}
 
 	c := Command{
-		ValuesFile: fmt.Sprintf("values-%s-%s.yaml", chartName, sanitizeVersion(chartVersion)),
-		ChartFile:  fmt.Sprintf("%s-%s.tgz", chartName, sanitizeVersion(chartVersion)),
-		Chart:      chartData,
-		Kustomize:  s.enableKustomize(annotations, upgrade),
+		ValuesFile:   fmt.Sprintf("values-%s-%s.yaml", chartName, sanitizeVersion(chartVersion)),
+		ChartFile:    fmt.Sprintf("%s-%s.tgz", chartName, sanitizeVersion(chartVersion)),
+		Chart:        chartData,
+		Kustomize:    s.enableKustomize(annotations, upgrade),
+		Verification: verification,
 	}
 
 	if len(values) > 0 {
@@ -742,6 +914,7 @@ func (s *Operations) getInstallCommand(repoNamespace, repoName string, body io.R
 		cmds   []Command
 		status = catalog.OperationStatus{
 			Action: "install",
+			DryRun: installArgs.DryRun,
 		}
 	)
 	// Sometimes there are two charts to be installed. First one being the CRD chart
@@ -758,6 +931,7 @@ func (s *Operations) getInstallCommand(repoNamespace, repoName string, body io.R
 			installArgs,
 		}
 		cmd.ReleaseName = chartInstall.ReleaseName
+		cmd.DryRun = installArgs.DryRun
 
 		if len(installArgs.Charts) == 1 {
 			if cmd.ReleaseName == "" {
@@ -795,7 +969,7 @@ func namespace(ns string) string {
 // Uses the Operations.Impersonator and Operations.ops to do it.
 // Returns the created catalog.Operation struct
 func (s *Operations) createOperation(ctx context.Context, user user.Info, status catalog.OperationStatus, cmds Commands, imageOverride string) (*catalog.Operation, error) {
-	if status.Action != "uninstall" {
+	if status.Action != "uninstall" && !status.DryRun {
 		_, err := s.createNamespace(ctx, status.Namespace, status.ProjectID)
 		if err != nil {
 			return nil, err
@@ -826,6 +1000,12 @@ func (s *Operations) createOperation(ctx context.Context, user user.Info, status
 		return nil, err
 	}
 
+	for _, cmd := range cmds {
+		if cmd.Verification != nil {
+			status.Verifications = append(status.Verifications, *cmd.Verification)
+		}
+	}
+
 	status.Token = pod.Labels[podimpersonation.TokenLabel]
 	status.PodName = pod.Name
 	status.PodNamespace = pod.Namespace
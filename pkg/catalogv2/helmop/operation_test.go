@@ -1,11 +1,24 @@
 package helmop
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/golang/mock/gomock"
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/time"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
 )
 
 type testCase struct {
@@ -103,6 +116,42 @@ func Test_Render(t *testing.T) {
 			},
 			failMsg: "uninstall test case failed",
 		},
+		{
+			commands: Commands{
+				Command{
+					Operation: "rollback",
+					ArgObjects: []interface{}{
+						&types2.ChartRollbackAction{Revision: 3, Wait: true, Force: true},
+					},
+					ReleaseName:      "test6",
+					ReleaseNamespace: "test-ns",
+					Revision:         3,
+				},
+			},
+			expected: map[string][]byte{
+				"operation000": []byte(strings.Join([]string{"rollback", "--force=true", "--namespace=test-ns", "--wait=true", "test6", "3"}, "\x00")),
+			},
+			failMsg: "rollback test case failed",
+		},
+		{
+			commands: Commands{
+				Command{
+					Operation: "upgrade",
+					ArgObjects: []interface{}{
+						&types2.ChartUpgradeAction{DryRun: true},
+					},
+					ChartFile:   "test-chart-v1.1.0.tgz",
+					Chart:       []byte("test-chart"),
+					ReleaseName: "test7",
+					DryRun:      true,
+				},
+			},
+			expected: map[string][]byte{
+				"operation000":          []byte(strings.Join([]string{"upgrade", "--dry-run=true", "--output=json", "test7", "/home/shell/helm/test-chart-v1.1.0.tgz"}, "\x00")),
+				"test-chart-v1.1.0.tgz": []byte("test-chart"),
+			},
+			failMsg: "dry run test case failed",
+		},
 	}
 	for _, testCase := range testCases {
 		actual, err := testCase.commands.Render()
@@ -110,3 +159,170 @@ func Test_Render(t *testing.T) {
 		asserts.Equal(testCase.expected, actual, testCase.failMsg)
 	}
 }
+
+type fakeHelmClient []*release.Release
+
+func (f fakeHelmClient) History(namespace, name string) ([]*release.Release, error) {
+	var result []*release.Release
+	for _, rel := range f {
+		if rel.Namespace == namespace && rel.Name == name {
+			result = append(result, rel)
+		}
+	}
+	return result, nil
+}
+
+func (f fakeHelmClient) HistoryAs(_ user.Info, namespace, name string) ([]*release.Release, error) {
+	return f.History(namespace, name)
+}
+
+// forbiddenHelmClient is a helm client for a user that can't read the release secrets.
+type forbiddenHelmClient struct {
+	fakeHelmClient
+}
+
+func (f forbiddenHelmClient) HistoryAs(_ user.Info, namespace, _ string) ([]*release.Release, error) {
+	return nil, apierrors.NewForbidden(corev1.Resource("secrets"), namespace, errors.New("cannot list secrets"))
+}
+
+func TestHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	apps := fake.NewMockClientInterface[*catalog.App, *catalog.AppList](ctrl)
+	apps.EXPECT().Get("test-ns", "test-app", gomock.Any()).Return(&catalog.App{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-app"},
+		Spec:       catalog.ReleaseSpec{Name: "test"},
+	}, nil)
+	deployed := time.Now()
+	s := &Operations{
+		apps: apps,
+		releases: fakeHelmClient{
+			{
+				Name:      "test",
+				Namespace: "test-ns",
+				Version:   1,
+				Info:      &release.Info{Status: release.StatusSuperseded, Description: "Install complete"},
+				Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "demo", Version: "1.0.0", AppVersion: "v1"}},
+				Config:    map[string]interface{}{"replicas": 1},
+			},
+			{
+				Name:      "test",
+				Namespace: "test-ns",
+				Version:   2,
+				Info:      &release.Info{Status: release.StatusDeployed, Description: "Upgrade complete", LastDeployed: deployed},
+				Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "demo", Version: "1.1.0", AppVersion: "v2"}},
+				Config:    map[string]interface{}{"replicas": 2},
+			},
+			{Name: "other", Namespace: "test-ns", Version: 1},
+		},
+	}
+
+	history, err := s.History(&user.DefaultInfo{Name: "test-user"}, "test-ns", "test-app")
+	require.NoError(t, err)
+	require.Len(t, history.Revisions, 2)
+	assert.Equal(t, types2.ReleaseRevision{
+		Revision:     2,
+		Status:       "deployed",
+		Description:  "Upgrade complete",
+		ChartName:    "demo",
+		ChartVersion: "1.1.0",
+		AppVersion:   "v2",
+		Updated:      metav1.NewTime(deployed.Time),
+		Values:       map[string]interface{}{"replicas": 2},
+	}, history.Revisions[0])
+	assert.Equal(t, 1, history.Revisions[1].Revision)
+	assert.Equal(t, "1.0.0", history.Revisions[1].ChartVersion)
+	assert.Equal(t, "superseded", history.Revisions[1].Status)
+}
+
+func TestHistoryWithoutSecretAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	apps := fake.NewMockClientInterface[*catalog.App, *catalog.AppList](ctrl)
+	apps.EXPECT().Get("test-ns", "test-app", gomock.Any()).Return(&catalog.App{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-app"},
+		Spec:       catalog.ReleaseSpec{Name: "test"},
+	}, nil)
+	s := &Operations{
+		apps: apps,
+		releases: forbiddenHelmClient{fakeHelmClient{
+			{Name: "test", Namespace: "test-ns", Version: 1, Info: &release.Info{Status: release.StatusDeployed}},
+		}},
+	}
+
+	history, err := s.History(&user.DefaultInfo{Name: "test-user"}, "test-ns", "test-app")
+	assert.True(t, apierrors.IsForbidden(err), "expected a forbidden error, got %v", err)
+	assert.Nil(t, history)
+}
+
+func TestGetSpecOfApp(t *testing.T) {
+	app := func(repoType, repoName string) *catalog.App {
+		return &catalog.App{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-app"},
+			Spec: catalog.ReleaseSpec{
+				Chart: &catalog.Chart{Metadata: &catalog.Metadata{Annotations: map[string]string{
+					"catalog.cattle.io/ui-source-repo-type": repoType,
+					"catalog.cattle.io/ui-source-repo":      repoName,
+				}}},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		app      *catalog.App
+		expected catalog.RepoSpec
+		// namespaced is true if the spec is the one of a namespaced repo
+		namespaced bool
+		// user is the user the operations of the app run as
+		user string
+	}{
+		{
+			name:     "cluster repo",
+			app:      app("cluster", "cluster-repo"),
+			expected: catalog.RepoSpec{URL: "https://cluster.example.com", ServiceAccount: "cluster-sa"},
+			user:     "system:serviceaccount:test-ns:cluster-sa",
+		},
+		{
+			name:       "namespaced repo in the namespace of the app",
+			app:        app("namespace", "repo"),
+			expected:   catalog.RepoSpec{URL: "https://namespace.example.com", ServiceAccount: "repo-sa"},
+			namespaced: true,
+			user:       "test-user",
+		},
+		{
+			name:       "missing namespaced repo",
+			app:        app("namespace", "missing"),
+			namespaced: true,
+			user:       "test-user",
+		},
+		{
+			name: "unknown repo type",
+			app:  app("other", "repo"),
+			user: "test-user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			apps := fake.NewMockClientInterface[*catalog.App, *catalog.AppList](ctrl)
+			apps.EXPECT().Get("test-ns", "test-app", gomock.Any()).Return(tt.app, nil).Times(2)
+			clusterRepos := fake.NewMockNonNamespacedClientInterface[*catalog.ClusterRepo, *catalog.ClusterRepoList](ctrl)
+			clusterRepos.EXPECT().Get("cluster-repo", gomock.Any()).Return(&catalog.ClusterRepo{
+				Spec: catalog.RepoSpec{URL: "https://cluster.example.com", ServiceAccount: "cluster-sa"},
+			}, nil).AnyTimes()
+			repos := fake.NewMockClientInterface[*catalog.Repo, *catalog.RepoList](ctrl)
+			repos.EXPECT().Get("test-ns", "repo", gomock.Any()).Return(&catalog.Repo{
+				Spec: catalog.RepoSpec{URL: "https://namespace.example.com", ServiceAccount: "repo-sa"},
+			}, nil).AnyTimes()
+			repos.EXPECT().Get("test-ns", "missing", gomock.Any()).Return(nil, fmt.Errorf("not found")).AnyTimes()
+			s := &Operations{apps: apps, clusterRepos: clusterRepos, repos: repos}
+
+			spec, namespaced, err := s.getSpec("test-ns", "test-app", true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *spec)
+			assert.Equal(t, tt.namespaced, namespaced)
+
+			userInfo, err := s.getUser(&user.DefaultInfo{Name: "test-user"}, "test-ns", "test-app", true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.user, userInfo.GetName(), "the service account of namespaced repos isn't used")
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package helmop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/yaml"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// Preview receives the user and the namespace and name of a dry run operation. It reads the releases rendered by the
+// finished pod of the operation from its logs, and returns the objects and values each of them would add, change or
+// remove compared to the deployed revision of the release, which is read as the user.
+func (s *Operations) Preview(ctx context.Context, user user.Info, namespace, name string) (*types2.ChartPreview, error) {
+	op, pod, err := s.getOperationPod(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if !op.Status.DryRun {
+		return nil, apierror.NewAPIError(validation.InvalidAction, "operation is not a dry run")
+	}
+	if err := dryRunFinished(pod); err != nil {
+		return nil, err
+	}
+
+	client, err := s.cg.AdminK8sInterface()
+	if err != nil {
+		return nil, err
+	}
+	logs, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{Container: "helm"}).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := parseDryRunOutput(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &types2.ChartPreview{}
+	for _, rel := range releases {
+		current, err := s.deployedRelease(user, rel.Namespace, rel.Name)
+		if err != nil {
+			return nil, err
+		}
+		releasePreview, err := previewRelease(current, rel)
+		if err != nil {
+			return nil, err
+		}
+		preview.Releases = append(preview.Releases, releasePreview)
+	}
+	return preview, nil
+}
+
+// dryRunFinished returns an error unless the helm container of the pod of a dry run exited successfully
+func dryRunFinished(pod *v1.Pod) error {
+	for _, container := range pod.Status.ContainerStatuses {
+		if container.Name != "helm" || container.State.Terminated == nil {
+			continue
+		}
+		if container.State.Terminated.ExitCode != 0 {
+			return apierror.NewAPIError(validation.InvalidState, "dry run failed, see the logs of the operation")
+		}
+		return nil
+	}
+	return apierror.NewAPIError(validation.InvalidState, "dry run has not finished")
+}
+
+// parseDryRunOutput returns the releases printed as JSON by the helm commands of a dry run, in the order they ran
+func parseDryRunOutput(logs []byte) ([]*release.Release, error) {
+	var releases []*release.Release
+	for _, line := range bytes.Split(logs, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+		rel := &release.Release{}
+		if err := json.Unmarshal(line, rel); err != nil || rel.Name == "" {
+			continue
+		}
+		releases = append(releases, rel)
+	}
+	if len(releases) == 0 {
+		return nil, apierror.NewAPIError(validation.InvalidState, "output of dry run does not contain a release")
+	}
+	return releases, nil
+}
+
+// deployedRelease returns the latest deployed revision of the release, or nil if the release isn't installed yet. The
+// revisions are read as the user, so that the preview doesn't reveal releases the user can't read.
+func (s *Operations) deployedRelease(user user.Info, namespace, name string) (*release.Release, error) {
+	releases, err := s.releases.HistoryAs(user, namespace, name)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for i := len(releases) - 1; i >= 0; i-- {
+		if releases[i].Info != nil && releases[i].Info.Status == release.StatusDeployed {
+			return releases[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// previewRelease compares the objects of the manifest and the user supplied values of a release rendered by a dry run
+// with those of the current revision of the release, which is nil for new releases
+func previewRelease(current, rel *release.Release) (types2.ReleasePreview, error) {
+	preview := types2.ReleasePreview{
+		ReleaseName: rel.Name,
+		Namespace:   rel.Namespace,
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		preview.ChartName = rel.Chart.Metadata.Name
+		preview.ChartVersion = rel.Chart.Metadata.Version
+	}
+
+	var (
+		currentManifest string
+		currentValues   map[string]interface{}
+	)
+	if current != nil {
+		preview.CurrentRevision = current.Version
+		if current.Chart != nil && current.Chart.Metadata != nil {
+			preview.CurrentVersion = current.Chart.Metadata.Version
+		}
+		currentManifest = current.Manifest
+		currentValues = current.Config
+	}
+
+	before, err := manifestObjects(currentManifest)
+	if err != nil {
+		return preview, fmt.Errorf("failed to parse manifest of release %s: %w", rel.Name, err)
+	}
+	after, err := manifestObjects(rel.Manifest)
+	if err != nil {
+		return preview, fmt.Errorf("failed to parse manifest of dry run of release %s: %w", rel.Name, err)
+	}
+
+	for _, key := range sortedKeys(after) {
+		obj, ok := before[key]
+		if !ok {
+			preview.Added = append(preview.Added, after[key].change)
+			continue
+		}
+		if fields := diffFields("", obj.content, after[key].content); len(fields) > 0 {
+			change := after[key].change
+			change.Fields = fields
+			if isSecret(change) {
+				change.Fields = redactSecretFields(fields)
+			}
+			preview.Changed = append(preview.Changed, change)
+		}
+	}
+	for _, key := range sortedKeys(before) {
+		if _, ok := after[key]; !ok {
+			preview.Removed = append(preview.Removed, before[key].change)
+		}
+	}
+
+	preview.Values = diffFields("", currentValues, rel.Config)
+	return preview, nil
+}
+
+// manifestObject is an object of a rendered manifest
+type manifestObject struct {
+	change  types2.ObjectChange    // the identity of the object
+	content map[string]interface{} // the content of the object
+}
+
+// manifestObjects returns the objects of a rendered manifest indexed by their api version, kind, namespace and name
+func manifestObjects(manifest string) (map[string]manifestObject, error) {
+	objs, err := yaml.ToObjects(strings.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]manifestObject{}
+	for _, obj := range objs {
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		change := types2.ObjectChange{
+			Namespace: objMeta.GetNamespace(),
+			Name:      objMeta.GetName(),
+		}
+		change.APIVersion, change.Kind = obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+		key := strings.Join([]string{change.Kind, change.Namespace, change.Name, change.APIVersion}, "/")
+		result[key] = manifestObject{change: change, content: content}
+	}
+	return result, nil
+}
+
+// redactedValue replaces the values of secrets in a preview
+const redactedValue = "[redacted]"
+
+func isSecret(change types2.ObjectChange) bool {
+	return change.APIVersion == "v1" && change.Kind == "Secret"
+}
+
+// redactSecretFields replaces the old and new values of the data and stringData fields of a secret, so that a preview
+// shows which keys of the secret change, but not their values.
+func redactSecretFields(fields []types2.FieldChange) []types2.FieldChange {
+	result := make([]types2.FieldChange, 0, len(fields))
+	for _, field := range fields {
+		if field.Path == "data" || field.Path == "stringData" ||
+			strings.HasPrefix(field.Path, "data.") || strings.HasPrefix(field.Path, "stringData.") {
+			if field.Old != nil {
+				field.Old = redactedValue
+			}
+			if field.New != nil {
+				field.New = redactedValue
+			}
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+func sortedKeys(objs map[string]manifestObject) []string {
+	keys := make([]string, 0, len(objs))
+	for key := range objs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffFields returns the leaf fields that differ between the old and new value, descending into maps and into lists
+// of the same length. Missing maps are compared as empty maps so that each of their fields is reported.
+func diffFields(path string, oldValue, newValue interface{}) []types2.FieldChange {
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	if (oldIsMap || oldValue == nil) && (newIsMap || newValue == nil) && (oldIsMap || newIsMap) {
+		keys := map[string]bool{}
+		for k := range oldMap {
+			keys[k] = true
+		}
+		for k := range newMap {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var changes []types2.FieldChange
+		for _, k := range sorted {
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			changes = append(changes, diffFields(fieldPath, oldMap[k], newMap[k])...)
+		}
+		return changes
+	}
+
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := newValue.([]interface{})
+	if oldIsList && newIsList && len(oldList) == len(newList) {
+		var changes []types2.FieldChange
+		for i := range oldList {
+			changes = append(changes, diffFields(fmt.Sprintf("%s[%d]", path, i), oldList[i], newList[i])...)
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(oldValue, newValue) {
+		return nil
+	}
+	return []types2.FieldChange{{Path: path, Old: oldValue, New: newValue}}
+}
@@ -0,0 +1,198 @@
+package helmop
+
+import (
+	"encoding/json"
+	"testing"
+
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	currentManifest = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  mode: fast
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: demo
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: demo
+        image: demo:1.0.0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: old
+`
+	previewManifest = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  mode: fast
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: demo
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: demo
+        image: demo:1.1.0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: new
+`
+)
+
+func TestParseDryRunOutput(t *testing.T) {
+	rel, err := json.Marshal(&release.Release{Name: "demo", Namespace: "test-ns", Version: 3, Manifest: previewManifest})
+	require.NoError(t, err)
+	logs := []byte("helm upgrade --dry-run=true --output=json demo /home/shell/helm/demo-1.1.0.tgz\r\n" + string(rel) + "\r\n{not json}\r\n")
+
+	releases, err := parseDryRunOutput(logs)
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.Equal(t, "demo", releases[0].Name)
+	assert.Equal(t, previewManifest, releases[0].Manifest)
+
+	_, err = parseDryRunOutput([]byte("Error: INSTALLATION FAILED\r\n"))
+	assert.ErrorContains(t, err, "does not contain a release")
+}
+
+func TestPreviewRelease(t *testing.T) {
+	current := &release.Release{
+		Name:      "demo",
+		Namespace: "test-ns",
+		Version:   2,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "demo", Version: "1.0.0"}},
+		Manifest:  currentManifest,
+		Config:    map[string]interface{}{"replicas": float64(1), "image": map[string]interface{}{"tag": "1.0.0"}},
+	}
+	rel := &release.Release{
+		Name:      "demo",
+		Namespace: "test-ns",
+		Version:   3,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "demo", Version: "1.1.0"}},
+		Manifest:  previewManifest,
+		Config:    map[string]interface{}{"replicas": float64(2), "image": map[string]interface{}{"tag": "1.1.0"}, "debug": true},
+	}
+
+	preview, err := previewRelease(current, rel)
+	require.NoError(t, err)
+	assert.Equal(t, types2.ReleasePreview{
+		ReleaseName:     "demo",
+		Namespace:       "test-ns",
+		ChartName:       "demo",
+		ChartVersion:    "1.1.0",
+		CurrentRevision: 2,
+		CurrentVersion:  "1.0.0",
+		Added:           []types2.ObjectChange{{APIVersion: "v1", Kind: "Service", Name: "new"}},
+		Changed: []types2.ObjectChange{{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "demo",
+			Fields: []types2.FieldChange{
+				{Path: "spec.replicas", Old: int64(1), New: int64(2)},
+				{Path: "spec.template.spec.containers[0].image", Old: "demo:1.0.0", New: "demo:1.1.0"},
+			},
+		}},
+		Removed: []types2.ObjectChange{{APIVersion: "v1", Kind: "Service", Name: "old"}},
+		Values: []types2.FieldChange{
+			{Path: "debug", New: true},
+			{Path: "image.tag", Old: "1.0.0", New: "1.1.0"},
+			{Path: "replicas", Old: float64(1), New: float64(2)},
+		},
+	}, preview)
+}
+
+func TestPreviewNewRelease(t *testing.T) {
+	rel := &release.Release{
+		Name:      "demo",
+		Namespace: "test-ns",
+		Version:   1,
+		Manifest:  previewManifest,
+		Config:    map[string]interface{}{"image": map[string]interface{}{"tag": "1.1.0"}},
+	}
+
+	preview, err := previewRelease(nil, rel)
+	require.NoError(t, err)
+	assert.Len(t, preview.Added, 3)
+	assert.Empty(t, preview.Changed)
+	assert.Empty(t, preview.Removed)
+	assert.Equal(t, []types2.FieldChange{{Path: "image.tag", New: "1.1.0"}}, preview.Values)
+}
+
+func TestDryRunFinished(t *testing.T) {
+	pod := func(state v1.ContainerState) *v1.Pod {
+		return &v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{Name: "helm", State: state}}}}
+	}
+
+	assert.NoError(t, dryRunFinished(pod(v1.ContainerState{Terminated: &v1.ContainerStateTerminated{}})))
+	assert.ErrorContains(t, dryRunFinished(pod(v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1}})), "dry run failed")
+	assert.ErrorContains(t, dryRunFinished(pod(v1.ContainerState{Running: &v1.ContainerStateRunning{}})), "has not finished")
+}
+
+func TestPreviewReleaseRedactsSecrets(t *testing.T) {
+	current := &release.Release{
+		Name:      "demo",
+		Namespace: "test-ns",
+		Version:   1,
+		Manifest: `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+type: Opaque
+data:
+  password: b2xk
+  removed: b2xk
+`,
+	}
+	rel := &release.Release{
+		Name:      "demo",
+		Namespace: "test-ns",
+		Version:   2,
+		Manifest: `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+type: kubernetes.io/basic-auth
+data:
+  password: bmV3
+stringData:
+  username: admin
+`,
+	}
+
+	preview, err := previewRelease(current, rel)
+	require.NoError(t, err)
+	require.Len(t, preview.Changed, 1)
+	assert.Equal(t, []types2.FieldChange{
+		{Path: "data.password", Old: redactedValue, New: redactedValue},
+		{Path: "data.removed", Old: redactedValue},
+		{Path: "stringData.username", New: redactedValue},
+		{Path: "type", Old: "Opaque", New: "kubernetes.io/basic-auth"},
+	}, preview.Changed[0].Fields)
+}
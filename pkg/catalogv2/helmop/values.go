@@ -0,0 +1,445 @@
+package helmop
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v2"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	schemaSource    = "values.schema.json"
+	questionsSource = "questions.yaml"
+)
+
+// ValuesError is returned when the values submitted for a chart violate its values.schema.json or the constraints of
+// its questions.yaml. It holds every violation, so that they can be reported to the user at once.
+type ValuesError struct {
+	Errors []types2.ValueError
+}
+
+func (e *ValuesError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, valueErr := range e.Errors {
+		if valueErr.Field == "" {
+			msgs = append(msgs, valueErr.Message)
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", valueErr.Field, valueErr.Message))
+	}
+	return "invalid values: " + strings.Join(msgs, "; ")
+}
+
+// chartQuestions holds the questions of the questions.yaml of a chart
+type chartQuestions struct {
+	Questions []v3.Question `yaml:"questions"`
+}
+
+// validateValues receives a chart and the values the user submitted for it. It sets the default of each displayed
+// question whose variable is neither in the values nor in the defaults of the chart, and validates the values merged
+// with the defaults of the chart against the values.schema.json of the chart and its subcharts, and against the
+// questions. It returns the values with the defaults of the questions, or a *ValuesError if the values are invalid.
+func validateValues(chrt *chart.Chart, values map[string]interface{}) (map[string]interface{}, error) {
+	questions, err := getQuestions(chrt)
+	if err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidState,
+			fmt.Sprintf("failed to parse questions of chart %s: %v", chrt.Name(), err))
+	}
+
+	values, err = applyQuestionDefaults(questions, chrt.Values, values)
+	if err != nil {
+		return nil, err
+	}
+
+	coalesced, err := chartutil.CoalesceValues(chrt, values)
+	if err != nil {
+		return nil, err
+	}
+
+	valueErrors, err := validateSchema(chrt, coalesced, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, valueErr := range validateQuestions(questions, coalesced) {
+		valueErr.Chart = chrt.Name()
+		valueErrors = append(valueErrors, valueErr)
+	}
+	if len(valueErrors) > 0 {
+		return nil, &ValuesError{Errors: valueErrors}
+	}
+	return values, nil
+}
+
+// getQuestions returns the questions of the questions.yaml or questions.yml file of the chart, if it has one
+func getQuestions(chrt *chart.Chart) ([]v3.Question, error) {
+	for _, file := range chrt.Files {
+		switch strings.ToLower(file.Name) {
+		case "questions.yaml", "questions.yml":
+			questions := chartQuestions{}
+			if err := yaml.Unmarshal(file.Data, &questions); err != nil {
+				return nil, err
+			}
+			return questions.Questions, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateSchema validates the coalesced values of the chart against its values.schema.json, and the values of each
+// subchart against the schema of the subchart. The fields of the errors are prefixed with the path of the subchart.
+func validateSchema(chrt *chart.Chart, values map[string]interface{}, prefix string) ([]types2.ValueError, error) {
+	var valueErrors []types2.ValueError
+	if len(chrt.Schema) > 0 {
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if string(valuesJSON) == "null" {
+			valuesJSON = []byte("{}")
+		}
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(chrt.Schema), gojsonschema.NewBytesLoader(valuesJSON))
+		if err != nil {
+			return nil, apierror.NewAPIError(validation.InvalidState,
+				fmt.Sprintf("failed to validate values against schema of chart %s: %v", chrt.Name(), err))
+		}
+		for _, resultErr := range result.Errors() {
+			field := resultErr.Field()
+			if field == gojsonschema.STRING_CONTEXT_ROOT {
+				field = ""
+			}
+			if property, ok := resultErr.Details()["property"].(string); ok && resultErr.Type() == "required" {
+				field = joinPath(field, property)
+			}
+			valueErrors = append(valueErrors, types2.ValueError{
+				Chart:   chrt.Name(),
+				Field:   joinPath(prefix, field),
+				Source:  schemaSource,
+				Rule:    resultErr.Type(),
+				Message: resultErr.Description(),
+			})
+		}
+	}
+
+	for _, subchart := range chrt.Dependencies() {
+		subchartValues, _ := values[subchart.Name()].(map[string]interface{})
+		subchartErrors, err := validateSchema(subchart, subchartValues, joinPath(prefix, subchart.Name()))
+		if err != nil {
+			return nil, err
+		}
+		valueErrors = append(valueErrors, subchartErrors...)
+	}
+	return valueErrors, nil
+}
+
+// question holds the constraints shared by questions and subquestions
+type question struct {
+	variable     string
+	typ          string
+	required     bool
+	def          string
+	minLength    int
+	maxLength    int
+	min          int
+	max          int
+	options      []string
+	validChars   string
+	invalidChars string
+	showIf       string
+}
+
+// displayedQuestions returns the questions and subquestions that are displayed for the values, as the UI only asks
+// for questions whose show_if condition holds, and for subquestions whose parent has the show_subquestion_if value.
+func displayedQuestions(questions []v3.Question, values map[string]interface{}) []question {
+	var result []question
+	for _, q := range questions {
+		parent := question{
+			variable:     q.Variable,
+			typ:          q.Type,
+			required:     q.Required,
+			def:          q.Default,
+			minLength:    q.MinLength,
+			maxLength:    q.MaxLength,
+			min:          q.Min,
+			max:          q.Max,
+			options:      q.Options,
+			validChars:   q.ValidChars,
+			invalidChars: q.InvalidChars,
+			showIf:       q.ShowIf,
+		}
+		if !showIf(parent.showIf, values) {
+			continue
+		}
+		result = append(result, parent)
+
+		if value, ok := lookupValue(values, q.Variable); !ok || q.ShowSubquestionIf == "" || fmt.Sprint(value) != q.ShowSubquestionIf {
+			continue
+		}
+		for _, sub := range q.Subquestions {
+			child := question{
+				variable:     sub.Variable,
+				typ:          sub.Type,
+				required:     sub.Required,
+				def:          sub.Default,
+				minLength:    sub.MinLength,
+				maxLength:    sub.MaxLength,
+				min:          sub.Min,
+				max:          sub.Max,
+				options:      sub.Options,
+				validChars:   sub.ValidChars,
+				invalidChars: sub.InvalidChars,
+				showIf:       sub.ShowIf,
+			}
+			if showIf(child.showIf, values) {
+				result = append(result, child)
+			}
+		}
+	}
+	return result
+}
+
+// applyQuestionDefaults returns a copy of the values with the default of each displayed question whose variable is
+// neither set in the values nor in the defaults of the chart. Defaults are converted to the type of the question.
+func applyQuestionDefaults(questions []v3.Question, chartValues, values map[string]interface{}) (map[string]interface{}, error) {
+	if len(questions) == 0 {
+		return values, nil
+	}
+
+	result := copyValues(values)
+
+	// subquestions and show_if conditions depend on the values of other questions, so each pass may display more
+	// questions; a question is defaulted at most once, so this ends once no new default is set
+	for {
+		applied := false
+		merged := chartutil.CoalesceTables(copyValues(result), copyValues(chartValues))
+		for _, q := range displayedQuestions(questions, merged) {
+			if q.def == "" {
+				continue
+			}
+			if _, ok := lookupValue(merged, q.variable); ok {
+				continue
+			}
+			value, err := convertDefault(q)
+			if err != nil {
+				return nil, apierror.NewAPIError(validation.InvalidState, err.Error())
+			}
+			setValue(result, q.variable, value)
+			applied = true
+		}
+		if !applied {
+			return result, nil
+		}
+	}
+}
+
+// validateQuestions returns the violations of the constraints of the displayed questions by the coalesced values
+func validateQuestions(questions []v3.Question, values map[string]interface{}) []types2.ValueError {
+	var valueErrors []types2.ValueError
+	for _, q := range displayedQuestions(questions, values) {
+		newError := func(rule, format string, args ...interface{}) types2.ValueError {
+			return types2.ValueError{
+				Field:   q.variable,
+				Source:  questionsSource,
+				Rule:    rule,
+				Message: fmt.Sprintf(format, args...),
+			}
+		}
+
+		value, ok := lookupValue(values, q.variable)
+		if !ok || value == nil || value == "" {
+			if q.required {
+				valueErrors = append(valueErrors, newError("required", "%s is required", q.variable))
+			}
+			continue
+		}
+
+		switch q.typ {
+		case "int":
+			n, ok := toInt(value)
+			if !ok {
+				valueErrors = append(valueErrors, newError("type", "must be an integer"))
+				continue
+			}
+			if q.min != 0 && n < q.min {
+				valueErrors = append(valueErrors, newError("min", "must be greater than or equal to %d", q.min))
+			}
+			if q.max != 0 && n > q.max {
+				valueErrors = append(valueErrors, newError("max", "must be less than or equal to %d", q.max))
+			}
+		case "boolean":
+			if _, ok := value.(bool); !ok {
+				valueErrors = append(valueErrors, newError("type", "must be a boolean"))
+			}
+		case "enum":
+			if !containsString(q.options, fmt.Sprint(value)) {
+				valueErrors = append(valueErrors, newError("options", "must be one of %s", strings.Join(q.options, ", ")))
+			}
+		case "string", "password", "multiline", "hostname":
+			s, ok := value.(string)
+			if !ok {
+				valueErrors = append(valueErrors, newError("type", "must be a string"))
+				continue
+			}
+			if q.minLength != 0 && len(s) < q.minLength {
+				valueErrors = append(valueErrors, newError("minLength", "must be at least %d characters long", q.minLength))
+			}
+			if q.maxLength != 0 && len(s) > q.maxLength {
+				valueErrors = append(valueErrors, newError("maxLength", "must be at most %d characters long", q.maxLength))
+			}
+			if q.validChars != "" {
+				if re, err := regexp.Compile(q.validChars); err == nil && !re.MatchString(s) {
+					valueErrors = append(valueErrors, newError("validChars", "must match %s", q.validChars))
+				}
+			}
+			if q.invalidChars != "" {
+				if re, err := regexp.Compile(q.invalidChars); err == nil && re.MatchString(s) {
+					valueErrors = append(valueErrors, newError("invalidChars", "must not match %s", q.invalidChars))
+				}
+			}
+		}
+	}
+	return valueErrors
+}
+
+// showIf evaluates a show_if condition of a question, which holds conditions of the form variable=value or
+// variable!=value joined by && and ||, where && binds tighter. An empty condition always holds.
+func showIf(condition string, values map[string]interface{}) bool {
+	if strings.TrimSpace(condition) == "" {
+		return true
+	}
+	for _, or := range strings.Split(condition, "||") {
+		holds := true
+		for _, and := range strings.Split(or, "&&") {
+			if !evalCondition(strings.TrimSpace(and), values) {
+				holds = false
+				break
+			}
+		}
+		if holds {
+			return true
+		}
+	}
+	return false
+}
+
+func evalCondition(condition string, values map[string]interface{}) bool {
+	negate := false
+	variable, expected, ok := strings.Cut(condition, "!=")
+	if ok {
+		negate = true
+	} else if variable, expected, ok = strings.Cut(condition, "="); !ok {
+		return false
+	}
+	value, _ := lookupValue(values, strings.TrimSpace(variable))
+	equal := value != nil && fmt.Sprint(value) == strings.TrimSpace(expected)
+	return equal != negate
+}
+
+// convertDefault converts the default of a question, which is always a string in questions.yaml, to its type
+func convertDefault(q question) (interface{}, error) {
+	switch q.typ {
+	case "int":
+		n, err := strconv.Atoi(q.def)
+		if err != nil {
+			return nil, fmt.Errorf("default %q of question %s is not an integer", q.def, q.variable)
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(q.def)
+		if err != nil {
+			return nil, fmt.Errorf("default %q of question %s is not a boolean", q.def, q.variable)
+		}
+		return b, nil
+	}
+	return q.def, nil
+}
+
+// lookupValue returns the value of the dot separated path in the values
+func lookupValue(values map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setValue sets the value of the dot separated path in the values, creating the maps along the path
+func setValue(values map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[keys[len(keys)-1]] = value
+}
+
+// copyValues returns a deep copy of the maps of the values, so that the values of the request are never modified
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return map[string]interface{}{}
+	}
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = copyValues(m)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+func toInt(value interface{}) (int, bool) {
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		if n != float64(int(n)) {
+			return 0, false
+		}
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	if field == "" {
+		return prefix
+	}
+	return prefix + "." + field
+}
@@ -0,0 +1,169 @@
+package helmop
+
+import (
+	"testing"
+
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const testQuestions = `
+questions:
+- variable: replicas
+  type: int
+  min: 1
+  max: 5
+  default: "2"
+- variable: ingress.enabled
+  type: boolean
+  default: "false"
+  show_subquestion_if: true
+  subquestions:
+  - variable: ingress.host
+    type: hostname
+    required: true
+    valid_chars: "^[a-z0-9.-]+$"
+- variable: storage.class
+  type: enum
+  options: ["fast", "slow"]
+  show_if: "storage.enabled=true"
+- variable: password
+  type: password
+  min_length: 8
+`
+
+const testSchema = `{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "image": {"type": "object", "properties": {"tag": {"type": "string"}}}
+  }
+}`
+
+const testSubchartSchema = `{
+  "type": "object",
+  "properties": {"port": {"type": "integer", "minimum": 1024}}
+}`
+
+func newTestChart() *chart.Chart {
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "demo", Version: "1.0.0"},
+		Values: map[string]interface{}{
+			"image":   map[string]interface{}{"tag": "v1"},
+			"storage": map[string]interface{}{"enabled": false},
+		},
+		Schema: []byte(testSchema),
+		Files:  []*chart.File{{Name: "questions.yaml", Data: []byte(testQuestions)}},
+	}
+	chrt.AddDependency(&chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "db", Version: "1.0.0"},
+		Values:   map[string]interface{}{"port": 5432},
+		Schema:   []byte(testSubchartSchema),
+	})
+	return chrt
+}
+
+func TestValidateValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]interface{}
+		wantValues map[string]interface{}
+		wantErrors []types2.ValueError
+	}{
+		{
+			name:   "defaults of questions are set",
+			values: map[string]interface{}{"password": "long enough"},
+			wantValues: map[string]interface{}{
+				"password": "long enough",
+				"replicas": 2,
+				"ingress":  map[string]interface{}{"enabled": false},
+			},
+		},
+		{
+			name: "user values are kept",
+			values: map[string]interface{}{
+				"replicas": float64(3),
+				"ingress":  map[string]interface{}{"enabled": true, "host": "demo.example.com"},
+			},
+			wantValues: map[string]interface{}{
+				"replicas": float64(3),
+				"ingress":  map[string]interface{}{"enabled": true, "host": "demo.example.com"},
+			},
+		},
+		{
+			name: "questions are violated",
+			values: map[string]interface{}{
+				"replicas": float64(9),
+				"ingress":  map[string]interface{}{"enabled": true},
+				"password": "short",
+			},
+			wantErrors: []types2.ValueError{
+				{Chart: "demo", Field: "replicas", Source: questionsSource, Rule: "max", Message: "must be less than or equal to 5"},
+				{Chart: "demo", Field: "ingress.host", Source: questionsSource, Rule: "required", Message: "ingress.host is required"},
+				{Chart: "demo", Field: "password", Source: questionsSource, Rule: "minLength", Message: "must be at least 8 characters long"},
+			},
+		},
+		{
+			name: "hidden questions are not validated",
+			values: map[string]interface{}{
+				"storage": map[string]interface{}{"class": "unknown"},
+				"ingress": map[string]interface{}{"enabled": false, "host": "Not Valid"},
+			},
+			wantValues: map[string]interface{}{
+				"replicas": 2,
+				"storage":  map[string]interface{}{"class": "unknown"},
+				"ingress":  map[string]interface{}{"enabled": false, "host": "Not Valid"},
+			},
+		},
+		{
+			name: "shown questions are validated",
+			values: map[string]interface{}{
+				"storage": map[string]interface{}{"enabled": true, "class": "unknown"},
+				"ingress": map[string]interface{}{"enabled": true, "host": "Not Valid"},
+			},
+			wantErrors: []types2.ValueError{
+				{Chart: "demo", Field: "ingress.host", Source: questionsSource, Rule: "validChars", Message: "must match ^[a-z0-9.-]+$"},
+				{Chart: "demo", Field: "storage.class", Source: questionsSource, Rule: "options", Message: "must be one of fast, slow"},
+			},
+		},
+		{
+			name: "schemas of chart and subchart are violated",
+			values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": 1},
+				"db":    map[string]interface{}{"port": 80},
+			},
+			wantErrors: []types2.ValueError{
+				{Chart: "demo", Field: "image.tag", Source: schemaSource, Rule: "invalid_type", Message: "Invalid type. Expected: string, given: integer"},
+				{Chart: "db", Field: "db.port", Source: schemaSource, Rule: "number_gte", Message: "Must be greater than or equal to 1024"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := validateValues(newTestChart(), tt.values)
+			if len(tt.wantErrors) > 0 {
+				valuesErr := &ValuesError{}
+				require.ErrorAs(t, err, &valuesErr)
+				assert.Equal(t, tt.wantErrors, valuesErr.Errors)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantValues, values)
+		})
+	}
+}
+
+func TestShowIf(t *testing.T) {
+	values := map[string]interface{}{
+		"a": true,
+		"b": map[string]interface{}{"c": "x"},
+	}
+	assert.True(t, showIf("", values))
+	assert.True(t, showIf("a=true", values))
+	assert.True(t, showIf("a=true&&b.c=x", values))
+	assert.False(t, showIf("a=true&&b.c=y", values))
+	assert.True(t, showIf("a=false||b.c!=y", values))
+	assert.False(t, showIf("missing=true", values))
+}
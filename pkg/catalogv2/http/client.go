@@ -12,7 +12,9 @@
 	corev1 "k8s.io/api/core/v1"
 )
 
-func HelmClient(secret *corev1.Secret, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, repoURL string) (*http.Client, error) {
+// HelmClient returns the client for the repo at repoURL. If publicOnlyIPs is set, which it is for namespaced repos, the
+// client only connects to public IPs.
+func HelmClient(secret *corev1.Secret, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, repoURL string, publicOnlyIPs bool) (*http.Client, error) {
 	var (
 		username  string
 		password  string
@@ -54,6 +56,9 @@ func HelmClient(secret *corev1.Secret, caBundle []byte, insecureSkipTLSVerify bo
 		Transport: transport,
 		Timeout:   30 * time.Second,
 	}
+	if publicOnlyIPs {
+		client.Transport = publicOnly(transport)
+	}
 	if username != "" || password != "" {
 		client.Transport = &basicRoundTripper{
 			username:               username,
@@ -20,12 +20,15 @@
 	corev1 "k8s.io/api/core/v1"
 )
 
-func Icon(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, chart *repo.ChartVersion) (io.ReadCloser, string, error) {
+// maxProvenanceSize is the largest provenance file which is downloaded.
+const maxProvenanceSize = 1 << 20
+
+func Icon(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, publicOnlyIPs bool, chart *repo.ChartVersion) (io.ReadCloser, string, error) {
 	if len(chart.URLs) == 0 {
 		return nil, "", fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
 	}
 
-	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL)
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL, publicOnlyIPs)
 	if err != nil {
 		return nil, "", err
 	}
@@ -65,18 +68,72 @@ func Icon(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTL
 	return ioutil.NopCloser(bytes.NewBuffer(data)), path.Ext(u.Path), nil
 }
 
-func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, chart *repo.ChartVersion) (io.ReadCloser, error) {
+func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, publicOnlyIPs bool, chart *repo.ChartVersion) (io.ReadCloser, error) {
 	if len(chart.URLs) == 0 {
 		return nil, fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
 	}
 
-	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL)
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL, publicOnlyIPs)
 	if err != nil {
 		return nil, err
 	}
 	defer client.CloseIdleConnections()
 
-	u, err := url.Parse(chart.URLs[0])
+	u, err := chartURL(repoURL, chart.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return ioutil.NopCloser(bytes.NewBuffer(data)), err
+}
+
+// Provenance downloads the .prov file helm publishes next to the chart archive of a signed chart, and returns it with
+// the name of the file of the archive, which is what the provenance file refers to it by.
+func Provenance(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, publicOnlyIPs bool, chart *repo.ChartVersion) ([]byte, string, error) {
+	if len(chart.URLs) == 0 {
+		return nil, "", fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL, publicOnlyIPs)
+	if err != nil {
+		return nil, "", err
+	}
+	defer client.CloseIdleConnections()
+
+	u, err := chartURL(repoURL, chart.URLs[0])
+	if err != nil {
+		return nil, "", err
+	}
+	chartFile := path.Base(u.Path)
+	u.Path += ".prov"
+	u.RawPath = ""
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download provenance file of chartName %s version %s: %w", chart.Name, chart.Version, validation.ErrorCode{
+			Status: resp.StatusCode,
+		})
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxProvenanceSize))
+	return data, chartFile, err
+}
+
+// chartURL resolves the URL of a chart archive, which may be relative to the URL of the repo.
+func chartURL(repoURL, rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, err
 	}
@@ -93,19 +150,11 @@ func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipT
 		// contain an access credential.
 		u.RawQuery = base.RawQuery
 	}
-
-	resp, err := client.Get(u.String())
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	data, err := ioutil.ReadAll(resp.Body)
-	return ioutil.NopCloser(bytes.NewBuffer(data)), err
+	return u, nil
 }
 
-func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool) (*repo.IndexFile, error) {
-	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL)
+func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, publicOnlyIPs bool) (*repo.IndexFile, error) {
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL, publicOnlyIPs)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// nonPublicNets are the special purpose networks which aren't covered by the checks of net.IP, such as the shared
+// address space of carrier-grade NAT, which is used for the pods and services of some clusters.
+var nonPublicNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// IsPublicIP returns true if the IP is a public unicast address, so that it isn't an address of Rancher itself, the
+// cluster it runs in or its cloud provider, such as the metadata service at 169.254.169.254.
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsLinkLocalMulticast() {
+		return false
+	}
+	for _, ipNet := range nonPublicNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePublicURL returns an error unless the URL is an http(s) or oci URL whose host only resolves to public IPs.
+// It is used for the URLs of namespaced repos, which can be created by project members, so that they can't make
+// Rancher send requests to addresses they couldn't reach themselves.
+func ValidatePublicURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "oci":
+	default:
+		return fmt.Errorf("URL %s of a namespaced repo must be http(s) or oci", rawURL)
+	}
+	_, err = ResolvePublicHost(u.Hostname())
+	return err
+}
+
+// ResolvePublicHost returns one of the IPs the host resolves to, or an error unless it only resolves to public IPs.
+// Clients which can't check the address they connect to must connect to the returned IP instead of resolving the host
+// again, since it could then resolve to a non-public IP.
+func ResolvePublicHost(host string) (net.IP, error) {
+	if host == "" {
+		return nil, fmt.Errorf("URL of a namespaced repo must have a host")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("host %s of a namespaced repo doesn't resolve to any address", host)
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return nil, fmt.Errorf("host %s of a namespaced repo resolves to non-public address %s", host, addr.IP)
+		}
+	}
+	return addrs[0].IP, nil
+}
+
+// publicOnlyRoundTripper refuses requests to URLs which aren't public, which includes the redirects the client follows.
+type publicOnlyRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (p *publicOnlyRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if err := ValidatePublicURL(request.URL.String()); err != nil {
+		return nil, err
+	}
+	return p.next.RoundTrip(request)
+}
+
+// publicOnly makes the transport only connect to public IPs. The host of each request is checked before it is sent,
+// and unless the requests go through a proxy, the address which is connected to is checked again, so that a host
+// can't resolve to a public IP when it is checked and to a private one when it is connected to.
+func publicOnly(transport *http.Transport) http.RoundTripper {
+	proxy := httpproxy.FromEnvironment()
+	if proxy.HTTPProxy == "" && proxy.HTTPSProxy == "" {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   publicOnlyControl,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	return &publicOnlyRoundTripper{next: transport}
+}
+
+func publicOnlyControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("connecting to non-public address %s is not allowed for namespaced repos", host)
+	}
+	return nil
+}
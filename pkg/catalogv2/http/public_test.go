@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{ip: "8.8.8.8", public: true},
+		{ip: "2001:4860:4860::8888", public: true},
+		{ip: "127.0.0.1"},
+		{ip: "::1"},
+		{ip: "10.43.0.1"},
+		{ip: "172.16.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "169.254.169.254"},
+		{ip: "100.64.0.1"},
+		{ip: "0.0.0.0"},
+		{ip: "0.1.2.3"},
+		{ip: "::"},
+		{ip: "fd00::1"},
+		{ip: "fe80::1"},
+		{ip: "224.0.0.1"},
+		{ip: "::ffff:127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.public, IsPublicIP(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestValidatePublicURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		expectedErr string
+	}{
+		{url: "https://8.8.8.8/charts"},
+		{url: "oci://8.8.8.8/charts"},
+		{url: "http://127.0.0.1:8080/charts", expectedErr: "non-public address 127.0.0.1"},
+		{url: "http://169.254.169.254/latest/meta-data", expectedErr: "non-public address 169.254.169.254"},
+		{url: "https://[::1]/charts", expectedErr: "non-public address ::1"},
+		{url: "file:///etc/passwd", expectedErr: "must be http(s) or oci"},
+		{url: "https:///charts", expectedErr: "must have a host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := ValidatePublicURL(tt.url)
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestHelmClientPublicOnlyIPs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := HelmClient(nil, nil, false, false, server.URL, false)
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	client, err = HelmClient(nil, nil, false, false, server.URL, true)
+	require.NoError(t, err)
+	_, err = client.Get(server.URL)
+	assert.ErrorContains(t, err, "non-public address 127.0.0.1")
+}
+
+func TestPublicOnlyControl(t *testing.T) {
+	assert.NoError(t, publicOnlyControl("tcp4", "8.8.8.8:443", nil))
+	assert.Error(t, publicOnlyControl("tcp4", "10.0.0.1:443", nil))
+	assert.Error(t, publicOnlyControl("tcp6", "[fe80::1]:443", nil))
+}
@@ -0,0 +1,66 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/catalogv2/provenance"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// VerifyChart verifies that the chart archive is the chart layer of the manifest of the chart version, and that the
+// manifest has a cosign signature made with the PEM encoded public key. Signatures are looked up with the tag cosign
+// stores them under, sha256-<digest of the manifest>.sig.
+func VerifyChart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, publicOnlyIPs bool, chartVersion *repo.ChartVersion, chart, publicKey []byte) error {
+	repoRef, ref, err := chartRef(repoURL, chartVersion)
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient(secret, caBundle, insecureSkipTLSVerify, publicOnlyIPs, repoRef)
+	if err != nil {
+		return err
+	}
+	defer c.httpClient.CloseIdleConnections()
+
+	ctx := context.Background()
+	manifest, manifestDigest, err := c.manifestWithDigest(ctx, ref)
+	if err != nil {
+		return err
+	}
+	// the chart was downloaded separately, so make sure it is the one whose manifest is signed
+	layer := chartLayer(manifest)
+	if layer == nil || layer.Digest.String() != provenance.Digest(chart) {
+		return fmt.Errorf("chart archive of %s does not match its manifest %s", ref, manifestDigest)
+	}
+
+	sigRef := ref
+	sigRef.Reference = strings.Replace(manifestDigest.String(), ":", "-", 1) + ".sig"
+	sigManifest, err := c.manifest(ctx, sigRef)
+	var errorCode validation.ErrorCode
+	if errors.As(err, &errorCode) && errorCode.Status == http.StatusNotFound {
+		return fmt.Errorf("chart %s has no cosign signature", ref)
+	} else if err != nil {
+		return err
+	}
+
+	err = fmt.Errorf("chart %s has no cosign signature", ref)
+	for _, layer := range sigManifest.Layers {
+		if layer.MediaType != provenance.SignatureMediaType {
+			continue
+		}
+		payload, blobErr := c.blob(ctx, sigRef, layer, maxManifestSize)
+		if blobErr != nil {
+			return blobErr
+		}
+		if err = provenance.VerifyCosign(publicKey, payload, layer.Annotations[provenance.SignatureAnnotation], manifestDigest.String()); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to verify cosign signature of %s: %w", ref, err)
+}
@@ -0,0 +1,103 @@
+package oci
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/provenance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// newSignedRegistry returns a registry serving the charts/demo repository over plain HTTP, with the 1.0.0 chart signed
+// by the key with cosign and the 2.0.0 chart unsigned.
+func newSignedRegistry(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	blobs := map[digest.Digest][]byte{}
+	manifests := map[string][]byte{}
+	addBlob := func(mediaType string, data []byte) ocispec.Descriptor {
+		d := digest.FromBytes(data)
+		blobs[d] = data
+		return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+	}
+	addManifest := func(tag string, manifest ocispec.Manifest) digest.Digest {
+		data, err := json.Marshal(manifest)
+		require.NoError(t, err)
+		manifests[tag] = data
+		return digest.FromBytes(data)
+	}
+
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		manifestDigest := addManifest(version, ocispec.Manifest{
+			Config: addBlob(registry.ConfigMediaType, []byte(`{"name":"demo","version":"`+version+`"}`)),
+			Layers: []ocispec.Descriptor{addBlob(registry.ChartLayerMediaType, []byte("chart "+version))},
+		})
+		if version != "1.0.0" {
+			continue
+		}
+		payload := []byte(`{"critical":{"image":{"docker-manifest-digest":"` + manifestDigest.String() + `"},"type":"cosign container image signature"}}`)
+		sum := sha256.Sum256(payload)
+		sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+		require.NoError(t, err)
+		layer := addBlob(provenance.SignatureMediaType, payload)
+		layer.Annotations = map[string]string{provenance.SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+		addManifest(strings.Replace(manifestDigest.String(), ":", "-", 1)+".sig", ocispec.Manifest{
+			Config: addBlob("application/vnd.oci.image.config.v1+json", []byte("{}")),
+			Layers: []ocispec.Descriptor{layer},
+		})
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v2/charts/demo/")
+		switch {
+		case strings.HasPrefix(path, "manifests/") && manifests[strings.TrimPrefix(path, "manifests/")] != nil:
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+			w.Write(manifests[strings.TrimPrefix(path, "manifests/")])
+		case strings.HasPrefix(path, "blobs/") && blobs[digest.Digest(strings.TrimPrefix(path, "blobs/"))] != nil:
+			w.Write(blobs[digest.Digest(strings.TrimPrefix(path, "blobs/"))])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerifyChart(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+	require.NoError(t, err)
+	otherPublicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	server := newSignedRegistry(t, key)
+	repoURL := "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts/demo"
+	chartVersion := func(version string) *repo.ChartVersion {
+		return &repo.ChartVersion{URLs: []string{repoURL + ":" + version}}
+	}
+
+	assert.NoError(t, VerifyChart(nil, repoURL, nil, true, false, chartVersion("1.0.0"), []byte("chart 1.0.0"), publicKey))
+	assert.ErrorContains(t, VerifyChart(nil, repoURL, nil, true, false, chartVersion("1.0.0"), []byte("chart 1.0.0"), otherPublicKey), "invalid cosign signature")
+	assert.ErrorContains(t, VerifyChart(nil, repoURL, nil, true, false, chartVersion("1.0.0"), []byte("other chart"), publicKey), "does not match its manifest")
+	assert.ErrorContains(t, VerifyChart(nil, repoURL, nil, true, false, chartVersion("2.0.0"), []byte("chart 2.0.0"), publicKey), "has no cosign signature")
+}
@@ -0,0 +1,382 @@
+// Package oci builds helm repo indexes from, and downloads charts stored as artifacts in, OCI registries.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	orasregistry "oras.land/oras-go/pkg/registry"
+	"oras.land/oras-go/pkg/registry/remote"
+	"oras.land/oras-go/pkg/registry/remote/auth"
+)
+
+const (
+	// Scheme is the scheme of the URL of repos stored in OCI registries.
+	Scheme = "oci://"
+
+	// maxManifestSize is the largest manifest or chart config which is read from a registry.
+	maxManifestSize = 4 << 20
+	// maxChartSize is the largest chart archive which is read from a registry.
+	maxChartSize = 20 << 20
+	// plainHTTPError is returned by the http client when a registry serves plain HTTP.
+	plainHTTPError = "server gave HTTP response"
+	// chartVersionCacheTTL is how long the chart versions read from manifests are cached.
+	chartVersionCacheTTL = 24 * time.Hour
+)
+
+// chartVersions caches the chart versions of manifests by the registry, repository and digest of the manifest.
+var chartVersions = cache.NewLRUExpireCache(4096)
+
+// IsOCI returns true if the repo URL refers to a chart repository in an OCI registry.
+func IsOCI(repoURL string) bool {
+	return strings.HasPrefix(repoURL, Scheme)
+}
+
+// ParseURL returns the reference of the oci:// URL of a chart repository or a chart version.
+func ParseURL(ociURL string) (orasregistry.Reference, error) {
+	if !IsOCI(ociURL) {
+		return orasregistry.Reference{}, fmt.Errorf("%s is not an %s URL", ociURL, Scheme)
+	}
+	ref, err := orasregistry.ParseReference(strings.TrimSuffix(strings.TrimPrefix(ociURL, Scheme), "/"))
+	if err != nil {
+		return ref, err
+	}
+	return ref, ref.ValidateRepository()
+}
+
+// DownloadIndex builds an index of the chart repository at repoURL with a chart version for each semver tag.
+func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, publicOnlyIPs bool) (*repo.IndexFile, error) {
+	ref, err := ParseURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	ref.Reference = ""
+
+	c, err := newClient(secret, caBundle, insecureSkipTLSVerify, publicOnlyIPs, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer c.httpClient.CloseIdleConnections()
+
+	logrus.Infof("Downloading repo index from %s", repoURL)
+	ctx := context.Background()
+	tags, err := c.tags(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	index := repo.NewIndexFile()
+	for _, tag := range tags {
+		// helm replaces the + of semver build metadata, which isn't valid in tags, with an underscore when pushing
+		if _, err := semver.StrictNewVersion(strings.ReplaceAll(tag, "_", "+")); err != nil {
+			continue
+		}
+		tagRef := ref
+		tagRef.Reference = tag
+		chartVersion, err := c.chartVersion(ctx, tagRef)
+		if err != nil {
+			// a single broken tag shouldn't hide all the other charts of the repo
+			logrus.Warnf("Skipping %s as its chart could not be read: %v", tagRef, err)
+			continue
+		}
+		if chartVersion == nil {
+			logrus.Debugf("Skipping %s as it is not a helm chart", tagRef)
+			continue
+		}
+		index.Entries[chartVersion.Name] = append(index.Entries[chartVersion.Name], chartVersion)
+	}
+
+	return index, nil
+}
+
+// Chart downloads the chart archive of the chart version from the registry it was indexed from.
+func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, publicOnlyIPs bool, chartVersion *repo.ChartVersion) (io.ReadCloser, error) {
+	repoRef, ref, err := chartRef(repoURL, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newClient(secret, caBundle, insecureSkipTLSVerify, publicOnlyIPs, repoRef)
+	if err != nil {
+		return nil, err
+	}
+	defer c.httpClient.CloseIdleConnections()
+
+	ctx := context.Background()
+	manifest, err := c.manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	layer := chartLayer(manifest)
+	if layer == nil {
+		return nil, fmt.Errorf("manifest of %s does not contain a layer with mediatype %s", ref, registry.ChartLayerMediaType)
+	}
+	data, err := c.blob(ctx, ref, *layer, maxChartSize)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// chartRef returns the references of the repo and of the chart version, which must be in the registry of the repo.
+func chartRef(repoURL string, chartVersion *repo.ChartVersion) (orasregistry.Reference, orasregistry.Reference, error) {
+	if len(chartVersion.URLs) == 0 {
+		return orasregistry.Reference{}, orasregistry.Reference{}, fmt.Errorf("failed to find chartName %s version %s: %w", chartVersion.Name, chartVersion.Version, validation.NotFound)
+	}
+	repoRef, err := ParseURL(repoURL)
+	if err != nil {
+		return repoRef, orasregistry.Reference{}, err
+	}
+	ref, err := ParseURL(chartVersion.URLs[0])
+	if err != nil {
+		return repoRef, ref, err
+	}
+	if ref.Registry != repoRef.Registry {
+		return repoRef, ref, fmt.Errorf("chart %s is not in the registry of repo %s", chartVersion.URLs[0], repoURL)
+	}
+	return repoRef, ref, nil
+}
+
+type client struct {
+	httpClient *http.Client
+	auth       *auth.Client
+	// allowPlainHTTP is only set for repos which skip TLS verification, so that credentials are never sent in the
+	// clear because a man in the middle answered over plain HTTP.
+	allowPlainHTTP bool
+	plainHTTP      bool
+}
+
+// newClient returns a client for the registry of ref. A basic auth secret is used as the credentials of the registry,
+// which are exchanged for a token if the registry asks for one, while a TLS secret is used as the client certificate.
+func newClient(secret *corev1.Secret, caBundle []byte, insecureSkipTLSVerify bool, publicOnlyIPs bool, ref orasregistry.Reference) (*client, error) {
+	var (
+		tlsSecret  *corev1.Secret
+		credential = auth.EmptyCredential
+	)
+	if secret != nil {
+		switch secret.Type {
+		case corev1.SecretTypeBasicAuth:
+			credential = auth.Credential{
+				Username: string(secret.Data[corev1.BasicAuthUsernameKey]),
+				Password: string(secret.Data[corev1.BasicAuthPasswordKey]),
+			}
+		case corev1.SecretTypeTLS:
+			tlsSecret = secret
+		}
+	}
+
+	httpClient, err := helmhttp.HelmClient(tlsSecret, caBundle, insecureSkipTLSVerify, false, "", publicOnlyIPs)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		httpClient:     httpClient,
+		allowPlainHTTP: insecureSkipTLSVerify,
+		auth: &auth.Client{
+			Client: httpClient,
+			Cache:  auth.NewCache(),
+			Credential: func(_ context.Context, host string) (auth.Credential, error) {
+				// never send the credentials of the repo to another registry
+				if host != ref.Host() {
+					return auth.EmptyCredential, nil
+				}
+				return credential, nil
+			},
+		},
+	}, nil
+}
+
+// retryPlainHTTP returns true if the request should be retried over plain HTTP, which is the case for insecure repos
+// in registries without TLS, such as a local registry used for development.
+func (c *client) retryPlainHTTP(err error) bool {
+	if err == nil || !c.allowPlainHTTP || c.plainHTTP || !strings.Contains(err.Error(), plainHTTPError) {
+		return false
+	}
+	c.plainHTTP = true
+	return true
+}
+
+func (c *client) tags(ctx context.Context, ref orasregistry.Reference) ([]string, error) {
+	for {
+		var tags []string
+		repository := &remote.Repository{
+			Client:    c.auth,
+			Reference: ref,
+			PlainHTTP: c.plainHTTP,
+		}
+		err := repository.Tags(ctx, func(page []string) error {
+			tags = append(tags, page...)
+			return nil
+		})
+		if c.retryPlainHTTP(err) {
+			continue
+		}
+		return tags, err
+	}
+}
+
+// get sends a GET request for the path under the repository of ref to its registry.
+func (c *client) get(ctx context.Context, ref orasregistry.Reference, path, accept string) (*http.Response, error) {
+	ctx = auth.AppendScopes(ctx, auth.ScopeRepository(ref.Repository, auth.ActionPull))
+	for {
+		scheme := "https"
+		if c.plainHTTP {
+			scheme = "http"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/v2/%s/%s", scheme, ref.Host(), ref.Repository, path), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", accept)
+		resp, err := c.auth.Do(req)
+		if c.retryPlainHTTP(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, validation.ErrorCode{
+				Status: resp.StatusCode,
+			}
+		}
+		return resp, nil
+	}
+}
+
+func (c *client) manifest(ctx context.Context, ref orasregistry.Reference) (*ocispec.Manifest, error) {
+	manifest, _, err := c.manifestWithDigest(ctx, ref)
+	return manifest, err
+}
+
+// manifestWithDigest returns the manifest at ref and the digest of its content, which is what signatures refer to.
+func (c *client) manifestWithDigest(ctx context.Context, ref orasregistry.Reference) (*ocispec.Manifest, digest.Digest, error) {
+	resp, err := c.get(ctx, ref, "manifests/"+ref.Reference, ocispec.MediaTypeImageManifest)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", err
+	}
+	manifest := &ocispec.Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, "", fmt.Errorf("failed to decode manifest of %s: %w", ref, err)
+	}
+	return manifest, digest.FromBytes(data), nil
+}
+
+// blob downloads the content of the descriptor and verifies its digest.
+func (c *client) blob(ctx context.Context, ref orasregistry.Reference, desc ocispec.Descriptor, maxSize int64) ([]byte, error) {
+	if desc.Size > maxSize {
+		return nil, fmt.Errorf("%s of %s is larger than %d bytes", desc.MediaType, ref, maxSize)
+	}
+	if err := desc.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	resp, err := c.get(ctx, ref, "blobs/"+desc.Digest.String(), desc.MediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, err
+	}
+	if desc.Digest.Algorithm().FromBytes(data) != desc.Digest {
+		return nil, fmt.Errorf("digest of %s of %s does not match %s", desc.MediaType, ref, desc.Digest)
+	}
+	return data, nil
+}
+
+// chartVersion returns the chart version of the helm chart at ref, or nil if it isn't a helm chart. The chart versions
+// are cached by the digest of their manifest, so that the config of each chart is only downloaded once rather than on
+// every refresh of the index.
+func (c *client) chartVersion(ctx context.Context, ref orasregistry.Reference) (*repo.ChartVersion, error) {
+	manifest, manifestDigest, err := c.manifestWithDigest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	key := ref.Registry + "/" + ref.Repository + "@" + manifestDigest.String()
+	if cached, ok := chartVersions.Get(key); ok {
+		return withChartURL(cached.(*repo.ChartVersion), ref), nil
+	}
+
+	chartVersion, err := c.readChartVersion(ctx, ref, manifest)
+	if err != nil {
+		return nil, err
+	}
+	chartVersions.Add(key, chartVersion, chartVersionCacheTTL)
+	return withChartURL(chartVersion, ref), nil
+}
+
+// withChartURL returns a copy of the cached chart version which refers to the tag it was read from.
+func withChartURL(cached *repo.ChartVersion, ref orasregistry.Reference) *repo.ChartVersion {
+	if cached == nil {
+		return nil
+	}
+	chartVersion := *cached
+	metadata := *cached.Metadata
+	chartVersion.Metadata = &metadata
+	chartVersion.URLs = []string{Scheme + ref.String()}
+	return &chartVersion
+}
+
+// readChartVersion downloads the chart metadata of the manifest, or returns nil if it isn't the manifest of a helm chart.
+func (c *client) readChartVersion(ctx context.Context, ref orasregistry.Reference, manifest *ocispec.Manifest) (*repo.ChartVersion, error) {
+	layer := chartLayer(manifest)
+	if manifest.Config.MediaType != registry.ConfigMediaType || layer == nil {
+		return nil, nil
+	}
+
+	config, err := c.blob(ctx, ref, manifest.Config, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &chart.Metadata{}
+	if err := json.Unmarshal(config, metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode chart metadata: %w", err)
+	}
+	if err := metadata.Validate(); err != nil {
+		return nil, err
+	}
+
+	chartVersion := &repo.ChartVersion{
+		Metadata: metadata,
+		Digest:   layer.Digest.Hex(),
+	}
+	if created, err := time.Parse(time.RFC3339, manifest.Annotations[ocispec.AnnotationCreated]); err == nil {
+		chartVersion.Created = created
+	}
+	return chartVersion, nil
+}
+
+func chartLayer(manifest *ocispec.Manifest) *ocispec.Descriptor {
+	for i, layer := range manifest.Layers {
+		if layer.MediaType == registry.ChartLayerMediaType || layer.MediaType == registry.LegacyChartLayerMediaType {
+			return &manifest.Layers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package oci
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// newRegistry returns a registry serving the charts/demo repository over plain HTTP, which requires basic auth. Charts
+// with the version "broken" have a config which is missing from the registry.
+func newRegistry(t *testing.T, charts map[string]string) *httptest.Server {
+	blobs := map[digest.Digest][]byte{}
+	manifests := map[string][]byte{}
+	addBlob := func(mediaType string, data []byte) ocispec.Descriptor {
+		d := digest.FromBytes(data)
+		blobs[d] = data
+		return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+	}
+	var tags []string
+	for tag, version := range charts {
+		tags = append(tags, tag)
+		manifest := ocispec.Manifest{
+			Config: addBlob("application/vnd.oci.image.config.v1+json", []byte("{}")),
+			Layers: []ocispec.Descriptor{addBlob(ocispec.MediaTypeImageLayerGzip, []byte("image layer"))},
+		}
+		if version == "broken" {
+			// the config of the chart is missing from the registry
+			manifest = ocispec.Manifest{
+				Config: ocispec.Descriptor{MediaType: registry.ConfigMediaType, Digest: digest.FromString("missing"), Size: 7},
+				Layers: []ocispec.Descriptor{addBlob(registry.ChartLayerMediaType, []byte("chart broken"))},
+			}
+		} else if version != "" {
+			config, err := json.Marshal(&chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: version})
+			require.NoError(t, err)
+			manifest = ocispec.Manifest{
+				Config:      addBlob(registry.ConfigMediaType, config),
+				Layers:      []ocispec.Descriptor{addBlob(registry.ChartLayerMediaType, []byte("chart "+version))},
+				Annotations: map[string]string{ocispec.AnnotationCreated: "2023-08-01T10:00:00Z"},
+			}
+		}
+		data, err := json.Marshal(manifest)
+		require.NoError(t, err)
+		manifests[tag] = data
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/v2/charts/demo/")
+		switch {
+		case path == "tags/list":
+			json.NewEncoder(w).Encode(map[string][]string{"tags": tags})
+		case strings.HasPrefix(path, "manifests/") && manifests[strings.TrimPrefix(path, "manifests/")] != nil:
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+			w.Write(manifests[strings.TrimPrefix(path, "manifests/")])
+		case strings.HasPrefix(path, "blobs/") && blobs[digest.Digest(strings.TrimPrefix(path, "blobs/"))] != nil:
+			w.Write(blobs[digest.Digest(strings.TrimPrefix(path, "blobs/"))])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseURL(t *testing.T) {
+	ref, err := ParseURL("oci://harbor.example.com:8443/library/charts/demo/")
+	require.NoError(t, err)
+	assert.Equal(t, "harbor.example.com:8443", ref.Registry)
+	assert.Equal(t, "library/charts/demo", ref.Repository)
+	assert.Empty(t, ref.Reference)
+
+	ref, err = ParseURL("oci://harbor.example.com/charts/demo:1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", ref.Reference)
+
+	_, err = ParseURL("https://harbor.example.com/charts/demo")
+	assert.Error(t, err)
+	_, err = ParseURL("oci://harbor.example.com/Charts")
+	assert.Error(t, err)
+}
+
+func TestDownloadIndexAndChart(t *testing.T) {
+	server := newRegistry(t, map[string]string{
+		"1.0.0":         "1.0.0",
+		"1.1.0_build.1": "1.1.0+build.1",
+		"2.0.0":         "",
+		"latest":        "1.1.0+build.1",
+	})
+	repoURL := "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts/demo"
+	secret := &corev1.Secret{
+		Type: corev1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte("user"),
+			corev1.BasicAuthPasswordKey: []byte("pass"),
+		},
+	}
+
+	// plain HTTP is only allowed for insecure repos
+	_, err := DownloadIndex(secret, repoURL, nil, false, false)
+	assert.ErrorContains(t, err, plainHTTPError)
+
+	_, err = DownloadIndex(nil, repoURL, nil, true, false)
+	assert.Error(t, err)
+
+	index, err := DownloadIndex(secret, repoURL, nil, true, false)
+	require.NoError(t, err)
+	index.SortEntries()
+	require.Len(t, index.Entries["demo"], 2)
+	latest := index.Entries["demo"][0]
+	assert.Equal(t, "1.1.0+build.1", latest.Version)
+	assert.Equal(t, []string{repoURL + ":1.1.0_build.1"}, latest.URLs)
+	assert.Equal(t, digest.FromString("chart 1.1.0+build.1").Hex(), latest.Digest)
+	assert.Equal(t, 2023, latest.Created.Year())
+	assert.Equal(t, "1.0.0", index.Entries["demo"][1].Version)
+
+	content, err := Chart(secret, repoURL, nil, true, false, latest)
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	assert.Equal(t, "chart 1.1.0+build.1", string(data))
+
+	// charts are only downloaded from the registry of the repo
+	otherRegistry := *latest
+	otherRegistry.URLs = []string{"oci://example.com/charts/demo:1.1.0_build.1"}
+	_, err = Chart(secret, repoURL, nil, true, false, &otherRegistry)
+	assert.Error(t, err)
+}
+
+func TestDownloadIndexSkipsBrokenTagsAndCachesCharts(t *testing.T) {
+	server := newRegistry(t, map[string]string{
+		"3.0.0": "3.0.0",
+		"3.1.0": "broken",
+	})
+	var blobRequests int
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/") {
+			blobRequests++
+		}
+		handler.ServeHTTP(w, r)
+	})
+	repoURL := "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts/demo"
+	secret := &corev1.Secret{
+		Type: corev1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte("user"),
+			corev1.BasicAuthPasswordKey: []byte("pass"),
+		},
+	}
+
+	index, err := DownloadIndex(secret, repoURL, nil, true, false)
+	require.NoError(t, err)
+	require.Len(t, index.Entries["demo"], 1, "the broken tag is skipped")
+	assert.Equal(t, "3.0.0", index.Entries["demo"][0].Version)
+	assert.Equal(t, []string{repoURL + ":3.0.0"}, index.Entries["demo"][0].URLs)
+	firstBlobRequests := blobRequests
+
+	index, err = DownloadIndex(secret, repoURL, nil, true, false)
+	require.NoError(t, err)
+	require.Len(t, index.Entries["demo"], 1)
+	assert.Equal(t, "3.0.0", index.Entries["demo"][0].Version)
+	assert.Equal(t, firstBlobRequests+1, blobRequests, "only the config of the broken tag is requested again")
+}
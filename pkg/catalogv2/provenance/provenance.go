@@ -0,0 +1,161 @@
+// Package provenance verifies the signatures of helm charts, either with the PGP signed .prov files helm creates when
+// packaging a chart with --sign, or with the cosign signatures of charts stored in OCI registries.
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/crypto/openpgp"           //nolint:staticcheck // helm signs charts with openpgp
+	"golang.org/x/crypto/openpgp/clearsign" //nolint:staticcheck
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// KeyringKey is the key of the PGP public keyring in a keyring secret.
+	KeyringKey = "keyring"
+	// CosignKey is the key of the PEM encoded cosign public key in a keyring secret.
+	CosignKey = "cosign.pub"
+
+	// SignatureMediaType is the media type of the layers of cosign signature manifests.
+	SignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// SignatureAnnotation is the annotation of a cosign signature layer holding the base64 encoded signature.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// sumCollection is the second document of the message of a .prov file, holding the digests of the signed archives.
+type sumCollection struct {
+	Files map[string]string `json:"files"`
+}
+
+// VerifyProvenance verifies that the .prov file is signed by a key of the keyring, which may be armored, and that it
+// holds the sha256 digest of the chart archive under the name of its file. It returns the identity of the signer.
+func VerifyProvenance(keyring []byte, chartFile string, chart, prov []byte) (string, error) {
+	entities, err := readKeyring(keyring)
+	if err != nil {
+		return "", err
+	}
+
+	block, _ := clearsign.Decode(prov)
+	if block == nil {
+		return "", errors.New("provenance file does not contain a signed message")
+	}
+	signer, err := openpgp.CheckDetachedSignature(entities, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify signature of provenance file: %w", err)
+	}
+
+	// the message holds the metadata of the chart and the digests of the archives, separated by a YAML document end
+	parts := bytes.Split(block.Plaintext, []byte("\n...\n"))
+	if len(parts) < 2 {
+		return "", errors.New("provenance file does not contain the digests of the chart")
+	}
+	sums := sumCollection{}
+	if err := yaml.Unmarshal(parts[1], &sums); err != nil {
+		return "", fmt.Errorf("failed to decode digests of provenance file: %w", err)
+	}
+	sum, ok := sums.Files[chartFile]
+	if !ok {
+		return "", fmt.Errorf("provenance file does not contain a digest for %s", chartFile)
+	}
+	if digest := Digest(chart); sum != digest {
+		return "", fmt.Errorf("digest %s of %s does not match %s of provenance file", digest, chartFile, sum)
+	}
+
+	var names []string
+	for name := range signer.Identities {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint), nil
+	}
+	sort.Strings(names)
+	return names[0], nil
+}
+
+func readKeyring(keyring []byte) (openpgp.EntityList, error) {
+	if len(bytes.TrimSpace(keyring)) == 0 {
+		return nil, errors.New("keyring is empty")
+	}
+	if entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyring)); err == nil {
+		return entities, nil
+	}
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(keyring))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	return entities, nil
+}
+
+// cosignPayload is the simple signing payload cosign signs, see
+// https://github.com/containers/image/blob/main/docs/containers-signature.5.md.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// VerifyCosign verifies that the base64 encoded signature of the payload was made with the PEM encoded public key, and
+// that the payload is the signature of the manifest with the digest.
+func VerifyCosign(publicKey, payload []byte, signature, manifestDigest string) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return errors.New("cosign public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse cosign public key: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode cosign signature: %w", err)
+	}
+
+	digest := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return errors.New("invalid cosign signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.New("invalid cosign signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, sig) {
+			return errors.New("invalid cosign signature")
+		}
+	default:
+		return fmt.Errorf("unsupported cosign public key type %T", key)
+	}
+
+	var p cosignPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("failed to decode cosign payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != manifestDigest {
+		return fmt.Errorf("cosign signature is for %s, not %s", p.Critical.Image.DockerManifestDigest, manifestDigest)
+	}
+	return nil
+}
+
+// Digest returns the sha256 digest of the data in the form helm and OCI registries use, sha256:<hex>.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
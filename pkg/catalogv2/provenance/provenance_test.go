@@ -0,0 +1,161 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"           //nolint:staticcheck
+	"golang.org/x/crypto/openpgp/armor"     //nolint:staticcheck
+	"golang.org/x/crypto/openpgp/clearsign" //nolint:staticcheck
+)
+
+// sign returns a .prov file for the chart archive signed by the entity, in the format of helm package --sign.
+func sign(t *testing.T, entity *openpgp.Entity, chartFile string, chart []byte) []byte {
+	message := fmt.Sprintf("apiVersion: v2\nname: demo\nversion: 1.0.0\n\n...\nfiles:\n  %s: %s\n", chartFile, Digest(chart))
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, entity.PrivateKey, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(message))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func publicKeyring(t *testing.T, armored bool, entities ...*openpgp.Entity) []byte {
+	var buf bytes.Buffer
+	w := &buf
+	if armored {
+		aw, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		require.NoError(t, err)
+		for _, entity := range entities {
+			require.NoError(t, entity.Serialize(aw))
+		}
+		require.NoError(t, aw.Close())
+		return buf.Bytes()
+	}
+	for _, entity := range entities {
+		require.NoError(t, entity.Serialize(w))
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	signer, err := openpgp.NewEntity("Chart Signer", "", "signer@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("Other", "", "other@example.com", nil)
+	require.NoError(t, err)
+
+	chart := []byte("chart archive")
+	prov := sign(t, signer, "demo-1.0.0.tgz", chart)
+
+	tests := []struct {
+		name      string
+		keyring   []byte
+		chartFile string
+		chart     []byte
+		prov      []byte
+		wantErr   string
+	}{
+		{
+			name:      "armored keyring",
+			keyring:   publicKeyring(t, true, other, signer),
+			chartFile: "demo-1.0.0.tgz",
+			chart:     chart,
+			prov:      prov,
+		},
+		{
+			name:      "binary keyring",
+			keyring:   publicKeyring(t, false, signer),
+			chartFile: "demo-1.0.0.tgz",
+			chart:     chart,
+			prov:      prov,
+		},
+		{
+			name:      "unknown signer",
+			keyring:   publicKeyring(t, true, other),
+			chartFile: "demo-1.0.0.tgz",
+			chart:     chart,
+			prov:      prov,
+			wantErr:   "failed to verify signature",
+		},
+		{
+			name:      "modified chart",
+			keyring:   publicKeyring(t, true, signer),
+			chartFile: "demo-1.0.0.tgz",
+			chart:     []byte("modified chart archive"),
+			prov:      prov,
+			wantErr:   "does not match",
+		},
+		{
+			name:      "other chart",
+			keyring:   publicKeyring(t, true, signer),
+			chartFile: "other-1.0.0.tgz",
+			chart:     chart,
+			prov:      prov,
+			wantErr:   "does not contain a digest",
+		},
+		{
+			name:      "unsigned",
+			keyring:   publicKeyring(t, true, signer),
+			chartFile: "demo-1.0.0.tgz",
+			chart:     chart,
+			prov:      []byte("files:\n  demo-1.0.0.tgz: " + Digest(chart)),
+			wantErr:   "does not contain a signed message",
+		},
+		{
+			name:      "empty keyring",
+			chartFile: "demo-1.0.0.tgz",
+			chart:     chart,
+			prov:      prov,
+			wantErr:   "keyring is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := VerifyProvenance(tt.keyring, tt.chartFile, tt.chart, tt.prov)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Chart Signer <signer@example.com>", identity)
+		})
+	}
+}
+
+func TestVerifyCosign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	manifestDigest := Digest([]byte("manifest"))
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"registry.example.com/charts/demo"},"image":{"docker-manifest-digest":"` +
+		manifestDigest + `"},"type":"cosign container image signature"},"optional":null}`)
+	signPayload := func(key *ecdsa.PrivateKey) string {
+		digest := sha256.Sum256(payload)
+		sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+
+	assert.NoError(t, VerifyCosign(publicKey, payload, signPayload(key), manifestDigest))
+	assert.ErrorContains(t, VerifyCosign(publicKey, payload, signPayload(otherKey), manifestDigest), "invalid cosign signature")
+	assert.ErrorContains(t, VerifyCosign(publicKey, payload, signPayload(key), Digest([]byte("other manifest"))), "cosign signature is for")
+	assert.ErrorContains(t, VerifyCosign([]byte("not a key"), payload, signPayload(key), manifestDigest), "not PEM encoded")
+}
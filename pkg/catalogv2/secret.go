@@ -2,14 +2,25 @@
 
 import (
 	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
 	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
-// GetSecret returns the Secret from the cluster repo's clientSecret spec field
+// GetSecret returns the Secret from the cluster repo's clientSecret spec field, or for oci:// repos the basic auth
+// secret named by its basicAuthSecretName field, which is in the namespace of the repo or cattle-system for cluster
+// repos.
 func GetSecret(secrets corev1controllers.SecretCache, repoSpec *v1.RepoSpec, repoNamespace string) (*corev1.Secret, error) {
 	if repoSpec.ClientSecret == nil {
-		return nil, nil
+		if repoSpec.BasicAuthSecretName == "" || !oci.IsOCI(repoSpec.URL) {
+			return nil, nil
+		}
+		ns := namespaces.System
+		if repoNamespace != "" {
+			ns = repoNamespace
+		}
+		return secrets.Get(ns, repoSpec.BasicAuthSecretName)
 	}
 	ns := repoSpec.ClientSecret.Namespace
 	if repoNamespace != "" {
@@ -18,3 +29,18 @@ func GetSecret(secrets corev1controllers.SecretCache, repoSpec *v1.RepoSpec, rep
 
 	return secrets.Get(ns, repoSpec.ClientSecret.Name)
 }
+
+// GetKeyringSecret returns the Secret from the repo's keyringSecret spec field, or nil if it has none. The secret of a
+// cluster repo is in cattle-system if its reference has no namespace.
+func GetKeyringSecret(secrets corev1controllers.SecretCache, repoSpec *v1.RepoSpec, repoNamespace string) (*corev1.Secret, error) {
+	if repoSpec.KeyringSecret == nil {
+		return nil, nil
+	}
+	ns := repoSpec.KeyringSecret.Namespace
+	if repoNamespace != "" {
+		ns = repoNamespace
+	} else if ns == "" {
+		ns = namespaces.System
+	}
+	return secrets.Get(ns, repoSpec.KeyringSecret.Name)
+}
@@ -0,0 +1,65 @@
+package catalogv2
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          v1.RepoSpec
+		repoNamespace string
+		wantSecret    string
+	}{
+		{
+			name:       "basic auth secret of an oci cluster repo",
+			spec:       v1.RepoSpec{URL: "oci://registry.example.com/charts", BasicAuthSecretName: "creds"},
+			wantSecret: "cattle-system/creds",
+		},
+		{
+			name:          "basic auth secret of an oci repo",
+			spec:          v1.RepoSpec{URL: "oci://registry.example.com/charts", BasicAuthSecretName: "creds"},
+			repoNamespace: "team",
+			wantSecret:    "team/creds",
+		},
+		{
+			name: "basic auth secret of an http repo is ignored",
+			spec: v1.RepoSpec{URL: "https://charts.example.com", BasicAuthSecretName: "creds"},
+		},
+		{
+			name: "basic auth secret of a git repo is ignored",
+			spec: v1.RepoSpec{GitRepo: "https://git.example.com/charts", BasicAuthSecretName: "creds"},
+		},
+		{
+			name:       "client secret",
+			spec:       v1.RepoSpec{URL: "https://charts.example.com", ClientSecret: &v1.SecretReference{Namespace: "other", Name: "client"}},
+			wantSecret: "other/client",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			secrets := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+			secrets.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(ns, name string) (*corev1.Secret, error) {
+				return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}, nil
+			}).AnyTimes()
+
+			secret, err := GetSecret(secrets, &tt.spec, tt.repoNamespace)
+			require.NoError(t, err)
+			if tt.wantSecret == "" {
+				assert.Nil(t, secret)
+				return
+			}
+			require.NotNil(t, secret)
+			assert.Equal(t, tt.wantSecret, secret.Namespace+"/"+secret.Name)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	ClusterAuthTokenScopeType           = "clusterAuthTokenScope"
+	ClusterAuthTokenScopeFieldReadOnly  = "readOnly"
+	ClusterAuthTokenScopeFieldResources = "resources"
+)
+
+type ClusterAuthTokenScope struct {
+	ReadOnly  bool                            `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+	Resources []ClusterAuthTokenScopeResource `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	ClusterAuthTokenScopeResourceType           = "clusterAuthTokenScopeResource"
+	ClusterAuthTokenScopeResourceFieldAPIGroup  = "apiGroup"
+	ClusterAuthTokenScopeResourceFieldResources = "resources"
+)
+
+type ClusterAuthTokenScopeResource struct {
+	APIGroup  string   `json:"apiGroup,omitempty" yaml:"apiGroup,omitempty"`
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
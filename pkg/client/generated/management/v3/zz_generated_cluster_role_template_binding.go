@@ -10,11 +10,13 @@
 	ClusterRoleTemplateBindingFieldClusterID        = "clusterId"
 	ClusterRoleTemplateBindingFieldCreated          = "created"
 	ClusterRoleTemplateBindingFieldCreatorID        = "creatorId"
+	ClusterRoleTemplateBindingFieldExpiresAt        = "expiresAt"
 	ClusterRoleTemplateBindingFieldGroupID          = "groupId"
 	ClusterRoleTemplateBindingFieldGroupPrincipalID = "groupPrincipalId"
 	ClusterRoleTemplateBindingFieldLabels           = "labels"
 	ClusterRoleTemplateBindingFieldName             = "name"
 	ClusterRoleTemplateBindingFieldNamespaceId      = "namespaceId"
+	ClusterRoleTemplateBindingFieldNotBefore        = "notBefore"
 	ClusterRoleTemplateBindingFieldOwnerReferences  = "ownerReferences"
 	ClusterRoleTemplateBindingFieldRemoved          = "removed"
 	ClusterRoleTemplateBindingFieldRoleTemplateID   = "roleTemplateId"
@@ -29,11 +31,13 @@ type ClusterRoleTemplateBinding struct {
 	ClusterID        string            `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
 	Created          string            `json:"created,omitempty" yaml:"created,omitempty"`
 	CreatorID        string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	ExpiresAt        string            `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
 	GroupID          string            `json:"groupId,omitempty" yaml:"groupId,omitempty"`
 	GroupPrincipalID string            `json:"groupPrincipalId,omitempty" yaml:"groupPrincipalId,omitempty"`
 	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Name             string            `json:"name,omitempty" yaml:"name,omitempty"`
 	NamespaceId      string            `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
+	NotBefore        string            `json:"notBefore,omitempty" yaml:"notBefore,omitempty"`
 	OwnerReferences  []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
 	Removed          string            `json:"removed,omitempty" yaml:"removed,omitempty"`
 	RoleTemplateID   string            `json:"roleTemplateId,omitempty" yaml:"roleTemplateId,omitempty"`
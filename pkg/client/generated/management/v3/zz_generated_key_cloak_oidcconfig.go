@@ -7,12 +7,16 @@
 	KeyCloakOIDCConfigFieldAnnotations         = "annotations"
 	KeyCloakOIDCConfigFieldAuthEndpoint        = "authEndpoint"
 	KeyCloakOIDCConfigFieldCertificate         = "certificate"
+	KeyCloakOIDCConfigFieldClaimRules          = "claimRules"
 	KeyCloakOIDCConfigFieldClientID            = "clientId"
 	KeyCloakOIDCConfigFieldClientSecret        = "clientSecret"
 	KeyCloakOIDCConfigFieldCreated             = "created"
 	KeyCloakOIDCConfigFieldCreatorID           = "creatorId"
 	KeyCloakOIDCConfigFieldEnabled             = "enabled"
+	KeyCloakOIDCConfigFieldGroupPrefix         = "groupPrefix"
+	KeyCloakOIDCConfigFieldGroupRewrites       = "groupRewrites"
 	KeyCloakOIDCConfigFieldGroupSearchEnabled  = "groupSearchEnabled"
+	KeyCloakOIDCConfigFieldGroupsClaim         = "groupsClaim"
 	KeyCloakOIDCConfigFieldIssuer              = "issuer"
 	KeyCloakOIDCConfigFieldLabels              = "labels"
 	KeyCloakOIDCConfigFieldName                = "name"
@@ -27,26 +31,30 @@
 )
 
 type KeyCloakOIDCConfig struct {
-	AccessMode          string            `json:"accessMode,omitempty" yaml:"accessMode,omitempty"`
-	AllowedPrincipalIDs []string          `json:"allowedPrincipalIds,omitempty" yaml:"allowedPrincipalIds,omitempty"`
-	Annotations         map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
-	AuthEndpoint        string            `json:"authEndpoint,omitempty" yaml:"authEndpoint,omitempty"`
-	Certificate         string            `json:"certificate,omitempty" yaml:"certificate,omitempty"`
-	ClientID            string            `json:"clientId,omitempty" yaml:"clientId,omitempty"`
-	ClientSecret        string            `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
-	Created             string            `json:"created,omitempty" yaml:"created,omitempty"`
-	CreatorID           string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
-	Enabled             bool              `json:"enabled,omitempty" yaml:"enabled,omitempty"`
-	GroupSearchEnabled  *bool             `json:"groupSearchEnabled,omitempty" yaml:"groupSearchEnabled,omitempty"`
-	Issuer              string            `json:"issuer,omitempty" yaml:"issuer,omitempty"`
-	Labels              map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
-	Name                string            `json:"name,omitempty" yaml:"name,omitempty"`
-	OwnerReferences     []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
-	PrivateKey          string            `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
-	RancherURL          string            `json:"rancherUrl,omitempty" yaml:"rancherUrl,omitempty"`
-	Removed             string            `json:"removed,omitempty" yaml:"removed,omitempty"`
-	Scopes              string            `json:"scope,omitempty" yaml:"scope,omitempty"`
-	Status              *AuthConfigStatus `json:"status,omitempty" yaml:"status,omitempty"`
-	Type                string            `json:"type,omitempty" yaml:"type,omitempty"`
-	UUID                string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	AccessMode          string             `json:"accessMode,omitempty" yaml:"accessMode,omitempty"`
+	AllowedPrincipalIDs []string           `json:"allowedPrincipalIds,omitempty" yaml:"allowedPrincipalIds,omitempty"`
+	Annotations         map[string]string  `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	AuthEndpoint        string             `json:"authEndpoint,omitempty" yaml:"authEndpoint,omitempty"`
+	Certificate         string             `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	ClaimRules          []OIDCClaimRule    `json:"claimRules,omitempty" yaml:"claimRules,omitempty"`
+	ClientID            string             `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	ClientSecret        string             `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
+	Created             string             `json:"created,omitempty" yaml:"created,omitempty"`
+	CreatorID           string             `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	Enabled             bool               `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	GroupPrefix         string             `json:"groupPrefix,omitempty" yaml:"groupPrefix,omitempty"`
+	GroupRewrites       []OIDCGroupRewrite `json:"groupRewrites,omitempty" yaml:"groupRewrites,omitempty"`
+	GroupSearchEnabled  *bool              `json:"groupSearchEnabled,omitempty" yaml:"groupSearchEnabled,omitempty"`
+	GroupsClaim         string             `json:"groupsClaim,omitempty" yaml:"groupsClaim,omitempty"`
+	Issuer              string             `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Labels              map[string]string  `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Name                string             `json:"name,omitempty" yaml:"name,omitempty"`
+	OwnerReferences     []OwnerReference   `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+	PrivateKey          string             `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+	RancherURL          string             `json:"rancherUrl,omitempty" yaml:"rancherUrl,omitempty"`
+	Removed             string             `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Scopes              string             `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Status              *AuthConfigStatus  `json:"status,omitempty" yaml:"status,omitempty"`
+	Type                string             `json:"type,omitempty" yaml:"type,omitempty"`
+	UUID                string             `json:"uuid,omitempty" yaml:"uuid,omitempty"`
 }
@@ -0,0 +1,22 @@
+package client
+
+const (
+	OIDCClaimRuleType              = "oidcClaimRule"
+	OIDCClaimRuleFieldClaim        = "claim"
+	OIDCClaimRuleFieldClusterRoles = "clusterRoles"
+	OIDCClaimRuleFieldGlobalRoles  = "globalRoles"
+	OIDCClaimRuleFieldName         = "name"
+	OIDCClaimRuleFieldOperator     = "operator"
+	OIDCClaimRuleFieldProjectRoles = "projectRoles"
+	OIDCClaimRuleFieldValue        = "value"
+)
+
+type OIDCClaimRule struct {
+	Claim        string                     `json:"claim,omitempty" yaml:"claim,omitempty"`
+	ClusterRoles []OIDCClaimRuleClusterRole `json:"clusterRoles,omitempty" yaml:"clusterRoles,omitempty"`
+	GlobalRoles  []string                   `json:"globalRoles,omitempty" yaml:"globalRoles,omitempty"`
+	Name         string                     `json:"name,omitempty" yaml:"name,omitempty"`
+	Operator     string                     `json:"operator,omitempty" yaml:"operator,omitempty"`
+	ProjectRoles []OIDCClaimRuleProjectRole `json:"projectRoles,omitempty" yaml:"projectRoles,omitempty"`
+	Value        string                     `json:"value,omitempty" yaml:"value,omitempty"`
+}
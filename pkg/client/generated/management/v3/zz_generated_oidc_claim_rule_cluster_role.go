@@ -0,0 +1,12 @@
+package client
+
+const (
+	OIDCClaimRuleClusterRoleType                  = "oidcClaimRuleClusterRole"
+	OIDCClaimRuleClusterRoleFieldClusterName      = "clusterName"
+	OIDCClaimRuleClusterRoleFieldRoleTemplateName = "roleTemplateName"
+)
+
+type OIDCClaimRuleClusterRole struct {
+	ClusterName      string `json:"clusterName,omitempty" yaml:"clusterName,omitempty"`
+	RoleTemplateName string `json:"roleTemplateName,omitempty" yaml:"roleTemplateName,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	OIDCClaimRuleProjectRoleType                  = "oidcClaimRuleProjectRole"
+	OIDCClaimRuleProjectRoleFieldProjectName      = "projectName"
+	OIDCClaimRuleProjectRoleFieldRoleTemplateName = "roleTemplateName"
+)
+
+type OIDCClaimRuleProjectRole struct {
+	ProjectName      string `json:"projectName,omitempty" yaml:"projectName,omitempty"`
+	RoleTemplateName string `json:"roleTemplateName,omitempty" yaml:"roleTemplateName,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	OIDCGroupRewriteType             = "oidcGroupRewrite"
+	OIDCGroupRewriteFieldPattern     = "pattern"
+	OIDCGroupRewriteFieldReplacement = "replacement"
+)
+
+type OIDCGroupRewrite struct {
+	Pattern     string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty" yaml:"replacement,omitempty"`
+}
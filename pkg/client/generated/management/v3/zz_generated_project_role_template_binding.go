@@ -9,11 +9,13 @@
 	ProjectRoleTemplateBindingFieldAnnotations      = "annotations"
 	ProjectRoleTemplateBindingFieldCreated          = "created"
 	ProjectRoleTemplateBindingFieldCreatorID        = "creatorId"
+	ProjectRoleTemplateBindingFieldExpiresAt        = "expiresAt"
 	ProjectRoleTemplateBindingFieldGroupID          = "groupId"
 	ProjectRoleTemplateBindingFieldGroupPrincipalID = "groupPrincipalId"
 	ProjectRoleTemplateBindingFieldLabels           = "labels"
 	ProjectRoleTemplateBindingFieldName             = "name"
 	ProjectRoleTemplateBindingFieldNamespaceId      = "namespaceId"
+	ProjectRoleTemplateBindingFieldNotBefore        = "notBefore"
 	ProjectRoleTemplateBindingFieldOwnerReferences  = "ownerReferences"
 	ProjectRoleTemplateBindingFieldProjectID        = "projectId"
 	ProjectRoleTemplateBindingFieldRemoved          = "removed"
@@ -29,11 +31,13 @@ type ProjectRoleTemplateBinding struct {
 	Annotations      map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 	Created          string            `json:"created,omitempty" yaml:"created,omitempty"`
 	CreatorID        string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	ExpiresAt        string            `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
 	GroupID          string            `json:"groupId,omitempty" yaml:"groupId,omitempty"`
 	GroupPrincipalID string            `json:"groupPrincipalId,omitempty" yaml:"groupPrincipalId,omitempty"`
 	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Name             string            `json:"name,omitempty" yaml:"name,omitempty"`
 	NamespaceId      string            `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
+	NotBefore        string            `json:"notBefore,omitempty" yaml:"notBefore,omitempty"`
 	OwnerReferences  []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
 	ProjectID        string            `json:"projectId,omitempty" yaml:"projectId,omitempty"`
 	Removed          string            `json:"removed,omitempty" yaml:"removed,omitempty"`
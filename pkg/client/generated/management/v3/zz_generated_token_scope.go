@@ -0,0 +1,14 @@
+package client
+
+const (
+	TokenScopeType           = "tokenScope"
+	TokenScopeFieldClusters  = "clusters"
+	TokenScopeFieldReadOnly  = "readOnly"
+	TokenScopeFieldResources = "resources"
+)
+
+type TokenScope struct {
+	Clusters  []string             `json:"clusters,omitempty" yaml:"clusters,omitempty"`
+	ReadOnly  bool                 `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+	Resources []TokenScopeResource `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	TokenScopeResourceType           = "tokenScopeResource"
+	TokenScopeResourceFieldAPIGroup  = "apiGroup"
+	TokenScopeResourceFieldResources = "resources"
+)
+
+type TokenScopeResource struct {
+	APIGroup  string   `json:"apiGroup,omitempty" yaml:"apiGroup,omitempty"`
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
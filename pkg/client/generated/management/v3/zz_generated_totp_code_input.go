@@ -0,0 +1,12 @@
+package client
+
+const (
+	TOTPCodeInputType              = "totpCodeInput"
+	TOTPCodeInputFieldCode         = "code"
+	TOTPCodeInputFieldRecoveryCode = "recoveryCode"
+)
+
+type TOTPCodeInput struct {
+	Code         string `json:"code,omitempty" yaml:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty" yaml:"recoveryCode,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	TOTPEnrollmentType                 = "totpEnrollment"
+	TOTPEnrollmentFieldProvisioningURI = "provisioningUri"
+	TOTPEnrollmentFieldSecret          = "secret"
+)
+
+type TOTPEnrollment struct {
+	ProvisioningURI string `json:"provisioningUri,omitempty" yaml:"provisioningUri,omitempty"`
+	Secret          string `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
@@ -0,0 +1,10 @@
+package client
+
+const (
+	TOTPRecoveryCodesType               = "totpRecoveryCodes"
+	TOTPRecoveryCodesFieldRecoveryCodes = "recoveryCodes"
+)
+
+type TOTPRecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes,omitempty" yaml:"recoveryCodes,omitempty"`
+}
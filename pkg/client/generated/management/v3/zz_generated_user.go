@@ -13,11 +13,14 @@
 	UserFieldDescription          = "description"
 	UserFieldEnabled              = "enabled"
 	UserFieldLabels               = "labels"
+	UserFieldLockedUntil          = "lockedUntil"
+	UserFieldMFAEnabled           = "mfaEnabled"
 	UserFieldMe                   = "me"
 	UserFieldMustChangePassword   = "mustChangePassword"
 	UserFieldName                 = "name"
 	UserFieldOwnerReferences      = "ownerReferences"
 	UserFieldPassword             = "password"
+	UserFieldPasswordChangedAt    = "passwordChangedAt"
 	UserFieldPrincipalIDs         = "principalIds"
 	UserFieldRemoved              = "removed"
 	UserFieldState                = "state"
@@ -36,11 +39,14 @@ type User struct {
 	Description          string            `json:"description,omitempty" yaml:"description,omitempty"`
 	Enabled              *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty"`
 	Labels               map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	LockedUntil          string            `json:"lockedUntil,omitempty" yaml:"lockedUntil,omitempty"`
+	MFAEnabled           bool              `json:"mfaEnabled,omitempty" yaml:"mfaEnabled,omitempty"`
 	Me                   bool              `json:"me,omitempty" yaml:"me,omitempty"`
 	MustChangePassword   bool              `json:"mustChangePassword,omitempty" yaml:"mustChangePassword,omitempty"`
 	Name                 string            `json:"name,omitempty" yaml:"name,omitempty"`
 	OwnerReferences      []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
 	Password             string            `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordChangedAt    string            `json:"passwordChangedAt,omitempty" yaml:"passwordChangedAt,omitempty"`
 	PrincipalIDs         []string          `json:"principalIds,omitempty" yaml:"principalIds,omitempty"`
 	Removed              string            `json:"removed,omitempty" yaml:"removed,omitempty"`
 	State                string            `json:"state,omitempty" yaml:"state,omitempty"`
@@ -71,10 +77,20 @@ type UserOperations interface {
 
 	ActionRefreshauthprovideraccess(resource *User) error
 
+	ActionResettotp(resource *User) (*User, error)
+
 	ActionSetpassword(resource *User, input *SetPasswordInput) (*User, error)
 
+	ActionUnlock(resource *User) (*User, error)
+
+	CollectionActionActivatetotp(resource *UserCollection, input *TOTPCodeInput) (*TOTPRecoveryCodes, error)
+
 	CollectionActionChangepassword(resource *UserCollection, input *ChangePasswordInput) error
 
+	CollectionActionDisabletotp(resource *UserCollection, input *TOTPCodeInput) error
+
+	CollectionActionEnrolltotp(resource *UserCollection) (*TOTPEnrollment, error)
+
 	CollectionActionRefreshauthprovideraccess(resource *UserCollection) error
 }
 
@@ -152,17 +168,46 @@ func (c *UserClient) ActionRefreshauthprovideraccess(resource *User) error {
 	return err
 }
 
+func (c *UserClient) ActionResettotp(resource *User) (*User, error) {
+	resp := &User{}
+	err := c.apiClient.Ops.DoAction(UserType, "resettotp", &resource.Resource, nil, resp)
+	return resp, err
+}
+
 func (c *UserClient) ActionSetpassword(resource *User, input *SetPasswordInput) (*User, error) {
 	resp := &User{}
 	err := c.apiClient.Ops.DoAction(UserType, "setpassword", &resource.Resource, input, resp)
 	return resp, err
 }
 
+func (c *UserClient) ActionUnlock(resource *User) (*User, error) {
+	resp := &User{}
+	err := c.apiClient.Ops.DoAction(UserType, "unlock", &resource.Resource, nil, resp)
+	return resp, err
+}
+
+func (c *UserClient) CollectionActionActivatetotp(resource *UserCollection, input *TOTPCodeInput) (*TOTPRecoveryCodes, error) {
+	resp := &TOTPRecoveryCodes{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "activatetotp", &resource.Collection, input, resp)
+	return resp, err
+}
+
 func (c *UserClient) CollectionActionChangepassword(resource *UserCollection, input *ChangePasswordInput) error {
 	err := c.apiClient.Ops.DoCollectionAction(UserType, "changepassword", &resource.Collection, input, nil)
 	return err
 }
 
+func (c *UserClient) CollectionActionDisabletotp(resource *UserCollection, input *TOTPCodeInput) error {
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "disabletotp", &resource.Collection, input, nil)
+	return err
+}
+
+func (c *UserClient) CollectionActionEnrolltotp(resource *UserCollection) (*TOTPEnrollment, error) {
+	resp := &TOTPEnrollment{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "enrolltotp", &resource.Collection, nil, resp)
+	return resp, err
+}
+
 func (c *UserClient) CollectionActionRefreshauthprovideraccess(resource *UserCollection) error {
 	err := c.apiClient.Ops.DoCollectionAction(UserType, "refreshauthprovideraccess", &resource.Collection, nil, nil)
 	return err
@@ -4,7 +4,9 @@
 	BasicLoginType              = "basicLogin"
 	BasicLoginFieldDescription  = "description"
 	BasicLoginFieldPassword     = "password"
+	BasicLoginFieldRecoveryCode = "recoveryCode"
 	BasicLoginFieldResponseType = "responseType"
+	BasicLoginFieldTOTPCode     = "totpCode"
 	BasicLoginFieldTTLMillis    = "ttl"
 	BasicLoginFieldUsername     = "username"
 )
@@ -12,7 +14,9 @@
 type BasicLogin struct {
 	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
 	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty" yaml:"recoveryCode,omitempty"`
 	ResponseType string `json:"responseType,omitempty" yaml:"responseType,omitempty"`
+	TOTPCode     string `json:"totpCode,omitempty" yaml:"totpCode,omitempty"`
 	TTLMillis    int64  `json:"ttl,omitempty" yaml:"ttl,omitempty"`
 	Username     string `json:"username,omitempty" yaml:"username,omitempty"`
 }
@@ -7,6 +7,7 @@
 	"github.com/rancher/rancher/pkg/capr/planner"
 	"github.com/rancher/rancher/pkg/controllers/capr/bootstrap"
 	"github.com/rancher/rancher/pkg/controllers/capr/dynamicschema"
+	"github.com/rancher/rancher/pkg/controllers/capr/etcdsnapshotverify"
 	"github.com/rancher/rancher/pkg/controllers/capr/machinedrain"
 	"github.com/rancher/rancher/pkg/controllers/capr/machinenodelookup"
 	"github.com/rancher/rancher/pkg/controllers/capr/machineprovision"
@@ -24,13 +25,18 @@
 	"github.com/rancher/rancher/pkg/wrangler"
 )
 
-func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager *kubeconfig.Manager) {
-	rkePlanner := planner.New(ctx, clients, planner.InfoFunctions{
+// NewPlanner returns a planner resolving images, release data and system pods the way Rancher does.
+func NewPlanner(ctx context.Context, clients *wrangler.Context) *planner.Planner {
+	return planner.New(ctx, clients, planner.InfoFunctions{
 		ImageResolver:           image.ResolveWithControlPlane,
 		ReleaseData:             capr.GetKDMReleaseData,
 		SystemAgentImage:        settings.SystemAgentInstallerImage.Get,
 		SystemPodLabelSelectors: systeminfo.NewRetriever(clients).GetSystemPodLabelSelectors,
 	})
+}
+
+func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager *kubeconfig.Manager) {
+	rkePlanner := NewPlanner(ctx, clients)
 	if features.MCM.Enabled() {
 		dynamicschema.Register(ctx, clients)
 		machineprovision.Register(ctx, clients, kubeconfigManager)
@@ -44,4 +50,5 @@ func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager
 	rkecontrolplane.Register(ctx, clients)
 	managesystemagent.Register(ctx, clients)
 	machinedrain.Register(ctx, clients)
+	etcdsnapshotverify.Register(ctx, clients, kubeconfigManager)
 }
@@ -0,0 +1,440 @@
+// Package etcdsnapshotverify verifies etcd snapshots stored in S3 or on the nodes of their cluster on request or on the
+// schedule of their cluster, and records the result on the status of the snapshot.
+package etcdsnapshotverify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr/etcdverify"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/provisioningv2/kubeconfig"
+	"github.com/rancher/rancher/pkg/wrangler"
+	corecontrollers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// verifyTimeout is how long downloading, verifying and restoring a snapshot may take
+	verifyTimeout     = 30 * time.Minute
+	defaultS3Endpoint = "s3.amazonaws.com"
+	// verifyWorkers is how many snapshots are verified at the same time
+	verifyWorkers = 2
+	// busyRetryInterval is how long until a due verification is retried when all workers are busy
+	busyRetryInterval = time.Minute
+	// workDir is where snapshots are downloaded to while they are verified. It is removed on startup, so that the
+	// snapshots of verifications that were interrupted don't fill up the disk.
+	workDir = "/var/lib/rancher/etcd-snapshot-verify"
+
+	// verifyScheduleAnnotation is the verification schedule of the cluster that was last seen for the snapshot, and
+	// verifyScheduleSinceAnnotation is when it was first seen, from which the schedule is counted.
+	verifyScheduleAnnotation      = "rke.cattle.io/verify-schedule"
+	verifyScheduleSinceAnnotation = "rke.cattle.io/verify-schedule-since"
+)
+
+// verifyRequest is a verification of a snapshot that is run by a worker
+type verifyRequest struct {
+	snapshot       *rkev1.ETCDSnapshot
+	controlPlane   *rkev1.RKEControlPlane
+	scratchRestore bool
+	verification   *rkev1.ETCDSnapshotVerification
+}
+
+type handler struct {
+	ctx               context.Context
+	etcdSnapshots     rkecontrollers.ETCDSnapshotController
+	controlPlanes     rkecontrollers.RKEControlPlaneCache
+	clusters          provisioningcontrollers.ClusterCache
+	secrets           corecontrollers.SecretCache
+	kubeconfigManager *kubeconfig.Manager
+	dir               string
+	requests          chan verifyRequest
+
+	lock     sync.Mutex
+	inFlight map[string]bool
+}
+
+func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager *kubeconfig.Manager) {
+	h := &handler{
+		ctx:               ctx,
+		etcdSnapshots:     clients.RKE.ETCDSnapshot(),
+		controlPlanes:     clients.RKE.RKEControlPlane().Cache(),
+		clusters:          clients.Provisioning.Cluster().Cache(),
+		secrets:           clients.Core.Secret().Cache(),
+		kubeconfigManager: kubeconfigManager,
+		dir:               workDir,
+		requests:          make(chan verifyRequest),
+		inFlight:          map[string]bool{},
+	}
+	if err := os.RemoveAll(h.dir); err != nil {
+		logrus.Warnf("[etcdsnapshotverify] failed to remove snapshots of interrupted verifications: %v", err)
+	}
+	for i := 0; i < verifyWorkers; i++ {
+		go h.worker()
+	}
+	clients.RKE.ETCDSnapshot().OnChange(ctx, "etcd-snapshot-verify", h.OnChange)
+}
+
+func (h *handler) OnChange(_ string, snapshot *rkev1.ETCDSnapshot) (*rkev1.ETCDSnapshot, error) {
+	if snapshot == nil || !snapshot.DeletionTimestamp.IsZero() || snapshot.Status.Missing {
+		return snapshot, nil
+	}
+
+	controlPlane, err := h.controlPlanes.Get(snapshot.Namespace, snapshot.Spec.ClusterName)
+	if apierrors.IsNotFound(err) {
+		return snapshot, nil
+	} else if err != nil {
+		return snapshot, err
+	}
+
+	now := time.Now()
+	if updated := withVerifySchedule(snapshot, controlPlane.Spec.ETCD, now); updated != nil {
+		// the update enqueues the snapshot again
+		return h.etcdSnapshots.Update(updated)
+	}
+
+	due, scratchRestore, next, err := verificationDue(snapshot, controlPlane.Spec.ETCD, now)
+	if err != nil {
+		return snapshot, err
+	}
+	if !due {
+		if !next.IsZero() {
+			h.etcdSnapshots.EnqueueAfter(snapshot.Namespace, snapshot.Name, next.Sub(now))
+		}
+		return snapshot, nil
+	}
+
+	verification := &rkev1.ETCDSnapshotVerification{
+		VerifiedAt: &metav1.Time{Time: now},
+	}
+	if snapshot.Spec.Verify != nil {
+		verification.Generation = snapshot.Spec.Verify.Generation
+	}
+	if !h.submit(verifyRequest{
+		snapshot:       snapshot.DeepCopy(),
+		controlPlane:   controlPlane.DeepCopy(),
+		scratchRestore: scratchRestore,
+		verification:   verification,
+	}) {
+		h.etcdSnapshots.EnqueueAfter(snapshot.Namespace, snapshot.Name, busyRetryInterval)
+	}
+	return snapshot, nil
+}
+
+// submit hands the verification to an idle worker, and returns false if all workers are busy. A verification of a
+// snapshot that is already being verified is dropped, as the snapshot is handled again once its result is recorded.
+func (h *handler) submit(request verifyRequest) bool {
+	key := request.snapshot.Namespace + "/" + request.snapshot.Name
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.inFlight[key] {
+		return true
+	}
+	select {
+	case h.requests <- request:
+		h.inFlight[key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *handler) worker() {
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case request := <-h.requests:
+			h.run(request)
+		}
+	}
+}
+
+// run verifies the snapshot of the request and records the result on its status.
+func (h *handler) run(request verifyRequest) {
+	snapshot := request.snapshot
+	defer func() {
+		h.lock.Lock()
+		delete(h.inFlight, snapshot.Namespace+"/"+snapshot.Name)
+		h.lock.Unlock()
+	}()
+
+	logrus.Infof("[etcdsnapshotverify] verifying etcd snapshot %s/%s", snapshot.Namespace, snapshot.Name)
+	verification := request.verification
+	if err := h.verify(snapshot, request.controlPlane, request.scratchRestore, verification); err != nil {
+		logrus.Errorf("[etcdsnapshotverify] etcd snapshot %s/%s failed verification: %v", snapshot.Namespace, snapshot.Name, err)
+		verification.Message = err.Error()
+	} else {
+		verification.Verified = true
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := h.etcdSnapshots.Get(snapshot.Namespace, snapshot.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Status.Verification = verification
+		_, err = h.etcdSnapshots.UpdateStatus(current)
+		return err
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logrus.Errorf("[etcdsnapshotverify] failed to record verification of etcd snapshot %s/%s: %v", snapshot.Namespace, snapshot.Name, err)
+	}
+}
+
+// withVerifySchedule returns a copy of the snapshot with the verification schedule of its cluster and the time it was
+// first seen recorded in its annotations, or nil if they are already recorded.
+func withVerifySchedule(snapshot *rkev1.ETCDSnapshot, etcd *rkev1.ETCD, now time.Time) *rkev1.ETCDSnapshot {
+	schedule := ""
+	if etcd != nil {
+		schedule = etcd.SnapshotVerifyScheduleCron
+	}
+	_, recorded := snapshot.Annotations[verifyScheduleAnnotation]
+	if schedule == "" {
+		if !recorded {
+			return nil
+		}
+		snapshot = snapshot.DeepCopy()
+		delete(snapshot.Annotations, verifyScheduleAnnotation)
+		delete(snapshot.Annotations, verifyScheduleSinceAnnotation)
+		return snapshot
+	}
+	if snapshot.Annotations[verifyScheduleAnnotation] == schedule && snapshot.Annotations[verifyScheduleSinceAnnotation] != "" {
+		return nil
+	}
+
+	snapshot = snapshot.DeepCopy()
+	if snapshot.Annotations == nil {
+		snapshot.Annotations = map[string]string{}
+	}
+	snapshot.Annotations[verifyScheduleAnnotation] = schedule
+	snapshot.Annotations[verifyScheduleSinceAnnotation] = now.UTC().Format(time.RFC3339)
+	return snapshot
+}
+
+// verificationDue returns whether the snapshot should be verified and if it should be restored into a scratch etcd
+// member. A snapshot is verified when the generation of its verify request changes, or when the verification schedule
+// of its cluster fired since it was last verified, or since the schedule was recorded on the snapshot if it wasn't
+// verified since. Otherwise, the time of the next scheduled verification is returned.
+func verificationDue(snapshot *rkev1.ETCDSnapshot, etcd *rkev1.ETCD, now time.Time) (bool, bool, time.Time, error) {
+	verification := snapshot.Status.Verification
+	if verify := snapshot.Spec.Verify; verify != nil && (verification == nil || verification.Generation != verify.Generation) {
+		return true, verify.ScratchRestore, time.Time{}, nil
+	}
+
+	if etcd == nil || etcd.SnapshotVerifyScheduleCron == "" {
+		return false, false, time.Time{}, nil
+	}
+	schedule, err := cron.ParseStandard(etcd.SnapshotVerifyScheduleCron)
+	if err != nil {
+		return false, false, time.Time{}, fmt.Errorf("invalid snapshotVerifyScheduleCron %s: %w", etcd.SnapshotVerifyScheduleCron, err)
+	}
+
+	last, err := time.Parse(time.RFC3339, snapshot.Annotations[verifyScheduleSinceAnnotation])
+	if err != nil || snapshot.Annotations[verifyScheduleAnnotation] != etcd.SnapshotVerifyScheduleCron {
+		// the schedule is not recorded yet
+		return false, false, time.Time{}, nil
+	}
+	if verification != nil && verification.VerifiedAt != nil && verification.VerifiedAt.After(last) {
+		last = verification.VerifiedAt.Time
+	}
+	if next := schedule.Next(last); next.After(now) {
+		return false, false, next, nil
+	}
+	return true, etcd.SnapshotVerifyScratchRestore, time.Time{}, nil
+}
+
+// verify downloads the snapshot from S3 or copies it from the node that stores it, verifies it, and optionally restores
+// it into a scratch etcd member. The result is set on the given verification.
+func (h *handler) verify(snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane, scratchRestore bool, verification *rkev1.ETCDSnapshotVerification) error {
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(h.dir, "snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(h.ctx, verifyTimeout)
+	defer cancel()
+
+	snapshotPath := filepath.Join(dir, "snapshot")
+	if snapshot.SnapshotFile.S3 == nil {
+		if err := h.fetchFromNode(ctx, snapshot, controlPlane, snapshotPath); err != nil {
+			return err
+		}
+	} else if err := h.downloadFromS3(ctx, snapshot, controlPlane, snapshotPath); err != nil {
+		return err
+	}
+
+	result, err := etcdverify.Verify(snapshotPath, snapshot.SnapshotFile.Size)
+	if err != nil {
+		return err
+	}
+	verification.SHA256 = result.SHA256
+	verification.Size = result.Size
+	verification.Keys = result.Keys
+	if !result.HasHash {
+		logrus.Warnf("[etcdsnapshotverify] etcd snapshot %s/%s has no integrity hash, only the database was checked", snapshot.Namespace, snapshot.Name)
+	}
+
+	if scratchRestore {
+		if err := etcdverify.ScratchRestore(ctx, dir, result.SnapshotPath); err != nil {
+			return fmt.Errorf("scratch restore failed: %w", err)
+		}
+		verification.ScratchRestored = true
+	}
+	return nil
+}
+
+// downloadFromS3 downloads the snapshot stored in S3 to dst.
+func (h *handler) downloadFromS3(ctx context.Context, snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane, dst string) error {
+	config, err := h.s3Config(snapshot.SnapshotFile.S3, controlPlane)
+	if err != nil {
+		return err
+	}
+	client, err := newS3Client(config)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(config.folder, snapshot.SnapshotFile.Name)
+	if err := client.FGetObject(ctx, config.bucket, key, dst, minio.GetObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to download snapshot %s from bucket %s: %w", key, config.bucket, err)
+	}
+	return nil
+}
+
+type s3Config struct {
+	endpoint      string
+	accessKey     string
+	secretKey     string
+	region        string
+	bucket        string
+	folder        string
+	endpointCA    string
+	skipSSLVerify bool
+}
+
+// s3Config returns the S3 configuration of the cluster with its cloud credential, which is used to download the
+// snapshot. The snapshot is reported by the cluster, so its S3 configuration is only used to find it in the bucket of the
+// cluster: its endpoint and bucket must be the ones of the cluster, and it may not name another cloud credential, so
+// that Rancher doesn't send the credential, or any other credential, to an endpoint chosen by the cluster.
+func (h *handler) s3Config(s3 *rkev1.ETCDSnapshotS3, controlPlane *rkev1.RKEControlPlane) (s3Config, error) {
+	var clusterS3 rkev1.ETCDSnapshotS3
+	if controlPlane.Spec.ETCD != nil && controlPlane.Spec.ETCD.S3 != nil {
+		clusterS3 = *controlPlane.Spec.ETCD.S3
+	}
+	if clusterS3.CloudCredentialName == "" {
+		return s3Config{}, fmt.Errorf("snapshots stored in S3 can only be verified with the S3 cloud credential of the cluster, which has none")
+	}
+	if s3.CloudCredentialName != "" && s3.CloudCredentialName != clusterS3.CloudCredentialName {
+		return s3Config{}, fmt.Errorf("snapshot uses cloud credential %s instead of the S3 cloud credential of the cluster", s3.CloudCredentialName)
+	}
+
+	cred, err := planner.GetS3Credential(h.secrets, controlPlane.Namespace, clusterS3.CloudCredentialName)
+	if err != nil {
+		return s3Config{}, err
+	}
+	if cred.AccessKey == "" || cred.SecretKey == "" {
+		return s3Config{}, fmt.Errorf("S3 cloud credential %s of the cluster has no access key", clusterS3.CloudCredentialName)
+	}
+
+	config := s3Config{
+		endpoint:      first(clusterS3.Endpoint, cred.Endpoint, defaultS3Endpoint),
+		accessKey:     cred.AccessKey,
+		secretKey:     cred.SecretKey,
+		region:        first(s3.Region, clusterS3.Region, cred.Region),
+		bucket:        first(clusterS3.Bucket, cred.Bucket),
+		folder:        first(s3.Folder, clusterS3.Folder, cred.Folder),
+		endpointCA:    first(clusterS3.EndpointCA, cred.EndpointCA),
+		skipSSLVerify: clusterS3.SkipSSLVerify || cred.SkipSSLVerify,
+	}
+	if s3.Endpoint != "" && normalizeEndpoint(s3.Endpoint) != normalizeEndpoint(config.endpoint) {
+		return s3Config{}, fmt.Errorf("snapshot is stored at S3 endpoint %s instead of the S3 endpoint %s of the cluster", s3.Endpoint, config.endpoint)
+	}
+	if s3.Bucket != "" && s3.Bucket != config.bucket {
+		return s3Config{}, fmt.Errorf("snapshot is stored in bucket %s instead of the bucket %s of the cluster", s3.Bucket, config.bucket)
+	}
+	return config, nil
+}
+
+// normalizeEndpoint removes the https scheme and trailing slashes of an S3 endpoint, which RKE2 and K3s ignore.
+func normalizeEndpoint(endpoint string) string {
+	return strings.TrimRight(strings.TrimPrefix(endpoint, "https://"), "/")
+}
+
+func newS3Client(config s3Config) (*minio.Client, error) {
+	if config.bucket == "" {
+		return nil, fmt.Errorf("snapshot has no S3 bucket")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: config.skipSSLVerify,
+	}
+	if config.endpointCA != "" {
+		ca := []byte(config.endpointCA)
+		if decoded, err := base64.StdEncoding.DecodeString(config.endpointCA); err == nil {
+			ca = decoded
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("endpoint CA of the snapshot is not a PEM encoded certificate")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	// the credentials of Rancher itself, such as the role of its instance, are never used
+	if config.accessKey == "" || config.secretKey == "" {
+		return nil, fmt.Errorf("snapshot has no S3 access key")
+	}
+	creds := credentials.NewStaticV4(config.accessKey, config.secretKey, "")
+
+	endpoint := normalizeEndpoint(config.endpoint)
+	secure := true
+	if strings.HasPrefix(endpoint, "http://") {
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		secure = false
+	}
+
+	return minio.New(endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    secure,
+		Region:    config.region,
+		Transport: transport,
+	})
+}
+
+// first returns the first non-blank value.
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,381 @@
+package etcdsnapshotverify
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	ctrlfake "github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVerificationDue(t *testing.T) {
+	now := time.Date(2023, time.October, 11, 12, 30, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-24 * time.Hour))
+	verifiedAt := metav1.NewTime(now.Add(-15 * time.Minute))
+	hourly := &rkev1.ETCD{SnapshotVerifyScheduleCron: "0 * * * *", SnapshotVerifyScratchRestore: true}
+
+	scheduledSince := now.Add(-24 * time.Hour).Format(time.RFC3339)
+	snapshot := func(verify *rkev1.ETCDSnapshotVerify, verification *rkev1.ETCDSnapshotVerification, s3 bool) *rkev1.ETCDSnapshot {
+		snapshot := &rkev1.ETCDSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: created,
+				Annotations: map[string]string{
+					verifyScheduleAnnotation:      "0 * * * *",
+					verifyScheduleSinceAnnotation: scheduledSince,
+				},
+			},
+			Spec:   rkev1.ETCDSnapshotSpec{Verify: verify},
+			Status: rkev1.ETCDSnapshotStatus{Verification: verification},
+		}
+		if s3 {
+			snapshot.SnapshotFile.S3 = &rkev1.ETCDSnapshotS3{Bucket: "snapshots"}
+		}
+		return snapshot
+	}
+
+	tests := []struct {
+		name               string
+		snapshot           *rkev1.ETCDSnapshot
+		etcd               *rkev1.ETCD
+		wantDue            bool
+		wantScratchRestore bool
+		wantNext           time.Time
+		wantErr            bool
+	}{
+		{
+			name:     "not requested",
+			snapshot: snapshot(nil, nil, true),
+		},
+		{
+			name:               "requested",
+			snapshot:           snapshot(&rkev1.ETCDSnapshotVerify{ScratchRestore: true}, nil, false),
+			wantDue:            true,
+			wantScratchRestore: true,
+		},
+		{
+			name:     "request was verified",
+			snapshot: snapshot(&rkev1.ETCDSnapshotVerify{Generation: 2}, &rkev1.ETCDSnapshotVerification{Generation: 2, VerifiedAt: &verifiedAt}, false),
+			etcd:     hourly,
+			wantNext: time.Date(2023, time.October, 11, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "request generation changed",
+			snapshot: snapshot(&rkev1.ETCDSnapshotVerify{Generation: 3}, &rkev1.ETCDSnapshotVerification{Generation: 2, VerifiedAt: &verifiedAt}, false),
+			wantDue:  true,
+		},
+		{
+			name:               "never verified on schedule",
+			snapshot:           snapshot(nil, nil, true),
+			etcd:               hourly,
+			wantDue:            true,
+			wantScratchRestore: true,
+		},
+		{
+			name:     "verified since the schedule fired",
+			snapshot: snapshot(nil, &rkev1.ETCDSnapshotVerification{VerifiedAt: &verifiedAt}, true),
+			etcd:     hourly,
+			wantNext: time.Date(2023, time.October, 11, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "schedule was enabled after the snapshot was created",
+			snapshot: func() *rkev1.ETCDSnapshot {
+				s := snapshot(nil, nil, true)
+				s.Annotations[verifyScheduleSinceAnnotation] = now.Add(-10 * time.Minute).Format(time.RFC3339)
+				return s
+			}(),
+			etcd:     hourly,
+			wantNext: time.Date(2023, time.October, 11, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "schedule is not recorded yet",
+			snapshot: func() *rkev1.ETCDSnapshot {
+				s := snapshot(nil, nil, true)
+				s.Annotations = nil
+				return s
+			}(),
+			etcd: hourly,
+		},
+		{
+			name:               "local snapshots are verified on schedule",
+			snapshot:           snapshot(nil, nil, false),
+			etcd:               hourly,
+			wantDue:            true,
+			wantScratchRestore: true,
+		},
+		{
+			name:     "invalid schedule",
+			snapshot: snapshot(nil, nil, true),
+			etcd:     &rkev1.ETCD{SnapshotVerifyScheduleCron: "hourly"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, scratchRestore, next, err := verificationDue(tt.snapshot, tt.etcd, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDue, due)
+			assert.Equal(t, tt.wantScratchRestore, scratchRestore)
+			assert.True(t, tt.wantNext.Equal(next), "expected next verification at %s, got %s", tt.wantNext, next)
+		})
+	}
+}
+
+func TestWithVerifySchedule(t *testing.T) {
+	now := time.Date(2023, time.October, 11, 12, 30, 0, 0, time.UTC)
+	hourly := &rkev1.ETCD{SnapshotVerifyScheduleCron: "0 * * * *"}
+	snapshot := func(annotations map[string]string, s3 bool) *rkev1.ETCDSnapshot {
+		snapshot := &rkev1.ETCDSnapshot{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+		if s3 {
+			snapshot.SnapshotFile.S3 = &rkev1.ETCDSnapshotS3{Bucket: "snapshots"}
+		}
+		return snapshot
+	}
+	recorded := map[string]string{
+		verifyScheduleAnnotation:      "0 * * * *",
+		verifyScheduleSinceAnnotation: "2023-10-10T00:00:00Z",
+	}
+
+	tests := []struct {
+		name            string
+		snapshot        *rkev1.ETCDSnapshot
+		etcd            *rkev1.ETCD
+		wantUpdated     bool
+		wantAnnotations map[string]string
+	}{
+		{
+			name:     "no schedule",
+			snapshot: snapshot(nil, true),
+		},
+		{
+			name:        "schedule is enabled",
+			snapshot:    snapshot(nil, true),
+			etcd:        hourly,
+			wantUpdated: true,
+			wantAnnotations: map[string]string{
+				verifyScheduleAnnotation:      "0 * * * *",
+				verifyScheduleSinceAnnotation: "2023-10-11T12:30:00Z",
+			},
+		},
+		{
+			name:     "schedule is recorded",
+			snapshot: snapshot(recorded, true),
+			etcd:     hourly,
+		},
+		{
+			name:        "schedule changed",
+			snapshot:    snapshot(recorded, true),
+			etcd:        &rkev1.ETCD{SnapshotVerifyScheduleCron: "0 0 * * *"},
+			wantUpdated: true,
+			wantAnnotations: map[string]string{
+				verifyScheduleAnnotation:      "0 0 * * *",
+				verifyScheduleSinceAnnotation: "2023-10-11T12:30:00Z",
+			},
+		},
+		{
+			name:            "schedule is disabled",
+			snapshot:        snapshot(recorded, true),
+			etcd:            &rkev1.ETCD{},
+			wantUpdated:     true,
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name:        "local snapshots have a schedule",
+			snapshot:    snapshot(nil, false),
+			etcd:        hourly,
+			wantUpdated: true,
+			wantAnnotations: map[string]string{
+				verifyScheduleAnnotation:      "0 * * * *",
+				verifyScheduleSinceAnnotation: "2023-10-11T12:30:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withVerifySchedule(tt.snapshot, tt.etcd, now)
+			if !tt.wantUpdated {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tt.wantAnnotations, got.Annotations)
+		})
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	h := &handler{requests: make(chan verifyRequest), inFlight: map[string]bool{}}
+	request := verifyRequest{snapshot: &rkev1.ETCDSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "snapshot"}}}
+
+	// no worker is idle
+	assert.False(t, h.submit(request))
+
+	received := make(chan verifyRequest)
+	go func() {
+		received <- <-h.requests
+	}()
+	assert.Eventually(t, func() bool { return h.submit(request) }, time.Second, time.Millisecond)
+	<-received
+	assert.True(t, h.inFlight["fleet-default/snapshot"])
+
+	// the snapshot is already being verified
+	assert.True(t, h.submit(request))
+}
+
+func TestFetchPod(t *testing.T) {
+	controlPlane := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.27.6+k3s1"},
+	}
+	snapshot := func(name, location, nodeName string) *rkev1.ETCDSnapshot {
+		return &rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{Name: name, Location: location, NodeName: nodeName}}
+	}
+
+	pod, err := fetchPod(snapshot("etcd-snapshot-node-1-1697025600", "file:///data/snapshots/etcd-snapshot-node-1-1697025600", "node-1"), controlPlane)
+	require.NoError(t, err)
+	assert.Equal(t, "node-1", pod.Spec.NodeName)
+	require.Len(t, pod.Spec.Volumes, 1)
+	assert.Equal(t, "/data/snapshots", pod.Spec.Volumes[0].HostPath.Path, "snapshots are read from where the node reported them")
+	require.Len(t, pod.Spec.Containers, 1)
+	assert.True(t, pod.Spec.Containers[0].VolumeMounts[0].ReadOnly)
+
+	for _, name := range []string{"", ".", "..", "../../etc/shadow", `..\shadow`} {
+		_, err := fetchPod(snapshot(name, "file:///var/lib/rancher/k3s/server/db/snapshots/"+name, "node-1"), controlPlane)
+		assert.Error(t, err, "snapshot name %q", name)
+	}
+	for _, location := range []string{
+		"",
+		"s3://bucket/etcd-snapshot-node-1-1697025600",
+		"file://host/snapshots/etcd-snapshot-node-1-1697025600",
+		"file://snapshots/etcd-snapshot-node-1-1697025600",
+		"file:///var/lib/rancher/k3s/server/db/snapshots/other",
+		"file:///var/lib/rancher/k3s/server/db/snapshots/../../../../../etc/etcd-snapshot-node-1-1697025600",
+		"file:///etcd-snapshot-node-1-1697025600",
+	} {
+		_, err := fetchPod(snapshot("etcd-snapshot-node-1-1697025600", location, "node-1"), controlPlane)
+		assert.Error(t, err, "snapshot location %q", location)
+	}
+	_, err = fetchPod(snapshot("etcd-snapshot-node-1-1697025600", "file:///data/snapshots/etcd-snapshot-node-1-1697025600", ""), controlPlane)
+	assert.Error(t, err)
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &limitedWriter{w: &buf, remaining: 4}
+	_, err := w.Write([]byte("abc"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("de"))
+	assert.ErrorIs(t, err, errSnapshotTooLarge)
+	assert.Equal(t, "abc", buf.String())
+}
+
+func TestS3Config(t *testing.T) {
+	controlPlane := func(s3 *rkev1.ETCDSnapshotS3) *rkev1.RKEControlPlane {
+		return &rkev1.RKEControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default"},
+			Spec: rkev1.RKEControlPlaneSpec{
+				RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+					ETCD: &rkev1.ETCD{S3: s3},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name         string
+		snapshotS3   *rkev1.ETCDSnapshotS3
+		clusterS3    *rkev1.ETCDSnapshotS3
+		expected     s3Config
+		expectedErr  string
+		noAccessKeys bool
+	}{
+		{
+			name: "snapshot in the bucket of the cluster",
+			snapshotS3: &rkev1.ETCDSnapshotS3{
+				Endpoint:      "https://s3.amazonaws.com/",
+				Bucket:        "default-bucket",
+				EndpointCA:    "/var/lib/rancher/rke2/server/db/s3-endpoint-ca.crt",
+				SkipSSLVerify: true,
+			},
+			clusterS3: &rkev1.ETCDSnapshotS3{CloudCredentialName: "s3-creds"},
+			expected: s3Config{
+				endpoint:   defaultS3Endpoint,
+				accessKey:  "access",
+				secretKey:  "secret",
+				bucket:     "default-bucket",
+				folder:     "default-folder",
+				endpointCA: "ca",
+			},
+		},
+		{
+			name:        "cluster without cloud credential",
+			snapshotS3:  &rkev1.ETCDSnapshotS3{Bucket: "default-bucket"},
+			clusterS3:   &rkev1.ETCDSnapshotS3{Bucket: "default-bucket"},
+			expectedErr: "has none",
+		},
+		{
+			name:        "snapshot with another cloud credential",
+			snapshotS3:  &rkev1.ETCDSnapshotS3{CloudCredentialName: "other-creds"},
+			clusterS3:   &rkev1.ETCDSnapshotS3{CloudCredentialName: "s3-creds"},
+			expectedErr: "uses cloud credential other-creds",
+		},
+		{
+			name:        "snapshot at another endpoint",
+			snapshotS3:  &rkev1.ETCDSnapshotS3{Endpoint: "169.254.169.254", Bucket: "default-bucket"},
+			clusterS3:   &rkev1.ETCDSnapshotS3{CloudCredentialName: "s3-creds"},
+			expectedErr: "S3 endpoint 169.254.169.254",
+		},
+		{
+			name:        "snapshot in another bucket",
+			snapshotS3:  &rkev1.ETCDSnapshotS3{Bucket: "other-bucket"},
+			clusterS3:   &rkev1.ETCDSnapshotS3{CloudCredentialName: "s3-creds"},
+			expectedErr: "bucket other-bucket",
+		},
+		{
+			name:         "cloud credential without access key",
+			snapshotS3:   &rkev1.ETCDSnapshotS3{Bucket: "default-bucket"},
+			clusterS3:    &rkev1.ETCDSnapshotS3{CloudCredentialName: "s3-creds"},
+			noAccessKeys: true,
+			expectedErr:  "has no access key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			data := map[string][]byte{
+				"s3credentialConfig-accessKey":         []byte("access"),
+				"s3credentialConfig-secretKey":         []byte("secret"),
+				"s3credentialConfig-defaultBucket":     []byte("default-bucket"),
+				"s3credentialConfig-defaultFolder":     []byte("default-folder"),
+				"s3credentialConfig-defaultEndpointCA": []byte("ca"),
+			}
+			if tt.noAccessKeys {
+				delete(data, "s3credentialConfig-accessKey")
+				delete(data, "s3credentialConfig-secretKey")
+			}
+			secrets := ctrlfake.NewMockCacheInterface[*corev1.Secret](ctrl)
+			secrets.EXPECT().Get("fleet-default", "s3-creds").Return(&corev1.Secret{Data: data}, nil).AnyTimes()
+			h := &handler{secrets: secrets}
+
+			got, err := h.s3Config(tt.snapshotS3, controlPlane(tt.clusterS3))
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestNewS3ClientRequiresAccessKey(t *testing.T) {
+	_, err := newS3Client(s3Config{endpoint: defaultS3Endpoint, bucket: "snapshots"})
+	assert.ErrorContains(t, err, "no S3 access key", "the credentials of Rancher itself are never used")
+}
@@ -0,0 +1,217 @@
+package etcdsnapshotverify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/provisioningv2/image"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// maxSnapshotSize is the largest snapshot that is copied from a node, which is the largest size of an etcd
+	// database with the hash etcd appends to snapshots.
+	maxSnapshotSize = 8<<30 + 512
+	// fetchPodLabel labels the pods which copy snapshots from the nodes of a cluster.
+	fetchPodLabel     = "rke.cattle.io/etcd-snapshot-verify"
+	fetchContainer    = "snapshot"
+	fetchMountPath    = "/snapshots"
+	fetchPollInterval = 2 * time.Second
+)
+
+// errSnapshotTooLarge is returned when a snapshot copied from a node is larger than maxSnapshotSize.
+var errSnapshotTooLarge = errors.New("snapshot is larger than the largest etcd database")
+
+// fetchFromNode copies the snapshot stored on a node of the cluster to dst. It is read from the directory of the location
+// the node reported for it, which is where it is restored from: a pod which mounts that directory read-only is run on
+// the node, and the snapshot is streamed out of it through the API of the cluster.
+func (h *handler) fetchFromNode(ctx context.Context, snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane, dst string) error {
+	pod, err := fetchPod(snapshot, controlPlane)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := h.clusters.Get(controlPlane.Namespace, controlPlane.Name)
+	if err != nil {
+		return err
+	}
+	config, err := h.kubeconfigManager.GetRESTConfig(cluster, cluster.Status)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	pods := clientset.CoreV1().Pods(pod.Namespace)
+
+	pod, err = pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create pod to copy snapshot from node %s: %w", snapshot.SnapshotFile.NodeName, err)
+	}
+	defer func() {
+		// the pod is deleted even if the verification timed out
+		if err := pods.Delete(context.Background(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: new(int64)}); err != nil {
+			logrus.Warnf("[etcdsnapshotverify] failed to delete pod %s/%s of cluster %s/%s: %v", pod.Namespace, pod.Name, controlPlane.Namespace, controlPlane.Name, err)
+		}
+	}()
+
+	err = wait.PollImmediateUntilWithContext(ctx, fetchPollInterval, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch current.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, fmt.Errorf("pod to copy snapshot from node %s exited: %s", snapshot.SnapshotFile.NodeName, current.Status.Message)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: fetchContainer,
+			Command:   []string{"cat", path.Join(fetchMountPath, snapshot.SnapshotFile.Name)},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: &limitedWriter{w: f, remaining: maxSnapshotSize},
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy snapshot from node %s: %w: %s", snapshot.SnapshotFile.NodeName, err, strings.TrimSpace(stderr.String()))
+	}
+	return f.Close()
+}
+
+// fetchPod returns the pod which copies the snapshot from the snapshot directory of the node that stores it.
+func fetchPod(snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane) (*corev1.Pod, error) {
+	dir, err := snapshotDir(snapshot.SnapshotFile)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.SnapshotFile.NodeName == "" {
+		return nil, fmt.Errorf("snapshot is stored on no node")
+	}
+
+	deadline := int64(verifyTimeout.Seconds())
+	hostPathType := corev1.HostPathDirectory
+	automount := false
+	root := int64(0)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "etcd-snapshot-verify-",
+			Namespace:    namespaces.System,
+			Labels: map[string]string{
+				fetchPodLabel: "true",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                     snapshot.SnapshotFile.NodeName,
+			RestartPolicy:                corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds:        &deadline,
+			AutomountServiceAccountToken: &automount,
+			Tolerations: []corev1.Toleration{{
+				Operator: corev1.TolerationOpExists,
+			}},
+			Containers: []corev1.Container{{
+				Name:    fetchContainer,
+				Image:   image.ResolveWithControlPlane(settings.ShellImage.Get(), controlPlane),
+				Command: []string{"sleep", fmt.Sprint(deadline)},
+				SecurityContext: &corev1.SecurityContext{
+					// snapshots are only readable by root
+					RunAsUser: &root,
+				},
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      "snapshots",
+					MountPath: fetchMountPath,
+					ReadOnly:  true,
+				}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "snapshots",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: dir,
+						Type: &hostPathType,
+					},
+				},
+			}},
+		},
+	}, nil
+}
+
+// snapshotDir returns the directory of the node that stores the snapshot, which is taken from the file:// location the
+// node reported for it. The location must be a clean absolute path to the file name of the snapshot.
+func snapshotDir(file rkev1.ETCDSnapshotFile) (string, error) {
+	name := file.Name
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid snapshot file name %q", name)
+	}
+	location, err := url.Parse(file.Location)
+	if err != nil {
+		return "", fmt.Errorf("invalid snapshot location %q: %w", file.Location, err)
+	}
+	if location.Scheme != "file" || location.Host != "" || !path.IsAbs(location.Path) ||
+		path.Clean(location.Path) != location.Path || strings.Contains(location.Path, `\`) || path.Base(location.Path) != name {
+		return "", fmt.Errorf("invalid location %q of snapshot file %q", file.Location, name)
+	}
+	dir := path.Dir(location.Path)
+	if dir == "/" {
+		return "", fmt.Errorf("invalid location %q of snapshot file %q", file.Location, name)
+	}
+	return dir, nil
+}
+
+// limitedWriter fails writes beyond the remaining number of bytes.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, errSnapshotTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.remaining -= int64(n)
+	return n, err
+}
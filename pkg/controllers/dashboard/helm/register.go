@@ -11,6 +11,7 @@ func Register(ctx context.Context, wrangler *wrangler.Context) {
 		wrangler.Apply,
 		wrangler.Core.Secret().Cache(),
 		wrangler.Catalog.ClusterRepo(),
+		wrangler.Catalog.Repo(),
 		wrangler.Core.ConfigMap(),
 		wrangler.Core.ConfigMap().Cache())
 	RegisterApps(ctx,
@@ -12,17 +12,21 @@
 	"github.com/rancher/rancher/pkg/catalogv2"
 	"github.com/rancher/rancher/pkg/catalogv2/git"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	namespaces "github.com/rancher/rancher/pkg/namespace"
 	"github.com/rancher/wrangler/pkg/apply"
 	"github.com/rancher/wrangler/pkg/condition"
 	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	name2 "github.com/rancher/wrangler/pkg/name"
+	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/repo"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -31,11 +35,18 @@
 
 var (
 	interval = 5 * time.Minute
+	// minBackoff and maxBackoff bound the delay before a failed download of a repo index is retried.
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+	// backoffJitter is the fraction of the backoff added at random, so that repos which failed together are not all
+	// retried together.
+	backoffJitter = 0.2
 )
 
 type repoHandler struct {
 	secrets        corev1controllers.SecretCache
 	clusterRepos   catalogcontrollers.ClusterRepoController
+	repos          catalogcontrollers.RepoController
 	configMaps     corev1controllers.ConfigMapClient
 	configMapCache corev1controllers.ConfigMapCache
 	apply          apply.Apply
@@ -45,55 +56,160 @@ func RegisterRepos(ctx context.Context,
 	apply apply.Apply,
 	secrets corev1controllers.SecretCache,
 	clusterRepos catalogcontrollers.ClusterRepoController,
+	repos catalogcontrollers.RepoController,
 	configMap corev1controllers.ConfigMapController,
 	configMapCache corev1controllers.ConfigMapCache) {
 	h := &repoHandler{
 		secrets:        secrets,
 		clusterRepos:   clusterRepos,
+		repos:          repos,
 		configMaps:     configMap,
 		configMapCache: configMapCache,
 		apply:          apply.WithCacheTypes(configMap).WithStrictCaching().WithSetOwnerReference(false, false),
 	}
 
+	// The Downloaded condition is set by the handler rather than the status handler, which would discard the backoff
+	// recorded in the status when the download fails.
 	catalogcontrollers.RegisterClusterRepoStatusHandler(ctx, clusterRepos,
-		condition.Cond(catalog.RepoDownloaded), "helm-clusterrepo-download", h.ClusterRepoDownloadStatusHandler)
+		"", "helm-clusterrepo-download", h.ClusterRepoDownloadStatusHandler)
+	catalogcontrollers.RegisterRepoStatusHandler(ctx, repos,
+		"", "helm-repo-download", h.RepoDownloadStatusHandler)
 
 }
 
 func RegisterReposForFollowers(ctx context.Context,
 	secrets corev1controllers.SecretCache,
-	clusterRepos catalogcontrollers.ClusterRepoController) {
+	clusterRepos catalogcontrollers.ClusterRepoController,
+	repos catalogcontrollers.RepoController) {
 	h := &repoHandler{
 		secrets:      secrets,
 		clusterRepos: clusterRepos,
+		repos:        repos,
 	}
 
 	catalogcontrollers.RegisterClusterRepoStatusHandler(ctx, clusterRepos,
 		condition.Cond(catalog.FollowerRepoDownloaded), "helm-clusterrepo-ensure", h.ClusterRepoDownloadEnsureStatusHandler)
+	catalogcontrollers.RegisterRepoStatusHandler(ctx, repos,
+		condition.Cond(catalog.FollowerRepoDownloaded), "helm-repo-ensure", h.RepoDownloadEnsureStatusHandler)
 
 }
 
 func (r *repoHandler) ClusterRepoDownloadEnsureStatusHandler(repo *catalog.ClusterRepo, status catalog.RepoStatus) (catalog.RepoStatus, error) {
-	r.clusterRepos.EnqueueAfter(repo.Name, interval)
+	r.clusterRepos.EnqueueAfter(repo.Name, refreshInterval(&repo.Spec))
+	return r.ensure(&repo.Spec, status, &repo.ObjectMeta)
+}
+
+func (r *repoHandler) RepoDownloadEnsureStatusHandler(repo *catalog.Repo, status catalog.RepoStatus) (catalog.RepoStatus, error) {
+	r.repos.EnqueueAfter(repo.Namespace, repo.Name, refreshInterval(&repo.Spec))
 	return r.ensure(&repo.Spec, status, &repo.ObjectMeta)
 }
 
 func (r *repoHandler) ClusterRepoDownloadStatusHandler(repo *catalog.ClusterRepo, status catalog.RepoStatus) (catalog.RepoStatus, error) {
-	err := r.ensureIndexConfigMap(repo, &status)
+	return r.downloadStatus(&repo.Spec, status, &repo.ObjectMeta, "ClusterRepo", func(after time.Duration) {
+		r.clusterRepos.EnqueueAfter(repo.Name, after)
+	})
+}
+
+func (r *repoHandler) RepoDownloadStatusHandler(repo *catalog.Repo, status catalog.RepoStatus) (catalog.RepoStatus, error) {
+	return r.downloadStatus(&repo.Spec, status, &repo.ObjectMeta, "Repo", func(after time.Duration) {
+		r.repos.EnqueueAfter(repo.Namespace, repo.Name, after)
+	})
+}
+
+// downloadStatus downloads the index of the ClusterRepo or Repo of the given kind when it is due for a refresh, and
+// records the result in its status. The repo is enqueued again when its next refresh or retry is due.
+func (r *repoHandler) downloadStatus(spec *catalog.RepoSpec, status catalog.RepoStatus, metadata *metav1.ObjectMeta, kind string, enqueueAfter func(time.Duration)) (catalog.RepoStatus, error) {
+	origStatus := status.DeepCopy()
+	err := r.ensureIndexConfigMap(spec, &status)
 	if err != nil {
 		return status, err
 	}
-	if !shouldRefresh(&repo.Spec, &status) {
-		r.clusterRepos.EnqueueAfter(repo.Name, interval)
+
+	now := time.Now()
+	if retryIn := backoffRemaining(metadata.Generation, &status, now); retryIn > 0 {
+		enqueueAfter(retryIn)
+		return status, nil
+	}
+	if !shouldRefresh(spec, &status) {
+		enqueueAfter(refreshInterval(spec))
 		return status, nil
 	}
 
-	return r.download(&repo.Spec, status, &repo.ObjectMeta, metav1.OwnerReference{
+	newStatus, err := r.download(spec, status, metadata, metav1.OwnerReference{
 		APIVersion: catalog.SchemeGroupVersion.Group + "/" + catalog.SchemeGroupVersion.Version,
-		Kind:       "ClusterRepo",
-		Name:       repo.Name,
-		UID:        repo.UID,
+		Kind:       kind,
+		Name:       metadata.Name,
+		UID:        metadata.UID,
 	})
+	if err != nil {
+		// keep the status of the last successful download and retry later rather than returning the error, which
+		// would retry as soon as the rate limit of the controller allows
+		status = recordDownloadFailure(status, metadata.Generation, err, now)
+		logrus.Errorf("Failed to download index of %s %s, retrying at %s: %v", kind, repoKey(metadata), status.NextAttemptTime.Format(time.RFC3339), err)
+		enqueueAfter(status.NextAttemptTime.Sub(now))
+	} else {
+		status = newStatus
+		status.LastDownloadError = ""
+		status.DownloadFailures = 0
+		status.NextAttemptTime = metav1.Time{}
+	}
+
+	downloaded := condition.Cond(catalog.RepoDownloaded)
+	downloaded.SetError(&status, "", err)
+	if !equality.Semantic.DeepEqual(origStatus, &status) {
+		downloaded.LastUpdated(&status, now.UTC().Format(time.RFC3339))
+	}
+	return status, nil
+}
+
+// repoKey returns the name of a ClusterRepo, or the namespace and name of a Repo.
+func repoKey(metadata *metav1.ObjectMeta) string {
+	if metadata.Namespace == "" {
+		return metadata.Name
+	}
+	return metadata.Namespace + "/" + metadata.Name
+}
+
+// refreshInterval returns how often the index of the repo is downloaded.
+func refreshInterval(spec *catalog.RepoSpec) time.Duration {
+	if spec.RefreshInterval > 0 {
+		return time.Duration(spec.RefreshInterval) * time.Second
+	}
+	return interval
+}
+
+// recordDownloadFailure records the failed download in the status along with when it is to be retried.
+func recordDownloadFailure(status catalog.RepoStatus, generation int64, err error, now time.Time) catalog.RepoStatus {
+	status.ObservedGeneration = generation
+	status.DownloadFailures++
+	status.LastDownloadError = err.Error()
+	status.NextAttemptTime = metav1.NewTime(now.Add(backoff(status.DownloadFailures)))
+	return status
+}
+
+// backoff returns how long to wait before retrying a download which failed the given number of times in a row. It
+// doubles with each failure, from minBackoff up to maxBackoff, with up to backoffJitter of it added at random.
+func backoff(failures int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return wait.Jitter(delay, backoffJitter)
+}
+
+// backoffRemaining returns how long until the failed download of the repo is retried, or 0 if it isn't backing off.
+// Changing the spec of the repo retries immediately.
+func backoffRemaining(generation int64, status *catalog.RepoStatus, now time.Time) time.Duration {
+	if status.DownloadFailures == 0 || status.ObservedGeneration != generation {
+		return 0
+	}
+	if remaining := status.NextAttemptTime.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
 }
 
 func toOwnerObject(namespace string, owner metav1.OwnerReference) runtime.Object {
@@ -224,10 +340,14 @@ func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoSt
 			return status, nil
 		}
 		index, err = git.BuildOrGetIndex(metadata.Namespace, metadata.Name, repoSpec.GitRepo)
+	} else if oci.IsOCI(repoSpec.URL) {
+		status.URL = repoSpec.URL
+		status.Branch = ""
+		index, err = oci.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify, metadata.Namespace != "")
 	} else if repoSpec.URL != "" {
 		status.URL = repoSpec.URL
 		status.Branch = ""
-		index, err = helmhttp.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify, repoSpec.DisableSameOriginCheck)
+		index, err = helmhttp.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify, repoSpec.DisableSameOriginCheck, metadata.Namespace != "")
 	} else {
 		return status, nil
 	}
@@ -255,11 +375,11 @@ func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoSt
 	return status, nil
 }
 
-func (r *repoHandler) ensureIndexConfigMap(repo *catalog.ClusterRepo, status *catalog.RepoStatus) error {
-	// Charts from the clusterRepo will be unavailable if the IndexConfigMap recorded in the status does not exist.
+func (r *repoHandler) ensureIndexConfigMap(spec *catalog.RepoSpec, status *catalog.RepoStatus) error {
+	// Charts from the repo will be unavailable if the IndexConfigMap recorded in the status does not exist.
 	// By resetting the value of IndexConfigMapName, IndexConfigMapNamespace, IndexConfigMapResourceVersion to "",
 	// the method shouldRefresh will return true and trigger the rebuild of the IndexConfigMap and accordingly update the status.
-	if repo.Spec.GitRepo != "" && status.IndexConfigMapName != "" {
+	if spec.GitRepo != "" && status.IndexConfigMapName != "" {
 		_, err := r.configMapCache.Get(status.IndexConfigMapNamespace, status.IndexConfigMapName)
 		if err != nil {
 			if apierrors.IsNotFound(err) {
@@ -290,6 +410,6 @@ func shouldRefresh(spec *catalog.RepoSpec, status *catalog.RepoStatus) bool {
 	if spec.ForceUpdate != nil && spec.ForceUpdate.After(status.DownloadTime.Time) && spec.ForceUpdate.Time.Before(time.Now()) {
 		return true
 	}
-	refreshTime := time.Now().Add(-interval)
+	refreshTime := time.Now().Add(-refreshInterval(spec))
 	return refreshTime.After(status.DownloadTime.Time)
 }
@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -209,3 +210,56 @@ func TestShouldRefresh(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldRefreshWithRefreshInterval(t *testing.T) {
+	spec := &catalog.RepoSpec{URL: "https://example.com", RefreshInterval: 3600}
+	status := &catalog.RepoStatus{
+		URL:                "https://example.com",
+		IndexConfigMapName: "configmap",
+		DownloadTime:       metav1.Time{Time: time.Now().Add(-30 * time.Minute)},
+	}
+	assert.False(t, shouldRefresh(spec, status))
+
+	status.DownloadTime = metav1.Time{Time: time.Now().Add(-61 * time.Minute)}
+	assert.True(t, shouldRefresh(spec, status))
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		min      time.Duration
+	}{
+		{failures: 1, min: minBackoff},
+		{failures: 2, min: 2 * minBackoff},
+		{failures: 4, min: 8 * minBackoff},
+		{failures: 100, min: maxBackoff},
+	}
+	for _, tt := range tests {
+		delay := backoff(tt.failures)
+		assert.GreaterOrEqual(t, delay, tt.min)
+		assert.LessOrEqual(t, delay, time.Duration(float64(tt.min)*(1+backoffJitter)))
+	}
+}
+
+func TestRecordDownloadFailure(t *testing.T) {
+	now := time.Now()
+	repo := &catalog.ClusterRepo{ObjectMeta: metav1.ObjectMeta{Name: "repo", Generation: 2}}
+	status := catalog.RepoStatus{ObservedGeneration: 1, IndexConfigMapName: "configmap", DownloadFailures: 1}
+
+	status = recordDownloadFailure(status, repo.Generation, errors.New("connection refused"), now)
+	assert.Equal(t, int64(2), status.ObservedGeneration)
+	assert.Equal(t, 2, status.DownloadFailures)
+	assert.Equal(t, "connection refused", status.LastDownloadError)
+	assert.Equal(t, "configmap", status.IndexConfigMapName)
+
+	remaining := backoffRemaining(repo.Generation, &status, now)
+	assert.Equal(t, status.NextAttemptTime.Sub(now), remaining)
+	assert.GreaterOrEqual(t, remaining, 2*minBackoff)
+
+	// the retry is due
+	assert.Zero(t, backoffRemaining(repo.Generation, &status, status.NextAttemptTime.Add(time.Second)))
+
+	// changing the spec retries immediately
+	repo.Generation++
+	assert.Zero(t, backoffRemaining(repo.Generation, &status, now))
+}
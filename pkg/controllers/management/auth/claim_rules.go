@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rancher/norman/objectclient"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	"github.com/rancher/rancher/pkg/auth/providers/oidc"
+	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	claimRuleControllerName = "mgmt-auth-claim-rule-controller"
+	// claimRuleResyncInterval is how often the bindings created by claim rules are checked for expiry.
+	claimRuleResyncInterval = 15 * time.Minute
+)
+
+// claimRuleController reconciles the bindings created by the claim rules of the OIDC auth providers with their rules,
+// so that they don't outlive the rules, or the claims of their users which are only known at login.
+type claimRuleController struct {
+	authConfigs             v3.AuthConfigController
+	authConfigsUnstructured objectclient.GenericClient
+	binder                  *oidc.ClaimRuleBinder
+}
+
+func newClaimRuleController(mgmt *config.ManagementContext) *claimRuleController {
+	return &claimRuleController{
+		authConfigs:             mgmt.Management.AuthConfigs("").Controller(),
+		authConfigsUnstructured: mgmt.Management.AuthConfigs("").ObjectClient().UnstructuredClient(),
+		binder:                  oidc.NewClaimRuleBinder(mgmt.Wrangler.Mgmt),
+	}
+}
+
+func (c *claimRuleController) sync(key string, obj *v3.AuthConfig) (runtime.Object, error) {
+	if obj == nil || (obj.Type != client.OIDCConfigType && obj.Type != client.KeyCloakOIDCConfigType) {
+		return obj, nil
+	}
+
+	var rules []v32.OIDCClaimRule
+	if obj.Enabled {
+		runtimeObj, err := c.authConfigsUnstructured.Get(obj.Name, metav1.GetOptions{})
+		if err != nil {
+			return obj, fmt.Errorf("failed to get auth config %s: %w", obj.Name, err)
+		}
+		u, ok := runtimeObj.(runtime.Unstructured)
+		if !ok {
+			return obj, fmt.Errorf("auth config %s is not an unstructured value", obj.Name)
+		}
+		oidcConfig := &v32.OIDCConfig{}
+		if err := common.Decode(u.UnstructuredContent(), oidcConfig); err != nil {
+			return obj, fmt.Errorf("failed to decode auth config %s: %w", obj.Name, err)
+		}
+		rules = oidcConfig.ClaimRules
+	}
+
+	// the claims of a user are verified at login and when the groups of the user are refreshed, so the roles granted by
+	// the claims outlive neither the sessions of the user nor its refreshes
+	ttl := 960 * time.Minute
+	if minutes, err := strconv.ParseInt(settings.AuthUserSessionTTLMinutes.Get(), 10, 64); err == nil {
+		ttl = time.Duration(minutes) * time.Minute
+	}
+	if err := c.binder.Reconcile(obj.Name, rules, time.Now().Add(-ttl)); err != nil {
+		return obj, err
+	}
+
+	if obj.Enabled {
+		c.authConfigs.EnqueueAfter("", obj.Name, claimRuleResyncInterval)
+	}
+	return obj, nil
+}
@@ -3,6 +3,7 @@
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -10,6 +11,7 @@
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	pkgrbac "github.com/rancher/rancher/pkg/rbac"
 	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -66,6 +68,9 @@ func (c *crtbLifecycle) Create(obj *v3.ClusterRoleTemplateBinding) (runtime.Obje
 	if err != nil {
 		return nil, err
 	}
+	if active, err := c.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err = c.reconcileBindings(obj)
 
 	return obj, err
@@ -79,20 +84,48 @@ func (c *crtbLifecycle) Updated(obj *v3.ClusterRoleTemplateBinding) (runtime.Obj
 	if err := c.reconcileLabels(obj); err != nil {
 		return nil, err
 	}
+	if active, err := c.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err = c.reconcileBindings(obj)
 	return obj, err
 }
 
 func (c *crtbLifecycle) Remove(obj *v3.ClusterRoleTemplateBinding) (runtime.Object, error) {
+	return nil, c.removeBindings(obj)
+}
+
+func (c *crtbLifecycle) removeBindings(obj *v3.ClusterRoleTemplateBinding) error {
 	if err := c.mgr.reconcileClusterMembershipBindingForDelete("", pkgrbac.GetRTBLabel(obj.ObjectMeta)); err != nil {
-		return nil, err
+		return err
 	}
 	if err := c.removeMGMTClusterScopedPrivilegesInProjectNamespace(obj); err != nil {
-		return nil, err
+		return err
 	}
 
-	err := c.mgr.removeAuthV2Permissions(authprovisioningv2.CRTBRoleBindingID, obj)
-	return nil, err
+	return c.mgr.removeAuthV2Permissions(authprovisioningv2.CRTBRoleBindingID, obj)
+}
+
+// reconcileWindow enforces the validity window of the binding and returns true if the binding currently grants access.
+// Pending bindings have their permissions removed until they become active, and expired bindings are deleted.
+func (c *crtbLifecycle) reconcileWindow(binding *v3.ClusterRoleTemplateBinding) (bool, error) {
+	state, after := pkgrbac.BindingWindow(binding.NotBefore, binding.ExpiresAt, time.Now())
+	if after > 0 {
+		c.mgr.crtbs.Controller().EnqueueAfter(binding.Namespace, binding.Name, after)
+	}
+
+	switch state {
+	case pkgrbac.BindingExpired:
+		logrus.Infof("[%v] Deleting expired ClusterRoleTemplateBinding %v/%v", ctrbMGMTController, binding.Namespace, binding.Name)
+		err := c.mgr.crtbs.DeleteNamespaced(binding.Namespace, binding.Name, &v1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return false, err
+	case pkgrbac.BindingPending:
+		return false, c.removeBindings(binding)
+	}
+	return true, nil
 }
 
 func (c *crtbLifecycle) reconcileSubject(binding *v3.ClusterRoleTemplateBinding) (*v3.ClusterRoleTemplateBinding, error) {
@@ -3,6 +3,7 @@
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -11,6 +12,7 @@
 	pkgrbac "github.com/rancher/rancher/pkg/rbac"
 	"github.com/sirupsen/logrus"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -57,6 +59,9 @@ func (p *prtbLifecycle) Create(obj *v3.ProjectRoleTemplateBinding) (runtime.Obje
 	if err != nil {
 		return nil, err
 	}
+	if active, err := p.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err = p.reconcileBindings(obj)
 	return obj, err
 }
@@ -72,32 +77,59 @@ func (p *prtbLifecycle) Updated(obj *v3.ProjectRoleTemplateBinding) (runtime.Obj
 	if err := p.reconcileLabels(obj); err != nil {
 		return nil, err
 	}
+	if active, err := p.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err = p.reconcileBindings(obj)
 	return obj, err
 }
 
 func (p *prtbLifecycle) Remove(obj *v3.ProjectRoleTemplateBinding) (runtime.Object, error) {
+	return nil, p.removeBindings(obj)
+}
+
+func (p *prtbLifecycle) removeBindings(obj *v3.ProjectRoleTemplateBinding) error {
 	parts := strings.SplitN(obj.ProjectName, ":", 2)
 	if len(parts) < 2 {
-		return nil, errors.Errorf("cannot determine project and cluster from %v", obj.ProjectName)
+		return errors.Errorf("cannot determine project and cluster from %v", obj.ProjectName)
 	}
 	clusterName := parts[0]
 	rtbNsAndName := pkgrbac.GetRTBLabel(obj.ObjectMeta)
 	if err := p.mgr.reconcileProjectMembershipBindingForDelete(clusterName, "", rtbNsAndName); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := p.mgr.reconcileClusterMembershipBindingForDelete("", rtbNsAndName); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := p.removeMGMTProjectScopedPrivilegesInClusterNamespace(obj, clusterName); err != nil {
-		return nil, err
+		return err
 	}
 
-	err := p.mgr.removeAuthV2Permissions(authprovisioningv2.PRTBRoleBindingID, obj)
+	return p.mgr.removeAuthV2Permissions(authprovisioningv2.PRTBRoleBindingID, obj)
+}
 
-	return nil, err
+// reconcileWindow enforces the validity window of the binding and returns true if the binding currently grants access.
+// Pending bindings have their permissions removed until they become active, and expired bindings are deleted.
+func (p *prtbLifecycle) reconcileWindow(binding *v3.ProjectRoleTemplateBinding) (bool, error) {
+	state, after := pkgrbac.BindingWindow(binding.NotBefore, binding.ExpiresAt, time.Now())
+	if after > 0 {
+		p.mgr.prtbs.Controller().EnqueueAfter(binding.Namespace, binding.Name, after)
+	}
+
+	switch state {
+	case pkgrbac.BindingExpired:
+		logrus.Infof("[%v] Deleting expired ProjectRoleTemplateBinding %v/%v", ptrbMGMTController, binding.Namespace, binding.Name)
+		err := p.mgr.prtbs.DeleteNamespaced(binding.Namespace, binding.Name, &v1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return false, err
+	case pkgrbac.BindingPending:
+		return false, p.removeBindings(binding)
+	}
+	return true, nil
 }
 
 func (p *prtbLifecycle) reconcileSubject(binding *v3.ProjectRoleTemplateBinding) (*v3.ProjectRoleTemplateBinding, error) {
@@ -62,6 +62,7 @@ func RegisterEarly(ctx context.Context, management *config.ManagementContext, cl
 	u := newUserLifecycle(management, clusterManager)
 	n := newTokenController(management.WithAgent(tokenController))
 	ac := newAuthConfigController(ctx, management, clusterManager.ScaledContext)
+	cr := newClaimRuleController(management)
 	ua := newUserAttributeController(management.WithAgent(userAttributeController))
 	s := newAuthSettingController(management)
 	rt := newRoleTemplateLifecycle(management, clusterManager)
@@ -79,6 +80,7 @@ func RegisterEarly(ctx context.Context, management *config.ManagementContext, cl
 	management.Management.ProjectRoleTemplateBindings("").AddHandler(ctx, prtbServiceAccountControllerName, prtbServiceAccountFinder.sync)
 	management.Management.Tokens("").AddHandler(ctx, tokenController, n.sync)
 	management.Management.AuthConfigs("").AddHandler(ctx, authConfigControllerName, ac.sync)
+	management.Management.AuthConfigs("").AddHandler(ctx, claimRuleControllerName, cr.sync)
 	management.Management.UserAttributes("").AddHandler(ctx, userAttributeController, ua.sync)
 	management.Management.Settings("").AddHandler(ctx, authSettingController, s.sync)
 	management.Management.GlobalRoleBindings("").AddHandler(ctx, "legacy-grb-cleaner", grbLegacy.sync)
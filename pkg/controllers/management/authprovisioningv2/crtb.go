@@ -16,11 +16,18 @@
 const CRTBRoleBindingID = "auth-prov-v2-crtb-rolebinding"
 
 // OnCRTB create a "membership" binding that gives the subject access to the the cluster custom resource itself
-// along with granting any clusterIndexed permissions based on the roleTemplate
+// along with granting any clusterIndexed permissions based on the roleTemplate. Bindings outside of their validity
+// window are skipped, their role bindings are removed by the management auth controllers.
 func (h *handler) OnCRTB(key string, crtb *v3.ClusterRoleTemplateBinding) (*v3.ClusterRoleTemplateBinding, error) {
 	if crtb == nil || crtb.DeletionTimestamp != nil || crtb.RoleTemplateName == "" || crtb.ClusterName == "" {
 		return crtb, nil
 	}
+	if state, after := rbac.BindingWindow(crtb.NotBefore, crtb.ExpiresAt, time.Now()); state != rbac.BindingActive {
+		if state == rbac.BindingPending {
+			h.clusterRoleTemplateBindingController.EnqueueAfter(crtb.Namespace, crtb.Name, after)
+		}
+		return crtb, nil
+	}
 
 	clusters, err := h.clusters.GetByIndex(byClusterName, crtb.ClusterName)
 	if err != nil {
@@ -0,0 +1,49 @@
+package authprovisioningv2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBindingsOutsideWindowAreSkipped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	crtbs := fake.NewMockControllerInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+	prtbs := fake.NewMockControllerInterface[*v3.ProjectRoleTemplateBinding, *v3.ProjectRoleTemplateBindingList](ctrl)
+	// the handler has no caches, so it would panic if the bindings weren't skipped
+	h := &handler{
+		clusterRoleTemplateBindingController: crtbs,
+		projectRoleTemplateBindingController: prtbs,
+	}
+
+	notBefore := metav1.NewTime(time.Now().Add(time.Hour))
+	expiresAt := metav1.NewTime(time.Now().Add(-time.Hour))
+	crtbs.EXPECT().EnqueueAfter("c-abcde", "pending", gomock.Any()).Do(func(_, _ string, after time.Duration) {
+		assert.InDelta(t, time.Hour, after, float64(time.Minute))
+	})
+	prtbs.EXPECT().EnqueueAfter("p-abcde", "pending", gomock.Any())
+
+	for _, crtb := range []*v3.ClusterRoleTemplateBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "c-abcde"}, NotBefore: &notBefore},
+		{ObjectMeta: metav1.ObjectMeta{Name: "expired", Namespace: "c-abcde"}, ExpiresAt: &expiresAt},
+	} {
+		crtb.RoleTemplateName = "cluster-owner"
+		crtb.ClusterName = "c-abcde"
+		_, err := h.OnCRTB(crtb.Namespace+"/"+crtb.Name, crtb)
+		assert.NoError(t, err)
+	}
+	for _, prtb := range []*v3.ProjectRoleTemplateBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "p-abcde"}, NotBefore: &notBefore},
+		{ObjectMeta: metav1.ObjectMeta{Name: "expired", Namespace: "p-abcde"}, ExpiresAt: &expiresAt},
+	} {
+		prtb.RoleTemplateName = "project-owner"
+		prtb.ProjectName = "c-abcde:p-abcde"
+		_, err := h.OnPRTB(prtb.Namespace+"/"+prtb.Name, prtb)
+		assert.NoError(t, err)
+	}
+}
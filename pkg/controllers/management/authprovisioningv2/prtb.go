@@ -18,10 +18,18 @@
 
 const PRTBRoleBindingID = "auth-prov-v2-prtb-rolebinding"
 
+// OnPRTB gives the subject of the binding access to view the provisioning cluster of its project. Bindings outside of
+// their validity window are skipped, their role bindings are removed by the management auth controllers.
 func (h *handler) OnPRTB(key string, prtb *v3.ProjectRoleTemplateBinding) (*v3.ProjectRoleTemplateBinding, error) {
 	if prtb == nil || prtb.DeletionTimestamp != nil || prtb.RoleTemplateName == "" || prtb.ProjectName == "" || prtb.ServiceAccount != "" {
 		return prtb, nil
 	}
+	if state, after := rbac.BindingWindow(prtb.NotBefore, prtb.ExpiresAt, time.Now()); state != rbac.BindingActive {
+		if state == rbac.BindingPending {
+			h.projectRoleTemplateBindingController.EnqueueAfter(prtb.Namespace, prtb.Name, after)
+		}
+		return prtb, nil
+	}
 
 	parts := strings.SplitN(prtb.ProjectName, ":", 2)
 	if len(parts) < 2 {
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"time"
 
 	apis "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -13,8 +14,16 @@
 
 var toCopy = map[string]bool{}
 
+// enabledAtSettings are the settings which enable a feature with a non-zero value, and are annotated with the time
+// they were last enabled.
+var enabledAtSettings = map[string]bool{
+	settings.TokenIdleTimeoutDays.Name: true,
+	settings.PasswordMaxAgeDays.Name:   true,
+}
+
 type handler struct {
-	cluster v3.ClusterController
+	cluster  v3.ClusterController
+	settings v3.SettingInterface
 }
 
 func init() {
@@ -27,10 +36,12 @@ func init() {
 
 func Register(ctx context.Context, management *config.ManagementContext) {
 	h := &handler{
-		cluster: management.Management.Clusters("").Controller(),
+		cluster:  management.Management.Clusters("").Controller(),
+		settings: management.Management.Settings(""),
 	}
 
 	management.Management.Settings("").AddHandler(ctx, "copy-settings", h.onChange)
+	management.Management.Settings("").AddHandler(ctx, "setting-enabled-at", h.setEnabledAt)
 }
 
 func (h *handler) onChange(key string, obj *apis.Setting) (runtime.Object, error) {
@@ -50,3 +61,31 @@ func (h *handler) onChange(key string, obj *apis.Setting) (runtime.Object, error
 
 	return obj, nil
 }
+
+// setEnabledAt sets the EnabledAtAnnotation of the settings of enabledAtSettings when they are enabled, and removes it
+// when they are disabled.
+func (h *handler) setEnabledAt(key string, obj *apis.Setting) (runtime.Object, error) {
+	if obj == nil || obj.DeletionTimestamp != nil || !enabledAtSettings[obj.Name] {
+		return obj, nil
+	}
+
+	value := obj.Value
+	if value == "" {
+		value = obj.Default
+	}
+	enabled := value != "" && value != "0"
+	if _, annotated := obj.Annotations[settings.EnabledAtAnnotation]; enabled == annotated {
+		return obj, nil
+	}
+
+	obj = obj.DeepCopy()
+	if enabled {
+		if obj.Annotations == nil {
+			obj.Annotations = map[string]string{}
+		}
+		obj.Annotations[settings.EnabledAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	} else {
+		delete(obj.Annotations, settings.EnabledAtAnnotation)
+	}
+	return h.settings.Update(obj)
+}
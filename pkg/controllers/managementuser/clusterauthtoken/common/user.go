@@ -4,6 +4,8 @@
 	"fmt"
 	"time"
 
+	apiclusterv3 "github.com/rancher/rancher/pkg/apis/cluster.cattle.io/v3"
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
 	clusterv3 "github.com/rancher/rancher/pkg/generated/norman/cluster.cattle.io/v3"
 	managementv3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -25,15 +27,46 @@ func NewClusterAuthToken(token *managementv3.Token, hashedValue string) (*cluste
 		SecretKeyHash: hashedValue,
 		ExpiresAt:     token.ExpiresAt,
 		Enabled:       tokenEnabled,
+		Scope:         NewClusterAuthTokenScope(token.Scope),
 	}
 	return result, nil
 }
 
+// NewClusterAuthTokenScope returns the scope of a cluster auth token created from a token with the given scope. The
+// clusters of the scope are not copied, as only tokens in scope of a cluster are synced to it.
+func NewClusterAuthTokenScope(scope *apimgmtv3.TokenScope) *apiclusterv3.ClusterAuthTokenScope {
+	if !IsScoped(scope) {
+		return nil
+	}
+	result := &apiclusterv3.ClusterAuthTokenScope{
+		ReadOnly: scope.ReadOnly,
+	}
+	for _, resource := range scope.Resources {
+		result.Resources = append(result.Resources, apiclusterv3.ClusterAuthTokenScopeResource{
+			APIGroup:  resource.APIGroup,
+			Resources: append([]string(nil), resource.Resources...),
+		})
+	}
+	return result
+}
+
+// IsScoped returns true if the scope restricts the requests a token can be used for within a cluster. Such tokens aren't
+// synced to the clusters, as kube-api-auth, which verifies the ClusterAuthTokens of the authorized cluster endpoint, is
+// shipped separately and doesn't enforce scopes.
+func IsScoped(scope *apimgmtv3.TokenScope) bool {
+	return scope != nil && (scope.ReadOnly || len(scope.Resources) > 0)
+}
+
 // VerifyClusterAuthToken verifies that a provided secret key is valid for the given clusterAuthToken.
 func VerifyClusterAuthToken(secretKey string, clusterAuthToken *clusterv3.ClusterAuthToken) error {
 	if !clusterAuthToken.Enabled {
 		return fmt.Errorf("token is not enabled")
 	}
+	// the scope can't be enforced here as the request the token is used for is unknown, so scoped tokens are refused
+	// rather than granting the full permissions of their user
+	if clusterAuthToken.Scope != nil {
+		return fmt.Errorf("token is scoped and can only be used through rancher")
+	}
 
 	expiresAt := clusterAuthToken.ExpiresAt
 	if expiresAt != "" {
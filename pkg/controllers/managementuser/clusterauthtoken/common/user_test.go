@@ -5,6 +5,7 @@
 	"testing"
 	"time"
 
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
 	"github.com/stretchr/testify/assert"
 
@@ -68,3 +69,46 @@ func TestInvalidExpiresAt(t *testing.T) {
 	clusterAuthToken, _ := NewClusterAuthToken(&token, hashedValue)
 	assert.NotNil(t, VerifyClusterAuthToken(token.Token, clusterAuthToken))
 }
+
+func TestScoped(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   *apimgmtv3.TokenScope
+		wantErr bool
+	}{
+		{
+			name: "no scope",
+		},
+		{
+			name:  "clusters only",
+			scope: &apimgmtv3.TokenScope{Clusters: []string{"c-1"}},
+		},
+		{
+			name:    "read only",
+			scope:   &apimgmtv3.TokenScope{ReadOnly: true},
+			wantErr: true,
+		},
+		{
+			name:    "resources",
+			scope:   &apimgmtv3.TokenScope{Resources: []apimgmtv3.TokenScopeResource{{APIGroup: "", Resources: []string{"pods"}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := getToken()
+			token.Scope = tt.scope
+			hashedValue, err := hashers.GetHasher().CreateHash(token.Token)
+			assert.NoError(t, err)
+			clusterAuthToken, err := NewClusterAuthToken(&token, hashedValue)
+			assert.NoError(t, err)
+			if tt.wantErr {
+				assert.NotNil(t, clusterAuthToken.Scope)
+				assert.Error(t, VerifyClusterAuthToken(token.Token, clusterAuthToken))
+			} else {
+				assert.Nil(t, clusterAuthToken.Scope)
+				assert.NoError(t, VerifyClusterAuthToken(token.Token, clusterAuthToken))
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@
 	"reflect"
 	"sort"
 
+	apiclusterv3 "github.com/rancher/rancher/pkg/apis/cluster.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
 	"github.com/rancher/rancher/pkg/controllers/managementuser/clusterauthtoken/common"
@@ -24,6 +25,7 @@ type tokenAttributeCompare struct {
 	expiresAt string
 	enabled   bool
 	value     string
+	scope     *apiclusterv3.ClusterAuthTokenScope
 }
 
 type tokenHandler struct {
@@ -39,6 +41,9 @@ type tokenHandler struct {
 
 // Create is called when a given token is created, and is responsible for creating a ClusterAuthToken in a downstream cluster.
 func (h *tokenHandler) Create(token *managementv3.Token) (runtime.Object, error) {
+	if common.IsScoped(token.Scope) {
+		return nil, h.deleteClusterAuthToken(token)
+	}
 	_, err := h.clusterAuthTokenLister.Get(h.namespace, token.Name)
 	if !errors.IsNotFound(err) {
 		return h.Updated(token)
@@ -56,7 +61,7 @@ func (h *tokenHandler) Create(token *managementv3.Token) (runtime.Object, error)
 			return token, generic.ErrSkip
 		}
 		// we only sync tokens downstream that were created with SHA3
-		if hashVersion == hashers.SHA3Version {
+		if canSyncHash(hashVersion) {
 			return nil, h.createClusterAuthToken(token, token.Token)
 		}
 		// token is hashed, but we can't sync it since we don't have the raw value
@@ -66,14 +71,19 @@ func (h *tokenHandler) Create(token *managementv3.Token) (runtime.Object, error)
 
 	}
 	// token isn't hashed, hash the value only for downstream
-	hasher := hashers.GetHasher()
-	hashedValue, err := hasher.CreateHash(token.Token)
+	hashedValue, err := hashers.Sha3Hasher{}.CreateHash(token.Token)
 	if err != nil {
 		return nil, fmt.Errorf("unable to hash value for token [%s]: %w", token.Name, err)
 	}
 	return nil, h.createClusterAuthToken(token, hashedValue)
 }
 
+// canSyncHash returns true if a hash of the given version can be copied to downstream clusters as is. kube-api-auth,
+// which verifies the ClusterAuthTokens downstream, is shipped separately and only supports SHA3 of the newer hashes.
+func canSyncHash(version hashers.HashVersion) bool {
+	return version == hashers.SHA3Version
+}
+
 // createClusterAuthToken handles actions commonly taken to create a clusterAuthToken from a token.
 func (h *tokenHandler) createClusterAuthToken(token *managementv3.Token, hashedValue string) error {
 	err := h.updateClusterUserAttribute(token)
@@ -93,6 +103,9 @@ func (h *tokenHandler) createClusterAuthToken(token *managementv3.Token, hashedV
 // Updated is called when a token is updated, and is responsible for creating/updating the corresponding
 // ClusterAuthTokens in the downstream cluster.
 func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error) {
+	if common.IsScoped(token.Scope) {
+		return nil, h.deleteClusterAuthToken(token)
+	}
 	clusterAuthToken, err := h.clusterAuthTokenLister.Get(h.namespace, token.Name)
 	if errors.IsNotFound(err) {
 		return h.Create(token)
@@ -111,11 +124,13 @@ func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error
 		enabled:   tokenEnabled,
 		expiresAt: token.ExpiresAt,
 		username:  token.UserID,
+		scope:     common.NewClusterAuthTokenScope(token.Scope),
 	}
 	old := tokenAttributeCompare{
 		enabled:   clusterAuthToken.Enabled,
 		expiresAt: clusterAuthToken.ExpiresAt,
 		username:  clusterAuthToken.UserName,
+		scope:     clusterAuthToken.Scope,
 	}
 
 	// if the token is hashed, compare its value to make sure the downstream has the latest hash
@@ -126,7 +141,7 @@ func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error
 			return token, generic.ErrSkip
 		}
 		// we only sync tokens downstream that were created with SHA3
-		if hashVersion == hashers.SHA3Version {
+		if canSyncHash(hashVersion) {
 			// trigger the compare to compare the values of the tokens
 			current.value = token.Token
 			old.value = clusterAuthToken.SecretKeyHash
@@ -139,6 +154,7 @@ func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error
 	clusterAuthToken.UserName = token.UserID
 	clusterAuthToken.Enabled = tokenEnabled
 	clusterAuthToken.ExpiresAt = token.ExpiresAt
+	clusterAuthToken.Scope = current.scope
 
 	// if we were comparing token values, then the token was hashed, so we can update the value downstream
 	if current.value != "" {
@@ -152,6 +168,16 @@ func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error
 	return nil, err
 }
 
+// deleteClusterAuthToken deletes the ClusterAuthToken of a scoped token, which may have been synced before scoped tokens
+// were skipped, so that the token can't be used through the authorized cluster endpoint with the full access of its user.
+func (h *tokenHandler) deleteClusterAuthToken(token *managementv3.Token) error {
+	err := h.clusterAuthToken.Delete(token.Name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 func (h *tokenHandler) Remove(token *managementv3.Token) (runtime.Object, error) {
 
 	tokens, err := h.tokenIndexer.ByIndex(tokenByUserAndClusterIndex, tokenUserClusterKey(token))
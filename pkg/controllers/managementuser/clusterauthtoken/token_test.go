@@ -12,6 +12,7 @@
 	"github.com/rancher/rancher/pkg/generated/norman/cluster.cattle.io/v3/fakes"
 	managementv3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	mgmtFakes "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/wrangler/pkg/generic"
 	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -25,7 +26,9 @@
 	tokenKey             = "cccccccccccccccccccccccccccccccccccccccccccccccccccccc"
 	hashedTokenKey       = "$3:1:GepdvExsvzA:JXMHpXDZqtU5zNh5y5HB8KmLKbHc2VdeuxQo6CTlLhyNifaYhJTnb+4Rf+xpnbsfd8tIlQ0ZgIi2edJrm9CpoA"
 	legacyHashedTokenKey = "$2:jwvzsLqh6Rg:FyeWbQuUt6VEMhQOe5J1kXPf0D4H9MRjub0aNaGzyx8"
-	invalidHashKey       = "$-1:invalidsalt"
+	// argon2idHashedTokenKey only needs a valid version, kube-api-auth can't verify any argon2id hash
+	argon2idHashedTokenKey = "$4:1:c2FsdA:aGFzaA"
+	invalidHashKey         = "$-1:invalidsalt"
 )
 
 func TestCreate(t *testing.T) {
@@ -97,6 +100,16 @@ func TestCreate(t *testing.T) {
 			wantError:            true,
 			wantSkipError:        true,
 		},
+		{
+			name:                "token hashing enabled, argon2id token hash, don't create token",
+			token:               hashToken(testToken, argon2idHashedTokenKey),
+			existingTokenError:  authTokenNotFoundError,
+			tokenHashingEnabled: true,
+
+			wantClusterAuthToken: false,
+			wantError:            true,
+			wantSkipError:        true,
+		},
 		{
 			name:               "token disabled, create token",
 			token:              setTokenEnabled(testToken, pointer.BoolPtr(false)),
@@ -209,6 +222,72 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestScopedTokenNotSynced(t *testing.T) {
+	token := &managementv3.Token{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-token",
+		},
+		UserID: userID,
+		Token:  tokenKey,
+		Scope:  &v3.TokenScope{ReadOnly: true},
+	}
+
+	for _, callCreate := range []bool{true, false} {
+		var deleted []string
+		h := tokenHandler{
+			clusterAuthTokenLister: &fakes.ClusterAuthTokenListerMock{},
+			clusterAuthToken: &fakes.ClusterAuthTokenInterfaceMock{
+				DeleteFunc: func(name string, _ *metav1.DeleteOptions) error {
+					deleted = append(deleted, name)
+					return apierrors.NewNotFound(schema.GroupResource{Group: "cluster.cattle.io", Resource: "ClusterAuthToken"}, name)
+				},
+			},
+		}
+		var err error
+		if callCreate {
+			_, err = h.Create(token)
+		} else {
+			_, err = h.Updated(token)
+		}
+		require.NoError(t, err)
+		require.Equal(t, []string{token.Name}, deleted)
+	}
+}
+
+func TestCreateWithArgon2idHashAlgorithm(t *testing.T) {
+	defer settings.TokenHashAlgorithm.Set(settings.TokenHashAlgorithm.Default)
+	require.NoError(t, settings.TokenHashAlgorithm.Set("argon2id"))
+
+	for _, tokenHashingEnabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("token hashing %t", tokenHashingEnabled), func(t *testing.T) {
+			features.TokenHashing.Set(tokenHashingEnabled)
+			token := &managementv3.Token{
+				ObjectMeta:  metav1.ObjectMeta{Name: "test-token"},
+				ClusterName: "c-abcde",
+				ExpiresAt:   "10000000000",
+				UserID:      userID,
+				Token:       tokenKey,
+			}
+			require.NoError(t, tokens.ConvertTokenKeyToHash(token))
+			require.False(t, tokens.NeedsRehash(token), "tokens of a cluster aren't re-hashed with argon2id")
+
+			output := runCreateUpdateTest(t, &testInput{
+				Token:               token,
+				ExistingTokenError:  apierrors.NewNotFound(schema.GroupResource{Group: "cluster.cattle.io", Resource: "ClusterAuthToken"}, token.Name),
+				TokenHashingEnabled: tokenHashingEnabled,
+				CallCreate:          true,
+			})
+			require.NoError(t, output.Error)
+			require.NotNil(t, output.ModifiedClusterAuthToken)
+			hash := output.ModifiedClusterAuthToken.SecretKeyHash
+			version, err := hashers.GetHashVersion(hash)
+			require.NoError(t, err)
+			require.Equal(t, hashers.SHA3Version, version)
+			require.NoError(t, hashers.Sha3Hasher{}.VerifyHash(hash, tokenKey))
+		})
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	testToken := &managementv3.Token{
 		ObjectMeta: metav1.ObjectMeta{
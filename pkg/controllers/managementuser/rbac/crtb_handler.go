@@ -1,6 +1,8 @@
 package rbac
 
 import (
+	"time"
+
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -23,6 +25,9 @@ type crtbLifecycle struct {
 }
 
 func (c *crtbLifecycle) Create(obj *v3.ClusterRoleTemplateBinding) (runtime.Object, error) {
+	if active, err := c.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err := c.syncCRTB(obj)
 	return obj, err
 }
@@ -31,6 +36,9 @@ func (c *crtbLifecycle) Updated(obj *v3.ClusterRoleTemplateBinding) (runtime.Obj
 	if err := c.reconcileCRTBUserClusterLabels(obj); err != nil {
 		return obj, err
 	}
+	if active, err := c.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err := c.syncCRTB(obj)
 	return obj, err
 }
@@ -40,6 +48,19 @@ func (c *crtbLifecycle) Remove(obj *v3.ClusterRoleTemplateBinding) (runtime.Obje
 	return obj, err
 }
 
+// reconcileWindow returns true if the binding currently grants access. The permissions of bindings outside of their
+// validity window are removed from the cluster, expired bindings are deleted by the management controllers.
+func (c *crtbLifecycle) reconcileWindow(binding *v3.ClusterRoleTemplateBinding) (bool, error) {
+	state, after := pkgrbac.BindingWindow(binding.NotBefore, binding.ExpiresAt, time.Now())
+	if state == pkgrbac.BindingActive {
+		return true, nil
+	}
+	if state == pkgrbac.BindingPending {
+		c.m.crtbs.Controller().EnqueueAfter(binding.Namespace, binding.Name, after)
+	}
+	return false, c.ensureCRTBDelete(binding)
+}
+
 func (c *crtbLifecycle) syncCRTB(binding *v3.ClusterRoleTemplateBinding) error {
 	if binding.RoleTemplateName == "" {
 		logrus.Warnf("ClusterRoleTemplateBinding %v has no role template set. Skipping.", binding.Name)
@@ -16,6 +16,7 @@
 	namespaceutil "github.com/rancher/rancher/pkg/namespace"
 	"github.com/rancher/rancher/pkg/project"
 	projectpkg "github.com/rancher/rancher/pkg/project"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -231,6 +232,11 @@ func (n *nsLifecycle) ensurePRTBAddToNamespace(ns *v1.Namespace) (bool, error) {
 			continue
 		}
 
+		// bindings outside of their validity window don't grant access, the prtb controller grants it once they do
+		if !pkgrbac.IsBindingActive(prtb.NotBefore, prtb.ExpiresAt, time.Now()) {
+			continue
+		}
+
 		if prtb.RoleTemplateName == "" {
 			logrus.Warnf("ProjectRoleTemplateBinding %v has no role template set. Skipping.", prtb.Name)
 			continue
@@ -3,6 +3,7 @@
 import (
 	"reflect"
 	"sort"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -64,6 +65,9 @@ type prtbLifecycle struct {
 }
 
 func (p *prtbLifecycle) Create(obj *v3.ProjectRoleTemplateBinding) (runtime.Object, error) {
+	if active, err := p.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err := p.syncPRTB(obj)
 	return obj, err
 }
@@ -72,10 +76,26 @@ func (p *prtbLifecycle) Updated(obj *v3.ProjectRoleTemplateBinding) (runtime.Obj
 	if err := p.reconcilePRTBUserClusterLabels(obj); err != nil {
 		return obj, err
 	}
+	if active, err := p.reconcileWindow(obj); err != nil || !active {
+		return obj, err
+	}
 	err := p.syncPRTB(obj)
 	return obj, err
 }
 
+// reconcileWindow returns true if the binding currently grants access. The permissions of bindings outside of their
+// validity window are removed from the cluster, expired bindings are deleted by the management controllers.
+func (p *prtbLifecycle) reconcileWindow(binding *v3.ProjectRoleTemplateBinding) (bool, error) {
+	state, after := pkgrbac.BindingWindow(binding.NotBefore, binding.ExpiresAt, time.Now())
+	if state == pkgrbac.BindingActive {
+		return true, nil
+	}
+	if state == pkgrbac.BindingPending {
+		p.m.prtbs.Controller().EnqueueAfter(binding.Namespace, binding.Name, after)
+	}
+	return false, p.ensurePRTBDelete(binding)
+}
+
 func (p *prtbLifecycle) Remove(obj *v3.ProjectRoleTemplateBinding) (runtime.Object, error) {
 	err := p.ensurePRTBDelete(obj)
 	return obj, err
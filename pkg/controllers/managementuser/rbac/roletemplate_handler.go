@@ -1,6 +1,8 @@
 package rbac
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 	wranglerv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -101,6 +103,9 @@ func (c *rtSync) syncRT(template *v3.RoleTemplate, usedInProjects bool, prtbs []
 		if !ok {
 			continue
 		}
+		if !rbac.IsBindingActive(prtb.NotBefore, prtb.ExpiresAt, time.Now()) {
+			continue
+		}
 
 		crbsToKeep, err := c.m.reconcileProjectAccessToGlobalResources(prtb, roles)
 		if err != nil {
@@ -142,6 +147,9 @@ func (c *rtSync) syncRT(template *v3.RoleTemplate, usedInProjects bool, prtbs []
 		if !ok {
 			continue
 		}
+		if !rbac.IsBindingActive(crtb.NotBefore, crtb.ExpiresAt, time.Now()) {
+			continue
+		}
 		if err := c.m.ensureClusterBindings(roles, crtb); err != nil {
 			return err
 		}
@@ -36,7 +36,8 @@ func Register(ctx context.Context, clients *wrangler.Context) {
 			clients.K8s.Discovery(),
 			clients.Core.ConfigMap().Cache(),
 			clients.Core.Secret().Cache(),
-			clients.Catalog.ClusterRepo().Cache()),
+			clients.Catalog.ClusterRepo().Cache(),
+			clients.Catalog.Repo().Cache()),
 		mccCache:      clients.Mgmt.ManagedChart().Cache(),
 		mccController: clients.Mgmt.ManagedChart(),
 		bundleCache:   clients.Fleet.Bundle().Cache(),
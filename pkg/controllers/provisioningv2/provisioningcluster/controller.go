@@ -215,10 +215,15 @@ func (h *handler) OnChange(_ string, cluster *rancherv1.Cluster) (*rancherv1.Clu
 	return cluster, nil
 }
 
-func (h *handler) findSnapshotClusterSpec(snapshotNamespace, snapshotName string) (*rancherv1.ClusterSpec, error) {
-	snapshot, err := h.etcdSnapshotCache.Get(snapshotNamespace, snapshotName)
+func (h *handler) findSnapshotClusterSpec(cluster *rancherv1.Cluster) (*rancherv1.ClusterSpec, error) {
+	restore := cluster.Spec.RKEConfig.ETCDSnapshotRestore
+	snapshot, err := h.etcdSnapshotCache.Get(cluster.Namespace, restore.Name)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving etcdsnapshot %s/%s: %w", snapshotNamespace, snapshotName, err)
+		return nil, fmt.Errorf("error retrieving etcdsnapshot %s/%s: %w", cluster.Namespace, restore.Name, err)
+	}
+	// the spec of another cluster is only restored if the clone of that cluster was authorized
+	if _, err := capr.ValidateETCDSnapshotClone(h.secretCache, cluster.Namespace, cluster.Name, restore, snapshot); err != nil {
+		return nil, err
 	}
 	return capr.ParseSnapshotClusterSpecOrError(snapshot)
 }
@@ -315,7 +320,7 @@ func (h *handler) OnRancherClusterChange(obj *rancherv1.Cluster, status rancherv
 			obj.Spec.RKEConfig.ETCDSnapshotRestore.RestoreRKEConfig != restoreRKEConfigNone {
 			logrus.Debugf("rkecluster %s/%s: Reconciling rkeconfig against specified etcd restore snapshot metadata", obj.Namespace, obj.Name)
 			if !equality.Semantic.DeepEqual(rkeCP.Status.ETCDSnapshotRestore, obj.Spec.RKEConfig.ETCDSnapshotRestore) {
-				clusterSpec, err := h.findSnapshotClusterSpec(obj.Namespace, obj.Spec.RKEConfig.ETCDSnapshotRestore.Name)
+				clusterSpec, err := h.findSnapshotClusterSpec(obj)
 				if err != nil {
 					return nil, status, err
 				}
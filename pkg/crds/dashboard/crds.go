@@ -88,6 +88,12 @@ func List(cfg *rest.Config) (_ []crd.CRD, err error) {
 				WithCategories("catalog").
 				WithColumn("URL", ".spec.url")
 		}),
+		newCRD(&catalogv1.Repo{}, func(c crd.CRD) crd.CRD {
+			return c.
+				WithStatus().
+				WithCategories("catalog").
+				WithColumn("URL", ".spec.url")
+		}),
 		newCRD(&catalogv1.Operation{}, func(c crd.CRD) crd.CRD {
 			return c.
 				WithStatus().
@@ -31,6 +31,7 @@ type CatalogV1Interface interface {
 	AppsGetter
 	ClusterReposGetter
 	OperationsGetter
+	ReposGetter
 }
 
 // CatalogV1Client is used to interact with features provided by the catalog.cattle.io group.
@@ -50,6 +51,10 @@ func (c *CatalogV1Client) Operations(namespace string) OperationInterface {
 	return newOperations(c, namespace)
 }
 
+func (c *CatalogV1Client) Repos(namespace string) RepoInterface {
+	return newRepos(c, namespace)
+}
+
 // NewForConfig creates a new CatalogV1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
@@ -34,6 +34,7 @@ type Interface interface {
 	App() AppController
 	ClusterRepo() ClusterRepoController
 	Operation() OperationController
+	Repo() RepoController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -57,3 +58,7 @@ func (v *version) ClusterRepo() ClusterRepoController {
 func (v *version) Operation() OperationController {
 	return generic.NewController[*v1.Operation, *v1.OperationList](schema.GroupVersionKind{Group: "catalog.cattle.io", Version: "v1", Kind: "Operation"}, "operations", true, v.controllerFactory)
 }
+
+func (v *version) Repo() RepoController {
+	return generic.NewController[*v1.Repo, *v1.RepoList](schema.GroupVersionKind{Group: "catalog.cattle.io", Version: "v1", Kind: "Repo"}, "repos", true, v.controllerFactory)
+}
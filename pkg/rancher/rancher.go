@@ -22,6 +22,7 @@
 	"github.com/rancher/rancher/pkg/auth"
 	"github.com/rancher/rancher/pkg/auth/audit"
 	"github.com/rancher/rancher/pkg/auth/requests"
+	"github.com/rancher/rancher/pkg/capr/planner"
 	"github.com/rancher/rancher/pkg/controllers/dashboard"
 	"github.com/rancher/rancher/pkg/controllers/dashboard/apiservice"
 	"github.com/rancher/rancher/pkg/controllers/dashboardapi"
@@ -157,6 +158,9 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 		// ensure indexers are registered for all replicas
 		provisioningv2.RegisterIndexers(wranglerContext)
 	}
+	if features.RKE2.Enabled() {
+		planner.RegisterIndexers(wranglerContext)
+	}
 
 	clientSet, err := clientset.NewForConfig(restConfig)
 	if err != nil {
@@ -0,0 +1,50 @@
+package rbac
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BindingWindowState is the state of a role template binding relative to its validity window.
+type BindingWindowState int
+
+const (
+	// BindingActive bindings grant access.
+	BindingActive BindingWindowState = iota
+	// BindingPending bindings will grant access once their notBefore time is reached.
+	BindingPending
+	// BindingExpired bindings are past their expiresAt time and are to be deleted.
+	BindingExpired
+)
+
+// BindingWindow returns the state at now of a role template binding valid from notBefore until expiresAt, either of
+// which can be nil, and how long until that state changes. The duration is 0 if the state never changes.
+func BindingWindow(notBefore, expiresAt *metav1.Time, now time.Time) (BindingWindowState, time.Duration) {
+	if expiresAt != nil && !now.Before(expiresAt.Time) {
+		return BindingExpired, 0
+	}
+	if notBefore != nil && now.Before(notBefore.Time) {
+		return BindingPending, notBefore.Sub(now)
+	}
+	if expiresAt != nil {
+		return BindingActive, expiresAt.Sub(now)
+	}
+	return BindingActive, 0
+}
+
+// IsBindingActive returns true if a role template binding valid from notBefore until expiresAt grants access at now.
+func IsBindingActive(notBefore, expiresAt *metav1.Time, now time.Time) bool {
+	state, _ := BindingWindow(notBefore, expiresAt, now)
+	return state == BindingActive
+}
+
+// ValidateBindingWindow returns an error if a role template binding valid from notBefore until expiresAt would never
+// grant access.
+func ValidateBindingWindow(notBefore, expiresAt *metav1.Time) error {
+	if notBefore != nil && expiresAt != nil && !expiresAt.After(notBefore.Time) {
+		return fmt.Errorf("expiresAt %s must be after notBefore %s", expiresAt.UTC().Format(time.RFC3339), notBefore.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
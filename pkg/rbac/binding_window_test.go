@@ -0,0 +1,80 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBindingWindow(t *testing.T) {
+	now := time.Now()
+	past := &metav1.Time{Time: now.Add(-time.Hour)}
+	future := &metav1.Time{Time: now.Add(time.Hour)}
+	later := &metav1.Time{Time: now.Add(2 * time.Hour)}
+
+	tests := []struct {
+		name      string
+		notBefore *metav1.Time
+		expiresAt *metav1.Time
+		wantState BindingWindowState
+		wantAfter time.Duration
+	}{
+		{
+			name:      "no window",
+			wantState: BindingActive,
+		},
+		{
+			name:      "started without expiry",
+			notBefore: past,
+			wantState: BindingActive,
+		},
+		{
+			name:      "active until expiry",
+			notBefore: past,
+			expiresAt: future,
+			wantState: BindingActive,
+			wantAfter: time.Hour,
+		},
+		{
+			name:      "pending",
+			notBefore: future,
+			expiresAt: later,
+			wantState: BindingPending,
+			wantAfter: time.Hour,
+		},
+		{
+			name:      "expired",
+			expiresAt: past,
+			wantState: BindingExpired,
+		},
+		{
+			name:      "expires before it starts",
+			notBefore: future,
+			expiresAt: past,
+			wantState: BindingExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, after := BindingWindow(tt.notBefore, tt.expiresAt, now)
+			assert.Equal(t, tt.wantState, state)
+			assert.Equal(t, tt.wantAfter, after)
+		})
+	}
+}
+
+func TestValidateBindingWindow(t *testing.T) {
+	now := time.Now()
+	past := &metav1.Time{Time: now.Add(-time.Hour)}
+	future := &metav1.Time{Time: now.Add(time.Hour)}
+
+	assert.NoError(t, ValidateBindingWindow(nil, nil))
+	assert.NoError(t, ValidateBindingWindow(past, nil))
+	assert.NoError(t, ValidateBindingWindow(nil, past))
+	assert.NoError(t, ValidateBindingWindow(past, future))
+	assert.Error(t, ValidateBindingWindow(future, past))
+	assert.Error(t, ValidateBindingWindow(future, future))
+}
@@ -464,6 +464,9 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 		MustImport(&Version, v3.SearchPrincipalsInput{}).
 		MustImport(&Version, v3.ChangePasswordInput{}).
 		MustImport(&Version, v3.SetPasswordInput{}).
+		MustImport(&Version, v3.TOTPCodeInput{}).
+		MustImport(&Version, v3.TOTPEnrollment{}).
+		MustImport(&Version, v3.TOTPRecoveryCodes{}).
 		MustImportAndCustomize(&Version, v3.User{}, func(schema *types.Schema) {
 			schema.ResourceActions = map[string]types.Action{
 				"setpassword": {
@@ -471,12 +474,28 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 					Output: "user",
 				},
 				"refreshauthprovideraccess": {},
+				"resettotp": {
+					Output: "user",
+				},
+				"unlock": {
+					Output: "user",
+				},
 			}
 			schema.CollectionActions = map[string]types.Action{
 				"changepassword": {
 					Input: "changePasswordInput",
 				},
 				"refreshauthprovideraccess": {},
+				"enrolltotp": {
+					Output: "totpEnrollment",
+				},
+				"activatetotp": {
+					Input:  "totpCodeInput",
+					Output: "totpRecoveryCodes",
+				},
+				"disabletotp": {
+					Input: "totpCodeInput",
+				},
 			}
 		}).
 		MustImportAndCustomize(&Version, v3.AuthConfig{}, func(schema *types.Schema) {
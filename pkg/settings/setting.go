@@ -8,6 +8,7 @@
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	authsettings "github.com/rancher/rancher/pkg/auth/settings"
@@ -19,6 +20,11 @@
 
 const RancherVersionDev = "2.8.99"
 
+// EnabledAtAnnotation is set on the settings which enable a feature with a non-zero value, like TokenIdleTimeoutDays,
+// to the time they were last enabled. Features which apply to objects that existed before they were enabled count
+// from it rather than from the creation of the objects.
+const EnabledAtAnnotation = "management.cattle.io/enabled-at"
+
 var (
 	releasePattern = regexp.MustCompile("^v[0-9]")
 	settings       = map[string]Setting{}
@@ -136,6 +142,114 @@
 	Rke2DefaultVersion = NewSetting("rke2-default-version", "")
 	K3sDefaultVersion  = NewSetting("k3s-default-version", "")
 
+	// AuditLogSinks is a comma separated list of destinations for API audit records: file, stdout, webhook and syslog.
+	// The file sink writes to the log file configured with the audit-log-path flag. The sinks, along with the batch,
+	// webhook and syslog settings below, are only read when Rancher starts, so changes take effect after a restart.
+	AuditLogSinks = NewSetting("audit-log-sinks", "file")
+
+	// AuditLogPolicy is an audit policy, in JSON or YAML, selecting the audit level per request by user, group, method,
+	// verb, path prefix and resource. Requests which match no rule are audited at the level set by the audit-level flag,
+	// so rules can audit requests even if the flag is 0.
+	AuditLogPolicy = NewSetting("audit-log-policy", "")
+
+	// AuditLogRedactionRules are rules, in JSON or YAML, naming headers, JSON keys and JSON paths to redact from audit
+	// records, optionally scoped to resource types. They are applied in addition to the built-in redaction.
+	AuditLogRedactionRules = NewSetting("audit-log-redaction-rules", "")
+
+	// AuditLogBatchSize is the maximum number of audit records the webhook and syslog sinks deliver at once.
+	AuditLogBatchSize = NewSetting("audit-log-batch-size", "100")
+
+	// AuditLogFlushInterval is the longest an audit record waits before the webhook and syslog sinks deliver it.
+	AuditLogFlushInterval = NewSetting("audit-log-flush-interval", "5s")
+
+	// AuditLogMaxRetries is the number of times the webhook and syslog sinks retry a failed delivery before dropping it.
+	AuditLogMaxRetries = NewSetting("audit-log-max-retries", "5")
+
+	// AuditLogWebhookURL is the endpoint the webhook sink POSTs batches of audit records to.
+	AuditLogWebhookURL = NewSetting("audit-log-webhook-url", "")
+
+	// AuditLogWebhookCACerts is a PEM bundle used to verify the certificate of the audit log webhook.
+	AuditLogWebhookCACerts = NewSetting("audit-log-webhook-cacerts", "")
+
+	// AuditLogWebhookInsecureSkipVerify disables certificate verification for the audit log webhook.
+	AuditLogWebhookInsecureSkipVerify = NewSetting("audit-log-webhook-insecure-skip-verify", "false")
+
+	// AuditLogSyslogAddress is the host:port of the syslog server the syslog sink sends audit records to over TCP.
+	AuditLogSyslogAddress = NewSetting("audit-log-syslog-address", "")
+
+	// AuditLogSyslogTLS enables TLS for the connection to the audit log syslog server.
+	AuditLogSyslogTLS = NewSetting("audit-log-syslog-tls", "false")
+
+	// AuditLogSyslogCACerts is a PEM bundle used to verify the certificate of the audit log syslog server.
+	AuditLogSyslogCACerts = NewSetting("audit-log-syslog-cacerts", "")
+
+	// AuditLogSyslogInsecureSkipVerify disables certificate verification for the audit log syslog server.
+	AuditLogSyslogInsecureSkipVerify = NewSetting("audit-log-syslog-insecure-skip-verify", "false")
+
+	// TokenHashAlgorithm is the algorithm used to hash new tokens when token hashing is enabled: sha3, argon2id or
+	// pbkdf2-sha512. Tokens hashed with a different algorithm are re-hashed the next time they are used. Tokens of a
+	// cluster are always hashed with sha3, as their hash is synced to the cluster for the authorized cluster endpoint.
+	TokenHashAlgorithm = NewSetting("token-hash-algorithm", "sha3")
+
+	// TokenIdleTimeoutDays is the number of days after which tokens which haven't been used are disabled or deleted,
+	// according to TokenIdleAction. 0 disables the timeout. System tokens and tokens of a cluster, which can be used
+	// through the authorized cluster endpoint without Rancher seeing it, are never idle.
+	TokenIdleTimeoutDays = NewSetting("token-idle-timeout-days", "0")
+
+	// TokenIdleAction is what happens to tokens idle for longer than TokenIdleTimeoutDays: disable or delete.
+	TokenIdleAction = NewSetting("token-idle-action", "disable")
+
+	// TrustedProxyCIDRs is a comma separated list of the CIDRs of the proxies and load balancers in front of Rancher.
+	// The address of the client of a request is taken from its X-Forwarded-For header only when the request comes from
+	// one of them, otherwise the header could be set by the client to any address.
+	TrustedProxyCIDRs = NewSetting("trusted-proxy-cidrs", "")
+
+	// AuthMFARequiredForAdmins requires users bound to the admin or restricted-admin global roles to log in to the
+	// local auth provider with a TOTP code. Admins who haven't enrolled a TOTP authenticator get a short-lived token
+	// at login which can only be used to enroll one, and then log in again with a code.
+	AuthMFARequiredForAdmins = NewSetting("auth-mfa-required-for-admins", "false")
+
+	// AuthLoginMaxUserAttempts is the number of consecutive failed password logins after which a username is locked
+	// out for AuthLoginLockoutDuration. 0, the default, disables the lockout of usernames, as anyone could otherwise
+	// lock out the admin by failing to log in with its username.
+	AuthLoginMaxUserAttempts = NewSetting("auth-login-max-user-attempts", "0")
+
+	// AuthLoginMaxIPAttempts is the number of consecutive failed password logins after which a source IP is locked out
+	// for AuthLoginLockoutDuration. 0 disables the lockout of IPs. IPs are only locked out when TrustedProxyCIDRs is
+	// set, as the address of clients behind the ingress is otherwise the address of the ingress. Successful logins
+	// don't reset the failures of an IP.
+	AuthLoginMaxIPAttempts = NewSetting("auth-login-max-ip-attempts", "100")
+
+	// AuthLoginLockoutDuration is how long the first lockout of a username or IP lasts. Each following lockout lasts
+	// twice as long as the previous one, up to AuthLoginMaxLockoutDuration.
+	AuthLoginLockoutDuration = NewSetting("auth-login-lockout-duration", "5m")
+
+	// AuthLoginMaxLockoutDuration is the longest a lockout lasts. Failed logins of a username or IP are forgotten once
+	// none happened for as long.
+	AuthLoginMaxLockoutDuration = NewSetting("auth-login-max-lockout-duration", "24h")
+
+	// PasswordRequiredCharacterClasses is a comma-separated list of the character classes which local user passwords
+	// must contain: lower, upper, digit and symbol.
+	PasswordRequiredCharacterClasses = NewSetting("password-required-character-classes", "")
+
+	// PasswordBlocklist is a newline or comma-separated list of passwords which local users can't use, in addition to a
+	// built-in list of common passwords. Passwords are compared case-insensitively.
+	PasswordBlocklist = NewSetting("password-blocklist", "")
+
+	// PasswordHistorySize is the number of previous passwords, including the current one, which local users can't
+	// reuse when changing their password. With 0, users can't change their password to the current one, but admins can
+	// set any password.
+	PasswordHistorySize = NewSetting("password-history-size", "0")
+
+	// PasswordMaxAgeDays is the number of days after which the password of local users expires, which requires them to
+	// change it when they next log in. 0 disables the expiration.
+	PasswordMaxAgeDays = NewSetting("password-max-age-days", "0")
+
+	// SCIMAuthProvider is the auth provider whose users and groups are provisioned by the SCIM endpoint at /v1-scim.
+	// SCIM provisioning is disabled when it is empty. The endpoint authenticates requests with the bearer token in
+	// the token key of the scim-token secret of the cattle-global-data namespace.
+	SCIMAuthProvider = NewSetting("scim-auth-provider", "")
+
 	// AuthTokenMaxTTLMinutes is the max allowable time to live for tokens. Excluding those created for UI sessions which is controlled by AuthUserSessionTTLMinutes.
 	AuthTokenMaxTTLMinutes = NewSetting("auth-token-max-ttl-minutes", "129600") // 90 days
 
@@ -276,6 +390,8 @@ func init() {
 	authsettings.AuthUserSessionTTLMinutes = AuthUserSessionTTLMinutes
 	authsettings.AuthUserInfoMaxAgeSeconds = AuthUserInfoMaxAgeSeconds
 	authsettings.FirstLogin = FirstLogin
+	authsettings.TokenHashAlgorithm = TokenHashAlgorithm
+	authsettings.AuthMFARequiredForAdmins = AuthMFARequiredForAdmins
 
 	if InjectDefaults == "" {
 		return
@@ -360,6 +476,21 @@ func (s Setting) GetInt() int {
 	return i
 }
 
+// EnabledAt returns the time the setting object was last enabled, from its EnabledAtAnnotation, and false if the
+// setting isn't annotated yet or the annotation isn't a valid time.
+func EnabledAt(obj *v32.Setting) (time.Time, bool) {
+	value, ok := obj.Annotations[EnabledAtAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	enabledAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logrus.Errorf("failed to parse annotation %s=%s of setting %s: %v", EnabledAtAnnotation, value, obj.Name, err)
+		return time.Time{}, false
+	}
+	return enabledAt, true
+}
+
 // SetProvider will set the given provider as the global provider for all settings.
 func SetProvider(p Provider) error {
 	if err := p.SetAll(settings); err != nil {
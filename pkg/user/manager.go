@@ -15,6 +15,8 @@ type TokenInput struct {
 	TTL           *int64
 	Randomize     bool
 	UserPrincipal v3.Principal
+	// Scope is the scope of the token, which must be the scope of the token it is created with, if any.
+	Scope *v3.TokenScope
 }
 
 type Manager interface {
@@ -371,13 +371,8 @@ func NewContext(ctx context.Context, clientConfig clientcmd.ClientConfig, restCo
 		k8s.Discovery(),
 		core.Core().V1().ConfigMap().Cache(),
 		core.Core().V1().Secret().Cache(),
-		helm.Catalog().V1().ClusterRepo().Cache())
-
-	helmop := helmop.NewOperations(cg,
-		helm.Catalog().V1(),
-		rbac.Rbac().V1(),
-		content,
-		core.Core().V1().Pod())
+		helm.Catalog().V1().ClusterRepo().Cache(),
+		helm.Catalog().V1().Repo().Cache())
 
 	cache := memory.NewMemCacheClient(k8s.Discovery())
 	restMapper := restmapper.NewDeferredDiscoveryRESTMapper(cache)
@@ -389,6 +384,13 @@ func NewContext(ctx context.Context, clientConfig clientcmd.ClientConfig, restCo
 	}
 	helmClient := helmcfg.NewClient(restClientGetter)
 
+	helmop := helmop.NewOperations(cg,
+		helm.Catalog().V1(),
+		rbac.Rbac().V1(),
+		content,
+		core.Core().V1().Pod(),
+		helmClient)
+
 	systemCharts, err := system.NewManager(ctx, content, helmop, core.Core().V1().Pod(),
 		mgmt.Management().V3().Setting(), ctlg.Catalog().V1().ClusterRepo(), helmClient)
 	if err != nil {